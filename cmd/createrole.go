@@ -53,7 +53,7 @@ func createRole(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatal("invalid config")
 	}
 
-	spiceClient, err := spicedbx.NewClient(cfg.SpiceDB, cfg.Tracing.Enabled)
+	spiceClient, spiceConn, err := spicedbx.NewClientConn(cfg.SpiceDB, cfg.Tracing.Enabled)
 	if err != nil {
 		logger.Fatalw("unable to initialize spicedb client", "error", err)
 	}
@@ -85,7 +85,13 @@ func createRole(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatalw("error parsing subject ID", "error", err)
 	}
 
-	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy), query.WithLogger(logger))
+	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy), query.WithLogger(logger), query.WithClientConn(spiceConn))
+
+	defer func() {
+		if err := engine.Close(); err != nil {
+			logger.Errorw("failed to close engine", "error", err)
+		}
+	}()
 
 	resource, err := engine.NewResourceFromID(resourceID)
 	if err != nil {
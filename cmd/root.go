@@ -13,6 +13,7 @@ import (
 	"go.uber.org/zap"
 
 	"go.infratographer.com/permissions-api/internal/config"
+	"go.infratographer.com/permissions-api/internal/spicedbx"
 )
 
 var (
@@ -53,10 +54,26 @@ func init() {
 	viperx.MustBindFlag(viper.GetViper(), "spicedb.insecure", rootCmd.PersistentFlags().Lookup("spicedb-insecure"))
 	rootCmd.PersistentFlags().Bool("spicedb-verifyca", false, "spicedb verify CA cert for secure connections")
 	viperx.MustBindFlag(viper.GetViper(), "spicedb.verifyca", rootCmd.PersistentFlags().Lookup("spicedb-verifyca"))
+	rootCmd.PersistentFlags().String("spicedb-ca-path", "", "spicedb CA bundle path for secure connections (default system trust store)")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.caPath", rootCmd.PersistentFlags().Lookup("spicedb-ca-path"))
+	rootCmd.PersistentFlags().String("spicedb-client-cert-path", "", "spicedb mTLS client certificate path")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.certPath", rootCmd.PersistentFlags().Lookup("spicedb-client-cert-path"))
+	rootCmd.PersistentFlags().String("spicedb-client-key-path", "", "spicedb mTLS client key path")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.keyPath", rootCmd.PersistentFlags().Lookup("spicedb-client-key-path"))
+	rootCmd.PersistentFlags().String("spicedb-server-name", "", "spicedb TLS server name override")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.serverName", rootCmd.PersistentFlags().Lookup("spicedb-server-name"))
 	rootCmd.PersistentFlags().String("spicedb-prefix", "", "spicedb prefix")
 	viperx.MustBindFlag(viper.GetViper(), "spicedb.prefix", rootCmd.PersistentFlags().Lookup("spicedb-prefix"))
 	rootCmd.PersistentFlags().String("spicedb-policy", "", "spicedb policy file")
 	viperx.MustBindFlag(viper.GetViper(), "spicedb.policyFile", rootCmd.PersistentFlags().Lookup("spicedb-policy"))
+	rootCmd.PersistentFlags().Duration("spicedb-keepalive-time", spicedbx.DefaultKeepaliveTime, "spicedb connection idle time before a keepalive ping")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.keepaliveTime", rootCmd.PersistentFlags().Lookup("spicedb-keepalive-time"))
+	rootCmd.PersistentFlags().Duration("spicedb-keepalive-timeout", spicedbx.DefaultKeepaliveTimeout, "spicedb keepalive ping acknowledgement timeout")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.keepaliveTimeout", rootCmd.PersistentFlags().Lookup("spicedb-keepalive-timeout"))
+	rootCmd.PersistentFlags().Duration("spicedb-max-connection-idle", 0, "spicedb connection idle time before it is closed (0 for unlimited)")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.maxConnectionIdle", rootCmd.PersistentFlags().Lookup("spicedb-max-connection-idle"))
+	rootCmd.PersistentFlags().String("spicedb-load-balancing-policy", spicedbx.DefaultLoadBalancingPolicy, "spicedb gRPC load balancing policy")
+	viperx.MustBindFlag(viper.GetViper(), "spicedb.loadBalancingPolicy", rootCmd.PersistentFlags().Lookup("spicedb-load-balancing-policy"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -5,12 +5,16 @@ import (
 	"fmt"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/authzed-go/v1"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.infratographer.com/x/otelx"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"go.infratographer.com/permissions-api/internal/config"
 	"go.infratographer.com/permissions-api/internal/iapl"
+	"go.infratographer.com/permissions-api/internal/query"
 	"go.infratographer.com/permissions-api/internal/spicedbx"
 )
 
@@ -23,13 +27,17 @@ var (
 		},
 	}
 
-	dryRun bool
+	dryRun         bool
+	diffSchema     bool
+	validateSchema bool
 )
 
 func init() {
 	rootCmd.AddCommand(schemaCmd)
 
 	schemaCmd.Flags().BoolVar(&dryRun, "dry-run", false, "dry run: print the schema instead of applying it")
+	schemaCmd.Flags().BoolVar(&validateSchema, "validate", false, "validate the schema against SpiceDB without applying it")
+	schemaCmd.Flags().BoolVar(&diffSchema, "diff", false, "print the differences between the live schema and the generated schema instead of applying it")
 
 	schemaCmd.Flags().Bool("mermaid", false, "outputs the policy as a mermaid chart definition")
 	schemaCmd.Flags().Bool("mermaid-markdown", false, "outputs the policy as a markdown mermaid chart definition")
@@ -64,7 +72,7 @@ func writeSchema(ctx context.Context, dryRun bool, cfg *config.AppConfig) {
 		logger.Fatalw("invalid spicedb policy", "error", err)
 	}
 
-	schemaStr, err := spicedbx.GenerateSchema("infratographer", policy.Schema())
+	schemaStr, err := spicedbx.GenerateSchema("infratographer", policy.Schema(), policy.Caveats()...)
 	if err != nil {
 		logger.Fatalw("failed to generate schema from policy", "error", err)
 	}
@@ -75,6 +83,10 @@ func writeSchema(ctx context.Context, dryRun bool, cfg *config.AppConfig) {
 		return
 	}
 
+	if err = spicedbx.CheckSchema(schemaStr); err != nil {
+		logger.Fatalw("generated schema is invalid", "error", err)
+	}
+
 	if dryRun {
 		fmt.Printf("%s", schemaStr)
 		return
@@ -85,17 +97,72 @@ func writeSchema(ctx context.Context, dryRun bool, cfg *config.AppConfig) {
 		logger.Fatalw("unable to initialize tracing system", "error", err)
 	}
 
-	client, err := spicedbx.NewClient(cfg.SpiceDB, cfg.Tracing.Enabled)
+	client, spiceConn, err := spicedbx.NewClientConn(cfg.SpiceDB, cfg.Tracing.Enabled)
 	if err != nil {
 		logger.Fatalw("unable to initialize spicedb client", "error", err)
 	}
 
+	if diffSchema {
+		printSchemaDiff(ctx, client, schemaStr)
+		return
+	}
+
+	if validateSchema {
+		if err = spicedbx.ValidateSchema(ctx, client, schemaStr); err != nil {
+			logger.Fatalw("schema failed validation", "error", err)
+		}
+
+		logger.Info("schema is valid")
+
+		return
+	}
+
 	logger.Debugw("Writing schema to DB", "schema", schemaStr)
 
-	_, err = client.WriteSchema(context.Background(), &v1.WriteSchemaRequest{Schema: schemaStr})
+	engine := query.NewEngine("infratographer", client, query.WithPolicy(policy), query.WithLogger(logger), query.WithClientConn(spiceConn))
+
+	defer func() {
+		if err := engine.Close(); err != nil {
+			logger.Errorw("failed to close engine", "error", err)
+		}
+	}()
+
+	_, err = engine.ApplySchema(ctx)
 	if err != nil {
 		logger.Fatalw("error writing schema to SpiceDB", "error", err)
 	}
 
 	logger.Info("schema applied to SpiceDB")
 }
+
+func printSchemaDiff(ctx context.Context, client *authzed.Client, desired string) {
+	var current string
+
+	resp, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+
+	switch {
+	case status.Code(err) == codes.NotFound:
+		logger.Warn("no schema found in SpiceDB, diffing against an empty schema")
+	case err != nil:
+		logger.Fatalw("error reading schema from SpiceDB", "error", err)
+	default:
+		// SpiceDB may hold the schemas of other namespaces sharing the cluster, which are not ours to diff.
+		current = spicedbx.NamespaceSchema(resp.SchemaText, "infratographer")
+	}
+
+	diff, err := spicedbx.DiffSchema(current, desired)
+	if err != nil {
+		logger.Fatalw("failed to diff schema", "error", err)
+	}
+
+	if diff.Empty() {
+		fmt.Println("no schema changes")
+		return
+	}
+
+	fmt.Println(diff.String())
+
+	if diff.Breaking() {
+		logger.Fatalw("schema changes are breaking", "breaking_changes", len(diff.BreakingChanges()))
+	}
+}
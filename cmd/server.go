@@ -46,7 +46,7 @@ func serve(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatalw("unable to initialize tracing system", "error", err)
 	}
 
-	spiceClient, err := spicedbx.NewClient(cfg.SpiceDB, cfg.Tracing.Enabled)
+	spiceClient, spiceConn, err := spicedbx.NewClientConn(cfg.SpiceDB, cfg.Tracing.Enabled)
 	if err != nil {
 		logger.Fatalw("unable to initialize spicedb client", "error", err)
 	}
@@ -68,7 +68,13 @@ func serve(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatalw("invalid spicedb policy", "error", err)
 	}
 
-	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy))
+	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy), query.WithClientConn(spiceConn))
+
+	defer func() {
+		if err := engine.Close(); err != nil {
+			logger.Errorw("failed to close engine", "error", err)
+		}
+	}()
 
 	srv, err := echox.NewServer(
 		logger.Desugar(),
@@ -85,7 +91,7 @@ func serve(ctx context.Context, cfg *config.AppConfig) {
 	}
 
 	srv.AddHandler(r)
-	srv.AddReadinessCheck("spicedb", spicedbx.Healthcheck(spiceClient))
+	srv.AddReadinessCheck("spicedb", engine.Healthcheck)
 
 	if err := srv.Run(); err != nil {
 		logger.Fatal("failed to run server", zap.Error(err))
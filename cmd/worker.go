@@ -47,7 +47,7 @@ func worker(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatalw("unable to initialize tracing system", "error", err)
 	}
 
-	spiceClient, err := spicedbx.NewClient(cfg.SpiceDB, cfg.Tracing.Enabled)
+	spiceClient, spiceConn, err := spicedbx.NewClientConn(cfg.SpiceDB, cfg.Tracing.Enabled)
 	if err != nil {
 		logger.Fatalw("unable to initialize spicedb client", "error", err)
 	}
@@ -69,7 +69,13 @@ func worker(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatalw("invalid spicedb policy", "error", err)
 	}
 
-	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy), query.WithLogger(logger))
+	engine := query.NewEngine("infratographer", spiceClient, query.WithPolicy(policy), query.WithLogger(logger), query.WithClientConn(spiceConn))
+
+	defer func() {
+		if err := engine.Close(); err != nil {
+			logger.Errorw("failed to close engine", "error", err)
+		}
+	}()
 
 	events, err := events.NewConnection(cfg.Events.Config, events.WithLogger(logger))
 	if err != nil {
@@ -105,7 +111,7 @@ func worker(ctx context.Context, cfg *config.AppConfig) {
 		logger.Fatal("failed to initialize new server", zap.Error(err))
 	}
 
-	srv.AddReadinessCheck("spicedb", spicedbx.Healthcheck(spiceClient))
+	srv.AddReadinessCheck("spicedb", engine.Healthcheck)
 
 	quit := make(chan os.Signal, 1)
 
@@ -51,7 +51,7 @@ func (r *Router) assignmentCreate(c echo.Context) error {
 
 	resource, err := r.engine.GetRoleResource(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting resource").SetInternal(err)
 	}
 
 	if err := r.checkActionWithResponse(ctx, subjectResource, actionRoleUpdate, resource); err != nil {
@@ -64,7 +64,7 @@ func (r *Router) assignmentCreate(c echo.Context) error {
 
 	_, err = r.engine.AssignSubjectRole(ctx, assigneeResource, role)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error creating resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error creating resource").SetInternal(err)
 	}
 
 	resp := createAssignmentResponse{
@@ -97,7 +97,7 @@ func (r *Router) assignmentsList(c echo.Context) error {
 
 	resource, err := r.engine.GetRoleResource(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting resource").SetInternal(err)
 	}
 
 	if err := r.checkActionWithResponse(ctx, subjectResource, actionRoleGet, resource); err != nil {
@@ -110,7 +110,7 @@ func (r *Router) assignmentsList(c echo.Context) error {
 
 	assignments, err := r.engine.ListAssignments(ctx, role, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error listing assignments").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error listing assignments").SetInternal(err)
 	}
 
 	items := make([]assignmentItem, len(assignments))
@@ -170,7 +170,7 @@ func (r *Router) assignmentDelete(c echo.Context) error {
 
 	resource, err := r.engine.GetRoleResource(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting resource").SetInternal(err)
 	}
 
 	if err := r.checkActionWithResponse(ctx, subjectResource, actionRoleUpdate, resource); err != nil {
@@ -183,7 +183,7 @@ func (r *Router) assignmentDelete(c echo.Context) error {
 
 	_, err = r.engine.UnassignSubjectRole(ctx, assigneeResource, role)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting assignment").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error deleting assignment").SetInternal(err)
 	}
 
 	resp := deleteAssignmentResponse{
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.infratographer.com/permissions-api/internal/query"
+	"go.infratographer.com/x/gidx"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// serverTimingHeader is the response header reporting how long the permission check took.
+const serverTimingHeader = "Server-Timing"
+
+// authorize decides whether a subject is allowed to perform an action on a resource, for
+// authorization middleware such as an ext_authz integration.
+// It will return a 200 if the subject is allowed to perform the action on the resource.
+// It will return a 403 if the subject is not allowed to perform the action on the resource.
+// Both return a body describing the decision, so middleware may forward the reason for a denial.
+// It will return a 400 if the action is not defined for the resource's type.
+//
+// The time taken by the permission check is returned in the Server-Timing header, as the "check" metric.
+//
+// Note that this expects a JWT token to be present in the request. This token must
+// contain the subject of the request in the "sub" claim.
+//
+// The following query parameters are required:
+// - resource: the resource ID to check
+// - action: the action to check
+func (r *Router) authorize(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "api.authorize")
+	defer span.End()
+
+	action, hasQuery := getParam(c, "action")
+	if !hasQuery || action == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing action query parameter")
+	}
+
+	resourceIDStr, hasResourceParam := getParam(c, "resource")
+	if !hasResourceParam {
+		return echo.NewHTTPError(http.StatusBadRequest, "missing resource query parameter")
+	}
+
+	resourceID, err := gidx.Parse(resourceIDStr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "error processing resource ID").SetInternal(err)
+	}
+
+	resource, err := r.engine.NewResourceFromID(resourceID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "error processing resource ID").SetInternal(err)
+	}
+
+	subjectResource, err := r.currentSubject(c)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	decision, err := r.engine.Authorize(ctx, subjectResource, action, resource)
+
+	c.Response().Header().Set(serverTimingHeader, serverTiming("check", time.Since(start)))
+
+	switch {
+	case errors.Is(err, query.ErrInvalidAction), errors.Is(err, query.ErrInvalidType):
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid action").SetInternal(err)
+	case err != nil:
+		span.SetStatus(codes.Error, err.Error())
+
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "an error occurred checking permissions").SetInternal(err)
+	}
+
+	resp := authorizeResponse{
+		Allowed:    decision.Allowed,
+		SubjectID:  subjectResource.ID.String(),
+		Action:     action,
+		ResourceID: resource.ID.String(),
+		Reason:     string(decision.Reason),
+		Message:    decision.Explanation,
+	}
+
+	if !decision.Allowed {
+		return c.JSON(http.StatusForbidden, resp)
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+// serverTiming returns a Server-Timing header value reporting the duration in milliseconds.
+func serverTiming(metric string, d time.Duration) string {
+	return fmt.Sprintf("%s;dur=%.3f", metric, float64(d.Microseconds())/1000)
+}
@@ -94,7 +94,7 @@ func (r *Router) checkActionWithResponse(ctx context.Context, subjectResource ty
 
 		return echo.NewHTTPError(http.StatusForbidden, msg).SetInternal(err)
 	case err != nil:
-		return echo.NewHTTPError(http.StatusInternalServerError, "an error occurred checking permissions").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "an error occurred checking permissions").SetInternal(err)
 	default:
 		return nil
 	}
@@ -259,7 +259,7 @@ func (r *Router) checkAllActions(c echo.Context) error {
 		combined := multierr.Combine(allErrors...)
 		span.SetStatus(codes.Error, combined.Error())
 
-		return echo.NewHTTPError(http.StatusInternalServerError, "an error occurred checking permissions").SetInternal(combined)
+		return echo.NewHTTPError(errorStatus(combined, http.StatusInternalServerError), "an error occurred checking permissions").SetInternal(combined)
 	}
 
 	if unauthorizedErrors != 0 {
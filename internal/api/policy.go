@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// policyGet returns the resource types, relationships and actions of the policy the engine was created with.
+func (r *Router) policyGet(c echo.Context) error {
+	_, span := tracer.Start(c.Request().Context(), "api.policyGet")
+	defer span.End()
+
+	resourceTypes := r.engine.ResourceTypes()
+
+	out := policyResponse{
+		ResourceTypes: make([]policyResourceType, len(resourceTypes)),
+	}
+
+	for i, resourceType := range resourceTypes {
+		item := policyResourceType{
+			Name:          resourceType.Name,
+			IDPrefix:      resourceType.IDPrefix,
+			Relationships: make([]policyRelationship, len(resourceType.Relationships)),
+			Actions:       make([]string, len(resourceType.Actions)),
+		}
+
+		for j, rel := range resourceType.Relationships {
+			item.Relationships[j] = policyRelationship{
+				Relation: rel.Relation,
+				Types:    rel.Types,
+			}
+		}
+
+		for j, action := range resourceType.Actions {
+			item.Actions[j] = action.Name
+		}
+
+		out.ResourceTypes[i] = item
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
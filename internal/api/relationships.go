@@ -27,15 +27,24 @@ func (r *Router) relationshipListFrom(c echo.Context) error {
 
 	rels, err := r.engine.ListRelationshipsFrom(ctx, resource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error listing relationships").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error listing relationships").SetInternal(err)
 	}
 
 	items := make([]relationshipItem, len(rels))
 
 	for i, rel := range rels {
 		items[i] = relationshipItem{
-			Relation:  rel.Relation,
-			SubjectID: rel.Subject.ID.String(),
+			Relation:        rel.Relation,
+			SubjectID:       rel.Subject.ID.String(),
+			SubjectRelation: rel.SubjectRelation,
+			Metadata:        rel.Metadata,
+		}
+
+		if rel.Caveat != nil {
+			items[i].Caveat = &relationshipCaveat{
+				Name:    rel.Caveat.Name,
+				Context: rel.Caveat.Context,
+			}
 		}
 	}
 
@@ -64,7 +73,7 @@ func (r *Router) relationshipListTo(c echo.Context) error {
 
 	rels, err := r.engine.ListRelationshipsTo(ctx, resource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error listing relationships").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error listing relationships").SetInternal(err)
 	}
 
 	items := make([]relationshipItem, len(rels))
@@ -2,6 +2,9 @@ package api
 
 import (
 	"errors"
+	"net/http"
+
+	"go.infratographer.com/permissions-api/internal/query"
 )
 
 // ErrorResponse represents the data that the server will return on any given call
@@ -20,3 +23,17 @@ var (
 	// ErrResourceAlreadyExists is returned when the resource already exists
 	ErrResourceAlreadyExists = errors.New("resource already exists")
 )
+
+// errorStatus returns the HTTP status code for an error returned by the engine. Errors reaching
+// SpiceDB are reported as unavailable or timed out so clients know they may retry, any other
+// error uses the given fallback status code.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, query.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, query.ErrDeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return fallback
+	}
+}
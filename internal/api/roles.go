@@ -1,9 +1,11 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"go.infratographer.com/permissions-api/internal/query"
 	"go.infratographer.com/x/gidx"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -49,14 +51,31 @@ func (r *Router) roleCreate(c echo.Context) error {
 		return err
 	}
 
-	role, _, err := r.engine.CreateRole(ctx, resource, reqBody.Actions)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error creating resource").SetInternal(err)
+	var roleOpts []query.RoleOption
+
+	if reqBody.Name != "" {
+		roleOpts = append(roleOpts, query.WithRoleName(reqBody.Name))
+	}
+
+	if reqBody.Description != "" {
+		roleOpts = append(roleOpts, query.WithRoleDescription(reqBody.Description))
+	}
+
+	role, _, err := r.engine.CreateRole(ctx, resource, reqBody.Actions, roleOpts...)
+
+	switch {
+	case errors.Is(err, query.ErrInvalidRoleName), errors.Is(err, query.ErrInvalidRoleDescription), errors.Is(err, query.ErrInvalidAction),
+		errors.Is(err, query.ErrInvalidActionName), errors.Is(err, query.ErrInvalidRoleOwner):
+		return echo.NewHTTPError(http.StatusBadRequest, "error creating resource").SetInternal(err)
+	case err != nil:
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error creating resource").SetInternal(err)
 	}
 
 	resp := roleResponse{
-		ID:      role.ID,
-		Actions: role.Actions,
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Actions:     role.Actions,
 	}
 
 	return c.JSON(http.StatusCreated, resp)
@@ -98,12 +117,14 @@ func (r *Router) roleGet(c echo.Context) error {
 
 	role, err := r.engine.GetRole(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting resource").SetInternal(err)
 	}
 
 	resp := roleResponse{
-		ID:      role.ID,
-		Actions: role.Actions,
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Actions:     role.Actions,
 	}
 
 	return c.JSON(http.StatusOK, resp)
@@ -136,7 +157,7 @@ func (r *Router) rolesList(c echo.Context) error {
 
 	roles, err := r.engine.ListRoles(ctx, resource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting role").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting role").SetInternal(err)
 	}
 
 	resp := listRolesResponse{
@@ -145,8 +166,10 @@ func (r *Router) rolesList(c echo.Context) error {
 
 	for _, role := range roles {
 		roleResp := roleResponse{
-			ID:      role.ID,
-			Actions: role.Actions,
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Actions:     role.Actions,
 		}
 
 		resp.Data = append(resp.Data, roleResp)
@@ -189,7 +212,7 @@ func (r *Router) roleDelete(c echo.Context) error {
 
 	_, err = r.engine.DeleteRole(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error deleting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error deleting resource").SetInternal(err)
 	}
 
 	resp := deleteRoleResponse{
@@ -224,7 +247,7 @@ func (r *Router) roleGetResource(c echo.Context) error {
 	// do the permissions check.
 	resource, err := r.engine.GetRoleResource(ctx, roleResource, "")
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "error getting resource").SetInternal(err)
+		return echo.NewHTTPError(errorStatus(err, http.StatusInternalServerError), "error getting resource").SetInternal(err)
 	}
 
 	if err := r.checkActionWithResponse(ctx, subjectResource, actionRoleGet, resource); err != nil {
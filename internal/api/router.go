@@ -65,10 +65,14 @@ func (r *Router) Routes(rg *echo.Group) {
 		v1.POST("/roles/:role_id/assignments", r.assignmentCreate)
 		v1.DELETE("/roles/:role_id/assignments", r.assignmentDelete)
 		v1.GET("/roles/:role_id/assignments", r.assignmentsList)
+		v1.GET("/policy", r.policyGet)
 
 		// /allow is the permissions check endpoint
 		v1.GET("/allow", r.checkAction)
 		v1.POST("/allow", r.checkAllActions)
+
+		// /authorize is the permissions check endpoint for authorization middleware
+		v1.GET("/authorize", r.authorize)
 	}
 }
 
@@ -5,12 +5,16 @@ import (
 )
 
 type createRoleRequest struct {
-	Actions []string `json:"actions" binding:"required"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Actions     []string `json:"actions" binding:"required"`
 }
 
 type roleResponse struct {
-	ID      gidx.PrefixedID `json:"id"`
-	Actions []string        `json:"actions"`
+	ID          gidx.PrefixedID `json:"id"`
+	Name        string          `json:"name,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Actions     []string        `json:"actions"`
 }
 
 type resourceResponse struct {
@@ -26,9 +30,17 @@ type listRolesResponse struct {
 }
 
 type relationshipItem struct {
-	ResourceID string `json:"resource_id,omitempty"`
-	Relation   string `json:"relation"`
-	SubjectID  string `json:"subject_id,omitempty"`
+	ResourceID      string              `json:"resource_id,omitempty"`
+	Relation        string              `json:"relation"`
+	SubjectID       string              `json:"subject_id,omitempty"`
+	SubjectRelation string              `json:"subject_relation,omitempty"`
+	Metadata        map[string]string   `json:"metadata,omitempty"`
+	Caveat          *relationshipCaveat `json:"caveat,omitempty"`
+}
+
+type relationshipCaveat struct {
+	Name    string         `json:"name"`
+	Context map[string]any `json:"context,omitempty"`
 }
 
 type listRelationshipsResponse struct {
@@ -58,3 +70,28 @@ type assignmentItem struct {
 type listAssignmentsResponse struct {
 	Data []assignmentItem `json:"data"`
 }
+
+type policyRelationship struct {
+	Relation string   `json:"relation"`
+	Types    []string `json:"types"`
+}
+
+type policyResourceType struct {
+	Name          string               `json:"name"`
+	IDPrefix      string               `json:"id_prefix"`
+	Relationships []policyRelationship `json:"relationships"`
+	Actions       []string             `json:"actions"`
+}
+
+type policyResponse struct {
+	ResourceTypes []policyResourceType `json:"resource_types"`
+}
+
+type authorizeResponse struct {
+	Allowed    bool   `json:"allowed"`
+	SubjectID  string `json:"subject_id"`
+	Action     string `json:"action"`
+	ResourceID string `json:"resource_id"`
+	Reason     string `json:"reason,omitempty"`
+	Message    string `json:"message"`
+}
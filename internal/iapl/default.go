@@ -12,6 +12,14 @@ func DefaultPolicyDocument() PolicyDocument {
 						Relation: "subject",
 						TargetTypeNames: []string{
 							"subject",
+							"role#subject",
+						},
+						Wildcard: true,
+					},
+					{
+						Relation: "owner",
+						TargetTypeNames: []string{
+							"resourceowner",
 						},
 					},
 				},
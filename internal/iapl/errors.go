@@ -5,6 +5,8 @@ import "errors"
 var (
 	// ErrorTypeExists represents an error where a duplicate type or union was declared.
 	ErrorTypeExists = errors.New("type already exists")
+	// ErrorIDPrefixExists represents an error where two resource types declared the same ID prefix.
+	ErrorIDPrefixExists = errors.New("id prefix already used")
 	// ErrorUnknownType represents an error where a resource type is unknown in the authorization policy.
 	ErrorUnknownType = errors.New("unknown resource type")
 	// ErrorInvalidCondition represents an error where an action binding condition is invalid.
@@ -13,4 +15,24 @@ var (
 	ErrorUnknownRelation = errors.New("unknown relation")
 	// ErrorUnknownAction represents an error where an action is not defined.
 	ErrorUnknownAction = errors.New("unknown action")
+	// ErrorUnknownCaveat represents an error where a caveat is not defined.
+	ErrorUnknownCaveat = errors.New("unknown caveat")
+	// ErrorCaveatExists represents an error where a duplicate caveat was declared.
+	ErrorCaveatExists = errors.New("caveat already exists")
+	// ErrorInvalidCaveat represents an error where a caveat definition is invalid.
+	ErrorInvalidCaveat = errors.New("invalid caveat")
+	// ErrorActionExists represents an error where a duplicate action was declared.
+	ErrorActionExists = errors.New("action already exists")
+	// ErrorConflictingDefinition represents an error where merged policy documents define the same element differently.
+	ErrorConflictingDefinition = errors.New("conflicting definition")
+	// ErrorInvalidActionName represents an error where an action name is not a valid permission name.
+	ErrorInvalidActionName = errors.New("invalid action name")
+	// ErrorInvalidRoleOwner represents an error where a role owner type cannot have roles bound to it.
+	ErrorInvalidRoleOwner = errors.New("invalid role owner")
+	// ErrorInvalidDefaultRole represents an error where a default role cannot be created.
+	ErrorInvalidDefaultRole = errors.New("invalid default role")
+	// ErrorInvalidCompositeAction represents an error where a composite action is used where it is not allowed.
+	ErrorInvalidCompositeAction = errors.New("invalid composite action")
+	// ErrorActionCollision represents an error where an action bound to a resource type would share its SpiceDB name with a relation.
+	ErrorActionCollision = errors.New("action collides with relation")
 )
@@ -0,0 +1,161 @@
+package iapl
+
+import (
+	"fmt"
+)
+
+// MergePolicyDocuments combines the given policy documents into a single document, so a core policy can
+// be extended by fragments defining their own resource types. Resource types and unions declared in
+// multiple documents are merged, with the target types of a relationship declared more than once unioned.
+// Action bindings and default roles are concatenated. A resource type declared with different ID prefixes,
+// an ID prefix used by different resource types, an action declared more than once, or conflicting
+// relationship or caveat definitions return an error. The merged document is validated before it is returned.
+func MergePolicyDocuments(docs ...PolicyDocument) (PolicyDocument, error) {
+	var (
+		out PolicyDocument
+
+		typeIndex   = make(map[string]int)
+		prefixTypes = make(map[string]string)
+		unionIndex  = make(map[string]int)
+		actions     = make(map[string]struct{})
+		caveatIndex = make(map[string]int)
+	)
+
+	for _, doc := range docs {
+		for _, rt := range doc.ResourceTypes {
+			if owner, ok := prefixTypes[rt.IDPrefix]; ok && owner != rt.Name {
+				return PolicyDocument{}, fmt.Errorf("resourceTypes: %s: idPrefix %s already used by %s: %w", rt.Name, rt.IDPrefix, owner, ErrorConflictingDefinition)
+			}
+
+			i, ok := typeIndex[rt.Name]
+			if !ok {
+				typeIndex[rt.Name] = len(out.ResourceTypes)
+				prefixTypes[rt.IDPrefix] = rt.Name
+
+				rt.Relationships = append([]Relationship(nil), rt.Relationships...)
+				out.ResourceTypes = append(out.ResourceTypes, rt)
+
+				continue
+			}
+
+			existing := &out.ResourceTypes[i]
+
+			if existing.IDPrefix != rt.IDPrefix {
+				return PolicyDocument{}, fmt.Errorf("resourceTypes: %s: idPrefix %s does not match %s: %w", rt.Name, rt.IDPrefix, existing.IDPrefix, ErrorConflictingDefinition)
+			}
+
+			for _, rel := range rt.Relationships {
+				if err := mergeRelationship(existing, rel); err != nil {
+					return PolicyDocument{}, fmt.Errorf("resourceTypes: %s: relationships: %w", rt.Name, err)
+				}
+			}
+		}
+
+		for _, union := range doc.Unions {
+			i, ok := unionIndex[union.Name]
+			if !ok {
+				unionIndex[union.Name] = len(out.Unions)
+
+				union.ResourceTypeNames = append([]string(nil), union.ResourceTypeNames...)
+				out.Unions = append(out.Unions, union)
+
+				continue
+			}
+
+			out.Unions[i].ResourceTypeNames = appendMissing(out.Unions[i].ResourceTypeNames, union.ResourceTypeNames...)
+		}
+
+		for _, action := range doc.Actions {
+			if _, ok := actions[action.Name]; ok {
+				return PolicyDocument{}, fmt.Errorf("actions: %s: %w", action.Name, ErrorActionExists)
+			}
+
+			actions[action.Name] = struct{}{}
+
+			out.Actions = append(out.Actions, action)
+		}
+
+		for _, caveat := range doc.Caveats {
+			i, ok := caveatIndex[caveat.Name]
+			if !ok {
+				caveatIndex[caveat.Name] = len(out.Caveats)
+				out.Caveats = append(out.Caveats, caveat)
+
+				continue
+			}
+
+			if !caveatsEqual(out.Caveats[i], caveat) {
+				return PolicyDocument{}, fmt.Errorf("caveats: %s: %w", caveat.Name, ErrorConflictingDefinition)
+			}
+		}
+
+		out.ActionBindings = append(out.ActionBindings, doc.ActionBindings...)
+		out.DefaultRoles = append(out.DefaultRoles, doc.DefaultRoles...)
+	}
+
+	if err := NewPolicy(out).Validate(); err != nil {
+		return PolicyDocument{}, err
+	}
+
+	return out, nil
+}
+
+// mergeRelationship adds the relationship to the resource type, unioning its target types with an
+// existing relationship of the same name.
+func mergeRelationship(rt *ResourceType, rel Relationship) error {
+	for i := range rt.Relationships {
+		existing := &rt.Relationships[i]
+
+		if existing.Relation != rel.Relation {
+			continue
+		}
+
+		if existing.Caveat != rel.Caveat || existing.Wildcard != rel.Wildcard {
+			return fmt.Errorf("%s: caveat or wildcard does not match: %w", rel.Relation, ErrorConflictingDefinition)
+		}
+
+		existing.TargetTypeNames = appendMissing(existing.TargetTypeNames, rel.TargetTypeNames...)
+
+		return nil
+	}
+
+	rel.TargetTypeNames = append([]string(nil), rel.TargetTypeNames...)
+	rt.Relationships = append(rt.Relationships, rel)
+
+	return nil
+}
+
+// appendMissing appends the values which are not already in the slice.
+func appendMissing(values []string, add ...string) []string {
+	for _, value := range add {
+		found := false
+
+		for _, existing := range values {
+			if existing == value {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+func caveatsEqual(a, b Caveat) bool {
+	if a.Name != b.Name || a.Expression != b.Expression || len(a.Parameters) != len(b.Parameters) {
+		return false
+	}
+
+	for i := range a.Parameters {
+		if a.Parameters[i] != b.Parameters[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,194 @@
+package iapl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"go.infratographer.com/permissions-api/internal/testingx"
+)
+
+func TestMergePolicyDocuments(t *testing.T) {
+	core := PolicyDocument{
+		ResourceTypes: []ResourceType{
+			{
+				Name:     "user",
+				IDPrefix: "idntusr",
+			},
+			{
+				Name:     "tenant",
+				IDPrefix: "tnntten",
+				Relationships: []Relationship{
+					{
+						Relation:        "member",
+						TargetTypeNames: []string{"user"},
+					},
+				},
+			},
+		},
+		Actions: []Action{
+			{
+				Name: "tenant_get",
+			},
+		},
+		ActionBindings: []ActionBinding{
+			{
+				ActionName: "tenant_get",
+				TypeName:   "tenant",
+				Conditions: []Condition{
+					{
+						RoleBinding: &ConditionRoleBinding{},
+					},
+				},
+			},
+		},
+	}
+
+	fragment := PolicyDocument{
+		ResourceTypes: []ResourceType{
+			{
+				Name:     "client",
+				IDPrefix: "idntcli",
+			},
+			{
+				Name:     "tenant",
+				IDPrefix: "tnntten",
+				Relationships: []Relationship{
+					{
+						Relation:        "member",
+						TargetTypeNames: []string{"client"},
+					},
+				},
+			},
+		},
+		Actions: []Action{
+			{
+				Name: "tenant_update",
+			},
+		},
+		ActionBindings: []ActionBinding{
+			{
+				ActionName: "tenant_update",
+				TypeName:   "tenant",
+				Conditions: []Condition{
+					{
+						RoleBinding: &ConditionRoleBinding{},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []testingx.TestCase[[]PolicyDocument, PolicyDocument]{
+		{
+			Name:  "Success",
+			Input: []PolicyDocument{core, fragment},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.NoError(t, res.Err)
+
+				require.Len(t, res.Success.ResourceTypes, 3)
+				require.Equal(t, "tenant", res.Success.ResourceTypes[1].Name)
+				require.Equal(t, []string{"user", "client"}, res.Success.ResourceTypes[1].Relationships[0].TargetTypeNames)
+				require.Len(t, res.Success.Actions, 2)
+				require.Len(t, res.Success.ActionBindings, 2)
+
+				// The inputs are not modified.
+				require.Equal(t, []string{"user"}, core.ResourceTypes[1].Relationships[0].TargetTypeNames)
+			},
+		},
+		{
+			Name: "IDPrefixMismatch",
+			Input: []PolicyDocument{
+				core,
+				{
+					ResourceTypes: []ResourceType{
+						{
+							Name:     "tenant",
+							IDPrefix: "tnntnew",
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorConflictingDefinition)
+			},
+		},
+		{
+			Name: "IDPrefixReused",
+			Input: []PolicyDocument{
+				core,
+				{
+					ResourceTypes: []ResourceType{
+						{
+							Name:     "organization",
+							IDPrefix: "tnntten",
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorConflictingDefinition)
+			},
+		},
+		{
+			Name:  "DuplicateAction",
+			Input: []PolicyDocument{core, {Actions: []Action{{Name: "tenant_get"}}}},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorActionExists)
+			},
+		},
+		{
+			Name: "RelationshipConflict",
+			Input: []PolicyDocument{
+				core,
+				{
+					ResourceTypes: []ResourceType{
+						{
+							Name:     "tenant",
+							IDPrefix: "tnntten",
+							Relationships: []Relationship{
+								{
+									Relation:        "member",
+									TargetTypeNames: []string{"user"},
+									Wildcard:        true,
+								},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorConflictingDefinition)
+			},
+		},
+		{
+			Name: "InvalidResult",
+			Input: []PolicyDocument{
+				core,
+				{
+					ActionBindings: []ActionBinding{
+						{
+							ActionName: "tenant_delete",
+							TypeName:   "tenant",
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownAction)
+			},
+		},
+	}
+
+	testFn := func(_ context.Context, docs []PolicyDocument) testingx.TestResult[PolicyDocument] {
+		doc, err := MergePolicyDocuments(docs...)
+
+		return testingx.TestResult[PolicyDocument]{
+			Success: doc,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(context.Background(), t, cases, testFn)
+}
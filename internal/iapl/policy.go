@@ -3,6 +3,9 @@ package iapl
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 
 	"go.infratographer.com/permissions-api/internal/types"
 	"gopkg.in/yaml.v3"
@@ -14,6 +17,8 @@ type PolicyDocument struct {
 	Unions         []Union
 	Actions        []Action
 	ActionBindings []ActionBinding
+	Caveats        []Caveat
+	DefaultRoles   []DefaultRole
 }
 
 // ResourceType represents a resource type in the authorization policy.
@@ -24,9 +29,50 @@ type ResourceType struct {
 }
 
 // Relationship represents a named relation between two resources.
+// A target type name may name a subject set in the form "type#relation", allowing the relation to target
+// all subjects of that relation on a resource, such as the subjects of another role.
+// If Caveat is set, relationships may optionally be conditioned on the named caveat. Relationship metadata
+// is written as the caveat's context, so the caveat's expression is evaluated with it on every check; a
+// caveat declared only to carry metadata should have an expression which is always true, such as "true".
+// If Wildcard is set, relationships may target all resources of a target type at once. Wildcards do not
+// apply to subject sets.
 type Relationship struct {
 	Relation        string
 	TargetTypeNames []string
+	Caveat          string
+	Wildcard        bool
+}
+
+// ActionNameRules describes the names actions may have. Actions become SpiceDB permissions, along
+// with a relation named for the action with a "_rel" suffix, so names must be valid identifiers in
+// the SpiceDB schema language once suffixed.
+const ActionNameRules = "3 to 60 characters of lowercase letters, digits and underscores, starting with a letter and not ending with an underscore"
+
+var actionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,58}[a-z0-9]$`)
+
+// ValidActionName reports whether the name follows ActionNameRules.
+func ValidActionName(name string) bool {
+	return actionNamePattern.MatchString(name)
+}
+
+// RoleOwnerRelation is the relation on the role type naming the types which may own roles.
+// A policy without it allows roles to be owned by any type with an action granted by a role binding.
+const RoleOwnerRelation = "owner"
+
+// roleMetadataRelation is the relation the generated schema adds to the role type to hold role metadata.
+const roleMetadataRelation = "metadata"
+
+// Caveat represents a named condition which is evaluated with context provided at check time.
+type Caveat struct {
+	Name       string
+	Parameters []CaveatParameter
+	Expression string
+}
+
+// CaveatParameter represents a typed parameter of a caveat.
+type CaveatParameter struct {
+	Name string
+	Type string
 }
 
 // Union represents a named union of multiple concrete resource types.
@@ -36,15 +82,22 @@ type Union struct {
 }
 
 // Action represents an action that can be taken in an authorization policy.
+// An action which includes other actions is a composite action, such as a "loadbalancer_manage" action
+// including the load balancer get, update and delete actions. Composite actions are not bound to
+// resource types and do not become permissions; a role given a composite action is given each of the
+// actions it includes instead. The included actions must be defined and may not be composite actions.
 type Action struct {
-	Name string
+	Name     string
+	Includes []string
 }
 
 // ActionBinding represents a binding of an action to a resource type or union.
+// Subjects of any of the ExcludedRelations may not perform the action, even if a condition allows them to.
 type ActionBinding struct {
-	ActionName string
-	TypeName   string
-	Conditions []Condition
+	ActionName        string
+	TypeName          string
+	Conditions        []Condition
+	ExcludedRelations []string
 }
 
 // Condition represents a necessary condition for performing an action.
@@ -54,7 +107,10 @@ type Condition struct {
 }
 
 // ConditionRoleBinding represents a condition where a role binding is necessary to perform an action.
-type ConditionRoleBinding struct{}
+// If Caveat is set, role bindings may optionally be conditioned on the named caveat.
+type ConditionRoleBinding struct {
+	Caveat string
+}
 
 // ConditionRelationshipAction represents a condition where another action must be allowed on a resource
 // along a relation to perform an action.
@@ -63,18 +119,35 @@ type ConditionRelationshipAction struct {
 	ActionName string
 }
 
+// DefaultRole represents a role created on every new tenant, so each tenant starts with the same
+// standard roles. Every action must be granted by a role binding on a common resource type.
+type DefaultRole struct {
+	Name        string
+	Description string
+	Actions     []string
+}
+
 // Policy represents an authorization policy as defined by IAPL.
 type Policy interface {
 	Validate() error
 	Schema() []types.ResourceType
+	Caveats() []types.Caveat
+	ResourceTypeByIDPrefix(prefix string) (ResourceType, bool)
+	ResourceTypeByName(name string) (ResourceType, bool)
+	AllActions() []string
+	RelationsForAction(action string) ([]string, error)
+	CompositeActions() map[string][]string
+	DefaultRoles() []DefaultRole
 }
 
 var _ Policy = &policy{}
 
 type policy struct {
 	rt map[string]ResourceType
+	px map[string]string
 	un map[string]Union
 	ac map[string]Action
+	cv map[string]Caveat
 	rb map[string]map[string]struct{}
 	bn []ActionBinding
 	p  PolicyDocument
@@ -83,8 +156,11 @@ type policy struct {
 // NewPolicy creates a policy from the given policy document.
 func NewPolicy(p PolicyDocument) Policy {
 	rt := make(map[string]ResourceType, len(p.ResourceTypes))
+	px := make(map[string]string, len(p.ResourceTypes))
+
 	for _, r := range p.ResourceTypes {
 		rt[r.Name] = r
+		px[r.IDPrefix] = r.Name
 	}
 
 	un := make(map[string]Union, len(p.Unions))
@@ -97,10 +173,17 @@ func NewPolicy(p PolicyDocument) Policy {
 		ac[a.Name] = a
 	}
 
+	cv := make(map[string]Caveat, len(p.Caveats))
+	for _, c := range p.Caveats {
+		cv[c.Name] = c
+	}
+
 	out := policy{
 		rt: rt,
+		px: px,
 		un: un,
 		ac: ac,
+		cv: cv,
 		p:  p,
 	}
 
@@ -112,18 +195,48 @@ func NewPolicy(p PolicyDocument) Policy {
 
 // NewPolicyFromFile reads the provided file path and returns a new Policy.
 func NewPolicyFromFile(filePath string) (Policy, error) {
-	file, err := os.Open(filePath)
+	policy, err := readPolicyDocument(filePath, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var policy PolicyDocument
+	return NewPolicy(policy), nil
+}
 
-	if err := yaml.NewDecoder(file).Decode(&policy); err != nil {
-		return nil, err
+// LoadPolicyDocument reads a policy document from the YAML file at the provided path and validates it.
+// Unlike NewPolicyFromFile, fields which are not part of a PolicyDocument are rejected, so a misspelled
+// key is reported with its line rather than silently ignored. Errors are prefixed with the path.
+func LoadPolicyDocument(filePath string) (PolicyDocument, error) {
+	doc, err := readPolicyDocument(filePath, true)
+	if err != nil {
+		return PolicyDocument{}, err
 	}
 
-	return NewPolicy(policy), nil
+	if err := NewPolicy(doc).Validate(); err != nil {
+		return PolicyDocument{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	return doc, nil
+}
+
+func readPolicyDocument(filePath string, strict bool) (PolicyDocument, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return PolicyDocument{}, err
+	}
+
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(strict)
+
+	var doc PolicyDocument
+
+	if err := decoder.Decode(&doc); err != nil {
+		return PolicyDocument{}, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	return doc, nil
 }
 
 func (v *policy) validateUnions() error {
@@ -142,13 +255,85 @@ func (v *policy) validateUnions() error {
 	return nil
 }
 
+func (v *policy) validateCaveats() error {
+	seen := make(map[string]struct{}, len(v.p.Caveats))
+
+	for _, caveat := range v.p.Caveats {
+		if caveat.Name == "" {
+			return fmt.Errorf("name: %w", ErrorInvalidCaveat)
+		}
+
+		if _, ok := seen[caveat.Name]; ok {
+			return fmt.Errorf("%s: %w", caveat.Name, ErrorCaveatExists)
+		}
+
+		seen[caveat.Name] = struct{}{}
+
+		if caveat.Expression == "" {
+			return fmt.Errorf("%s: expression: %w", caveat.Name, ErrorInvalidCaveat)
+		}
+
+		for _, param := range caveat.Parameters {
+			if param.Name == "" {
+				return fmt.Errorf("%s: parameters: %w", caveat.Name, ErrorInvalidCaveat)
+			}
+
+			if !validCaveatParameterType(param.Type) {
+				return fmt.Errorf("%s: parameters: %s: %s: %w", caveat.Name, param.Name, param.Type, ErrorInvalidCaveat)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validCaveatParameterType reports whether the given type is a SpiceDB caveat parameter type.
+func validCaveatParameterType(typeName string) bool {
+	for _, generic := range []string{"list", "map"} {
+		if strings.HasPrefix(typeName, generic+"<") && strings.HasSuffix(typeName, ">") {
+			return validCaveatParameterType(typeName[len(generic)+1 : len(typeName)-1])
+		}
+	}
+
+	switch typeName {
+	case "any", "bool", "bytes", "double", "duration", "int", "ipaddress", "string", "timestamp", "uint":
+		return true
+	default:
+		return false
+	}
+}
+
 func (v *policy) validateResourceTypes() error {
+	prefixTypes := make(map[string]string, len(v.p.ResourceTypes))
+
 	for _, resourceType := range v.p.ResourceTypes {
+		// Types without a prefix cannot be resolved from an ID, so they cannot conflict.
+		if resourceType.IDPrefix != "" {
+			if owner, ok := prefixTypes[resourceType.IDPrefix]; ok && owner != resourceType.Name {
+				return fmt.Errorf("%s: idPrefix %s already used by %s: %w", resourceType.Name, resourceType.IDPrefix, owner, ErrorIDPrefixExists)
+			}
+
+			prefixTypes[resourceType.IDPrefix] = resourceType.Name
+		}
+
 		for _, rel := range resourceType.Relationships {
 			for _, name := range rel.TargetTypeNames {
-				if _, ok := v.rt[name]; !ok {
+				typeName, relation, isSubjectSet := strings.Cut(name, "#")
+
+				target, ok := v.rt[typeName]
+				if !ok {
 					return fmt.Errorf("%s: relationships: %s: %w", resourceType.Name, name, ErrorUnknownType)
 				}
+
+				if isSubjectSet && !resourceTypeHasRelation(target, relation) {
+					return fmt.Errorf("%s: relationships: %s: %w", resourceType.Name, name, ErrorUnknownRelation)
+				}
+			}
+
+			if rel.Caveat != "" {
+				if _, ok := v.cv[rel.Caveat]; !ok {
+					return fmt.Errorf("%s: relationships: %s: %s: %w", resourceType.Name, rel.Relation, rel.Caveat, ErrorUnknownCaveat)
+				}
 			}
 		}
 	}
@@ -156,6 +341,16 @@ func (v *policy) validateResourceTypes() error {
 	return nil
 }
 
+func resourceTypeHasRelation(rt ResourceType, relation string) bool {
+	for _, rel := range rt.Relationships {
+		if rel.Relation == relation {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (v *policy) validateConditionRelationshipAction(rt ResourceType, c ConditionRelationshipAction) error {
 	var (
 		rel   Relationship
@@ -199,6 +394,12 @@ func (v *policy) validateConditions(rt ResourceType, conds []Condition) error {
 			return fmt.Errorf("%d: %w", i, ErrorInvalidCondition)
 		}
 
+		if cond.RoleBinding != nil && cond.RoleBinding.Caveat != "" {
+			if _, ok := v.cv[cond.RoleBinding.Caveat]; !ok {
+				return fmt.Errorf("%d: %s: %w", i, cond.RoleBinding.Caveat, ErrorUnknownCaveat)
+			}
+		}
+
 		if cond.RelationshipAction != nil {
 			if err := v.validateConditionRelationshipAction(rt, *cond.RelationshipAction); err != nil {
 				return fmt.Errorf("%d: %w", i, err)
@@ -209,12 +410,38 @@ func (v *policy) validateConditions(rt ResourceType, conds []Condition) error {
 	return nil
 }
 
+func (v *policy) validateActions() error {
+	for _, action := range v.p.Actions {
+		if !ValidActionName(action.Name) {
+			return fmt.Errorf("%q: %w: must be %s", action.Name, ErrorInvalidActionName, ActionNameRules)
+		}
+
+		for _, name := range action.Includes {
+			included, ok := v.ac[name]
+			if !ok {
+				return fmt.Errorf("%s: includes: %s: %w", action.Name, name, ErrorUnknownAction)
+			}
+
+			if len(included.Includes) != 0 {
+				return fmt.Errorf("%s: includes: %s: %w: composite actions may not include composite actions", action.Name, name, ErrorInvalidCompositeAction)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (v *policy) validateActionBindings() error {
 	for i, binding := range v.bn {
-		if _, ok := v.ac[binding.ActionName]; !ok {
+		action, ok := v.ac[binding.ActionName]
+		if !ok {
 			return fmt.Errorf("%d: %s: %w", i, binding.ActionName, ErrorUnknownAction)
 		}
 
+		if len(action.Includes) != 0 {
+			return fmt.Errorf("%d: %s: %w: composite actions may not be bound", i, binding.ActionName, ErrorInvalidCompositeAction)
+		}
+
 		rt, ok := v.rt[binding.TypeName]
 		if !ok {
 			return fmt.Errorf("%d: %s: %w", i, binding.TypeName, ErrorUnknownType)
@@ -223,19 +450,200 @@ func (v *policy) validateActionBindings() error {
 		if err := v.validateConditions(rt, binding.Conditions); err != nil {
 			return fmt.Errorf("%d: conditions: %w", i, err)
 		}
+
+		for _, relation := range binding.ExcludedRelations {
+			if !resourceTypeHasRelation(rt, relation) {
+				return fmt.Errorf("%d: excludedRelations: %s: %w", i, relation, ErrorUnknownRelation)
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateActionCollisions checks no action bound to a resource type shares its name with a relation in the
+// type's SpiceDB definition, where relations and permissions share a single set of names. Each bound action
+// becomes a permission of its own name and a relation with a "_rel" suffix, so an action collides with a
+// relation of the type such as "parent", either by name or by its "_rel" relation, or with the relation of
+// another action bound to the type, such as "get_rel" with the relation of "get". Actions of the same name
+// bound to different types do not collide, as each definition has its own names.
+func (v *policy) validateActionCollisions() error {
+	for _, rt := range v.p.ResourceTypes {
+		bound := make([]string, 0, len(v.rb[rt.Name]))
+
+		for action := range v.rb[rt.Name] {
+			bound = append(bound, action)
+		}
+
+		sort.Strings(bound)
+
+		relations := make(map[string]string, len(rt.Relationships)+len(bound))
+
+		for _, rel := range rt.Relationships {
+			relations[rel.Relation] = "relation " + rel.Relation
+		}
+
+		if rt.Name == "role" {
+			relations[roleMetadataRelation] = "relation " + roleMetadataRelation
+		}
+
+		for _, action := range bound {
+			if existing, ok := relations[action+"_rel"]; ok {
+				return fmt.Errorf("%s: %s: %w: relation %s_rel is also %s", rt.Name, action, ErrorActionCollision, action, existing)
+			}
+
+			relations[action+"_rel"] = "the relation of action " + action
+		}
+
+		for _, action := range bound {
+			if existing, ok := relations[action]; ok {
+				return fmt.Errorf("%s: %s: %w: permission %s is also %s", rt.Name, action, ErrorActionCollision, action, existing)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateRoleOwners checks the types the role type's owner relation targets, if it has one, are
+// types roles can be bound to, which are those with an action granted by a role binding.
+func (v *policy) validateRoleOwners() error {
+	roleType, ok := v.rt[types.RoleResourceType]
+	if !ok {
+		return nil
+	}
+
+	for _, rel := range roleType.Relationships {
+		if rel.Relation != RoleOwnerRelation {
+			continue
+		}
+
+		for _, name := range rel.TargetTypeNames {
+			if strings.Contains(name, "#") || !v.hasRoleBinding(name) {
+				return fmt.Errorf("%s: %w", name, ErrorInvalidRoleOwner)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasRoleBinding reports whether an action on the given resource type is granted by a role binding.
+func (v *policy) hasRoleBinding(typeName string) bool {
+	for _, binding := range v.bn {
+		if binding.TypeName != typeName {
+			continue
+		}
+
+		for _, cond := range binding.Conditions {
+			if cond.RoleBinding != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// validateDefaultRoles checks each default role has a unique name and known actions, all granted by a
+// role binding on at least one common resource type so the role can be created.
+func (v *policy) validateDefaultRoles() error {
+	names := make(map[string]struct{}, len(v.p.DefaultRoles))
+
+	for i, role := range v.p.DefaultRoles {
+		if role.Name == "" {
+			return fmt.Errorf("%d: %w: name is required", i, ErrorInvalidDefaultRole)
+		}
+
+		if _, ok := names[role.Name]; ok {
+			return fmt.Errorf("%s: %w: name declared more than once", role.Name, ErrorInvalidDefaultRole)
+		}
+
+		names[role.Name] = struct{}{}
+
+		if len(role.Actions) == 0 {
+			return fmt.Errorf("%s: %w: no actions", role.Name, ErrorInvalidDefaultRole)
+		}
+
+		for _, action := range role.Actions {
+			if _, ok := v.ac[action]; !ok {
+				return fmt.Errorf("%s: %s: %w", role.Name, action, ErrorUnknownAction)
+			}
+		}
+
+		if len(v.roleBindingTypes(v.expandActions(role.Actions))) == 0 {
+			return fmt.Errorf("%s: %w: no resource type grants all of its actions by role binding", role.Name, ErrorInvalidDefaultRole)
+		}
+	}
+
+	return nil
+}
+
+// expandActions replaces each composite action with the actions it includes.
+func (v *policy) expandActions(actions []string) []string {
+	out := make([]string, 0, len(actions))
+
+	for _, name := range actions {
+		if included := v.ac[name].Includes; len(included) != 0 {
+			out = appendMissing(out, included...)
+		} else {
+			out = appendMissing(out, name)
+		}
+	}
+
+	return out
+}
+
+// roleBindingTypes returns the sorted names of the resource types on which every one of the actions is
+// granted by a role binding.
+func (v *policy) roleBindingTypes(actions []string) []string {
+	counts := make(map[string]int)
+
+	for _, action := range actions {
+		seen := make(map[string]struct{})
+
+		for _, binding := range v.bn {
+			if binding.ActionName != action {
+				continue
+			}
+
+			if _, ok := seen[binding.TypeName]; ok {
+				continue
+			}
+
+			for _, cond := range binding.Conditions {
+				if cond.RoleBinding != nil {
+					seen[binding.TypeName] = struct{}{}
+					counts[binding.TypeName]++
+
+					break
+				}
+			}
+		}
+	}
+
+	var out []string
+
+	for typeName, count := range counts {
+		if count == len(actions) {
+			out = append(out, typeName)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
 func (v *policy) expandActionBindings() {
 	for _, bn := range v.p.ActionBindings {
 		if u, ok := v.un[bn.TypeName]; ok {
 			for _, typeName := range u.ResourceTypeNames {
 				binding := ActionBinding{
-					TypeName:   typeName,
-					ActionName: bn.ActionName,
-					Conditions: bn.Conditions,
+					TypeName:          typeName,
+					ActionName:        bn.ActionName,
+					Conditions:        bn.Conditions,
+					ExcludedRelations: bn.ExcludedRelations,
 				}
 				v.bn = append(v.bn, binding)
 			}
@@ -277,6 +685,10 @@ func (v *policy) expandResourceTypes() {
 }
 
 func (v *policy) Validate() error {
+	if err := v.validateCaveats(); err != nil {
+		return fmt.Errorf("caveats: %w", err)
+	}
+
 	if err := v.validateUnions(); err != nil {
 		return fmt.Errorf("unions: %w", err)
 	}
@@ -285,13 +697,145 @@ func (v *policy) Validate() error {
 		return fmt.Errorf("resourceTypes: %w", err)
 	}
 
+	if err := v.validateActions(); err != nil {
+		return fmt.Errorf("actions: %w", err)
+	}
+
 	if err := v.validateActionBindings(); err != nil {
 		return fmt.Errorf("actionBindings: %w", err)
 	}
 
+	if err := v.validateActionCollisions(); err != nil {
+		return fmt.Errorf("actionBindings: %w", err)
+	}
+
+	if err := v.validateRoleOwners(); err != nil {
+		return fmt.Errorf("roleOwners: %w", err)
+	}
+
+	if err := v.validateDefaultRoles(); err != nil {
+		return fmt.Errorf("defaultRoles: %w", err)
+	}
+
 	return nil
 }
 
+// ResourceTypeByIDPrefix returns the resource type whose IDs use the given gidx prefix.
+// Unions in the returned type's relationships are expanded to their resource types.
+func (v *policy) ResourceTypeByIDPrefix(prefix string) (ResourceType, bool) {
+	name, ok := v.px[prefix]
+	if !ok {
+		return ResourceType{}, false
+	}
+
+	return v.ResourceTypeByName(name)
+}
+
+// ResourceTypeByName returns the resource type with the given name.
+// Unions in the returned type's relationships are expanded to their resource types.
+func (v *policy) ResourceTypeByName(name string) (ResourceType, bool) {
+	rt, ok := v.rt[name]
+
+	return rt, ok
+}
+
+// AllActions returns the names of the actions the policy defines, sorted and without duplicates.
+func (v *policy) AllActions() []string {
+	out := make([]string, 0, len(v.ac))
+
+	for name := range v.ac {
+		out = append(out, name)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+// RelationsForAction returns the relations the permissions generated for the action are composed of,
+// across every resource type the action is bound to, sorted and without duplicates. These are the
+// relations of the action's relationship action conditions and excluded relations, along with the
+// relation named for the action with a "_rel" suffix when the action is granted by a role binding.
+// The relations of a composite action are those of the actions it includes. An action which is not
+// defined returns ErrorUnknownAction.
+func (v *policy) RelationsForAction(action string) ([]string, error) {
+	ac, ok := v.ac[action]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", action, ErrorUnknownAction)
+	}
+
+	actions := []string{action}
+	if len(ac.Includes) != 0 {
+		actions = ac.Includes
+	}
+
+	relations := make(map[string]struct{})
+
+	for _, binding := range v.bn {
+		if !containsAction(actions, binding.ActionName) {
+			continue
+		}
+
+		for _, cond := range binding.Conditions {
+			switch {
+			case cond.RoleBinding != nil:
+				relations[binding.ActionName+"_rel"] = struct{}{}
+			case cond.RelationshipAction != nil:
+				relations[cond.RelationshipAction.Relation] = struct{}{}
+			}
+		}
+
+		for _, relation := range binding.ExcludedRelations {
+			relations[relation] = struct{}{}
+		}
+	}
+
+	out := make([]string, 0, len(relations))
+
+	for relation := range relations {
+		out = append(out, relation)
+	}
+
+	sort.Strings(out)
+
+	return out, nil
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, name := range actions {
+		if name == action {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CompositeActions returns the actions each composite action includes, by composite action name.
+func (v *policy) CompositeActions() map[string][]string {
+	out := make(map[string][]string)
+
+	for name, action := range v.ac {
+		if len(action.Includes) != 0 {
+			out[name] = append([]string(nil), action.Includes...)
+		}
+	}
+
+	return out
+}
+
+// DefaultRoles returns the roles the policy declares should be created on every new tenant.
+func (v *policy) DefaultRoles() []DefaultRole {
+	out := make([]DefaultRole, len(v.p.DefaultRoles))
+
+	for i, role := range v.p.DefaultRoles {
+		role.Actions = append([]string(nil), role.Actions...)
+		out[i] = role
+	}
+
+	return out
+}
+
 func (v *policy) Schema() []types.ResourceType {
 	typeMap := map[string]*types.ResourceType{}
 
@@ -305,6 +849,8 @@ func (v *policy) Schema() []types.ResourceType {
 			outRel := types.ResourceTypeRelationship{
 				Relation: rel.Relation,
 				Types:    rel.TargetTypeNames,
+				Caveat:   rel.Caveat,
+				Wildcard: rel.Wildcard,
 			}
 
 			out.Relationships = append(out.Relationships, outRel)
@@ -315,7 +861,8 @@ func (v *policy) Schema() []types.ResourceType {
 
 	for _, b := range v.bn {
 		action := types.Action{
-			Name: b.ActionName,
+			Name:              b.ActionName,
+			ExcludedRelations: b.ExcludedRelations,
 		}
 
 		for _, c := range b.Conditions {
@@ -337,3 +884,22 @@ func (v *policy) Schema() []types.ResourceType {
 
 	return out
 }
+
+func (v *policy) Caveats() []types.Caveat {
+	out := make([]types.Caveat, len(v.p.Caveats))
+
+	for i, c := range v.p.Caveats {
+		caveat := types.Caveat{
+			Name:       c.Name,
+			Expression: c.Expression,
+		}
+
+		for _, param := range c.Parameters {
+			caveat.Parameters = append(caveat.Parameters, types.CaveatParameter(param))
+		}
+
+		out[i] = caveat
+	}
+
+	return out
+}
@@ -2,8 +2,12 @@ package iapl
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.infratographer.com/permissions-api/internal/testingx"
 )
@@ -31,6 +35,24 @@ func TestPolicy(t *testing.T) {
 				require.ErrorIs(t, res.Err, ErrorTypeExists)
 			},
 		},
+		{
+			Name: "IDPrefixExists",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name:     "foo",
+						IDPrefix: "testfoo",
+					},
+					{
+						Name:     "bar",
+						IDPrefix: "testfoo",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorIDPrefixExists)
+			},
+		},
 		{
 			Name: "UnknownTypeInUnion",
 			Input: PolicyDocument{
@@ -94,6 +116,48 @@ func TestPolicy(t *testing.T) {
 				require.ErrorIs(t, res.Err, ErrorUnknownType)
 			},
 		},
+		{
+			Name: "UnknownRelationInSubjectSet",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+						Relationships: []Relationship{
+							{
+								Relation: "bar",
+								TargetTypeNames: []string{
+									"foo#baz",
+								},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownRelation)
+			},
+		},
+		{
+			Name: "SubjectSetSuccess",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+						Relationships: []Relationship{
+							{
+								Relation: "bar",
+								TargetTypeNames: []string{
+									"foo#bar",
+								},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.NoError(t, res.Err)
+			},
+		},
 		{
 			Name: "UnknownActionInCondition",
 			Input: PolicyDocument{
@@ -310,6 +374,135 @@ func TestPolicy(t *testing.T) {
 				require.ErrorIs(t, res.Err, ErrorUnknownAction)
 			},
 		},
+		{
+			Name: "UnknownCaveatInRelationship",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+						Relationships: []Relationship{
+							{
+								Relation: "bar",
+								TargetTypeNames: []string{
+									"foo",
+								},
+								Caveat: "baz",
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownCaveat)
+			},
+		},
+		{
+			Name: "UnknownCaveatInRoleBinding",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{
+									Caveat: "baz",
+								},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownCaveat)
+			},
+		},
+		{
+			Name: "DuplicateCaveat",
+			Input: PolicyDocument{
+				Caveats: []Caveat{
+					{
+						Name:       "baz",
+						Expression: "true",
+					},
+					{
+						Name:       "baz",
+						Expression: "true",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorCaveatExists)
+			},
+		},
+		{
+			Name: "InvalidCaveatParameterType",
+			Input: PolicyDocument{
+				Caveats: []Caveat{
+					{
+						Name: "baz",
+						Parameters: []CaveatParameter{
+							{
+								Name: "allowed",
+								Type: "list<strings>",
+							},
+						},
+						Expression: "true",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidCaveat)
+			},
+		},
+		{
+			Name: "CaveatSuccess",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+						Relationships: []Relationship{
+							{
+								Relation: "bar",
+								TargetTypeNames: []string{
+									"foo",
+								},
+								Caveat: "baz",
+							},
+						},
+					},
+				},
+				Caveats: []Caveat{
+					{
+						Name: "baz",
+						Parameters: []CaveatParameter{
+							{
+								Name: "allowed",
+								Type: "list<string>",
+							},
+							{
+								Name: "name",
+								Type: "string",
+							},
+						},
+						Expression: "name in allowed",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.NoError(t, res.Err)
+			},
+		},
 		{
 			Name: "Success",
 			Input: PolicyDocument{
@@ -370,17 +563,796 @@ func TestPolicy(t *testing.T) {
 				require.NoError(t, res.Err)
 			},
 		},
-	}
-
-	testFn := func(_ context.Context, p PolicyDocument) testingx.TestResult[struct{}] {
-		policy := NewPolicy(p)
-		err := policy.Validate()
-
-		return testingx.TestResult[struct{}]{
-			Success: struct{}{},
-			Err:     err,
-		}
-	}
-
-	testingx.RunTests(context.Background(), t, cases, testFn)
+		{
+			Name: "InvalidActionName",
+			Input: PolicyDocument{
+				Actions: []Action{
+					{
+						Name: "loadbalancer-get",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidActionName)
+			},
+		},
+		{
+			Name: "UnknownExcludedRelation",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+						ExcludedRelations: []string{
+							"banned",
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownRelation)
+			},
+		},
+		{
+			Name: "DefaultRoleUnknownAction",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				DefaultRoles: []DefaultRole{
+					{
+						Name:    "viewer",
+						Actions: []string{"qux"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownAction)
+			},
+		},
+		{
+			Name: "DefaultRoleNotBindable",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+					{
+						Name: "quux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+					{
+						TypeName:   "bar",
+						ActionName: "quux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+				DefaultRoles: []DefaultRole{
+					{
+						Name:    "viewer",
+						Actions: []string{"qux", "quux"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidDefaultRole)
+			},
+		},
+		{
+			Name: "DefaultRoleDuplicateName",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+				DefaultRoles: []DefaultRole{
+					{
+						Name:    "viewer",
+						Actions: []string{"qux"},
+					},
+					{
+						Name:    "viewer",
+						Actions: []string{"qux"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidDefaultRole)
+			},
+		},
+		{
+			Name: "CompositeActionUnknownAction",
+			Input: PolicyDocument{
+				Actions: []Action{
+					{
+						Name:     "qux_manage",
+						Includes: []string{"qux"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownAction)
+			},
+		},
+		{
+			Name: "CompositeActionIncludesComposite",
+			Input: PolicyDocument{
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+					{
+						Name:     "qux_manage",
+						Includes: []string{"qux"},
+					},
+					{
+						Name:     "qux_admin",
+						Includes: []string{"qux_manage"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidCompositeAction)
+			},
+		},
+		{
+			Name: "CompositeActionBound",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+					{
+						Name:     "qux_manage",
+						Includes: []string{"qux"},
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux_manage",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidCompositeAction)
+			},
+		},
+		{
+			Name: "ActionCollidesWithRelation",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+						Relationships: []Relationship{
+							{
+								Relation:        "parent",
+								TargetTypeNames: []string{"foo"},
+							},
+						},
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "parent",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "parent",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorActionCollision)
+				assert.ErrorContains(t, res.Err, "foo: parent")
+			},
+		},
+		{
+			Name: "ActionCollidesWithActionRelation",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+					{
+						Name: "qux_rel",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "bar",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+					{
+						TypeName:   "foo",
+						ActionName: "qux_rel",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorActionCollision)
+				assert.ErrorContains(t, res.Err, "foo: qux_rel: action collides with relation: permission qux_rel is also the relation of action qux")
+			},
+		},
+		{
+			Name: "SameActionOnTypesSuccess",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+					{
+						Name: "bar",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+					{
+						TypeName:   "bar",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.NoError(t, res.Err)
+			},
+		},
+		{
+			Name: "CompositeActionSuccess",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+					{
+						Name: "quux",
+					},
+					{
+						Name:     "qux_manage",
+						Includes: []string{"qux", "quux"},
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+					{
+						TypeName:   "foo",
+						ActionName: "quux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+				DefaultRoles: []DefaultRole{
+					{
+						Name:    "manager",
+						Actions: []string{"qux_manage"},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.NoError(t, res.Err)
+			},
+		},
+		{
+			Name: "InvalidRoleOwner",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "role",
+						Relationships: []Relationship{
+							{
+								Relation: "owner",
+								TargetTypeNames: []string{
+									"foo",
+								},
+							},
+						},
+					},
+					{
+						Name: "foo",
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.ErrorIs(t, res.Err, ErrorInvalidRoleOwner)
+			},
+		},
+		{
+			Name: "RoleOwnerSuccess",
+			Input: PolicyDocument{
+				ResourceTypes: []ResourceType{
+					{
+						Name: "role",
+						Relationships: []Relationship{
+							{
+								Relation: "owner",
+								TargetTypeNames: []string{
+									"foo",
+								},
+							},
+						},
+					},
+					{
+						Name: "foo",
+					},
+				},
+				Actions: []Action{
+					{
+						Name: "qux",
+					},
+				},
+				ActionBindings: []ActionBinding{
+					{
+						TypeName:   "foo",
+						ActionName: "qux",
+						Conditions: []Condition{
+							{
+								RoleBinding: &ConditionRoleBinding{},
+							},
+						},
+					},
+				},
+			},
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[struct{}]) {
+				require.NoError(t, res.Err)
+			},
+		},
+	}
+
+	testFn := func(_ context.Context, p PolicyDocument) testingx.TestResult[struct{}] {
+		policy := NewPolicy(p)
+		err := policy.Validate()
+
+		return testingx.TestResult[struct{}]{
+			Success: struct{}{},
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(context.Background(), t, cases, testFn)
+}
+
+func TestLoadPolicyDocument(t *testing.T) {
+	dir := t.TempDir()
+
+	writePolicy := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+		return path
+	}
+
+	cases := []testingx.TestCase[string, PolicyDocument]{
+		{
+			Name: "Valid",
+			Input: writePolicy("valid.yaml", `
+resourcetypes:
+  - name: user
+    idprefix: idntusr
+  - name: tenant
+    idprefix: tnntten
+    relationships:
+      - relation: member
+        targettypenames:
+          - user
+actions:
+  - name: tenant_get
+actionbindings:
+  - actionname: tenant_get
+    typename: tenant
+    conditions:
+      - rolebinding: {}
+`),
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.NoError(t, res.Err)
+				require.Len(t, res.Success.ResourceTypes, 2)
+				require.Equal(t, "tnntten", res.Success.ResourceTypes[1].IDPrefix)
+			},
+		},
+		{
+			Name:  "NotFound",
+			Input: filepath.Join(dir, "missing.yaml"),
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, os.ErrNotExist)
+			},
+		},
+		{
+			Name: "UnknownField",
+			Input: writePolicy("unknownfield.yaml", `
+resourcetypes:
+  - name: user
+    idprefx: idntusr
+`),
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorContains(t, res.Err, "idprefx")
+			},
+		},
+		{
+			Name: "UnknownTargetType",
+			Input: writePolicy("unknowntype.yaml", `
+resourcetypes:
+  - name: tenant
+    idprefix: tnntten
+    relationships:
+      - relation: member
+        targettypenames:
+          - user
+`),
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[PolicyDocument]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownType)
+				require.ErrorContains(t, res.Err, "tenant: relationships: user")
+			},
+		},
+	}
+
+	testFn := func(_ context.Context, path string) testingx.TestResult[PolicyDocument] {
+		doc, err := LoadPolicyDocument(path)
+
+		return testingx.TestResult[PolicyDocument]{
+			Success: doc,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(context.Background(), t, cases, testFn)
+}
+
+func TestValidActionName(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"qux",
+		"loadbalancer_get",
+		"a1_b2",
+		"a" + strings.Repeat("b", 59),
+	}
+
+	invalid := []string{
+		"",
+		"ab",
+		"Loadbalancer_get",
+		"loadbalancer-get",
+		"loadbalancer get",
+		"1loadbalancer",
+		"_loadbalancer",
+		"loadbalancer_",
+		"loadbalancer.get",
+		"a" + strings.Repeat("b", 60),
+	}
+
+	for _, name := range valid {
+		assert.True(t, ValidActionName(name), name)
+	}
+
+	for _, name := range invalid {
+		assert.False(t, ValidActionName(name), name)
+	}
+}
+
+func TestResourceTypeLookup(t *testing.T) {
+	policy := DefaultPolicy()
+
+	tenant, ok := policy.ResourceTypeByIDPrefix("tnntten")
+	require.True(t, ok)
+	require.Equal(t, "tenant", tenant.Name)
+
+	byName, ok := policy.ResourceTypeByName("tenant")
+	require.True(t, ok)
+	require.Equal(t, tenant, byName)
+
+	_, ok = policy.ResourceTypeByIDPrefix("missing")
+	require.False(t, ok)
+
+	_, ok = policy.ResourceTypeByName("missing")
+	require.False(t, ok)
+}
+
+func TestAllActions(t *testing.T) {
+	expected := []string{
+		"loadbalancer_create",
+		"loadbalancer_delete",
+		"loadbalancer_get",
+		"loadbalancer_list",
+		"loadbalancer_update",
+	}
+
+	require.Equal(t, expected, DefaultPolicy().AllActions())
+
+	policy := NewPolicy(PolicyDocument{
+		Actions: []Action{
+			{
+				Name: "qux",
+			},
+			{
+				Name: "baz",
+			},
+			{
+				Name: "qux",
+			},
+		},
+	})
+
+	require.Equal(t, []string{"baz", "qux"}, policy.AllActions())
+
+	require.Empty(t, NewPolicy(PolicyDocument{}).AllActions())
+}
+
+func TestDefaultRoles(t *testing.T) {
+	doc := DefaultPolicyDocument()
+	doc.DefaultRoles = []DefaultRole{
+		{
+			Name:    "viewer",
+			Actions: []string{"loadbalancer_get", "loadbalancer_list"},
+		},
+		{
+			Name:        "admin",
+			Description: "manages load balancers",
+			Actions:     []string{"loadbalancer_create", "loadbalancer_delete", "loadbalancer_get", "loadbalancer_list", "loadbalancer_update"},
+		},
+	}
+
+	policy := NewPolicy(doc)
+	require.NoError(t, policy.Validate())
+
+	roles := policy.DefaultRoles()
+	require.Equal(t, doc.DefaultRoles, roles)
+
+	// The returned roles do not share the policy's action slices.
+	roles[0].Actions[0] = "loadbalancer_delete"
+	assert.Equal(t, "loadbalancer_get", policy.DefaultRoles()[0].Actions[0])
+
+	assert.Empty(t, DefaultPolicy().DefaultRoles())
+}
+
+func TestCompositeActions(t *testing.T) {
+	doc := DefaultPolicyDocument()
+	doc.Actions = append(doc.Actions, Action{
+		Name:     "loadbalancer_manage",
+		Includes: []string{"loadbalancer_get", "loadbalancer_update", "loadbalancer_delete"},
+	})
+
+	policy := NewPolicy(doc)
+	require.NoError(t, policy.Validate())
+
+	composites := policy.CompositeActions()
+	require.Equal(t, map[string][]string{
+		"loadbalancer_manage": {"loadbalancer_get", "loadbalancer_update", "loadbalancer_delete"},
+	}, composites)
+
+	// The returned actions do not share the policy's slices.
+	composites["loadbalancer_manage"][0] = "loadbalancer_list"
+	assert.Equal(t, "loadbalancer_get", policy.CompositeActions()["loadbalancer_manage"][0])
+
+	assert.Empty(t, DefaultPolicy().CompositeActions())
+}
+
+func TestRelationsForAction(t *testing.T) {
+	doc := DefaultPolicyDocument()
+	doc.Actions = append(doc.Actions, Action{
+		Name:     "loadbalancer_manage",
+		Includes: []string{"loadbalancer_get", "loadbalancer_update"},
+	})
+
+	policy := NewPolicy(doc)
+	require.NoError(t, policy.Validate())
+
+	testCases := []testingx.TestCase[string, []string]{
+		{
+			Name:  "RoleBindingAndRelationships",
+			Input: "loadbalancer_update",
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[[]string]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []string{"loadbalancer_update_rel", "owner", "parent"}, res.Success)
+			},
+		},
+		{
+			Name:  "Composite",
+			Input: "loadbalancer_manage",
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[[]string]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []string{"loadbalancer_get_rel", "loadbalancer_update_rel", "owner", "parent"}, res.Success)
+			},
+		},
+		{
+			Name:  "UnknownAction",
+			Input: "loadbalancer_explode",
+			CheckFn: func(_ context.Context, t *testing.T, res testingx.TestResult[[]string]) {
+				require.ErrorIs(t, res.Err, ErrorUnknownAction)
+			},
+		},
+	}
+
+	testFn := func(_ context.Context, action string) testingx.TestResult[[]string] {
+		relations, err := policy.RelationsForAction(action)
+
+		return testingx.TestResult[[]string]{
+			Success: relations,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(context.Background(), t, testCases, testFn)
+}
+
+func TestRelationsForActionExcluded(t *testing.T) {
+	policy := NewPolicy(PolicyDocument{
+		ResourceTypes: []ResourceType{
+			{
+				Name: "user",
+			},
+			{
+				Name: "document",
+				Relationships: []Relationship{
+					{
+						Relation:        "banned",
+						TargetTypeNames: []string{"user"},
+					},
+				},
+			},
+		},
+		Actions: []Action{
+			{
+				Name: "document_get",
+			},
+			{
+				Name: "document_delete",
+			},
+		},
+		ActionBindings: []ActionBinding{
+			{
+				TypeName:   "document",
+				ActionName: "document_get",
+				Conditions: []Condition{
+					{
+						RoleBinding: &ConditionRoleBinding{},
+					},
+				},
+				ExcludedRelations: []string{"banned"},
+			},
+		},
+	})
+	require.NoError(t, policy.Validate())
+
+	relations, err := policy.RelationsForAction("document_get")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"banned", "document_get_rel"}, relations)
+
+	// An action which is not bound is composed of no relations.
+	relations, err = policy.RelationsForAction("document_delete")
+	require.NoError(t, err)
+	assert.Empty(t, relations)
 }
@@ -0,0 +1,153 @@
+package query
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// checkCacheKey identifies a cached permission check. Results are only reused for checks made
+// with the same consistency mode and query token.
+type checkCacheKey struct {
+	subject  types.Resource
+	action   string
+	resource types.Resource
+	mode     ConsistencyMode
+	token    string
+}
+
+type checkCacheEntry struct {
+	key     checkCacheKey
+	allowed bool
+	expires time.Time
+}
+
+// checkCache is a size bounded LRU cache of permission check results with a TTL.
+type checkCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[checkCacheKey]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func newCheckCache(size int, ttl time.Duration) *checkCache {
+	return &checkCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[checkCacheKey]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the cached result for the key, if present and not expired.
+func (c *checkCache) get(key checkCacheKey) (allowed bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if ok {
+		entry := elem.Value.(*checkCacheEntry)
+
+		if c.now().Before(entry.expires) {
+			c.order.MoveToFront(elem)
+
+			return entry.allowed, true
+		}
+
+		c.remove(elem)
+	}
+
+	return false, false
+}
+
+// set stores the result for the key, evicting the least recently used entry if the cache is full.
+func (c *checkCache) set(key checkCacheKey, allowed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*checkCacheEntry)
+		entry.allowed = allowed
+		entry.expires = expires
+
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[key] = c.order.PushFront(&checkCacheEntry{
+		key:     key,
+		allowed: allowed,
+		expires: expires,
+	})
+}
+
+func (c *checkCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*checkCacheEntry).key)
+}
+
+// WithCheckCache caches up to size permission check results for ttl. Results are keyed by the
+// subject, action, resource and query token of the check, so only checks which are not fully
+// consistent are cached: those made with a query token or with ConsistencyMinimizeLatency.
+// Hits and misses are recorded as the permissions_check_cache_hits_total and
+// permissions_check_cache_misses_total metrics, see WithMetrics. The cache is disabled if size or ttl
+// is not positive.
+func WithCheckCache(size int, ttl time.Duration) Option {
+	return func(e *engine) {
+		if size <= 0 || ttl <= 0 {
+			e.checkCache = nil
+
+			return
+		}
+
+		e.checkCache = newCheckCache(size, ttl)
+	}
+}
+
+// checkCacheKeyFor returns the cache key for the check, or false if the check must not be cached
+// because it requires the most recent data.
+func (e *engine) checkCacheKeyFor(ctx context.Context, subject types.Resource, action string, resource types.Resource) (checkCacheKey, bool) {
+	if e.checkCache == nil {
+		return checkCacheKey{}, false
+	}
+
+	key := checkCacheKey{
+		subject:  subject,
+		action:   action,
+		resource: resource,
+		mode:     e.consistencyModeFor(ctx),
+	}
+
+	switch key.mode {
+	case ConsistencyMinimizeLatency:
+		return key, true
+	case ConsistencyFullyConsistent:
+		return checkCacheKey{}, false
+	default:
+		key.token = queryTokenFor(ctx, "")
+
+		if key.token == "" && key.mode == ConsistencyAtLeastAsFresh {
+			token, ordered := e.lastWriteToken()
+			if !ordered {
+				return checkCacheKey{}, false
+			}
+
+			key.token = token
+		}
+
+		return key, key.token != ""
+	}
+}
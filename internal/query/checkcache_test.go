@@ -0,0 +1,168 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestCheckCache(t *testing.T) {
+	t.Parallel()
+
+	key := func(action string) checkCacheKey {
+		return checkCacheKey{
+			subject:  types.Resource{Type: "user", ID: "idntusr-a"},
+			action:   action,
+			resource: types.Resource{Type: "tenant", ID: "tnntten-a"},
+			token:    "token",
+		}
+	}
+
+	t.Run("LRUEviction", func(t *testing.T) {
+		t.Parallel()
+
+		cache := newCheckCache(2, time.Minute)
+
+		cache.set(key("a"), true)
+		cache.set(key("b"), false)
+
+		// Reading a makes b the least recently used entry.
+		_, ok := cache.get(key("a"))
+		assert.True(t, ok)
+
+		cache.set(key("c"), true)
+
+		_, ok = cache.get(key("b"))
+		assert.False(t, ok)
+
+		allowed, ok := cache.get(key("a"))
+		assert.True(t, ok)
+		assert.True(t, allowed)
+
+		allowed, ok = cache.get(key("c"))
+		assert.True(t, ok)
+		assert.True(t, allowed)
+	})
+
+	t.Run("TTLExpiry", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+
+		cache := newCheckCache(2, time.Minute)
+		cache.now = func() time.Time { return now }
+
+		cache.set(key("a"), true)
+
+		_, ok := cache.get(key("a"))
+		assert.True(t, ok)
+
+		now = now.Add(time.Minute)
+
+		_, ok = cache.get(key("a"))
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.order.Len())
+	})
+
+	t.Run("TokenSeparation", func(t *testing.T) {
+		t.Parallel()
+
+		cache := newCheckCache(2, time.Minute)
+
+		cache.set(key("a"), true)
+
+		other := key("a")
+		other.token = "newer"
+
+		_, ok := cache.get(other)
+		assert.False(t, ok)
+	})
+}
+
+func TestCheckCacheKeyFor(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		options  []Option
+		mode     *ConsistencyMode
+		token    string
+		expOK    bool
+		expToken string
+	}
+
+	minimize := ConsistencyMinimizeLatency
+	full := ConsistencyFullyConsistent
+
+	testCases := []testCase{
+		{
+			name:  "Disabled",
+			token: "token",
+		},
+		{
+			name:    "NoToken",
+			options: []Option{WithCheckCache(10, time.Minute)},
+		},
+		{
+			name:     "Token",
+			options:  []Option{WithCheckCache(10, time.Minute)},
+			token:    "token",
+			expOK:    true,
+			expToken: "token",
+		},
+		{
+			name:    "MinimizeLatency",
+			options: []Option{WithCheckCache(10, time.Minute)},
+			mode:    &minimize,
+			token:   "token",
+			expOK:   true,
+		},
+		{
+			name:    "FullyConsistent",
+			options: []Option{WithCheckCache(10, time.Minute)},
+			mode:    &full,
+			token:   "token",
+		},
+		{
+			name:    "ZeroSize",
+			options: []Option{WithCheckCache(0, time.Minute)},
+			token:   "token",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := &engine{}
+
+			for _, opt := range tc.options {
+				opt(e)
+			}
+
+			ctx := context.Background()
+
+			if tc.mode != nil {
+				ctx = ContextWithConsistency(ctx, *tc.mode)
+			}
+
+			if tc.token != "" {
+				ctx = ContextWithQueryToken(ctx, tc.token)
+			}
+
+			key, ok := e.checkCacheKeyFor(ctx, types.Resource{}, "read", types.Resource{})
+
+			assert.Equal(t, tc.expOK, ok)
+
+			if ok {
+				assert.Equal(t, tc.expToken, key.token)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WithClientConn sets the connection underlying the engine's client, which Close closes.
+// Without it, the client's connection is left to its owner.
+func WithClientConn(conn io.Closer) Option {
+	return func(e *engine) {
+		e.conn = conn
+	}
+}
+
+// Close releases the engine's resources. Queued role events are published first, then observers which
+// implement io.Closer are closed, in the order they were added, so any changes they buffer are flushed,
+// and then the connection set with WithClientConn is closed. The engine must not be used once closed.
+// Close may be called more than once; later calls return the result of the first.
+func (e *engine) Close() error {
+	e.closeOnce.Do(func() {
+		var errs []error
+
+		e.stopEventPublisher()
+
+		for _, observer := range e.observers {
+			closer, ok := observer.(io.Closer)
+			if !ok {
+				continue
+			}
+
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close observer: %w", err))
+			}
+		}
+
+		if e.conn != nil {
+			if err := e.conn.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close spicedb connection: %w", err))
+			}
+		}
+
+		e.closeErr = errors.Join(errs...)
+	})
+
+	return e.closeErr
+}
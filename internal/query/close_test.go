@@ -0,0 +1,54 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+type testCloser struct {
+	closed int
+	err    error
+}
+
+func (c *testCloser) Close() error {
+	c.closed++
+
+	return c.err
+}
+
+type closingObserver struct {
+	testCloser
+}
+
+func (o *closingObserver) OnCreate(context.Context, []types.Relationship, string) error {
+	return nil
+}
+
+func (o *closingObserver) OnDelete(context.Context, []types.Relationship, string) error {
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	errConn := errors.New("connection already closed")
+
+	observer := &closingObserver{}
+	conn := &testCloser{err: errConn}
+
+	e := NewEngine("test", nil, WithObserver(observer), WithClientConn(conn))
+
+	assert.ErrorIs(t, e.Close(), errConn)
+	assert.ErrorIs(t, e.Close(), errConn)
+
+	assert.Equal(t, 1, observer.closed)
+	assert.Equal(t, 1, conn.closed)
+
+	// An engine without a connection has nothing to close.
+	assert.NoError(t, NewEngine("test", nil).Close())
+}
@@ -0,0 +1,272 @@
+package query
+
+import (
+	"context"
+	"sync"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"go.infratographer.com/permissions-api/internal/spicedbx"
+)
+
+// ConsistencyMode determines how fresh the data read from SpiceDB must be.
+//
+// The mode is honored by every engine method which reads from SpiceDB: GetRole, GetRoleResource,
+// ListAssignments, ListRelationshipsFrom, ListRelationshipsFromPage, ListRelationshipsTo, ListRoles,
+// ListRolesPage, ListSubjectActions, SubjectHasPermission, SubjectHasPermissionWithContext and
+// SubjectHasPermissions, as well as the reads performed by DeleteRole and UpdateRole.
+type ConsistencyMode int
+
+const (
+	// ConsistencyAtLeastAsFresh reads data at least as fresh as the provided query token. When no token
+	// is provided, reads use minimize latency while permission checks are fully consistent, unless
+	// WithReadYourWrites is given. This is the default mode.
+	ConsistencyAtLeastAsFresh ConsistencyMode = iota
+	// ConsistencyMinimizeLatency reads the most readily available data, ignoring any provided query token.
+	ConsistencyMinimizeLatency
+	// ConsistencyFullyConsistent reads the most recent data, ignoring any provided query token.
+	ConsistencyFullyConsistent
+	// ConsistencyAtExactSnapshot reads data exactly at the snapshot of the provided query token,
+	// so repeated reads with the same token return the same results. When no token is provided,
+	// reads are fully consistent.
+	//
+	// SpiceDB only keeps the history needed to read a snapshot for its garbage collection window
+	// (--datastore-gc-window, 24 hours by default). Reads at a snapshot older than the window fail,
+	// so audits needing older state must capture it while the snapshot is still readable.
+	// A snapshot read may also be slower than a read at the latest revision as it cannot be
+	// served from SpiceDB's caches.
+	ConsistencyAtExactSnapshot
+)
+
+type (
+	consistencyContextKey struct{}
+	queryTokenContextKey  struct{}
+	readAtContextKey      struct{}
+)
+
+// ContextWithConsistency returns a context which overrides the engine's default consistency mode
+// for any engine call made with it.
+func ContextWithConsistency(ctx context.Context, mode ConsistencyMode) context.Context {
+	return context.WithValue(ctx, consistencyContextKey{}, mode)
+}
+
+// ContextWithQueryToken returns a context carrying a query token for engine calls which do not take
+// one as an argument, such as permission checks. A query token passed as an argument takes precedence.
+func ContextWithQueryToken(ctx context.Context, queryToken string) context.Context {
+	return context.WithValue(ctx, queryTokenContextKey{}, queryToken)
+}
+
+// ContextAtSnapshot returns a context which pins every engine read and permission check made with it
+// to the exact snapshot of the query token, such as one captured for an audit. See ConsistencyAtExactSnapshot
+// for the limits of snapshot reads. A query token passed to an engine method takes precedence, so methods
+// should be called with an empty query token.
+func ContextAtSnapshot(ctx context.Context, queryToken string) context.Context {
+	return ContextWithConsistency(ContextWithQueryToken(ctx, queryToken), ConsistencyAtExactSnapshot)
+}
+
+func queryTokenFor(ctx context.Context, queryToken string) string {
+	if queryToken != "" {
+		return queryToken
+	}
+
+	token, _ := ctx.Value(queryTokenContextKey{}).(string)
+
+	return token
+}
+
+// readAtRecorder records the revision of the first relationships read with its context,
+// so further reads can be made at the same snapshot.
+type readAtRecorder struct {
+	mu    sync.Mutex
+	token string
+}
+
+func contextWithReadAtRecorder(ctx context.Context) (context.Context, *readAtRecorder) {
+	recorder := &readAtRecorder{}
+
+	return context.WithValue(ctx, readAtContextKey{}, recorder), recorder
+}
+
+// recordReadAt records the token a read was made at, if the context has a recorder without one.
+func recordReadAt(ctx context.Context, token string) {
+	recorder, ok := ctx.Value(readAtContextKey{}).(*readAtRecorder)
+	if !ok || token == "" {
+		return
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	if recorder.token == "" {
+		recorder.token = token
+	}
+}
+
+func (r *readAtRecorder) readAt() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.token
+}
+
+// writeTokenTracker holds the query token of the freshest write made by an engine. Writes may complete
+// out of order, so a token only replaces the one held if it is fresher. If two tokens cannot be compared,
+// such as after the datastore changes, which write is freshest is unknown and the tracker is unordered.
+type writeTokenTracker struct {
+	mu        sync.RWMutex
+	token     string
+	unordered bool
+}
+
+func (t *writeTokenTracker) record(token string) {
+	if token == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token == "" {
+		t.token = token
+
+		return
+	}
+
+	cmp, err := spicedbx.CompareTokens(token, t.token)
+
+	switch {
+	case err != nil:
+		t.token = token
+		t.unordered = true
+	case cmp > 0:
+		t.token = token
+	}
+}
+
+func (t *writeTokenTracker) get() (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.token, !t.unordered
+}
+
+// WithReadYourWrites makes reads and permission checks given no query token see the engine's own
+// writes. The engine tracks the query token of its most recently completed write, and reads and checks
+// without a query token, either as an argument or with the call context, are made at least as fresh as
+// it. This only applies with ConsistencyAtLeastAsFresh, the default mode.
+//
+// A single token, that of the freshest write, is tracked, so memory use is constant, but it is shared by
+// every caller of the engine: a read waits for the most recent write by any caller, which may add latency
+// for callers which did not write. If the tokens of two writes cannot be compared, reads and checks
+// without a query token are fully consistent from then on, as the freshest write is unknown. Writes
+// made through other engines or processes are not tracked, so reads may still be stale with respect
+// to them.
+func WithReadYourWrites() Option {
+	return func(e *engine) {
+		e.lastWrite = &writeTokenTracker{}
+	}
+}
+
+// recordWrite tracks the query token of a completed write, if read your writes is enabled.
+func (e *engine) recordWrite(token string) {
+	if e.lastWrite != nil {
+		e.lastWrite.record(token)
+	}
+}
+
+// lastWriteToken returns the query token of the freshest write, or an empty string if read your writes
+// is not enabled or nothing has been written. If the freshest write is unknown, ordered is false and
+// reads must be fully consistent to see it.
+func (e *engine) lastWriteToken() (token string, ordered bool) {
+	if e.lastWrite == nil {
+		return "", true
+	}
+
+	return e.lastWrite.get()
+}
+
+// WithDefaultConsistency sets the consistency mode used when one is not provided with the call context.
+func WithDefaultConsistency(mode ConsistencyMode) Option {
+	return func(e *engine) {
+		e.consistencyMode = mode
+	}
+}
+
+func (e *engine) consistencyModeFor(ctx context.Context) ConsistencyMode {
+	if mode, ok := ctx.Value(consistencyContextKey{}).(ConsistencyMode); ok {
+		return mode
+	}
+
+	return e.consistencyMode
+}
+
+// readConsistency returns the consistency requirement for reading relationships.
+func (e *engine) readConsistency(ctx context.Context, queryToken string) *pb.Consistency {
+	return e.consistency(e.consistencyModeFor(ctx), queryTokenFor(ctx, queryToken), minimizeLatency())
+}
+
+// checkConsistency returns the consistency requirement for checking permissions.
+func (e *engine) checkConsistency(ctx context.Context, queryToken string) *pb.Consistency {
+	return e.consistency(e.consistencyModeFor(ctx), queryTokenFor(ctx, queryToken), fullyConsistent())
+}
+
+// consistency maps the mode and query token to a SpiceDB consistency requirement. When the mode is
+// at least as fresh and no query token was provided, the most recent write's token is used with read
+// your writes, otherwise noToken is used.
+func (e *engine) consistency(mode ConsistencyMode, queryToken string, noToken *pb.Consistency) *pb.Consistency {
+	switch mode {
+	case ConsistencyMinimizeLatency:
+		return minimizeLatency()
+	case ConsistencyFullyConsistent:
+		return fullyConsistent()
+	case ConsistencyAtExactSnapshot:
+		if queryToken == "" {
+			return fullyConsistent()
+		}
+
+		return &pb.Consistency{
+			Requirement: &pb.Consistency_AtExactSnapshot{
+				AtExactSnapshot: &pb.ZedToken{
+					Token: queryToken,
+				},
+			},
+		}
+	default:
+		if queryToken == "" {
+			token, ordered := e.lastWriteToken()
+			if !ordered {
+				return fullyConsistent()
+			}
+
+			queryToken = token
+		}
+
+		if queryToken == "" {
+			return noToken
+		}
+
+		return &pb.Consistency{
+			Requirement: &pb.Consistency_AtLeastAsFresh{
+				AtLeastAsFresh: &pb.ZedToken{
+					Token: queryToken,
+				},
+			},
+		}
+	}
+}
+
+func minimizeLatency() *pb.Consistency {
+	return &pb.Consistency{
+		Requirement: &pb.Consistency_MinimizeLatency{
+			MinimizeLatency: true,
+		},
+	}
+}
+
+func fullyConsistent() *pb.Consistency {
+	return &pb.Consistency{
+		Requirement: &pb.Consistency_FullyConsistent{
+			FullyConsistent: true,
+		},
+	}
+}
@@ -0,0 +1,269 @@
+package query
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsistency(t *testing.T) {
+	t.Parallel()
+
+	type testInput struct {
+		defaultMode ConsistencyMode
+		override    *ConsistencyMode
+		queryToken  string
+		ctxToken    string
+		snapshot    string
+		// readYourWrites enables read your writes, with lastWrite recorded as the last write's token.
+		readYourWrites bool
+		lastWrite      string
+	}
+
+	type testCase struct {
+		name      string
+		input     testInput
+		readCheck func(*testing.T, *pb.Consistency)
+		permCheck func(*testing.T, *pb.Consistency)
+	}
+
+	exact := ConsistencyAtExactSnapshot
+	minimize := ConsistencyMinimizeLatency
+
+	testCases := []testCase{
+		{
+			name:  "DefaultNoToken",
+			input: testInput{},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetFullyConsistent())
+			},
+		},
+		{
+			name: "DefaultToken",
+			input: testInput{
+				queryToken: "token",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtLeastAsFresh().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtLeastAsFresh().GetToken())
+			},
+		},
+		{
+			name: "FullyConsistent",
+			input: testInput{
+				defaultMode: ConsistencyFullyConsistent,
+				queryToken:  "token",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetFullyConsistent())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetFullyConsistent())
+			},
+		},
+		{
+			name: "OverrideExactSnapshot",
+			input: testInput{
+				defaultMode: ConsistencyFullyConsistent,
+				override:    &exact,
+				queryToken:  "token",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtExactSnapshot().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtExactSnapshot().GetToken())
+			},
+		},
+		{
+			name: "OverrideMinimizeLatency",
+			input: testInput{
+				override:   &minimize,
+				queryToken: "token",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+		},
+		{
+			name: "ContextToken",
+			input: testInput{
+				ctxToken: "ctxtoken",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "ctxtoken", c.GetAtLeastAsFresh().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "ctxtoken", c.GetAtLeastAsFresh().GetToken())
+			},
+		},
+		{
+			name: "ContextSnapshot",
+			input: testInput{
+				snapshot: "snapshot",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "snapshot", c.GetAtExactSnapshot().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "snapshot", c.GetAtExactSnapshot().GetToken())
+			},
+		},
+		{
+			name: "ArgumentTokenPrecedence",
+			input: testInput{
+				queryToken: "token",
+				ctxToken:   "ctxtoken",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtLeastAsFresh().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "token", c.GetAtLeastAsFresh().GetToken())
+			},
+		},
+		{
+			name: "ReadYourWrites",
+			input: testInput{
+				readYourWrites: true,
+				lastWrite:      "written",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "written", c.GetAtLeastAsFresh().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "written", c.GetAtLeastAsFresh().GetToken())
+			},
+		},
+		{
+			name: "ReadYourWritesNothingWritten",
+			input: testInput{
+				readYourWrites: true,
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetFullyConsistent())
+			},
+		},
+		{
+			name: "ReadYourWritesTokenPrecedence",
+			input: testInput{
+				readYourWrites: true,
+				lastWrite:      "written",
+				ctxToken:       "ctxtoken",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "ctxtoken", c.GetAtLeastAsFresh().GetToken())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.Equal(t, "ctxtoken", c.GetAtLeastAsFresh().GetToken())
+			},
+		},
+		{
+			name: "ReadYourWritesOtherMode",
+			input: testInput{
+				defaultMode:    ConsistencyMinimizeLatency,
+				readYourWrites: true,
+				lastWrite:      "written",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+		},
+		{
+			name: "ReadYourWritesDisabled",
+			input: testInput{
+				lastWrite: "written",
+			},
+			readCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetMinimizeLatency())
+			},
+			permCheck: func(t *testing.T, c *pb.Consistency) {
+				assert.True(t, c.GetFullyConsistent())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := &engine{}
+			WithDefaultConsistency(tc.input.defaultMode)(e)
+
+			if tc.input.readYourWrites {
+				WithReadYourWrites()(e)
+			}
+
+			e.recordWrite(tc.input.lastWrite)
+
+			ctx := context.Background()
+			if tc.input.override != nil {
+				ctx = ContextWithConsistency(ctx, *tc.input.override)
+			}
+
+			if tc.input.ctxToken != "" {
+				ctx = ContextWithQueryToken(ctx, tc.input.ctxToken)
+			}
+
+			if tc.input.snapshot != "" {
+				ctx = ContextAtSnapshot(ctx, tc.input.snapshot)
+			}
+
+			tc.readCheck(t, e.readConsistency(ctx, tc.input.queryToken))
+			tc.permCheck(t, e.checkConsistency(ctx, tc.input.queryToken))
+		})
+	}
+}
+
+// v1Token returns a V1 zedtoken for the given revision.
+func v1Token(revision string) string {
+	decimal := append([]byte{0x0a, byte(len(revision))}, revision...)
+
+	return base64.StdEncoding.EncodeToString(append([]byte{0x1a, byte(len(decimal))}, decimal...))
+}
+
+func TestWriteTokenTracker(t *testing.T) {
+	t.Parallel()
+
+	e := &engine{}
+	WithReadYourWrites()(e)
+
+	e.recordWrite(v1Token("200"))
+	// A write completing after a fresher one does not replace its token.
+	e.recordWrite(v1Token("100"))
+
+	token, ordered := e.lastWriteToken()
+	assert.Equal(t, v1Token("200"), token)
+	assert.True(t, ordered)
+
+	e.recordWrite(v1Token("300"))
+
+	token, ordered = e.lastWriteToken()
+	assert.Equal(t, v1Token("300"), token)
+	assert.True(t, ordered)
+
+	// Once tokens cannot be compared the freshest write is unknown, so reads are fully consistent.
+	e.recordWrite("not a token")
+
+	_, ordered = e.lastWriteToken()
+	assert.False(t, ordered)
+	assert.True(t, e.readConsistency(context.Background(), "").GetFullyConsistent())
+}
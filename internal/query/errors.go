@@ -1,6 +1,9 @@
 package query
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrActionNotAssigned represents an error condition where the subject is not able to complete
@@ -22,6 +25,115 @@ var (
 	// ErrRoleNotFound represents an error when no matching role was found on resource
 	ErrRoleNotFound = errors.New("role not found")
 
+	// ErrInvalidAction represents an error where the given action is not valid for the resource
+	ErrInvalidAction = errors.New("invalid action")
+
+	// ErrInvalidActionName represents an error where an action name can never be valid, as it is not a valid permission name
+	ErrInvalidActionName = errors.New("invalid action name")
+
+	// ErrInvalidRoleName represents an error when a role name is not valid
+	ErrInvalidRoleName = errors.New("invalid role name")
+
+	// ErrInvalidRoleDescription represents an error when a role description is not valid
+	ErrInvalidRoleDescription = errors.New("invalid role description")
+
+	// ErrUnexpectedResponse represents an error when SpiceDB returns a response which does not match the request
+	ErrUnexpectedResponse = errors.New("unexpected response")
+
+	// ErrInvalidCaveatContext represents an error when the provided caveat context cannot be used
+	ErrInvalidCaveatContext = errors.New("invalid caveat context")
+
+	// ErrInvalidRoleParent represents an error when a role parent is not valid
+	ErrInvalidRoleParent = errors.New("invalid role parent")
+
+	// ErrInvalidCursor represents an error when a pagination cursor is malformed
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrResourceCycle represents an error when a resource is its own ancestor
+	ErrResourceCycle = errors.New("resource hierarchy contains a cycle")
+
+	// ErrTooManyParents represents an error when a resource has more than one parent
+	ErrTooManyParents = errors.New("resource has too many parents")
+
 	// ErrRoleHasTooManyResources represents an error which a role has too many resources
 	ErrRoleHasTooManyResources = errors.New("role has too many resources")
+
+	// ErrUnavailable represents an error where SpiceDB could not be reached. The request may be retried.
+	ErrUnavailable = errors.New("permissions backend unavailable")
+
+	// ErrDeadlineExceeded represents an error where a SpiceDB request did not complete before its deadline.
+	ErrDeadlineExceeded = errors.New("permissions backend deadline exceeded")
+
+	// ErrPermissionDenied represents an error where SpiceDB rejected the credentials used for a request.
+	ErrPermissionDenied = errors.New("permissions backend permission denied")
+
+	// ErrSchemaMissing represents an error where the SpiceDB schema does not define the policy's resource types
+	ErrSchemaMissing = errors.New("permissions schema missing")
+
+	// ErrOrphanedRelationships represents an error where a schema change would orphan existing relationships
+	ErrOrphanedRelationships = errors.New("schema change orphans existing relationships")
+
+	// ErrSchemaMismatch represents an error where the live SpiceDB schema differs from the schema generated from the policy
+	ErrSchemaMismatch = errors.New("permissions schema mismatch")
+
+	// ErrInvalidID represents an error when a resource ID is not a valid prefixed ID
+	ErrInvalidID = errors.New("invalid id")
+
+	// ErrInvalidRoleOwner represents an error when a role is created on a resource whose type may not own roles
+	ErrInvalidRoleOwner = errors.New("invalid role owner")
+
+	// ErrTransactionDone represents an error where a transaction is used after it was committed
+	ErrTransactionDone = errors.New("transaction already committed")
+
+	// ErrTransactionTooLarge represents an error where a transaction has more updates than can be written at once
+	ErrTransactionTooLarge = errors.New("transaction too large")
+
+	// ErrBulkImportUnavailable represents an error where relationships are imported by an engine without an experimental API client
+	ErrBulkImportUnavailable = errors.New("bulk import unavailable, no experimental client configured")
+
+	// ErrBulkExportUnavailable represents an error where relationships are exported by an engine without an experimental API client
+	ErrBulkExportUnavailable = errors.New("bulk export unavailable, no experimental client configured")
+
+	// ErrUnknownResourceType represents an error when no resource type is registered for an id prefix
+	ErrUnknownResourceType = errors.New("unknown resource type")
+
+	// ErrResourceTypeExists represents an error when a resource type conflicts with a registered type
+	ErrResourceTypeExists = errors.New("resource type already exists")
+
+	// ErrRelationshipExists represents an error when a relationship being created already exists
+	ErrRelationshipExists = errors.New("relationship already exists")
+
+	// ErrRoleConflict represents an error when a role being created has the ID of a role with a different owner or actions
+	ErrRoleConflict = errors.New("role conflicts with an existing role")
+
+	// ErrRoleDeleted represents an error when a role retained by WithRoleTombstones is assigned or given actions
+	ErrRoleDeleted = errors.New("role is deleted")
+
+	// ErrRoleWithoutActions represents an error when a change would leave a role with no actions
+	ErrRoleWithoutActions = errors.New("role must have at least one action")
+
+	// ErrReadOnly represents an error when a change is made with an engine configured WithReadOnly
+	ErrReadOnly = errors.New("engine is read-only")
 )
+
+// UnknownResourceTypeError is returned when an ID's prefix does not belong to any registered resource type.
+type UnknownResourceTypeError struct {
+	Prefix string
+	// Type is the resource type the prefix is mapped to by WithResourceTypeMapping, if it is mapped.
+	Type string
+}
+
+// Error returns the error message, naming the unknown prefix.
+func (e *UnknownResourceTypeError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: prefix %q is mapped to unknown resource type %q", ErrUnknownResourceType, e.Prefix, e.Type)
+	}
+
+	return fmt.Sprintf("%s: no resource type for prefix %q", ErrUnknownResourceType, e.Prefix)
+}
+
+// Is reports whether the target is ErrUnknownResourceType, or ErrInvalidNamespace which was returned
+// for unknown prefixes before resource types could be registered.
+func (e *UnknownResourceTypeError) Is(target error) bool {
+	return target == ErrUnknownResourceType || target == ErrInvalidNamespace
+}
@@ -0,0 +1,282 @@
+package query
+
+import (
+	"context"
+	"time"
+
+	"go.infratographer.com/x/events"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+const (
+	// RoleEventTopic is the topic role events are published to.
+	RoleEventTopic = "role"
+
+	// RoleEventTypeCreate is the event type published when a role is created.
+	RoleEventTypeCreate = "create"
+	// RoleEventTypeDelete is the event type published when a role is deleted.
+	RoleEventTypeDelete = "delete"
+	// RoleEventTypeAssign is the event type published when a role is assigned to a subject.
+	RoleEventTypeAssign = "assign"
+	// RoleEventTypeUnassign is the event type published when a role is unassigned from a subject.
+	RoleEventTypeUnassign = "unassign"
+
+	eventSource              = "permissions-api"
+	maxEventPublishAttempts  = 3
+	eventPublishRetryBackoff = 100 * time.Millisecond
+
+	// maxQueuedRoleEvents bounds the role events waiting to be published.
+	maxQueuedRoleEvents = 1000
+)
+
+// WithEventsPublisher sets the publisher role events are published with.
+// Events are published on role creation, deletion, assignment and unassignment. They are queued and
+// published in the background, and Close publishes any still queued before returning.
+func WithEventsPublisher(pub events.Publisher) Option {
+	return func(e *engine) {
+		e.publisher = pub
+	}
+}
+
+// roleEvent describes a change to a role to be published.
+type roleEvent struct {
+	eventType string
+	role      types.Role
+	resource  types.Resource
+	subject   *types.Resource
+}
+
+func (ev roleEvent) message(at time.Time) events.EventMessage {
+	msg := events.EventMessage{
+		SubjectID: ev.role.ID,
+		EventType: ev.eventType,
+		Source:    eventSource,
+		Timestamp: at,
+		Data: map[string]interface{}{
+			"actions": ev.role.Actions,
+		},
+	}
+
+	if ev.resource.ID != "" {
+		msg.AdditionalSubjectIDs = append(msg.AdditionalSubjectIDs, ev.resource.ID)
+		msg.Data["resourceID"] = ev.resource.ID
+	}
+
+	if ev.subject != nil {
+		msg.AdditionalSubjectIDs = append(msg.AdditionalSubjectIDs, ev.subject.ID)
+		msg.Data["subjectID"] = ev.subject.ID
+	}
+
+	return msg
+}
+
+// queuedRoleEvents are role events waiting to be published.
+type queuedRoleEvents struct {
+	ctx    context.Context
+	at     time.Time
+	events []roleEvent
+	// lookup is set for assignment events, which are sent with the resource the role is bound to and its
+	// actions there, looked up by the publisher as of queryToken.
+	lookup     bool
+	queryToken string
+}
+
+// startEventPublisher starts publishing queued role events in the background.
+func (e *engine) startEventPublisher() {
+	e.eventQueue = make(chan queuedRoleEvents, maxQueuedRoleEvents)
+	e.eventsDone = make(chan struct{})
+
+	go func() {
+		defer close(e.eventsDone)
+
+		for queued := range e.eventQueue {
+			e.sendRoleEvents(queued)
+		}
+	}()
+}
+
+// stopEventPublisher publishes the events still queued and stops publishing. Events published once
+// it has been called are dropped.
+func (e *engine) stopEventPublisher() {
+	e.eventsMu.Lock()
+
+	if e.eventQueue == nil || e.eventsClosed {
+		e.eventsMu.Unlock()
+
+		return
+	}
+
+	e.eventsClosed = true
+
+	close(e.eventQueue)
+
+	e.eventsMu.Unlock()
+
+	<-e.eventsDone
+}
+
+// publishRoleEvent queues the event to be published in the background if a publisher is configured.
+func (e *engine) publishRoleEvent(ctx context.Context, ev roleEvent) {
+	e.queueRoleEvents(ctx, queuedRoleEvents{
+		events: []roleEvent{ev},
+	})
+}
+
+// publishRoleAssignmentEvents queues the events for assigning or unassigning the role to each of the subjects
+// to be published in the background if a publisher is configured. The role's resource and actions are looked
+// up once for all of the events by the publisher, so the mutation does not wait on the read.
+func (e *engine) publishRoleAssignmentEvents(ctx context.Context, eventType string, role types.Role, subjects []types.Resource, queryToken string) {
+	queued := queuedRoleEvents{
+		events:     make([]roleEvent, len(subjects)),
+		lookup:     true,
+		queryToken: queryToken,
+	}
+
+	for i := range subjects {
+		queued.events[i] = roleEvent{
+			eventType: eventType,
+			role:      role,
+			subject:   &subjects[i],
+		}
+	}
+
+	e.queueRoleEvents(ctx, queued)
+}
+
+// queueRoleEvents queues the events so the mutation is not held up by retries of a slow or failing broker.
+// The events keep the trace of ctx but not its cancellation, as they are published after the request
+// returns. When maxQueuedRoleEvents are already waiting, or the engine is closed, the events are dropped
+// and logged rather than blocking the mutation.
+func (e *engine) queueRoleEvents(ctx context.Context, queued queuedRoleEvents) {
+	if e.publisher == nil || len(queued.events) == 0 {
+		return
+	}
+
+	queued.ctx = trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	queued.at = time.Now().UTC()
+
+	e.eventsMu.Lock()
+	defer e.eventsMu.Unlock()
+
+	if !e.eventsClosed {
+		select {
+		case e.eventQueue <- queued:
+			return
+		default:
+		}
+	}
+
+	e.logger.Errorw("role event queue full or closed, dropping events",
+		"event_type", queued.events[0].eventType,
+		"role_id", queued.events[0].role.ID,
+		"events", len(queued.events),
+	)
+}
+
+// sendRoleEvents publishes the queued events, first looking up the role of assignment events.
+func (e *engine) sendRoleEvents(queued queuedRoleEvents) {
+	var target roleEventTarget
+
+	if queued.lookup {
+		target = e.findRoleEventTarget(queued.ctx, queued.events[0].role, queued.queryToken)
+	}
+
+	for _, ev := range queued.events {
+		if queued.lookup {
+			ev = target.assignmentEvent(ev.eventType, *ev.subject, ev.role)
+		}
+
+		e.sendRoleEvent(queued.ctx, ev.message(queued.at))
+	}
+}
+
+// sendRoleEvent publishes the event message, retrying a bounded number of times.
+// Failures are logged and never returned so a publishing problem does not fail the mutation.
+func (e *engine) sendRoleEvent(ctx context.Context, msg events.EventMessage) {
+	var (
+		attempt int
+		err     error
+	)
+
+	for attempt = 1; ; attempt++ {
+		if _, err = e.publisher.PublishEvent(ctx, RoleEventTopic, msg); err == nil {
+			return
+		}
+
+		if attempt == maxEventPublishAttempts {
+			break
+		}
+
+		if sleepErr := sleepContext(ctx, eventPublishRetryBackoff*time.Duration(attempt)); sleepErr != nil {
+			break
+		}
+	}
+
+	e.logger.Errorw("failed to publish role event",
+		"event_type", msg.EventType,
+		"role_id", msg.SubjectID,
+		"attempts", attempt,
+		"error", err,
+	)
+}
+
+// sleepContext waits for the given duration or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// roleEventTarget is the resource a role is bound to and its actions on it, as reported in role events.
+type roleEventTarget struct {
+	resource types.Resource
+	actions  []string
+}
+
+// findRoleEventTarget looks up the resource the role is bound to and its actions on it. A failed lookup is
+// logged and leaves the target empty, so events are still published with what is known of the role.
+func (e *engine) findRoleEventTarget(ctx context.Context, role types.Role, queryToken string) roleEventTarget {
+	var target roleEventTarget
+
+	resActions, err := e.findRoleResourceActions(ctx, role.Resource(), queryToken)
+	if err != nil {
+		e.logger.Warnw("failed to look up role for event", "role_id", role.ID, "error", err)
+
+		return target
+	}
+
+	for resource, relActions := range resActions {
+		target.resource = resource
+		target.actions = make([]string, len(relActions))
+
+		for i, relAction := range relActions {
+			target.actions[i] = relationToAction(relAction)
+		}
+	}
+
+	return target
+}
+
+// assignmentEvent builds the event for assigning or unassigning the role to the subject.
+func (t roleEventTarget) assignmentEvent(eventType string, subject types.Resource, role types.Role) roleEvent {
+	ev := roleEvent{
+		eventType: eventType,
+		role:      role,
+		subject:   &subject,
+	}
+
+	if t.resource.ID != "" {
+		ev.resource = t.resource
+		ev.role.Actions = t.actions
+	}
+
+	return ev
+}
@@ -0,0 +1,165 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.infratographer.com/x/events"
+	"go.infratographer.com/x/gidx"
+	"go.uber.org/zap"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+var errPublishFailed = errors.New("publish failed")
+
+type testPublisher struct {
+	failures int
+	messages []events.EventMessage
+	calls    int
+}
+
+func (p *testPublisher) PublishChange(context.Context, string, events.ChangeMessage) (events.Message[events.ChangeMessage], error) {
+	return nil, nil
+}
+
+func (p *testPublisher) PublishEvent(_ context.Context, _ string, message events.EventMessage) (events.Message[events.EventMessage], error) {
+	p.calls++
+
+	if p.calls <= p.failures {
+		return nil, errPublishFailed
+	}
+
+	p.messages = append(p.messages, message)
+
+	return nil, nil
+}
+
+func TestPublishRoleEvent(t *testing.T) {
+	t.Parallel()
+
+	roleID := gidx.MustNewID(RolePrefix)
+	tenID := gidx.MustNewID("tnntten")
+	subjID := gidx.MustNewID("idntusr")
+
+	ev := roleEvent{
+		eventType: RoleEventTypeAssign,
+		role: types.Role{
+			ID:      roleID,
+			Actions: []string{"loadbalancer_get"},
+		},
+		resource: types.Resource{Type: "tenant", ID: tenID},
+		subject:  &types.Resource{Type: "user", ID: subjID},
+	}
+
+	type testCase struct {
+		name     string
+		failures int
+		expCalls int
+		expSent  int
+	}
+
+	testCases := []testCase{
+		{
+			name:     "Success",
+			expCalls: 1,
+			expSent:  1,
+		},
+		{
+			name:     "RetrySuccess",
+			failures: 2,
+			expCalls: 3,
+			expSent:  1,
+		},
+		{
+			name:     "RetryExhausted",
+			failures: maxEventPublishAttempts,
+			expCalls: maxEventPublishAttempts,
+			expSent:  0,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pub := &testPublisher{failures: tc.failures}
+
+			e := &engine{
+				logger: zap.NewNop().Sugar(),
+			}
+
+			WithEventsPublisher(pub)(e)
+
+			e.startEventPublisher()
+
+			e.publishRoleEvent(context.Background(), ev)
+
+			assert.NoError(t, e.Close())
+
+			assert.Equal(t, tc.expCalls, pub.calls)
+			assert.Len(t, pub.messages, tc.expSent)
+
+			for _, msg := range pub.messages {
+				assert.Equal(t, roleID, msg.SubjectID)
+				assert.Equal(t, RoleEventTypeAssign, msg.EventType)
+				assert.Equal(t, []gidx.PrefixedID{tenID, subjID}, msg.AdditionalSubjectIDs)
+				assert.Equal(t, []string{"loadbalancer_get"}, msg.Data["actions"])
+			}
+		})
+	}
+}
+
+func TestPublishRoleEventQueueFull(t *testing.T) {
+	t.Parallel()
+
+	pub := &testPublisher{}
+
+	e := &engine{
+		logger: zap.NewNop().Sugar(),
+	}
+
+	WithEventsPublisher(pub)(e)
+
+	// Without a running publisher nothing is taken from the queue, so it fills.
+	e.eventQueue = make(chan queuedRoleEvents, 1)
+
+	ev := roleEvent{
+		eventType: RoleEventTypeCreate,
+		role:      types.Role{ID: gidx.MustNewID(RolePrefix)},
+	}
+
+	e.publishRoleEvent(context.Background(), ev)
+	e.publishRoleEvent(context.Background(), ev)
+
+	assert.Len(t, e.eventQueue, 1)
+	assert.Zero(t, pub.calls)
+}
+
+func TestPublishRoleEventAfterClose(t *testing.T) {
+	t.Parallel()
+
+	pub := &testPublisher{}
+
+	e := &engine{
+		logger: zap.NewNop().Sugar(),
+	}
+
+	WithEventsPublisher(pub)(e)
+
+	e.startEventPublisher()
+
+	assert.NoError(t, e.Close())
+
+	assert.NotPanics(t, func() {
+		e.publishRoleEvent(context.Background(), roleEvent{
+			eventType: RoleEventTypeCreate,
+			role:      types.Role{ID: gidx.MustNewID(RolePrefix)},
+		})
+	})
+
+	assert.Zero(t, pub.calls)
+}
@@ -0,0 +1,363 @@
+package query
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.infratographer.com/permissions-api/internal/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PermissionTree is the effective permission graph of a role: the actions the role grants
+// on its resource and the subjects the role is assigned to.
+type PermissionTree struct {
+	Role     types.Resource
+	Resource types.Resource
+	Actions  []string
+	Subjects []PermissionTreeNode
+}
+
+// PermissionTreeNode is a subject in a PermissionTree.
+// A node with a Relation is a set of subjects, such as the subjects of another role, whose members are
+// expanded into Children. Inherited is set on subjects which received the role through such a set rather
+// than being assigned the role directly.
+type PermissionTreeNode struct {
+	Subject   types.Resource
+	Relation  string
+	Inherited bool
+	Children  []PermissionTreeNode
+}
+
+// ExpandRole returns the permission tree of the given role.
+func (e *engine) ExpandRole(ctx context.Context, roleResource types.Resource, queryToken string) (_ *PermissionTree, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ExpandRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ExpandRole", time.Now(), &err)
+
+	resActions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return nil, ErrRoleNotFound
+	}
+
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
+
+		return nil, ErrRoleHasTooManyResources
+	}
+
+	tree := &PermissionTree{
+		Role: roleResource,
+	}
+
+	for resource, relActions := range resActions {
+		tree.Resource = resource
+
+		for _, relAction := range relActions {
+			tree.Actions = append(tree.Actions, relationToAction(relAction))
+		}
+	}
+
+	visited := map[string]struct{}{
+		roleResource.ID.String() + "#" + roleSubjectRelation: {},
+	}
+
+	tree.Subjects, err = e.expandSubjects(ctx, e.readConsistency(ctx, queryToken), resourceToSpiceDBRef(e.namespace, roleResource), roleSubjectRelation, false, visited)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// expandSubjects returns the subjects of the relation on the given object, recursively expanding subject sets.
+// Subject sets which were already visited are returned without children to guard against cycles.
+func (e *engine) expandSubjects(ctx context.Context, consistency *pb.Consistency, object *pb.ObjectReference, relation string, inherited bool, visited map[string]struct{}) ([]PermissionTreeNode, error) {
+	request := &pb.ExpandPermissionTreeRequest{
+		Consistency: consistency,
+		Resource:    object,
+		Permission:  relation,
+	}
+
+	var resp *pb.ExpandPermissionTreeResponse
+
+	err := e.retry(ctx, true, func() (err error) {
+		resp, err = e.client.ExpandPermissionTree(ctx, request)
+
+		return err
+	})
+	if err != nil {
+		return nil, newSpiceDBError(err)
+	}
+
+	var nodes []PermissionTreeNode
+
+	for _, subject := range treeSubjects(resp.TreeRoot) {
+		res, err := e.resourceFromSpiceDBRef(subject.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		node := PermissionTreeNode{
+			Subject:   res,
+			Relation:  subject.OptionalRelation,
+			Inherited: inherited,
+		}
+
+		if subject.OptionalRelation != "" {
+			key := subject.Object.ObjectId + "#" + subject.OptionalRelation
+
+			if _, ok := visited[key]; !ok {
+				visited[key] = struct{}{}
+
+				node.Children, err = e.expandSubjects(ctx, consistency, subject.Object, subject.OptionalRelation, true, visited)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// treeSubjects collects the subjects from all leaves of the given tree.
+func treeSubjects(tree *pb.PermissionRelationshipTree) []*pb.SubjectReference {
+	if tree == nil {
+		return nil
+	}
+
+	if leaf := tree.GetLeaf(); leaf != nil {
+		return leaf.Subjects
+	}
+
+	var subjects []*pb.SubjectReference
+
+	for _, child := range tree.GetIntermediate().GetChildren() {
+		subjects = append(subjects, treeSubjects(child)...)
+	}
+
+	return subjects
+}
+
+// Path is the chain of relationships by which a subject holds an action on a resource, as found by
+// ResolvePermissionPath.
+type Path struct {
+	Subject  types.Resource
+	Action   string
+	Resource types.Resource
+	Granted  bool
+	// Complete is set when Steps reach the subject. When no chain reaches the subject, Steps are the
+	// longest chain of subject sets followed from the resource, such as one ending at a role the subject is
+	// not assigned, which shows how close the subject came to the action.
+	Complete bool
+	Steps    []PathStep
+}
+
+// PathStep is a hop in a Path: the subjects of Relation on Resource include Subject or, when SubjectRelation
+// is set, the subjects of SubjectRelation on Subject. Relation may be a permission, such as an action, which
+// the subject holds through relationships of its own.
+type PathStep struct {
+	Resource        types.Resource
+	Relation        string
+	Subject         types.Resource
+	SubjectRelation string
+}
+
+// ResolvePermissionPath returns the chain of relationships which grants the subject the action on the resource,
+// from the resource through roles and parent resources to the subject. The chain is found by expanding the
+// permission tree of each hop in turn, so it is meant for investigating a single check rather than for use
+// on a request path. When the action is denied, the longest partial chain followed is returned instead.
+func (e *engine) ResolvePermissionPath(ctx context.Context, subject types.Resource, action string, resource types.Resource) (_ *Path, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ResolvePermissionPath",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+				attribute.String("permissions.action", action),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ResolvePermissionPath", time.Now(), &err)
+
+	if err := e.validateAction(resource.Type, action); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	allowed, err := e.HasPermission(ctx, subject, action, resource)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	resolver := &pathResolver{
+		e:           e,
+		consistency: e.checkConsistency(ctx, ""),
+		subject:     resourceToSpiceDBRef(e.namespace, subject),
+		visited:     make(map[string]struct{}),
+	}
+
+	steps, found, err := resolver.resolve(ctx, resourceToSpiceDBRef(e.namespace, resource), action, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	if !found {
+		steps = resolver.closest
+	}
+
+	span.SetAttributes(
+		attribute.Bool("permissions.allowed", allowed),
+		attribute.Int("permissions.path_steps", len(steps)),
+	)
+
+	return &Path{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+		Granted:  allowed,
+		Complete: found,
+		Steps:    steps,
+	}, nil
+}
+
+// pathResolver searches permission trees for a chain of relationships reaching a subject.
+type pathResolver struct {
+	e           *engine
+	consistency *pb.Consistency
+	subject     *pb.ObjectReference
+	visited     map[string]struct{}
+	closest     []PathStep
+}
+
+// resolve expands the relation on the object, following subject sets depth first until the subject is reached.
+// Subject sets which were already visited are not expanded again to guard against cycles.
+func (r *pathResolver) resolve(ctx context.Context, object *pb.ObjectReference, relation string, steps []PathStep) ([]PathStep, bool, error) {
+	key := object.ObjectType + ":" + object.ObjectId + "#" + relation
+
+	if _, ok := r.visited[key]; ok {
+		return nil, false, nil
+	}
+
+	r.visited[key] = struct{}{}
+
+	request := &pb.ExpandPermissionTreeRequest{
+		Consistency: r.consistency,
+		Resource:    object,
+		Permission:  relation,
+	}
+
+	var resp *pb.ExpandPermissionTreeResponse
+
+	err := r.e.retry(ctx, true, func() (err error) {
+		resp, err = r.e.client.ExpandPermissionTree(ctx, request)
+
+		return err
+	})
+	if err != nil {
+		return nil, false, newSpiceDBError(err)
+	}
+
+	res, err := r.e.resourceFromSpiceDBRef(object)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, subject := range grantingSubjects(resp.TreeRoot) {
+		subjRes, err := r.e.resourceFromSpiceDBRef(subject.Object)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Each chain gets its own copy of the steps so sibling chains do not overwrite each other.
+		next := append(append([]PathStep{}, steps...), PathStep{
+			Resource:        res,
+			Relation:        relation,
+			Subject:         subjRes,
+			SubjectRelation: subject.OptionalRelation,
+		})
+
+		if subject.OptionalRelation == "" && subject.Object.ObjectType == r.subject.ObjectType &&
+			(subject.Object.ObjectId == r.subject.ObjectId || subject.Object.ObjectId == types.WildcardID.String()) {
+			return next, true, nil
+		}
+
+		// Other subjects are dead ends, so only subject sets, such as the subjects of a role, make a chain closer.
+		if subject.OptionalRelation == "" {
+			continue
+		}
+
+		if len(next) > len(r.closest) {
+			r.closest = next
+		}
+
+		found, ok, err := r.resolve(ctx, subject.Object, subject.OptionalRelation, next)
+		if err != nil || ok {
+			return found, ok, err
+		}
+	}
+
+	return nil, false, nil
+}
+
+// grantingSubjects collects the subjects from the leaves of the given tree which may grant its permission.
+// Unlike treeSubjects, the subjects an exclusion removes are left out.
+func grantingSubjects(tree *pb.PermissionRelationshipTree) []*pb.SubjectReference {
+	if tree == nil {
+		return nil
+	}
+
+	if leaf := tree.GetLeaf(); leaf != nil {
+		return leaf.Subjects
+	}
+
+	children := tree.GetIntermediate().GetChildren()
+
+	if tree.GetIntermediate().GetOperation() == pb.AlgebraicSubjectSet_OPERATION_EXCLUSION && len(children) != 0 {
+		children = children[:1]
+	}
+
+	var subjects []*pb.SubjectReference
+
+	for _, child := range children {
+		subjects = append(subjects, grantingSubjects(child)...)
+	}
+
+	return subjects
+}
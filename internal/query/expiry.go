@@ -0,0 +1,144 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+const (
+	// assignmentExpiryCaveat is the caveat on role assignments which lapse at a given time.
+	// It is defined alongside the role type in the generated schema.
+	assignmentExpiryCaveat = "role_assignment_expiry"
+
+	// caveatContextNow is the caveat context key holding the time a permission is checked at.
+	caveatContextNow = "now"
+)
+
+// AssignSubjectRoleUntil assigns the given role to the given subject until expiresAt. Permission checks
+// supply the current time, so once the assignment expires it no longer grants anything, although the
+// assignment itself remains until unassigned. A check cached while the assignment was in effect may be
+// returned until the check cache's TTL elapses. Wildcard subjects may not be assigned a role with an expiry.
+func (e *engine) AssignSubjectRoleUntil(ctx context.Context, subject types.Resource, role types.Role, expiresAt time.Time) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.AssignSubjectRoleUntil",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+			attribute.String("permissions.expires_at", expiresAt.UTC().Format(time.RFC3339)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "AssignSubjectRoleUntil", time.Now(), &err)
+
+	if subject.IsWildcard() {
+		err := fmt.Errorf("%w: wildcard assignments cannot expire", ErrInvalidRelationship)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	if err := e.checkRoleNotDeleted(ctx, role.Resource()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	update, err := e.subjectRoleRelCreateUntil(subject, role, expiresAt)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	r, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{update},
+	})
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	e.notifyCreate(ctx, []types.Relationship{roleAssignmentRelationship(subject, role)}, r.WrittenAt.GetToken())
+	e.publishRoleAssignmentEvents(ctx, RoleEventTypeAssign, role, []types.Resource{subject}, r.WrittenAt.GetToken())
+
+	return r.WrittenAt.GetToken(), nil
+}
+
+// subjectRoleRelCreateUntil returns the update assigning the role to the subject until expiresAt.
+// The assignment is touched so an existing assignment's expiry is replaced.
+func (e *engine) subjectRoleRelCreateUntil(subject types.Resource, role types.Role, expiresAt time.Time) (*pb.RelationshipUpdate, error) {
+	caveatContext, err := structpb.NewStruct(map[string]any{
+		"expires_at": expiresAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	update := e.subjectRoleRelCreate(subject, role)
+
+	update.Operation = pb.RelationshipUpdate_OPERATION_TOUCH
+	update.Relationship.OptionalCaveat = &pb.ContextualizedCaveat{
+		CaveatName: e.namespace + "/" + assignmentExpiryCaveat,
+		Context:    caveatContext,
+	}
+
+	return update, nil
+}
+
+// checkContext returns the caveat context for a permission check, which is the given caveat context
+// with the current time set so expiring role assignments resolve. The time is always set here, replacing
+// any given in the caveat context, so a caller cannot revive an expired assignment.
+func checkContext(caveatContext map[string]any) (*structpb.Struct, error) {
+	merged := make(map[string]any, len(caveatContext)+1)
+
+	for k, v := range caveatContext {
+		merged[k] = v
+	}
+
+	merged[caveatContextNow] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	out, err := structpb.NewStruct(merged)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCaveatContext, err)
+	}
+
+	return out, nil
+}
+
+// assignmentExpiry returns when the role assignment expires, or false if it does not.
+func (e *engine) assignmentExpiry(rel *pb.Relationship) (time.Time, bool) {
+	caveat := rel.GetOptionalCaveat()
+	if caveat.GetCaveatName() != e.namespace+"/"+assignmentExpiryCaveat {
+		return time.Time{}, false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339Nano, caveat.GetContext().GetFields()["expires_at"].GetStringValue())
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
@@ -0,0 +1,194 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// ExportReport describes an export started by ExportRelationships. QueryToken is set when the export
+// starts, while Exported and Err are only set once the export's channel is closed.
+type ExportReport struct {
+	// QueryToken is the snapshot the relationships are exported at. Exporting again with it returns
+	// the same relationships while SpiceDB still holds the snapshot.
+	QueryToken string
+	// Exported is the number of relationships sent on the channel.
+	Exported int
+	// Err is the error which ended the export early, if any.
+	Err error
+}
+
+// ExportRelationships streams every relationship in the engine's namespace, read from SpiceDB's bulk
+// export API at the exact snapshot of the query token, so the export is a coherent point-in-time dump.
+// Without a query token the current snapshot is captured and used. Relationships are sent on the
+// returned channel as they are read, and the channel is closed when the export ends; the report's
+// Exported and Err may be read once it is closed. Canceling the context ends the export early.
+// Each relationship's Caveat is set to the caveat it is conditioned on, such as the expiry of a role
+// assignment, so ImportRelationships restores it. The engine must be created with WithExperimentalClient.
+func (e *engine) ExportRelationships(ctx context.Context, queryToken string) (<-chan types.Relationship, *ExportReport, error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ExportRelationships",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	// The export continues after returning, so its duration and error are recorded when it ends.
+	start := time.Now()
+
+	if e.experimental == nil {
+		err := ErrBulkExportUnavailable
+
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		e.observe(ctx, "ExportRelationships", start, &err)
+
+		return nil, nil, err
+	}
+
+	if queryToken == "" {
+		token, err := e.snapshotToken(ctx)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			e.observe(ctx, "ExportRelationships", start, &err)
+
+			return nil, nil, err
+		}
+
+		queryToken = token
+	}
+
+	recordZedToken(span, queryToken)
+
+	report := &ExportReport{
+		QueryToken: queryToken,
+	}
+
+	out := make(chan types.Relationship)
+
+	go func() {
+		defer span.End()
+		defer e.observe(ctx, "ExportRelationships", start, &report.Err)
+		defer close(out)
+
+		report.Err = e.exportRelationships(ctx, queryToken, out, report)
+
+		span.SetAttributes(attribute.Int("permissions.exported", report.Exported))
+
+		if report.Err != nil {
+			span.RecordError(report.Err)
+			span.SetStatus(codes.Error, report.Err.Error())
+		}
+	}()
+
+	return out, report, nil
+}
+
+// snapshotToken returns a query token for the current snapshot.
+func (e *engine) snapshotToken(ctx context.Context) (string, error) {
+	var resp *pb.ReadSchemaResponse
+
+	err := e.retry(ctx, true, func() (err error) {
+		resp, err = e.client.ReadSchema(ctx, &pb.ReadSchemaRequest{})
+
+		return err
+	})
+	if err != nil {
+		return "", newSpiceDBError(err)
+	}
+
+	return resp.GetReadAt().GetToken(), nil
+}
+
+// exportRelationships sends the namespace's relationships at the snapshot to out. A stream which
+// fails part way is resumed from the last relationship read.
+func (e *engine) exportRelationships(ctx context.Context, queryToken string, out chan<- types.Relationship, report *ExportReport) error {
+	prefix := e.namespace + "/"
+
+	var cursor *pb.Cursor
+
+	err := e.retry(ctx, true, func() error {
+		stream, err := e.experimental.BulkExportRelationships(ctx, &pb.BulkExportRelationshipsRequest{
+			Consistency: &pb.Consistency{
+				Requirement: &pb.Consistency_AtExactSnapshot{
+					AtExactSnapshot: &pb.ZedToken{
+						Token: queryToken,
+					},
+				},
+			},
+			OptionalCursor: cursor,
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+
+			switch err {
+			case nil:
+			case io.EOF:
+				return nil
+			default:
+				return err
+			}
+
+			for _, rel := range resp.Relationships {
+				// Relationships of other namespaces sharing the cluster are not exported.
+				if !strings.HasPrefix(rel.Resource.ObjectType, prefix) {
+					continue
+				}
+
+				item, err := e.relationshipFromSpiceDB(rel)
+				if err != nil {
+					return err
+				}
+
+				item.Caveat = e.relationshipCaveatFromSpiceDB(rel)
+
+				select {
+				case out <- item:
+					report.Exported++
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			cursor = resp.AfterResultCursor
+		}
+	})
+
+	return newSpiceDBError(err)
+}
+
+// relationshipFromSpiceDB returns the relationship for the given SpiceDB relationship.
+func (e *engine) relationshipFromSpiceDB(rel *pb.Relationship) (types.Relationship, error) {
+	res, err := e.resourceFromSpiceDBRef(rel.Resource)
+	if err != nil {
+		return types.Relationship{}, fmt.Errorf("resource %s:%s: %w", rel.Resource.ObjectType, rel.Resource.ObjectId, err)
+	}
+
+	subj, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+	if err != nil {
+		return types.Relationship{}, fmt.Errorf("subject %s:%s: %w", rel.Subject.Object.ObjectType, rel.Subject.Object.ObjectId, err)
+	}
+
+	return types.Relationship{
+		Resource:        res,
+		Relation:        rel.Relation,
+		Subject:         subj,
+		SubjectRelation: rel.Subject.OptionalRelation,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestExportRelationships(t *testing.T) {
+	namespace := "testexport"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	rel := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{rel})
+	require.NoError(t, err)
+
+	export := func(queryToken string) ([]types.Relationship, *ExportReport) {
+		rels, report, err := e.ExportRelationships(ctx, queryToken)
+		require.NoError(t, err)
+
+		var out []types.Relationship
+
+		for rel := range rels {
+			out = append(out, rel)
+		}
+
+		require.NoError(t, report.Err)
+		assert.Equal(t, len(out), report.Exported)
+
+		return out, report
+	}
+
+	exported, report := export(queryToken)
+	assert.Equal(t, []types.Relationship{rel}, exported)
+	assert.Equal(t, queryToken, report.QueryToken)
+
+	// Without a query token the current snapshot is captured, and exporting again at it is repeatable.
+	_, err = e.DeleteRelationships(ctx, rel)
+	require.NoError(t, err)
+
+	exported, report = export("")
+	assert.Empty(t, exported)
+	require.NotEmpty(t, report.QueryToken)
+
+	// The snapshot before the deletion still holds the relationship.
+	exported, _ = export(queryToken)
+	assert.Equal(t, []types.Relationship{rel}, exported)
+}
+
+func TestExportRelationshipsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	_, _, err := e.ExportRelationships(context.Background(), "")
+	assert.ErrorIs(t, err, ErrBulkExportUnavailable)
+}
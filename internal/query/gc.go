@@ -0,0 +1,187 @@
+package query
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.infratographer.com/x/gidx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/iapl"
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// GCReport describes the orphaned relationships removed by GarbageCollect.
+type GCReport struct {
+	// Roles are the roles which no longer have a definition and had relationships left behind.
+	Roles []types.Resource
+	// Relationships are the orphaned relationships which were deleted.
+	Relationships []types.Relationship
+	// QueryToken is the token of the last deletion, empty if nothing was deleted.
+	QueryToken string
+}
+
+// GarbageCollect deletes the assignments and parent links of roles which no longer have a definition,
+// such as assignments left behind by DeleteRole. A role is only collected once its actions, metadata and
+// owner are all gone, so a role which still has any of them, such as one part way through a failed
+// DeleteRoles, is left for DeleteRoles to finish. The remaining relationships of a deleted role do not
+// record the resource it was defined on, so orphans are collected across the whole namespace. Roles are
+// read fully consistently so a role which is being created is never collected.
+func (e *engine) GarbageCollect(ctx context.Context) (_ GCReport, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.GarbageCollect",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "GarbageCollect", time.Now(), &err)
+
+	var report GCReport
+
+	ctx = ContextWithConsistency(ctx, ConsistencyFullyConsistent)
+
+	rels, roleRels, err := e.readRoleRelationships(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return report, err
+	}
+
+	roleIDs := make([]gidx.PrefixedID, 0, len(roleRels))
+
+	for roleID := range roleRels {
+		roleIDs = append(roleIDs, roleID)
+	}
+
+	// A parent link belongs to both roles, so it is only reported once.
+	deleted := make(map[int]struct{})
+
+	sort.Slice(roleIDs, func(i, j int) bool {
+		return roleIDs[i] < roleIDs[j]
+	})
+
+	for _, roleID := range roleIDs {
+		roleRes := types.Resource{
+			Type: "role",
+			ID:   roleID,
+		}
+
+		resActions, err := e.findRoleResourceActions(ctx, roleRes, "")
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return report, err
+		}
+
+		if len(resActions) != 0 || roleDefined(roleID, rels, roleRels[roleID]) {
+			continue
+		}
+
+		filters := []*pb.RelationshipFilter{
+			{
+				ResourceType:       e.namespace + "/role",
+				OptionalResourceId: roleID.String(),
+			},
+			e.roleParentFilter(roleID),
+		}
+
+		for _, filter := range filters {
+			report.QueryToken, err = e.deleteRelationships(ctx, filter)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return report, err
+			}
+		}
+
+		e.logger.Infow("collected orphaned role relationships", "role", roleID, "relationships", len(roleRels[roleID]))
+
+		report.Roles = append(report.Roles, roleRes)
+
+		for _, i := range roleRels[roleID] {
+			if _, ok := deleted[i]; ok {
+				continue
+			}
+
+			deleted[i] = struct{}{}
+
+			report.Relationships = append(report.Relationships, rels[i])
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("permissions.orphaned_roles", len(report.Roles)),
+		attribute.Int("permissions.orphaned_relationships", len(report.Relationships)),
+	)
+
+	recordZedToken(span, report.QueryToken)
+
+	return report, nil
+}
+
+// roleDefined reports whether the role's relationships include its metadata or owner.
+func roleDefined(roleID gidx.PrefixedID, rels []types.Relationship, indexes []int) bool {
+	for _, i := range indexes {
+		rel := rels[i]
+
+		if rel.Resource.ID == roleID && (rel.Relation == roleMetadataRelation || rel.Relation == iapl.RoleOwnerRelation) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readRoleRelationships returns the relationships of every role in the namespace, other than
+// their definitions, along with the indexes of each role's relationships by role ID.
+// A link from a child role's subjects to its parent is indexed under both roles.
+func (e *engine) readRoleRelationships(ctx context.Context) ([]types.Relationship, map[gidx.PrefixedID][]int, error) {
+	relationships, err := e.readRelationships(ctx, &pb.RelationshipFilter{
+		ResourceType: e.namespace + "/role",
+	}, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make([]types.Relationship, 0, len(relationships))
+	index := make(map[gidx.PrefixedID][]int)
+
+	for _, rel := range relationships {
+		res, err := e.resourceFromSpiceDBRef(rel.Resource)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		subj, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		item := types.Relationship{
+			Resource:        res,
+			Relation:        rel.Relation,
+			Subject:         subj,
+			SubjectRelation: rel.Subject.OptionalRelation,
+		}
+
+		index[res.ID] = append(index[res.ID], len(out))
+
+		if subj.Type == "role" && rel.Subject.OptionalRelation == roleSubjectRelation && subj.ID != res.ID {
+			index[subj.ID] = append(index[subj.ID], len(out))
+		}
+
+		out = append(out, item)
+	}
+
+	return out, index, nil
+}
@@ -0,0 +1,78 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.infratographer.com/permissions-api/internal/spicedbx"
+)
+
+// Healthcheck checks that SpiceDB can be reached and that its schema defines every resource type of
+// the engine's policy in the engine's namespace. The request is not retried and does not wait for
+// the connection to become ready, so an unreachable SpiceDB fails fast with an error matching
+// ErrUnavailable, while a missing schema or definition returns ErrSchemaMissing.
+func (e *engine) Healthcheck(ctx context.Context) (err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.Healthcheck",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "Healthcheck", time.Now(), &err)
+
+	resp, err := e.client.ReadSchema(ctx, &pb.ReadSchemaRequest{}, grpc.WaitForReady(false))
+
+	switch {
+	case status.Code(err) == grpccodes.NotFound:
+		err = fmt.Errorf("%w: no schema has been written", ErrSchemaMissing)
+	case err != nil:
+		err = newSpiceDBError(err)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	defined := make(map[string]struct{})
+
+	for _, name := range spicedbx.DefinitionNames(resp.SchemaText) {
+		defined[name] = struct{}{}
+	}
+
+	var missing []string
+
+	for _, resType := range e.resourceTypes() {
+		name := e.namespace + "/" + resType.Name
+
+		if _, ok := defined[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) != 0 {
+		err := fmt.Errorf("%w: missing definitions %s", ErrSchemaMissing, strings.Join(missing, ", "))
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
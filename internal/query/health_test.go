@@ -0,0 +1,32 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.infratographer.com/permissions-api/internal/spicedbx"
+)
+
+func TestHealthcheck(t *testing.T) {
+	namespace := "testhealth"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	assert.NoError(t, e.Healthcheck(ctx))
+
+	client, err := spicedbx.NewClient(spicedbx.Config{
+		Endpoint: "spicedb:50051",
+		Key:      "infradev",
+		Insecure: true,
+	}, false)
+	require.NoError(t, err)
+
+	missing := NewEngine("testhealthmissing", client, WithPolicy(testPolicy()))
+
+	err = missing.Healthcheck(ctx)
+	assert.ErrorIs(t, err, ErrSchemaMissing)
+	assert.NotErrorIs(t, err, ErrUnavailable)
+}
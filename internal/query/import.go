@@ -0,0 +1,323 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// defaultImportBatchSize is the number of relationships sent in each message of an import.
+const defaultImportBatchSize = 1000
+
+// WithExperimentalClient sets the client for SpiceDB's experimental APIs, which ImportRelationships uses.
+func WithExperimentalClient(client pb.ExperimentalServiceClient) Option {
+	return func(e *engine) {
+		e.experimental = client
+	}
+}
+
+// ImportReport describes the relationships loaded by ImportRelationships.
+type ImportReport struct {
+	// Received is the number of relationships read from the channel.
+	Received int
+	// Sent is the number of relationships sent to SpiceDB.
+	Sent int
+	// Loaded is the number of relationships SpiceDB wrote, which is only set once the import completes.
+	Loaded uint64
+	// Rejected are the relationships which failed validation and were not sent.
+	Rejected []RejectedRelationship
+}
+
+// RejectedRelationship is a relationship an import did not send, along with the reason it was rejected.
+type RejectedRelationship struct {
+	Relationship types.Relationship
+	Err          error
+}
+
+// ImportOption is a functional option for importing relationships.
+type ImportOption func(opts *importOptions)
+
+type importOptions struct {
+	skipValidation bool
+	strict         bool
+	batchSize      int
+	progress       func(ImportReport)
+}
+
+// SkipImportValidation sends relationships without checking them against the policy, for imports
+// from a trusted source. SpiceDB still rejects relationships its schema does not allow, failing the import.
+func SkipImportValidation() ImportOption {
+	return func(opts *importOptions) {
+		opts.skipValidation = true
+	}
+}
+
+// StrictImport makes an import all or nothing: a relationship which fails validation ends the import
+// with an error, and none of the relationships are loaded. The relationship is still included in the
+// report's rejected relationships.
+func StrictImport() ImportOption {
+	return func(opts *importOptions) {
+		opts.strict = true
+	}
+}
+
+// WithImportBatchSize sets the number of relationships sent in each message of an import.
+func WithImportBatchSize(size int) ImportOption {
+	return func(opts *importOptions) {
+		if size > 0 {
+			opts.batchSize = size
+		}
+	}
+}
+
+// WithImportProgress sets a function called with the report so far each time a batch is sent.
+func WithImportProgress(fn func(ImportReport)) ImportOption {
+	return func(opts *importOptions) {
+		opts.progress = fn
+	}
+}
+
+// ImportRelationships streams the relationships read from rels to SpiceDB's bulk import API until
+// rels is closed, sending them in batches. SpiceDB loads the whole import in a single transaction,
+// so if any relationship already exists, or the context is canceled, none are loaded. Relationships
+// which fail validation are skipped and included in the report, so one bad relationship does not fail
+// the rest of the import, unless StrictImport or SkipImportValidation is given. A relationship's Caveat
+// is written as given, so the relationships of an export, including role metadata and the expiry of
+// role assignments, are restored as they were exported.
+// Observers are not notified and no events are published for imported relationships. The engine must
+// be created with WithExperimentalClient.
+func (e *engine) ImportRelationships(ctx context.Context, rels <-chan types.Relationship, opts ...ImportOption) (_ ImportReport, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ImportRelationships",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ImportRelationships", time.Now(), &err)
+
+	options := importOptions{
+		batchSize: defaultImportBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	report, err := e.importRelationships(ctx, rels, options)
+
+	span.SetAttributes(
+		attribute.Int("permissions.received", report.Received),
+		attribute.Int("permissions.rejected", len(report.Rejected)),
+		attribute.Int64("permissions.loaded", int64(report.Loaded)),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return report, err
+	}
+
+	return report, nil
+}
+
+func (e *engine) importRelationships(ctx context.Context, rels <-chan types.Relationship, options importOptions) (ImportReport, error) {
+	var report ImportReport
+
+	if err := e.checkWritable(); err != nil {
+		return report, err
+	}
+
+	if e.experimental == nil {
+		return report, ErrBulkImportUnavailable
+	}
+
+	// Canceling the stream aborts the import if it is not completed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := e.experimental.BulkImportRelationships(ctx)
+	if err != nil {
+		return report, newSpiceDBError(err)
+	}
+
+	batch := make([]*pb.Relationship, 0, options.batchSize)
+
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		req := &pb.BulkImportRelationshipsRequest{
+			Relationships: batch,
+		}
+
+		if err := stream.Send(req); err != nil {
+			// The server ended the stream, and the reason is returned when receiving its response.
+			if errors.Is(err, io.EOF) {
+				if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+					err = recvErr
+				}
+			}
+
+			return newSpiceDBError(err)
+		}
+
+		report.Sent += len(batch)
+		batch = make([]*pb.Relationship, 0, options.batchSize)
+
+		e.logger.Debugw("imported relationships", "namespace", e.namespace, "sent", report.Sent, "rejected", len(report.Rejected))
+
+		if options.progress != nil {
+			options.progress(report)
+		}
+
+		return nil
+	}
+
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case rel, ok := <-rels:
+			if !ok {
+				done = true
+
+				break
+			}
+
+			report.Received++
+
+			var err error
+
+			if !options.skipValidation {
+				err = e.validateImportRelationship(rel)
+			}
+
+			var spiceRel *pb.Relationship
+
+			if err == nil {
+				spiceRel, err = e.importRelationship(rel)
+			}
+
+			if err != nil {
+				report.Rejected = append(report.Rejected, RejectedRelationship{
+					Relationship: rel,
+					Err:          err,
+				})
+
+				// Returning cancels the stream, so nothing sent so far is loaded.
+				if options.strict {
+					return report, fmt.Errorf("relationship %d: %w", report.Received-1, err)
+				}
+
+				continue
+			}
+
+			batch = append(batch, spiceRel)
+
+			if len(batch) == options.batchSize {
+				if err := send(); err != nil {
+					return report, err
+				}
+			}
+		}
+	}
+
+	if err := send(); err != nil {
+		return report, err
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return report, fmt.Errorf("failed to complete import: %w", newSpiceDBError(err))
+	}
+
+	report.Loaded = resp.GetNumLoaded()
+
+	return report, nil
+}
+
+// validateImportRelationship checks a relationship being imported. Besides the relationships the policy
+// defines, a role's metadata relationship is accepted, and a relationship's caveat must be one its
+// relation may be conditioned on.
+func (e *engine) validateImportRelationship(rel types.Relationship) error {
+	if rel.Resource.Type == "role" && rel.Relation == roleMetadataRelation {
+		if rel.Subject != rel.Resource || rel.SubjectRelation != "" {
+			return fmt.Errorf("%w: role %s relation must have the role itself as its subject", ErrInvalidRelationship, roleMetadataRelation)
+		}
+
+		return e.validateImportCaveat(rel, roleMetadataCaveat)
+	}
+
+	if err := e.validateRelationship(rel); err != nil {
+		return err
+	}
+
+	var caveats []string
+
+	if resType, ok := e.resourceType(rel.Resource.Type); ok {
+		for _, typeRel := range resType.Relationships {
+			if typeRel.Relation == rel.Relation && typeRel.Caveat != "" {
+				caveats = append(caveats, typeRel.Caveat)
+			}
+		}
+	}
+
+	if rel.Resource.Type == "role" && rel.Relation == roleSubjectRelation {
+		caveats = append(caveats, assignmentExpiryCaveat)
+	}
+
+	return e.validateImportCaveat(rel, caveats...)
+}
+
+// validateImportCaveat checks the relationship's caveat, if it has one, is one of the given caveats.
+func (e *engine) validateImportCaveat(rel types.Relationship, caveats ...string) error {
+	if rel.Caveat == nil {
+		return nil
+	}
+
+	for _, name := range caveats {
+		if rel.Caveat.Name == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: relation %s may not be conditioned on caveat %s", ErrInvalidRelationship, rel.Relation, rel.Caveat.Name)
+}
+
+// importRelationship returns the SpiceDB relationship to import for the given relationship. A caveat
+// read from SpiceDB, such as by ExportRelationships, is written as it was read in place of any metadata,
+// so role metadata and assignment expiries are restored.
+func (e *engine) importRelationship(rel types.Relationship) (*pb.Relationship, error) {
+	spiceRel := e.relationshipsToUpdates([]types.Relationship{rel})[0].Relationship
+
+	if rel.Caveat == nil {
+		return spiceRel, nil
+	}
+
+	caveatContext, err := structpb.NewStruct(rel.Caveat.Context)
+	if err != nil {
+		return nil, fmt.Errorf("%w: caveat %s context: %s", ErrInvalidRelationship, rel.Caveat.Name, err)
+	}
+
+	spiceRel.OptionalCaveat = &pb.ContextualizedCaveat{
+		CaveatName: e.namespace + "/" + rel.Caveat.Name,
+		Context:    caveatContext,
+	}
+
+	return spiceRel, nil
+}
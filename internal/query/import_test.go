@@ -0,0 +1,211 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestImportRelationships(t *testing.T) {
+	namespace := "testimport"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	var valid []types.Relationship
+
+	for i := 0; i < 5; i++ {
+		childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+		require.NoError(t, err)
+
+		valid = append(valid, types.Relationship{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  parentRes,
+		})
+	}
+
+	invalid := types.Relationship{
+		Resource: parentRes,
+		Relation: "bogus",
+		Subject:  valid[0].Resource,
+	}
+
+	input := []types.Relationship{valid[0], valid[1], invalid, valid[2], valid[3], valid[4]}
+
+	rels := make(chan types.Relationship)
+
+	go func() {
+		defer close(rels)
+
+		for _, rel := range input {
+			rels <- rel
+		}
+	}()
+
+	var progress []int
+
+	report, err := e.ImportRelationships(ctx, rels, WithImportBatchSize(2), WithImportProgress(func(r ImportReport) {
+		progress = append(progress, r.Sent)
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, report.Received)
+	assert.Equal(t, 5, report.Sent)
+	assert.Equal(t, uint64(5), report.Loaded)
+	assert.Equal(t, []int{2, 4, 5}, progress)
+
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, invalid, report.Rejected[0].Relationship)
+	assert.ErrorIs(t, report.Rejected[0].Err, ErrInvalidRelationship)
+
+	readCtx := ContextWithConsistency(ctx, ConsistencyFullyConsistent)
+
+	for _, rel := range valid {
+		exists, err := e.HasRelationship(readCtx, rel, "")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	// Importing relationships which already exist fails the whole import.
+	rels = make(chan types.Relationship, 1)
+	rels <- valid[0]
+	close(rels)
+
+	_, err = e.ImportRelationships(ctx, rels)
+	assert.Error(t, err)
+}
+
+func TestImportRelationshipsStrict(t *testing.T) {
+	namespace := "testimport"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	valid := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	invalid := types.Relationship{
+		Resource: parentRes,
+		Relation: "bogus",
+		Subject:  childRes,
+	}
+
+	rels := make(chan types.Relationship, 2)
+	rels <- valid
+	rels <- invalid
+	close(rels)
+
+	// The valid relationship is sent before the invalid one is read, but is not loaded.
+	report, err := e.ImportRelationships(ctx, rels, StrictImport(), WithImportBatchSize(1))
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+	assert.Equal(t, 1, report.Sent)
+	assert.Zero(t, report.Loaded)
+
+	require.Len(t, report.Rejected, 1)
+	assert.Equal(t, invalid, report.Rejected[0].Relationship)
+
+	exists, err := e.HasRelationship(ContextWithConsistency(ctx, ConsistencyFullyConsistent), valid, "")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestImportRelationshipsUnavailable(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	rels := make(chan types.Relationship)
+	close(rels)
+
+	_, err := e.ImportRelationships(context.Background(), rels)
+	assert.ErrorIs(t, err, ErrBulkImportUnavailable)
+}
+
+func TestImportRelationshipCaveat(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil).(*engine)
+
+	role, err := e.NewResourceFromID(gidx.MustNewID("permrol"))
+	require.NoError(t, err)
+	user, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	expiry := &types.RelationshipCaveat{
+		Name:    assignmentExpiryCaveat,
+		Context: map[string]any{"expires_at": "2030-01-01T00:00:00Z"},
+	}
+
+	testCases := []struct {
+		name   string
+		rel    types.Relationship
+		errors bool
+	}{
+		{
+			name: "expiring assignment",
+			rel:  types.Relationship{Resource: role, Relation: roleSubjectRelation, Subject: user, Caveat: expiry},
+		},
+		{
+			name: "role metadata",
+			rel: types.Relationship{
+				Resource: role,
+				Relation: roleMetadataRelation,
+				Subject:  role,
+				Caveat:   &types.RelationshipCaveat{Name: roleMetadataCaveat, Context: map[string]any{"name": "admins"}},
+			},
+		},
+		{
+			name:   "role metadata on another subject",
+			rel:    types.Relationship{Resource: role, Relation: roleMetadataRelation, Subject: user},
+			errors: true,
+		},
+		{
+			name: "caveat not allowed on relation",
+			rel: types.Relationship{
+				Resource: role,
+				Relation: roleSubjectRelation,
+				Subject:  user,
+				Caveat:   &types.RelationshipCaveat{Name: roleMetadataCaveat},
+			},
+			errors: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := e.validateImportRelationship(tc.rel)
+			if tc.errors {
+				assert.ErrorIs(t, err, ErrInvalidRelationship)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			rel, err := e.importRelationship(tc.rel)
+			require.NoError(t, err)
+
+			assert.Equal(t, "test/"+tc.rel.Caveat.Name, rel.OptionalCaveat.CaveatName)
+			assert.Equal(t, tc.rel.Caveat.Context, rel.OptionalCaveat.Context.AsMap())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package query
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/status"
+)
+
+// logWrite logs each update of a successful write at debug level.
+func (e *engine) logWrite(req *pb.WriteRelationshipsRequest, queryToken string) {
+	for _, update := range req.Updates {
+		rel := update.GetRelationship()
+
+		e.logger.Debugw("wrote relationship",
+			"operation", update.GetOperation().String(),
+			"resource_type", rel.GetResource().GetObjectType(),
+			"resource", rel.GetResource().GetObjectId(),
+			"relation", rel.GetRelation(),
+			"subject_type", rel.GetSubject().GetObject().GetObjectType(),
+			"subject", rel.GetSubject().GetObject().GetObjectId(),
+			"subject_relation", rel.GetSubject().GetOptionalRelation(),
+			"zedtoken", queryToken,
+		)
+	}
+}
+
+// logDelete logs a successful delete by filter at debug level.
+func (e *engine) logDelete(filter *pb.RelationshipFilter, queryToken string) {
+	e.logger.Debugw("deleted relationships",
+		"operation", "DELETE_BY_FILTER",
+		"resource_type", filter.GetResourceType(),
+		"resource", filter.GetOptionalResourceId(),
+		"relation", filter.GetOptionalRelation(),
+		"subject_type", filter.GetOptionalSubjectFilter().GetSubjectType(),
+		"subject", filter.GetOptionalSubjectFilter().GetOptionalSubjectId(),
+		"zedtoken", queryToken,
+	)
+}
+
+// logSpiceDBError logs a failed SpiceDB request at error level along with its gRPC code.
+// Requests abandoned because the caller's context ended are not SpiceDB failures, so are
+// logged at debug level.
+func (e *engine) logSpiceDBError(ctx context.Context, err error) {
+	code := status.Code(err)
+
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		e.logger.Debugw("spicedb request abandoned", "code", code.String(), "error", err)
+
+		return
+	}
+
+	e.logger.Errorw("spicedb request failed", "code", code.String(), "error", err)
+}
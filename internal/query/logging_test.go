@@ -0,0 +1,84 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogging(t *testing.T) {
+	t.Parallel()
+
+	newEngine := func() (*engine, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.DebugLevel)
+
+		return &engine{logger: zap.New(core).Sugar()}, logs
+	}
+
+	t.Run("Write", func(t *testing.T) {
+		t.Parallel()
+
+		e, logs := newEngine()
+
+		e.logWrite(&pb.WriteRelationshipsRequest{
+			Updates: []*pb.RelationshipUpdate{
+				{
+					Operation: pb.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: &pb.Relationship{
+						Resource: &pb.ObjectReference{ObjectType: "ns/tenant", ObjectId: "tnntten-a"},
+						Relation: "parent",
+						Subject: &pb.SubjectReference{
+							Object: &pb.ObjectReference{ObjectType: "ns/tenant", ObjectId: "tnntten-b"},
+						},
+					},
+				},
+			},
+		}, "token")
+
+		entries := logs.All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "OPERATION_TOUCH", fields["operation"])
+		assert.Equal(t, "tnntten-a", fields["resource"])
+		assert.Equal(t, "parent", fields["relation"])
+		assert.Equal(t, "token", fields["zedtoken"])
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		t.Parallel()
+
+		e, logs := newEngine()
+
+		e.logSpiceDBError(context.Background(), status.Error(codes.Unavailable, "down"))
+
+		entries := logs.All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+		assert.Equal(t, "Unavailable", entries[0].ContextMap()["code"])
+	})
+
+	t.Run("Abandoned", func(t *testing.T) {
+		t.Parallel()
+
+		e, logs := newEngine()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		e.logSpiceDBError(ctx, status.Error(codes.Canceled, "canceled"))
+
+		entries := logs.All()
+		require.Len(t, entries, 1)
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	})
+}
@@ -0,0 +1,402 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.infratographer.com/x/gidx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// LookupResources returns every resource of the given type the subject may perform the action on.
+func (e *engine) LookupResources(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string) ([]types.Resource, error) {
+	resources, _, err := e.LookupResourcesPage(ctx, subject, action, resourceType, queryToken, PageOpts{})
+
+	return resources, err
+}
+
+// LookupResourcesPage returns a page of the resources of the given type the subject may perform the action on.
+// The current time is supplied as caveat context, so expired role assignments grant nothing. Resources
+// the subject may only act on given other caveat context are not returned.
+func (e *engine) LookupResourcesPage(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string, page PageOpts) (_ []types.Resource, _ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.LookupResources",
+		trace.WithAttributes(
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.String("permissions.action", action),
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.String("permissions.resource_type", resourceType),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "LookupResources", time.Now(), &err)
+
+	if err := e.validateAction(resourceType, action); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	reqContext, err := checkContext(nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	req := &pb.LookupResourcesRequest{
+		Consistency:        e.checkConsistency(ctx, queryToken),
+		ResourceObjectType: e.namespace + "/" + resourceType,
+		Permission:         action,
+		Subject: &pb.SubjectReference{
+			Object: resourceToSpiceDBRef(e.namespace, subject),
+		},
+		Context: reqContext,
+	}
+
+	if page.Limit > 0 {
+		req.OptionalLimit = uint32(page.Limit)
+
+		if page.Cursor != "" {
+			req.OptionalCursor = &pb.Cursor{
+				Token: page.Cursor,
+			}
+		}
+	}
+
+	var (
+		resources []types.Resource
+		cursor    string
+		results   int
+	)
+
+	// A stream which fails part way is read again from the start.
+	err = e.retry(ctx, true, func() error {
+		resources, cursor, results = nil, "", 0
+
+		stream, err := e.client.LookupResources(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+
+			switch err {
+			case nil:
+			case io.EOF:
+				return nil
+			default:
+				return err
+			}
+
+			results++
+			cursor = resp.GetAfterResultCursor().GetToken()
+
+			if resp.Permissionship != pb.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION {
+				continue
+			}
+
+			resource, err := e.resourceFromSpiceDBRef(&pb.ObjectReference{
+				ObjectType: req.ResourceObjectType,
+				ObjectId:   resp.ResourceObjectId,
+			})
+			if err != nil {
+				return err
+			}
+
+			resources = append(resources, resource)
+		}
+	})
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	// A short page means there is nothing left to read.
+	if page.Limit <= 0 || results < page.Limit {
+		cursor = ""
+	}
+
+	span.SetAttributes(attribute.Int("permissions.resources", len(resources)))
+
+	return resources, cursor, nil
+}
+
+// validateAction ensures the action is defined for the resource type.
+func (e *engine) validateAction(resourceType, action string) error {
+	resType, ok := e.resourceType(resourceType)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidType, resourceType)
+	}
+
+	for _, typeAction := range resType.Actions {
+		if typeAction.Name == action {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidAction, action)
+}
+
+// LookupSubjects returns every subject of the given type which may perform the action on the resource.
+// If the action is granted to all subjects of the type through a wildcard, the results include
+// types.WildcardResource(subjectType), which callers should treat as public access; subjects granted
+// the action individually are still returned alongside it. The current time is supplied as caveat
+// context, so subjects whose role assignment has expired are not returned, nor are subjects which may
+// only perform the action given other caveat context.
+func (e *engine) LookupSubjects(ctx context.Context, resource types.Resource, action string, subjectType string, queryToken string) (_ []types.Resource, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.LookupSubjects",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.String("permissions.action", action),
+				attribute.String("permissions.subject_type", subjectType),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "LookupSubjects", time.Now(), &err)
+
+	if err := e.validateAction(resource.Type, action); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	if _, ok := e.resourceType(subjectType); !ok {
+		err := fmt.Errorf("%w: %s", ErrInvalidType, subjectType)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	reqContext, err := checkContext(nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	req := &pb.LookupSubjectsRequest{
+		Consistency:       e.checkConsistency(ctx, queryToken),
+		Resource:          resourceToSpiceDBRef(e.namespace, resource),
+		Permission:        action,
+		SubjectObjectType: e.namespace + "/" + subjectType,
+		WildcardOption:    pb.LookupSubjectsRequest_WILDCARD_OPTION_INCLUDE_WILDCARDS,
+		Context:           reqContext,
+	}
+
+	var subjects []types.Resource
+
+	// A stream which fails part way is read again from the start.
+	err = e.retry(ctx, true, func() error {
+		subjects = nil
+
+		stream, err := e.client.LookupSubjects(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for {
+			resp, err := stream.Recv()
+
+			switch err {
+			case nil:
+			case io.EOF:
+				return nil
+			default:
+				return err
+			}
+
+			if resp.Subject.GetPermissionship() != pb.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION {
+				continue
+			}
+
+			subject, err := e.resourceFromSpiceDBRef(&pb.ObjectReference{
+				ObjectType: req.SubjectObjectType,
+				ObjectId:   resp.Subject.SubjectObjectId,
+			})
+			if err != nil {
+				return err
+			}
+
+			subjects = append(subjects, subject)
+		}
+	})
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.subjects", len(subjects)))
+
+	return subjects, nil
+}
+
+// ListSubjectPermissionsInSubtree returns the actions the subject may perform on the root resource and on
+// every resource beneath it, keyed by resource ID. Resources beneath the root are found by following the
+// relations their actions are inherited through, such as a tenant's parent or a load balancer's owner, back
+// to the root. Rather than checking every action on every resource, each action of each resource type in the
+// subtree is looked up once with LookupResources and the results are limited to the subtree. Resources the
+// subject may perform no action on are not included, and each resource's actions are sorted.
+func (e *engine) ListSubjectPermissionsInSubtree(ctx context.Context, subject, root types.Resource, queryToken string) (_ map[gidx.PrefixedID][]string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ListSubjectPermissionsInSubtree",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(root),
+				attribute.Stringer("permissions.actor", subject.ID),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ListSubjectPermissionsInSubtree", time.Now(), &err)
+
+	subtree, err := e.subtreeResources(ctx, root, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	out := make(map[gidx.PrefixedID][]string)
+
+	for typeName, ids := range subtree {
+		resType, _ := e.resourceType(typeName)
+		seen := make(map[string]struct{}, len(resType.Actions))
+
+		for _, action := range resType.Actions {
+			if _, ok := seen[action.Name]; ok {
+				continue
+			}
+
+			seen[action.Name] = struct{}{}
+
+			resources, err := e.LookupResources(ctx, subject, action.Name, typeName, queryToken)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return nil, err
+			}
+
+			for _, res := range resources {
+				if _, ok := ids[res.ID]; ok {
+					out[res.ID] = append(out[res.ID], action.Name)
+				}
+			}
+		}
+	}
+
+	for _, actions := range out {
+		sort.Strings(actions)
+	}
+
+	span.SetAttributes(
+		attribute.Int("permissions.subtree_resources", subtreeSize(subtree)),
+		attribute.Int("permissions.resources", len(out)),
+	)
+
+	return out, nil
+}
+
+// subtreeResources returns the IDs of the root and of the resources beneath it, by resource type. A resource
+// is beneath another if a relation its actions are inherited through has the other as its subject.
+func (e *engine) subtreeResources(ctx context.Context, root types.Resource, queryToken string) (map[string]map[gidx.PrefixedID]struct{}, error) {
+	if _, ok := e.resourceType(root.Type); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidType, root.Type)
+	}
+
+	subtree := map[string]map[gidx.PrefixedID]struct{}{
+		root.Type: {root.ID: {}},
+	}
+
+	for queue := []types.Resource{root}; len(queue) != 0; queue = queue[1:] {
+		current := queue[0]
+
+		relTypes, _ := e.subjectRelations(current.Type)
+
+		for relation, typeNames := range relTypes {
+			for _, typeName := range typeNames {
+				resType, ok := e.resourceType(typeName)
+				if !ok || !containsString(inheritedRelations(resType), relation) {
+					continue
+				}
+
+				relationships, err := e.readRelationships(ctx, &pb.RelationshipFilter{
+					ResourceType:     e.namespace + "/" + typeName,
+					OptionalRelation: relation,
+					OptionalSubjectFilter: &pb.SubjectFilter{
+						SubjectType:       e.namespace + "/" + current.Type,
+						OptionalSubjectId: current.ID.String(),
+					},
+				}, queryToken)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, rel := range relationships {
+					child, err := e.resourceFromSpiceDBRef(rel.Resource)
+					if err != nil {
+						return nil, err
+					}
+
+					if _, ok := subtree[child.Type][child.ID]; ok {
+						continue
+					}
+
+					if subtree[child.Type] == nil {
+						subtree[child.Type] = make(map[gidx.PrefixedID]struct{})
+					}
+
+					subtree[child.Type][child.ID] = struct{}{}
+					queue = append(queue, child)
+				}
+			}
+		}
+	}
+
+	return subtree, nil
+}
+
+// subtreeSize returns the number of resources in the subtree.
+func subtreeSize(subtree map[string]map[gidx.PrefixedID]struct{}) int {
+	var size int
+
+	for _, ids := range subtree {
+		size += len(ids)
+	}
+
+	return size
+}
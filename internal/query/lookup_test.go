@@ -0,0 +1,316 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/testingx"
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestLookupResources(t *testing.T) {
+	namespace := "testlookup"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	var (
+		allowed    []types.Resource
+		queryToken string
+	)
+
+	for i := 0; i < 3; i++ {
+		tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+		require.NoError(t, err)
+
+		actions := []string{"loadbalancer_get"}
+
+		// The last tenant's role does not grant the action being looked up.
+		if i == 2 {
+			actions = []string{"loadbalancer_update"}
+		} else {
+			allowed = append(allowed, tenRes)
+		}
+
+		role, _, err := e.CreateRole(ctx, tenRes, actions)
+		require.NoError(t, err)
+
+		queryToken, err = e.AssignSubjectRole(ctx, subjRes, role)
+		require.NoError(t, err)
+	}
+
+	type testInput struct {
+		action       string
+		resourceType string
+	}
+
+	testCases := []testingx.TestCase[testInput, []types.Resource]{
+		{
+			Name: "InvalidType",
+			Input: testInput{
+				action:       "loadbalancer_get",
+				resourceType: "unknown",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidType)
+			},
+		},
+		{
+			Name: "InvalidAction",
+			Input: testInput{
+				action:       "bad_action",
+				resourceType: "tenant",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name: "Success",
+			Input: testInput{
+				action:       "loadbalancer_get",
+				resourceType: "tenant",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				require.NoError(t, res.Err)
+				assert.ElementsMatch(t, allowed, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input testInput) testingx.TestResult[[]types.Resource] {
+		resources, err := e.LookupResources(ctx, subjRes, input.action, input.resourceType, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: resources,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestLookupResourcesPage(t *testing.T) {
+	namespace := "testlookup"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	var queryToken string
+
+	for i := 0; i < 3; i++ {
+		tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+		require.NoError(t, err)
+
+		role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+		require.NoError(t, err)
+
+		queryToken, err = e.AssignSubjectRole(ctx, subjRes, role)
+		require.NoError(t, err)
+	}
+
+	firstPage, cursor, err := e.LookupResourcesPage(ctx, subjRes, "loadbalancer_get", "tenant", queryToken, PageOpts{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, firstPage, 2)
+	require.NotEmpty(t, cursor)
+
+	secondPage, cursor, err := e.LookupResourcesPage(ctx, subjRes, "loadbalancer_get", "tenant", queryToken, PageOpts{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, secondPage, 1)
+	assert.Empty(t, cursor)
+
+	assert.NotContains(t, firstPage, secondPage[0])
+}
+
+func TestLookupSubjects(t *testing.T) {
+	namespace := "testlookup"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	publicTenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	publicRole, _, err := e.CreateRole(ctx, publicTenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+	queryToken, err := e.AssignSubjectRole(ctx, types.WildcardResource("user"), publicRole)
+	require.NoError(t, err)
+
+	type testInput struct {
+		resource    types.Resource
+		action      string
+		subjectType string
+	}
+
+	testCases := []testingx.TestCase[testInput, []types.Resource]{
+		{
+			Name: "InvalidSubjectType",
+			Input: testInput{
+				resource:    tenRes,
+				action:      "loadbalancer_get",
+				subjectType: "unknown",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidType)
+			},
+		},
+		{
+			Name: "Subjects",
+			Input: testInput{
+				resource:    tenRes,
+				action:      "loadbalancer_get",
+				subjectType: "user",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []types.Resource{subjRes}, res.Success)
+			},
+		},
+		{
+			Name: "OtherSubjectType",
+			Input: testInput{
+				resource:    tenRes,
+				action:      "loadbalancer_get",
+				subjectType: "client",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				require.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name: "Public",
+			Input: testInput{
+				resource:    publicTenRes,
+				action:      "loadbalancer_get",
+				subjectType: "user",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []types.Resource{types.WildcardResource("user")}, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input testInput) testingx.TestResult[[]types.Resource] {
+		subjects, err := e.LookupSubjects(ctx, input.resource, input.action, input.subjectType, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: subjects,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestListSubjectPermissionsInSubtree(t *testing.T) {
+	namespace := "testsubtree"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	rootRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	lbRes, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  rootRes,
+		},
+		{
+			Resource: lbRes,
+			Relation: "owner",
+			Subject:  childRes,
+		},
+	})
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, rootRes, []string{"loadbalancer_get", "loadbalancer_update"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	// The subject's access to a tenant outside the subtree is not included.
+	otherRole, _, err := e.CreateRole(ctx, otherRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, otherRole)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, map[gidx.PrefixedID][]string]{
+		{
+			Name:  "InvalidType",
+			Input: types.Resource{Type: "unknown", ID: rootRes.ID},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[map[gidx.PrefixedID][]string]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidType)
+			},
+		},
+		{
+			Name:  "Root",
+			Input: rootRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[map[gidx.PrefixedID][]string]) {
+				require.NoError(t, res.Err)
+
+				expected := map[gidx.PrefixedID][]string{
+					rootRes.ID:  {"loadbalancer_get", "loadbalancer_update"},
+					childRes.ID: {"loadbalancer_get", "loadbalancer_update"},
+					lbRes.ID:    {"loadbalancer_get", "loadbalancer_update"},
+				}
+
+				assert.Equal(t, expected, res.Success)
+			},
+		},
+		{
+			Name:  "Child",
+			Input: childRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[map[gidx.PrefixedID][]string]) {
+				require.NoError(t, res.Err)
+
+				expected := map[gidx.PrefixedID][]string{
+					childRes.ID: {"loadbalancer_get", "loadbalancer_update"},
+					lbRes.ID:    {"loadbalancer_get", "loadbalancer_update"},
+				}
+
+				assert.Equal(t, expected, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, root types.Resource) testingx.TestResult[map[gidx.PrefixedID][]string] {
+		permissions, err := e.ListSubjectPermissionsInSubtree(ctx, subjRes, root, queryToken)
+
+		return testingx.TestResult[map[gidx.PrefixedID][]string]{
+			Success: permissions,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
@@ -0,0 +1,188 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "permissions"
+	metricsSubsystem = "engine"
+
+	errorClassUnavailable      = "unavailable"
+	errorClassDeadlineExceeded = "deadline_exceeded"
+	errorClassPermissionDenied = "permission_denied"
+	errorClassBackend          = "backend"
+	errorClassNotFound         = "not_found"
+	errorClassInvalid          = "invalid_argument"
+	errorClassInternal         = "internal"
+)
+
+// invalidArgumentErrors are the errors returned for a request which can never succeed as made.
+var invalidArgumentErrors = []error{
+	ErrInvalidReference,
+	ErrInvalidNamespace,
+	ErrUnknownResourceType,
+	ErrInvalidID,
+	ErrResourceTypeExists,
+	ErrInvalidType,
+	ErrInvalidRelationship,
+	ErrInvalidAction,
+	ErrInvalidActionName,
+	ErrInvalidRoleName,
+	ErrInvalidRoleDescription,
+	ErrInvalidCaveatContext,
+	ErrInvalidRoleParent,
+	ErrInvalidRoleOwner,
+	ErrTransactionTooLarge,
+	ErrInvalidCursor,
+	ErrResourceCycle,
+	ErrTooManyParents,
+	ErrRoleHasTooManyResources,
+	ErrRoleConflict,
+	ErrRoleWithoutActions,
+	ErrRoleDeleted,
+}
+
+// engineMetrics are the Prometheus collectors engine operations are recorded with.
+type engineMetrics struct {
+	duration    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	checks      *prometheus.CounterVec
+	cacheHits   prometheus.Counter
+	cacheMisses prometheus.Counter
+}
+
+// WithMetrics registers the engine's Prometheus metrics with the given registerer:
+// permissions_engine_operation_duration_seconds, a histogram of operation duration by method,
+// permissions_engine_operation_errors_total, a counter of failed operations by method and error class,
+// and permissions_engine_checks_total, a counter of permission check outcomes by method and outcome,
+// from which the allowed to denied ratio can be computed. Checks answered by the check cache are counted
+// the same as any other, and hits and misses of the cache itself are counted by
+// permissions_check_cache_hits_total and permissions_check_cache_misses_total. Collectors already
+// registered with the registerer are reused, so multiple engines may share one registerer.
+func WithMetrics(registerer prometheus.Registerer) Option {
+	return func(e *engine) {
+		e.metrics = newEngineMetrics(registerer)
+	}
+}
+
+func newEngineMetrics(registerer prometheus.Registerer) *engineMetrics {
+	return &engineMetrics{
+		duration: registerCollector(registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of permissions engine operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"})),
+		errors: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "operation_errors_total",
+			Help:      "Permissions engine operations which failed, by error class.",
+		}, []string{"method", "class"})),
+		checks: registerCollector(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubsystem,
+			Name:      "checks_total",
+			Help:      "Permission checks, by outcome.",
+		}, []string{"method", "outcome"})),
+		cacheHits: registerCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "check_cache",
+			Name:      "hits_total",
+			Help:      "Permission checks answered from the check cache.",
+		})),
+		cacheMisses: registerCollector(registerer, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "check_cache",
+			Name:      "misses_total",
+			Help:      "Permission checks not found in the check cache.",
+		})),
+	}
+}
+
+// registerCollector registers the collector, returning the existing collector if an identical one
+// was already registered.
+func registerCollector[C prometheus.Collector](registerer prometheus.Registerer, collector C) C {
+	if err := registerer.Register(collector); err != nil {
+		var existing prometheus.AlreadyRegisteredError
+
+		if errors.As(err, &existing) {
+			if c, ok := existing.ExistingCollector.(C); ok {
+				return c
+			}
+		}
+
+		panic(err)
+	}
+
+	return collector
+}
+
+// observe records the duration of the engine operation started at start and, if it failed, its error.
+// It is deferred by each engine method with a pointer to its returned error. A denied permission check
+// is an outcome rather than a failure, so ErrActionNotAssigned is not counted as an error.
+func (e *engine) observe(_ context.Context, method string, start time.Time, err *error) {
+	if e.metrics == nil {
+		return
+	}
+
+	e.metrics.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+
+	if *err != nil && !errors.Is(*err, ErrActionNotAssigned) {
+		e.metrics.errors.WithLabelValues(method, errorClass(*err)).Inc()
+	}
+}
+
+// recordCheck records the outcome of a permission check.
+func (e *engine) recordCheck(_ context.Context, method, outcome string) {
+	if e.metrics == nil {
+		return
+	}
+
+	e.metrics.checks.WithLabelValues(method, outcome).Inc()
+}
+
+// recordCacheLookup records whether a permission check was answered from the check cache.
+func (e *engine) recordCacheLookup(_ context.Context, hit bool) {
+	if e.metrics == nil {
+		return
+	}
+
+	if hit {
+		e.metrics.cacheHits.Inc()
+	} else {
+		e.metrics.cacheMisses.Inc()
+	}
+}
+
+// errorClass returns the class an error is counted under.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, ErrUnavailable):
+		return errorClassUnavailable
+	case errors.Is(err, ErrDeadlineExceeded):
+		return errorClassDeadlineExceeded
+	case errors.Is(err, ErrPermissionDenied):
+		return errorClassPermissionDenied
+	case errors.Is(err, ErrRoleNotFound):
+		return errorClassNotFound
+	}
+
+	for _, invalid := range invalidArgumentErrors {
+		if errors.Is(err, invalid) {
+			return errorClassInvalid
+		}
+	}
+
+	if _, ok := StatusFromError(err); ok {
+		return errorClassBackend
+	}
+
+	return errorClassInternal
+}
@@ -0,0 +1,123 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+
+	e := NewEngine("test", nil, WithMetrics(registry), WithCheckCache(10, time.Minute)).(*engine)
+
+	ctx := context.Background()
+
+	observe := func(method string, err error) {
+		e.observe(ctx, method, time.Now(), &err)
+	}
+
+	observe("CreateRole", nil)
+	observe("CreateRole", fmt.Errorf("failed to create role: %w", ErrUnavailable))
+	observe("GetRole", ErrRoleNotFound)
+	observe("SubjectHasPermission", ErrActionNotAssigned)
+
+	assert.Equal(t, uint64(2), observationCount(t, e.metrics.duration.WithLabelValues("CreateRole")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics.errors.WithLabelValues("CreateRole", errorClassUnavailable)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics.errors.WithLabelValues("GetRole", errorClassNotFound)))
+	// A denied check is not an error.
+	assert.Equal(t, uint64(1), observationCount(t, e.metrics.duration.WithLabelValues("SubjectHasPermission")))
+	assert.Zero(t, testutil.ToFloat64(e.metrics.errors.WithLabelValues("SubjectHasPermission", errorClassInternal)))
+
+	// Checks answered by the check cache are counted.
+	subject, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	resource, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+
+	ctx = ContextWithQueryToken(ctx, "token")
+
+	key, ok := e.checkCacheKeyFor(ctx, subject, "loadbalancer_get", resource)
+	require.True(t, ok)
+
+	e.checkCache.set(key, true)
+
+	require.NoError(t, e.SubjectHasPermission(ctx, subject, "loadbalancer_get", resource))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics.cacheHits))
+	assert.Equal(t, float64(1), testutil.ToFloat64(e.metrics.checks.WithLabelValues("SubjectHasPermission", outcomeAllowed)))
+
+	// Engines sharing a registerer share its collectors.
+	other := NewEngine("test", nil, WithMetrics(registry)).(*engine)
+	assert.Same(t, e.metrics.checks, other.metrics.checks)
+}
+
+// observationCount returns the number of observations made by a histogram.
+func observationCount(t *testing.T, observer prometheus.Observer) uint64 {
+	t.Helper()
+
+	var m dto.Metric
+
+	require.NoError(t, observer.(prometheus.Metric).Write(&m))
+
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		err      error
+		expClass string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "Unavailable",
+			err:      fmt.Errorf("read: %w", ErrUnavailable),
+			expClass: errorClassUnavailable,
+		},
+		{
+			name:     "DeadlineExceeded",
+			err:      ErrDeadlineExceeded,
+			expClass: errorClassDeadlineExceeded,
+		},
+		{
+			name:     "NotFound",
+			err:      ErrRoleNotFound,
+			expClass: errorClassNotFound,
+		},
+		{
+			name:     "InvalidArgument",
+			err:      fmt.Errorf("%w: bad_action", ErrInvalidAction),
+			expClass: errorClassInvalid,
+		},
+		{
+			name:     "Internal",
+			err:      errors.New("something else"),
+			expClass: errorClassInternal,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expClass, errorClass(tc.err))
+		})
+	}
+}
@@ -3,6 +3,7 @@ package mock
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go.infratographer.com/permissions-api/internal/iapl"
 	"go.infratographer.com/permissions-api/internal/query"
@@ -30,11 +31,66 @@ func (e *Engine) AssignSubjectRole(ctx context.Context, subject types.Resource,
 	return "", nil
 }
 
+// AssignSubjectRoles does nothing but satisfies the Engine interface.
+func (e *Engine) AssignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (string, error) {
+	return "", nil
+}
+
+// AssignSubjectRolesWithReport returns nothing but satisfies the Engine interface.
+func (e *Engine) AssignSubjectRolesWithReport(ctx context.Context, subjects []types.Resource, role types.Role, opts ...query.AssignOption) (query.AssignmentReport, error) {
+	return query.AssignmentReport{}, nil
+}
+
+// AssignSubjectRoleUntil does nothing but satisfies the Engine interface.
+func (e *Engine) AssignSubjectRoleUntil(ctx context.Context, subject types.Resource, role types.Role, expiresAt time.Time) (string, error) {
+	return "", nil
+}
+
+// UnassignSubjectRoles does nothing but satisfies the Engine interface.
+func (e *Engine) UnassignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (string, error) {
+	return "", nil
+}
+
+// FilterResourcesByPermission returns nothing but satisfies the Engine interface.
+func (e *Engine) FilterResourcesByPermission(ctx context.Context, subject types.Resource, action string, resources []types.Resource, queryToken string) ([]types.Resource, error) {
+	return nil, nil
+}
+
+// SubjectHasAnyPermission returns nothing but satisfies the Engine interface.
+func (e *Engine) SubjectHasAnyPermission(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (bool, error) {
+	return false, nil
+}
+
+// SubjectHasAnyAccess returns nothing but satisfies the Engine interface.
+func (e *Engine) SubjectHasAnyAccess(ctx context.Context, subject types.Resource, resource types.Resource, queryToken string) (bool, error) {
+	return false, nil
+}
+
+// SubjectHasAllPermissions returns nothing but satisfies the Engine interface.
+func (e *Engine) SubjectHasAllPermissions(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (bool, error) {
+	return false, nil
+}
+
+// SubjectsWithPermission returns nothing but satisfies the Engine interface.
+func (e *Engine) SubjectsWithPermission(ctx context.Context, subjects []types.Resource, action string, resource types.Resource, queryToken string) ([]types.Resource, error) {
+	return nil, nil
+}
+
 // UnassignSubjectRole does nothing but satisfies the Engine interface.
 func (e *Engine) UnassignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (string, error) {
 	return "", nil
 }
 
+// ImportRelationships returns nothing but satisfies the Engine interface.
+func (e *Engine) ImportRelationships(ctx context.Context, rels <-chan types.Relationship, opts ...query.ImportOption) (query.ImportReport, error) {
+	return query.ImportReport{}, nil
+}
+
+// ExportRelationships returns nothing but satisfies the Engine interface.
+func (e *Engine) ExportRelationships(ctx context.Context, queryToken string) (<-chan types.Relationship, *query.ExportReport, error) {
+	return nil, nil, nil
+}
+
 // CreateRelationships does nothing but satisfies the Engine interface.
 func (e *Engine) CreateRelationships(ctx context.Context, rels []types.Relationship) (string, error) {
 	args := e.Called()
@@ -42,8 +98,27 @@ func (e *Engine) CreateRelationships(ctx context.Context, rels []types.Relations
 	return args.String(0), args.Error(1)
 }
 
+// CreateRelationshipsStrict does nothing but satisfies the Engine interface.
+func (e *Engine) CreateRelationshipsStrict(ctx context.Context, rels []types.Relationship) (string, error) {
+	args := e.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+// UpsertRelationships does nothing but satisfies the Engine interface.
+func (e *Engine) UpsertRelationships(ctx context.Context, rels []types.Relationship) (string, error) {
+	args := e.Called()
+
+	return args.String(0), args.Error(1)
+}
+
+// RoleTuples returns nothing but satisfies the Engine interface.
+func (e *Engine) RoleTuples(owner types.Resource, actions []string) ([]types.Relationship, error) {
+	return nil, nil
+}
+
 // CreateRole creates a Role object and does not persist it anywhere.
-func (e *Engine) CreateRole(ctx context.Context, res types.Resource, actions []string) (types.Role, string, error) {
+func (e *Engine) CreateRole(ctx context.Context, res types.Resource, actions []string, opts ...query.RoleOption) (types.Role, string, error) {
 	// Copy actions instead of using the given slice
 	outActions := make([]string, len(actions))
 
@@ -54,26 +129,102 @@ func (e *Engine) CreateRole(ctx context.Context, res types.Resource, actions []s
 		Actions: outActions,
 	}
 
+	for _, opt := range opts {
+		if err := opt(&role); err != nil {
+			return types.Role{}, "", err
+		}
+	}
+
 	return role, "", nil
 }
 
+// BootstrapTenant returns nothing but satisfies the Engine interface.
+func (e *Engine) BootstrapTenant(ctx context.Context, tenant types.Resource) ([]types.Role, string, error) {
+	return nil, "", nil
+}
+
+// CreateRoles creates a Role object for each spec and does not persist them anywhere.
+func (e *Engine) CreateRoles(ctx context.Context, owner types.Resource, roleSpecs []query.RoleSpec) ([]types.Role, string, error) {
+	roles := make([]types.Role, len(roleSpecs))
+
+	for i, spec := range roleSpecs {
+		var opts []query.RoleOption
+
+		if spec.Name != "" {
+			opts = append(opts, query.WithRoleName(spec.Name))
+		}
+
+		if spec.Description != "" {
+			opts = append(opts, query.WithRoleDescription(spec.Description))
+		}
+
+		if len(spec.Parents) != 0 {
+			opts = append(opts, query.WithRoleParents(spec.Parents...))
+		}
+
+		role, _, err := e.CreateRole(ctx, owner, spec.Actions, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+
+		roles[i] = role
+	}
+
+	return roles, "", nil
+}
+
 // GetRole returns nothing but satisfies the Engine interface.
 func (e *Engine) GetRole(ctx context.Context, roleResource types.Resource, queryToken string) (types.Role, error) {
 	return types.Role{}, nil
 }
 
+// ExpandRole returns nothing but satisfies the Engine interface.
+func (e *Engine) ExpandRole(ctx context.Context, roleResource types.Resource, queryToken string) (*query.PermissionTree, error) {
+	return nil, nil
+}
+
+// ResolvePermissionPath returns nothing but satisfies the Engine interface.
+func (e *Engine) ResolvePermissionPath(ctx context.Context, subject types.Resource, action string, resource types.Resource) (*query.Path, error) {
+	return nil, nil
+}
+
 // GetRoleResource returns nothing but satisfies the Engine interface.
 func (e *Engine) GetRoleResource(ctx context.Context, roleResource types.Resource, queryToken string) (types.Resource, error) {
 	return types.Resource{}, nil
 }
 
+// GetRoleWithAssignments returns nothing but satisfies the Engine interface.
+func (e *Engine) GetRoleWithAssignments(ctx context.Context, roleResource types.Resource, queryToken string) (query.RoleDetail, error) {
+	return query.RoleDetail{}, nil
+}
+
 // ListAssignments returns nothing but satisfies the Engine interface.
 func (e *Engine) ListAssignments(ctx context.Context, role types.Role, queryToken string) ([]types.Resource, error) {
 	return nil, nil
 }
 
+// CountAssignments returns nothing but satisfies the Engine interface.
+func (e *Engine) CountAssignments(ctx context.Context, role types.Role, queryToken string) (int, error) {
+	return 0, nil
+}
+
+// HasRelationship returns nothing but satisfies the Engine interface.
+func (e *Engine) HasRelationship(ctx context.Context, rel types.Relationship, queryToken string) (bool, error) {
+	return false, nil
+}
+
 // ListRelationshipsFrom returns nothing but satisfies the Engine interface.
-func (e *Engine) ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string) ([]types.Relationship, error) {
+func (e *Engine) ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string, opts ...query.RelationshipFilterOption) ([]types.Relationship, error) {
+	return nil, nil
+}
+
+// ListRelationshipsFromPage returns nothing but satisfies the Engine interface.
+func (e *Engine) ListRelationshipsFromPage(ctx context.Context, resource types.Resource, queryToken string, page query.PageOpts, opts ...query.RelationshipFilterOption) ([]types.Relationship, string, error) {
+	return nil, "", nil
+}
+
+// ListAncestors returns nothing but satisfies the Engine interface.
+func (e *Engine) ListAncestors(ctx context.Context, resource types.Resource, queryToken string) ([]types.Resource, error) {
 	return nil, nil
 }
 
@@ -82,11 +233,41 @@ func (e *Engine) ListRelationshipsTo(ctx context.Context, resource types.Resourc
 	return nil, nil
 }
 
+// ListEffectiveRoles returns nothing but satisfies the Engine interface.
+func (e *Engine) ListEffectiveRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error) {
+	return nil, nil
+}
+
 // ListRoles returns nothing but satisfies the Engine interface.
-func (e *Engine) ListRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error) {
+func (e *Engine) ListRoles(ctx context.Context, resource types.Resource, queryToken string, opts ...query.ListRolesOption) ([]types.Role, error) {
+	return nil, nil
+}
+
+// ListRolesPage returns nothing but satisfies the Engine interface.
+func (e *Engine) ListRolesPage(ctx context.Context, resource types.Resource, queryToken string, page query.PageOpts, opts ...query.ListRolesOption) ([]types.Role, string, error) {
+	return nil, "", nil
+}
+
+// ListAllRoles returns nothing but satisfies the Engine interface.
+func (e *Engine) ListAllRoles(ctx context.Context, queryToken string) ([]types.Role, error) {
 	return nil, nil
 }
 
+// GarbageCollect does nothing but satisfies the Engine interface.
+func (e *Engine) GarbageCollect(ctx context.Context) (query.GCReport, error) {
+	return query.GCReport{}, nil
+}
+
+// ListRolesForSubject returns nothing but satisfies the Engine interface.
+func (e *Engine) ListRolesForSubject(ctx context.Context, subject types.Resource, queryToken string) ([]types.Role, error) {
+	return nil, nil
+}
+
+// SubjectHasRole returns nothing but satisfies the Engine interface.
+func (e *Engine) SubjectHasRole(ctx context.Context, subject types.Resource, role types.Role, queryToken string) (bool, error) {
+	return false, nil
+}
+
 // DeleteRelationships does nothing but satisfies the Engine interface.
 func (e *Engine) DeleteRelationships(ctx context.Context, relationships ...types.Relationship) (string, error) {
 	args := e.Called()
@@ -101,13 +282,81 @@ func (e *Engine) DeleteRole(ctx context.Context, roleResource types.Resource, qu
 	return args.String(0), args.Error(1)
 }
 
-// DeleteResourceRelationships does nothing but satisfies the Engine interface.
-func (e *Engine) DeleteResourceRelationships(ctx context.Context, resource types.Resource) (string, error) {
+// PurgeRole does nothing but satisfies the Engine interface.
+func (e *Engine) PurgeRole(ctx context.Context, roleResource types.Resource, queryToken string) (string, error) {
+	return "", nil
+}
+
+// DeleteRoles does nothing but satisfies the Engine interface.
+func (e *Engine) DeleteRoles(ctx context.Context, roleResources []types.Resource) (string, error) {
 	args := e.Called()
 
 	return args.String(0), args.Error(1)
 }
 
+// MoveRole returns nothing but satisfies the Engine interface.
+func (e *Engine) MoveRole(ctx context.Context, role types.Role, newOwner types.Resource) (string, error) {
+	return "", nil
+}
+
+// UpdateRole returns a Role object with the given actions and does not persist it anywhere.
+func (e *Engine) UpdateRole(ctx context.Context, roleResource types.Resource, actions []string) (types.Role, string, error) {
+	outActions := make([]string, len(actions))
+
+	copy(outActions, actions)
+
+	role := types.Role{
+		ID:      roleResource.ID,
+		Actions: outActions,
+	}
+
+	return role, "", nil
+}
+
+// AddRoleAction does nothing but satisfies the Engine interface.
+func (e *Engine) AddRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error) {
+	return "", nil
+}
+
+// RemoveRoleAction does nothing but satisfies the Engine interface.
+func (e *Engine) RemoveRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error) {
+	return "", nil
+}
+
+// DeleteResourceRelationships does nothing but satisfies the Engine interface.
+func (e *Engine) DeleteResourceRelationships(ctx context.Context, resource types.Resource) (int, string, error) {
+	args := e.Called()
+
+	return args.Int(0), args.String(1), args.Error(2)
+}
+
+// DeleteRelationshipsMatching does nothing but satisfies the Engine interface.
+func (e *Engine) DeleteRelationshipsMatching(ctx context.Context, resource types.Resource, opts ...query.RelationshipFilterOption) (int, string, error) {
+	args := e.Called()
+
+	return args.Int(0), args.String(1), args.Error(2)
+}
+
+// QualifyType returns the resource type prefixed by the mock's Namespace.
+func (e *Engine) QualifyType(resourceType string) string {
+	return e.Namespace + "/" + resourceType
+}
+
+// Close does nothing but satisfies the Engine interface.
+func (e *Engine) Close() error {
+	return nil
+}
+
+// NewResourceFromIDString parses the given ID and creates a new resource object based on it.
+func (e *Engine) NewResourceFromIDString(s string) (types.Resource, error) {
+	id, err := gidx.Parse(s)
+	if err != nil {
+		return types.Resource{}, err
+	}
+
+	return e.NewResourceFromID(id)
+}
+
 // NewResourceFromID creates a new resource object based on the given ID.
 func (e *Engine) NewResourceFromID(id gidx.PrefixedID) (types.Resource, error) {
 	prefix := id.Prefix()
@@ -153,9 +402,177 @@ func (e *Engine) GetResourceType(name string) *types.ResourceType {
 	return nil
 }
 
+// ResourceTypes returns the resource types of the default policy.
+func (e *Engine) ResourceTypes() []types.ResourceType {
+	if e.schema == nil {
+		e.schema = iapl.DefaultPolicy().Schema()
+	}
+
+	return e.schema
+}
+
 // SubjectHasPermission returns nil to satisfy the Engine interface.
 func (e *Engine) SubjectHasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) error {
 	e.Called()
 
 	return nil
 }
+
+// SubjectHasPermissionAt returns nil to satisfy the Engine interface.
+func (e *Engine) SubjectHasPermissionAt(ctx context.Context, subject types.Resource, action string, resource types.Resource, queryToken string) error {
+	e.Called()
+
+	return nil
+}
+
+// HasPermission returns true to satisfy the Engine interface.
+func (e *Engine) HasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) (bool, error) {
+	e.Called()
+
+	return true, nil
+}
+
+// CheckPermissionWithReason returns an allowed decision to satisfy the Engine interface.
+func (e *Engine) CheckPermissionWithReason(ctx context.Context, subject types.Resource, action string, resource types.Resource) (query.PermissionDecision, error) {
+	e.Called()
+
+	return query.PermissionDecision{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+		Allowed:  true,
+	}, nil
+}
+
+// Authorize returns an allowed decision to satisfy the Engine interface.
+func (e *Engine) Authorize(ctx context.Context, subject types.Resource, action string, resource types.Resource) (query.PermissionDecision, error) {
+	e.Called()
+
+	return query.PermissionDecision{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+		Allowed:  true,
+	}, nil
+}
+
+// SubjectHasPermissions returns an allowed result for every check to satisfy the Engine interface.
+func (e *Engine) SubjectHasPermissions(ctx context.Context, subject types.Resource, checks []query.PermissionCheck) ([]query.PermissionResult, error) {
+	e.Called()
+
+	results := make([]query.PermissionResult, len(checks))
+
+	for i, check := range checks {
+		results[i] = query.PermissionResult{
+			Action:   check.Action,
+			Resource: check.Resource,
+			Allowed:  true,
+		}
+	}
+
+	return results, nil
+}
+
+// ListSubjectActions returns nothing but satisfies the Engine interface.
+func (e *Engine) ListSubjectActions(ctx context.Context, subject, resource types.Resource, queryToken string) ([]string, error) {
+	return nil, nil
+}
+
+// LookupResources returns nothing but satisfies the Engine interface.
+func (e *Engine) LookupResources(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string) ([]types.Resource, error) {
+	return nil, nil
+}
+
+// LookupResourcesPage returns nothing but satisfies the Engine interface.
+func (e *Engine) LookupResourcesPage(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string, page query.PageOpts) ([]types.Resource, string, error) {
+	return nil, "", nil
+}
+
+// LookupSubjects returns nothing but satisfies the Engine interface.
+func (e *Engine) LookupSubjects(ctx context.Context, resource types.Resource, action string, subjectType string, queryToken string) ([]types.Resource, error) {
+	return nil, nil
+}
+
+// ListSubjectPermissionsInSubtree returns nothing but satisfies the Engine interface.
+func (e *Engine) ListSubjectPermissionsInSubtree(ctx context.Context, subject, root types.Resource, queryToken string) (map[gidx.PrefixedID][]string, error) {
+	return nil, nil
+}
+
+// Healthcheck does nothing but satisfies the Engine interface.
+func (e *Engine) Healthcheck(ctx context.Context) error {
+	return nil
+}
+
+// ApplySchema does nothing but satisfies the Engine interface.
+func (e *Engine) ApplySchema(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+// Schema returns nothing but satisfies the Engine interface.
+func (e *Engine) Schema() (string, error) {
+	return "", nil
+}
+
+// VerifySchema does nothing but satisfies the Engine interface.
+func (e *Engine) VerifySchema(ctx context.Context) error {
+	return nil
+}
+
+// ReconcilePolicy does nothing but satisfies the Engine interface.
+func (e *Engine) ReconcilePolicy(ctx context.Context, newPolicy iapl.Policy, opts ...query.ReconcileOption) (query.ReconcileReport, error) {
+	return query.ReconcileReport{}, nil
+}
+
+// RegisterResourceType does nothing but satisfies the Engine interface.
+func (e *Engine) RegisterResourceType(rt iapl.ResourceType) error {
+	return nil
+}
+
+// SubjectHasPermissionWithContext returns nil to satisfy the Engine interface.
+func (e *Engine) SubjectHasPermissionWithContext(ctx context.Context, subject types.Resource, action string, resource types.Resource, caveatContext map[string]any) error {
+	e.Called()
+
+	return nil
+}
+
+// Begin returns a transaction which does nothing but satisfies the Engine interface.
+func (e *Engine) Begin() query.Tx {
+	return &Tx{}
+}
+
+// Tx is a transaction which records nothing and writes nothing.
+type Tx struct{}
+
+var _ query.Tx = &Tx{}
+
+// CreateRelationships does nothing but satisfies the Tx interface.
+func (t *Tx) CreateRelationships(rels []types.Relationship) error {
+	return nil
+}
+
+// DeleteRelationships does nothing but satisfies the Tx interface.
+func (t *Tx) DeleteRelationships(rels ...types.Relationship) error {
+	return nil
+}
+
+// CreateRole creates a Role object and does not persist it anywhere.
+func (t *Tx) CreateRole(res types.Resource, actions []string, opts ...query.RoleOption) (types.Role, error) {
+	role, _, err := (&Engine{}).CreateRole(context.Background(), res, actions, opts...)
+
+	return role, err
+}
+
+// AssignSubjectRole does nothing but satisfies the Tx interface.
+func (t *Tx) AssignSubjectRole(subject types.Resource, role types.Role) error {
+	return nil
+}
+
+// UnassignSubjectRole does nothing but satisfies the Tx interface.
+func (t *Tx) UnassignSubjectRole(subject types.Resource, role types.Role) error {
+	return nil
+}
+
+// Commit does nothing but satisfies the Tx interface.
+func (t *Tx) Commit(ctx context.Context) (string, error) {
+	return "", nil
+}
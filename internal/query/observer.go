@@ -0,0 +1,47 @@
+package query
+
+import (
+	"context"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// RelationshipObserver is notified after the engine successfully creates or deletes relationships.
+// Errors returned by an observer are logged and do not fail the operation.
+type RelationshipObserver interface {
+	OnCreate(ctx context.Context, rels []types.Relationship, queryToken string) error
+	OnDelete(ctx context.Context, rels []types.Relationship, queryToken string) error
+}
+
+// WithObserver adds an observer which is notified of relationship changes.
+// Observers are called in the order they are added.
+func WithObserver(observer RelationshipObserver) Option {
+	return func(e *engine) {
+		e.observers = append(e.observers, observer)
+	}
+}
+
+func (e *engine) notifyCreate(ctx context.Context, rels []types.Relationship, queryToken string) {
+	for _, observer := range e.observers {
+		if err := observer.OnCreate(ctx, rels, queryToken); err != nil {
+			e.logger.Warnw("relationship observer failed", "event", "create", "relationships", len(rels), "error", err)
+		}
+	}
+}
+
+func (e *engine) notifyDelete(ctx context.Context, rels []types.Relationship, queryToken string) {
+	for _, observer := range e.observers {
+		if err := observer.OnDelete(ctx, rels, queryToken); err != nil {
+			e.logger.Warnw("relationship observer failed", "event", "delete", "relationships", len(rels), "error", err)
+		}
+	}
+}
+
+// roleAssignmentRelationship returns the relationship which assigns the role to the subject.
+func roleAssignmentRelationship(subject types.Resource, role types.Role) types.Relationship {
+	return types.Relationship{
+		Resource: role.Resource(),
+		Relation: roleSubjectRelation,
+		Subject:  subject,
+	}
+}
@@ -0,0 +1,8 @@
+package query
+
+// PageOpts controls the pagination of list results.
+// A zero Limit returns all results. Cursor is the opaque cursor returned by a previous page.
+type PageOpts struct {
+	Limit  int
+	Cursor string
+}
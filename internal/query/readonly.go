@@ -0,0 +1,21 @@
+package query
+
+// WithReadOnly makes the engine reject every change to SpiceDB with ErrReadOnly, for services which only
+// check permissions. Reads and permission checks are unchanged. Writes are rejected where the engine sends
+// them to SpiceDB, so a method which reads before writing, such as DeleteRole, may still perform its reads,
+// and invalid arguments are reported as they would be without WithReadOnly. Nothing is written and no
+// events or notifications are sent for a rejected change.
+func WithReadOnly() Option {
+	return func(e *engine) {
+		e.readOnly = true
+	}
+}
+
+// checkWritable returns ErrReadOnly if the engine may not change SpiceDB.
+func (e *engine) checkWritable() error {
+	if e.readOnly {
+		return ErrReadOnly
+	}
+
+	return nil
+}
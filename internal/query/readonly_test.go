@@ -0,0 +1,56 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	// The engine has no client, so each change must be rejected before SpiceDB is called.
+	e := NewEngine("test", nil, WithReadOnly())
+
+	ctx := context.Background()
+
+	tenRes, err := e.NewResourceFromIDString("tnntten-abc123")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromIDString("tnntten-def456")
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromIDString("idntusr-abc123")
+	require.NoError(t, err)
+
+	rel := types.Relationship{
+		Resource: tenRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	role := types.Role{
+		ID: gidx.MustNewID(RolePrefix),
+	}
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{rel})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = e.UpsertRelationships(ctx, []types.Relationship{rel})
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = e.AssignSubjectRole(ctx, userRes, role)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, err = e.AssignSubjectRoles(ctx, []types.Resource{userRes}, role)
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	tx := e.Begin()
+	require.NoError(t, tx.CreateRelationships([]types.Relationship{rel}))
+
+	_, err = tx.Commit(ctx)
+	assert.ErrorIs(t, err, ErrReadOnly)
+}
@@ -0,0 +1,338 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// DenialReason classifies why a permission check was denied.
+type DenialReason string
+
+const (
+	// DenialReasonNone is used when the check was allowed.
+	DenialReasonNone DenialReason = ""
+	// DenialReasonConditional is used when the subject holds the permission only if caveat conditions are met.
+	DenialReasonConditional DenialReason = "conditional"
+	// DenialReasonExpired is used when the subject was assigned a role granting the action on the resource,
+	// but the assignment has expired.
+	DenialReasonExpired DenialReason = "expired"
+	// DenialReasonRoleOnOtherResource is used when the subject has a role granting the action,
+	// but the role is bound to a resource the action does not flow from.
+	DenialReasonRoleOnOtherResource DenialReason = "role_on_other_resource"
+	// DenialReasonNotAssigned is used when roles granting the action are bound to the resource,
+	// but the subject is not assigned any of them.
+	DenialReasonNotAssigned DenialReason = "not_assigned"
+	// DenialReasonNoRole is used when the subject has no role granting the action
+	// and no role granting the action is bound to the resource.
+	DenialReasonNoRole DenialReason = "no_role"
+	// DenialReasonDenied is used when the check was denied and the denial was not explained, as by Authorize.
+	DenialReasonDenied DenialReason = "denied"
+)
+
+// PermissionDecision is the result of a permission check along with an explanation of the result.
+type PermissionDecision struct {
+	Subject  types.Resource
+	Action   string
+	Resource types.Resource
+	Allowed  bool
+	Reason   DenialReason
+	// Roles are the roles the explanation refers to: the subject's expired roles granting the action for
+	// DenialReasonExpired, the subject's roles granting the action for DenialReasonRoleOnOtherResource,
+	// or the roles bound to the resource for DenialReasonNotAssigned.
+	Roles       []types.Resource
+	Explanation string
+}
+
+// CheckPermissionWithReason checks if the given subject can do the given action on the given resource.
+// When the check is denied, the subject's roles and the roles bound to the resource are inspected
+// to find the nearest missing link, which is returned as a human-readable explanation.
+func (e *engine) CheckPermissionWithReason(ctx context.Context, subject types.Resource, action string, resource types.Resource) (_ PermissionDecision, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.CheckPermissionWithReason",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+				attribute.String("permissions.action", action),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "CheckPermissionWithReason", time.Now(), &err)
+
+	decision := PermissionDecision{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+	}
+
+	allowed, err := e.checkSubjectPermission(ctx, subject, action, resource, nil)
+
+	switch {
+	case err == nil && allowed:
+		decision.Allowed = true
+		decision.Explanation = fmt.Sprintf("subject %s has %s on %s %s", subject.ID, action, resource.Type, resource.ID)
+
+		return decision, nil
+	case errors.Is(err, ErrActionNotAssigned):
+		decision.Reason = DenialReasonConditional
+		decision.Explanation = fmt.Sprintf("subject %s has %s on %s %s only when caveat conditions are met (%s)", subject.ID, action, resource.Type, resource.ID, err)
+
+		return decision, nil
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return PermissionDecision{}, err
+	}
+
+	if err := e.explainDenial(ctx, &decision); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return PermissionDecision{}, err
+	}
+
+	span.SetAttributes(attribute.String("permissions.denial_reason", string(decision.Reason)))
+
+	return decision, nil
+}
+
+// Authorize decides whether the given subject can do the given action on the given resource, for
+// authorization middleware which needs an allow or deny decision rather than an error. Unlike
+// CheckPermissionWithReason, a denial is not explained, so a decision costs a single permission check.
+// A denied check returns a decision with DenialReasonDenied and a nil error; the error is reserved for
+// checks which could not be completed, including an action which is not defined for the resource type,
+// which returns ErrInvalidAction.
+func (e *engine) Authorize(ctx context.Context, subject types.Resource, action string, resource types.Resource) (_ PermissionDecision, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.Authorize",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+				attribute.String("permissions.action", action),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "Authorize", time.Now(), &err)
+
+	if err := e.validateAction(resource.Type, action); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return PermissionDecision{}, err
+	}
+
+	decision := PermissionDecision{
+		Subject:  subject,
+		Action:   action,
+		Resource: resource,
+	}
+
+	err = e.SubjectHasPermission(ctx, subject, action, resource)
+
+	switch {
+	case err == nil:
+		decision.Allowed = true
+		decision.Explanation = fmt.Sprintf("subject %s has %s on %s %s", subject.ID, action, resource.Type, resource.ID)
+	case errors.Is(err, ErrActionNotAssigned):
+		decision.Reason = DenialReasonDenied
+		decision.Explanation = fmt.Sprintf("subject %s does not have %s on %s %s", subject.ID, action, resource.Type, resource.ID)
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return PermissionDecision{}, err
+	}
+
+	span.SetAttributes(attribute.Bool("permissions.allowed", decision.Allowed))
+
+	return decision, nil
+}
+
+// explainDenial fills in the reason and explanation of a denied decision.
+func (e *engine) explainDenial(ctx context.Context, decision *PermissionDecision) error {
+	subject, action, resource := decision.Subject, decision.Action, decision.Resource
+
+	grantingRoles, err := e.subjectRolesGranting(ctx, subject, action)
+	if err != nil {
+		return err
+	}
+
+	var expiredRoles []types.Resource
+
+	for roleRes, grant := range grantingRoles {
+		// The check was denied, so an assignment to a role granting the action on the resource itself
+		// can only be one which has expired.
+		if grant.resource == resource {
+			expiredRoles = append(expiredRoles, roleRes)
+		}
+	}
+
+	if len(expiredRoles) != 0 {
+		sortResources(expiredRoles)
+
+		decision.Reason = DenialReasonExpired
+		decision.Roles = expiredRoles
+
+		role := expiredRoles[0]
+
+		decision.Explanation = fmt.Sprintf(
+			"subject %s was assigned role %s granting %s on %s %s, but the assignment has expired",
+			subject.ID, role.ID, action, resource.Type, resource.ID,
+		)
+
+		if expiresAt := grantingRoles[role].expiresAt; !expiresAt.IsZero() {
+			decision.Explanation += " (at " + expiresAt.UTC().Format(time.RFC3339) + ")"
+		}
+
+		return nil
+	}
+
+	if len(grantingRoles) != 0 {
+		decision.Reason = DenialReasonRoleOnOtherResource
+
+		for roleRes := range grantingRoles {
+			decision.Roles = append(decision.Roles, roleRes)
+		}
+
+		sortResources(decision.Roles)
+
+		role := decision.Roles[0]
+		roleOwner := grantingRoles[role].resource
+
+		decision.Explanation = fmt.Sprintf(
+			"subject %s has role %s granting %s on %s %s, which does not grant %s on %s %s",
+			subject.ID, role.ID, action, roleOwner.Type, roleOwner.ID, action, resource.Type, resource.ID,
+		)
+
+		return nil
+	}
+
+	boundRoles, err := e.rolesBoundForAction(ctx, action, resource)
+	if err != nil {
+		return err
+	}
+
+	if len(boundRoles) != 0 {
+		decision.Reason = DenialReasonNotAssigned
+		decision.Roles = boundRoles
+		decision.Explanation = fmt.Sprintf(
+			"subject %s is not assigned any of the %d roles granting %s on %s %s",
+			subject.ID, len(boundRoles), action, resource.Type, resource.ID,
+		)
+
+		return nil
+	}
+
+	decision.Reason = DenialReasonNoRole
+	decision.Explanation = fmt.Sprintf(
+		"subject %s has no role granting %s on %s %s",
+		subject.ID, action, resource.Type, resource.ID,
+	)
+
+	return nil
+}
+
+// roleGrant is the resource a role assigned to a subject is bound to, and when the assignment expires.
+type roleGrant struct {
+	resource types.Resource
+	// expiresAt is zero if the assignment does not expire.
+	expiresAt time.Time
+}
+
+// subjectRolesGranting returns the roles directly assigned to the subject which include the action,
+// mapped to the resource each role is bound to and the expiry of the assignment.
+func (e *engine) subjectRolesGranting(ctx context.Context, subject types.Resource, action string) (map[types.Resource]roleGrant, error) {
+	filter := &pb.RelationshipFilter{
+		ResourceType:     e.namespace + "/role",
+		OptionalRelation: roleSubjectRelation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/" + subject.Type,
+			OptionalSubjectId: subject.ID.String(),
+		},
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[types.Resource]roleGrant)
+
+	for _, rel := range relationships {
+		roleRes, err := e.resourceFromSpiceDBRef(rel.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		expiresAt, _ := e.assignmentExpiry(rel)
+
+		resActions, err := e.findRoleResourceActions(ctx, roleRes, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for res, relActions := range resActions {
+			for _, relAction := range relActions {
+				if relationToAction(relAction) == action {
+					out[roleRes] = roleGrant{resource: res, expiresAt: expiresAt}
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// rolesBoundForAction returns the roles bound directly to the resource which grant the action.
+func (e *engine) rolesBoundForAction(ctx context.Context, action string, resource types.Resource) ([]types.Resource, error) {
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/" + resource.Type,
+		OptionalResourceId: resource.ID.String(),
+		OptionalRelation:   actionToRelation(action),
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]types.Resource, 0, len(relationships))
+
+	for _, rel := range relationships {
+		roleRes, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, roleRes)
+	}
+
+	sortResources(out)
+
+	return out, nil
+}
+
+// sortResources sorts resources by ID so explanations are stable.
+func sortResources(resources []types.Resource) {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].ID < resources[j].ID
+	})
+}
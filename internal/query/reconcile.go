@@ -0,0 +1,354 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.infratographer.com/permissions-api/internal/iapl"
+	"go.infratographer.com/permissions-api/internal/spicedbx"
+)
+
+// ReconcileReport describes the schema changes made by ReconcilePolicy.
+type ReconcileReport struct {
+	// Changes are the differences between the live schema and the policy's schema.
+	Changes []spicedbx.SchemaChange
+	// Orphaned are the relationships which the policy's schema can no longer hold, by change.
+	Orphaned []OrphanedRelationships
+	// Applied is true if the live schema is the policy's schema, whether or not it had to be written.
+	Applied bool
+	// QueryToken reflects the deletion of orphaned relationships, if any were deleted.
+	QueryToken string
+}
+
+// OrphanedRelationships counts the relationships which a breaking schema change orphans.
+type OrphanedRelationships struct {
+	Change spicedbx.SchemaChange
+	Count  int
+}
+
+// ReconcileOption is a functional option for reconciling a policy.
+type ReconcileOption func(opts *reconcileOptions)
+
+type reconcileOptions struct {
+	force bool
+}
+
+// ForceReconcile deletes relationships orphaned by the policy's schema so it can be written.
+// The relationships are deleted before the schema is written and are not restored if writing
+// the schema then fails.
+func ForceReconcile() ReconcileOption {
+	return func(opts *reconcileOptions) {
+		opts.force = true
+	}
+}
+
+// ReconcilePolicy writes the schema for the given policy to the engine's namespace and makes the policy
+// the engine's policy. The live schema is diffed against the policy's schema first, and relationships
+// which a removed definition or relation, or a relation no longer allowing a subject type, would orphan
+// are counted in the report. SpiceDB refuses to write a schema which orphans relationships, so unless
+// ForceReconcile is given, ReconcilePolicy returns ErrOrphanedRelationships along with the report
+// without changing anything. Resource types added with RegisterResourceType are replaced by the policy.
+// Only the engine's namespace is diffed and replaced; other namespaces' definitions are left in place.
+func (e *engine) ReconcilePolicy(ctx context.Context, newPolicy iapl.Policy, opts ...ReconcileOption) (_ ReconcileReport, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ReconcilePolicy",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ReconcilePolicy", time.Now(), &err)
+
+	var options reconcileOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	report, err := e.reconcilePolicy(ctx, newPolicy, options)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return report, err
+	}
+
+	span.SetAttributes(
+		attribute.Int("permissions.schema_changes", len(report.Changes)),
+		attribute.Bool("permissions.schema_applied", report.Applied),
+	)
+
+	return report, nil
+}
+
+func (e *engine) reconcilePolicy(ctx context.Context, newPolicy iapl.Policy, options reconcileOptions) (ReconcileReport, error) {
+	var report ReconcileReport
+
+	if err := newPolicy.Validate(); err != nil {
+		return report, err
+	}
+
+	schema, err := spicedbx.GenerateSchema(e.namespace, newPolicy.Schema(), newPolicy.Caveats()...)
+	if err != nil {
+		return report, err
+	}
+
+	if err := spicedbx.CheckSchema(schema); err != nil {
+		return report, err
+	}
+
+	current, err := e.readSchema(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	// Only the engine's namespace is replaced, so other namespaces' definitions are not changes.
+	current = spicedbx.NamespaceSchema(current, e.namespace)
+
+	diff, err := spicedbx.DiffSchema(current, schema)
+	if err != nil {
+		return report, err
+	}
+
+	report.Changes = diff.Changes
+
+	var (
+		orphanFilters []*pb.RelationshipFilter
+		orphaned      int
+	)
+
+	// Orphaned relationships are read fully consistently so none written just before are missed.
+	readCtx := ContextWithConsistency(ctx, ConsistencyFullyConsistent)
+
+	for _, change := range diff.BreakingChanges() {
+		count := 0
+
+		for _, filter := range spicedbx.ConflictFilters(change) {
+			rels, err := e.readRelationships(readCtx, filter, "")
+			if err != nil {
+				return report, err
+			}
+
+			if len(rels) != 0 {
+				count += len(rels)
+
+				orphanFilters = append(orphanFilters, filter)
+			}
+		}
+
+		if count != 0 {
+			report.Orphaned = append(report.Orphaned, OrphanedRelationships{
+				Change: change,
+				Count:  count,
+			})
+
+			orphaned += count
+		}
+	}
+
+	if orphaned != 0 && !options.force {
+		return report, fmt.Errorf("%w: %d relationships", ErrOrphanedRelationships, orphaned)
+	}
+
+	for _, filter := range orphanFilters {
+		report.QueryToken, err = e.deleteRelationships(ctx, filter)
+		if err != nil {
+			return report, fmt.Errorf("failed to delete orphaned relationships: %w", err)
+		}
+	}
+
+	if !diff.Empty() {
+		if _, err := e.writeSchema(ctx, schema); err != nil {
+			return report, err
+		}
+	}
+
+	report.Applied = true
+
+	e.schemaMu.Lock()
+	defer e.schemaMu.Unlock()
+
+	e.schema = newPolicy.Schema()
+	e.caveats = newPolicy.Caveats()
+	e.defaultRoles = newPolicy.DefaultRoles()
+	e.compositeActions = newPolicy.CompositeActions()
+
+	e.cacheSchemaResources()
+
+	return report, nil
+}
+
+// Schema returns the schema generated from the engine's namespace and policy, which is the schema
+// ApplySchema writes. Nothing is read from or written to SpiceDB, so it may be used to register the
+// schema externally or to diff against the live schema. Only the engine's namespace is included.
+func (e *engine) Schema() (string, error) {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	return spicedbx.GenerateSchema(e.namespace, e.schema, e.caveats...)
+}
+
+// VerifySchema reads the live schema and diffs the engine's namespace against the schema generated from
+// the engine's policy, as ReconcilePolicy does. If they differ, as when a schema write failed or another
+// version of the policy is deployed, ErrSchemaMismatch is returned describing each change required to go
+// from the live schema to the generated one. Definitions of other namespaces are ignored.
+func (e *engine) VerifySchema(ctx context.Context) (err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.VerifySchema",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "VerifySchema", time.Now(), &err)
+
+	if err := e.verifySchema(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+func (e *engine) verifySchema(ctx context.Context) error {
+	schema, err := e.Schema()
+	if err != nil {
+		return err
+	}
+
+	live, err := e.readSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	diff, err := spicedbx.DiffSchema(spicedbx.NamespaceSchema(live, e.namespace), schema)
+	if err != nil {
+		return err
+	}
+
+	if diff.Empty() {
+		return nil
+	}
+
+	changes := make([]string, len(diff.Changes))
+
+	for i, change := range diff.Changes {
+		changes[i] = change.String()
+	}
+
+	return fmt.Errorf("%w: %d changes from live schema: %s", ErrSchemaMismatch, len(changes), strings.Join(changes, "; "))
+}
+
+// ApplySchema writes the schema generated from the engine's namespace and policy, returning the
+// query token of the write. Writing a schema identical to the live schema changes nothing, so it is
+// safe to call repeatedly. Unlike ReconcilePolicy, the live schema is not checked first, so a schema
+// which would orphan relationships is rejected by SpiceDB. Definitions of other namespaces in the live
+// schema are written back unchanged.
+func (e *engine) ApplySchema(ctx context.Context) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ApplySchema",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ApplySchema", time.Now(), &err)
+
+	schema, err := e.Schema()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	resp, err := e.writeSchema(ctx, schema)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, resp.GetWrittenAt().GetToken())
+
+	return resp.GetWrittenAt().GetToken(), nil
+}
+
+// schemaWriteMu serializes schema writes by the engines of a process, so engines for different
+// namespaces sharing a SpiceDB cluster do not overwrite each other's definitions. Engines in other
+// processes writing at the same moment may still race.
+var schemaWriteMu sync.Mutex
+
+// writeSchema writes the given schema for the engine's namespace. SpiceDB holds a single schema, so
+// the live schema is read first and the definitions of other namespaces are written along with it.
+func (e *engine) writeSchema(ctx context.Context, schema string) (*pb.WriteSchemaResponse, error) {
+	if err := e.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	schemaWriteMu.Lock()
+	defer schemaWriteMu.Unlock()
+
+	live, err := e.readSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.WriteSchemaRequest{
+		Schema: spicedbx.MergeNamespaceSchema(live, e.namespace, schema),
+	}
+
+	var resp *pb.WriteSchemaResponse
+
+	err = e.retry(ctx, true, func() (err error) {
+		resp, err = e.client.WriteSchema(ctx, req)
+
+		return err
+	})
+	if err != nil {
+		return nil, newSpiceDBError(err)
+	}
+
+	e.recordWrite(resp.GetWrittenAt().GetToken())
+
+	return resp, nil
+}
+
+// readSchema returns the live schema, which is empty if no schema has been written.
+func (e *engine) readSchema(ctx context.Context) (string, error) {
+	var resp *pb.ReadSchemaResponse
+
+	err := e.retry(ctx, true, func() (err error) {
+		resp, err = e.client.ReadSchema(ctx, &pb.ReadSchemaRequest{})
+
+		return err
+	})
+
+	switch {
+	case status.Code(err) == grpccodes.NotFound:
+		return "", nil
+	case err != nil:
+		return "", newSpiceDBError(err)
+	}
+
+	return resp.SchemaText, nil
+}
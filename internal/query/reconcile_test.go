@@ -0,0 +1,214 @@
+package query
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/iapl"
+	"go.infratographer.com/permissions-api/internal/spicedbx"
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestReconcilePolicy(t *testing.T) {
+	namespace := "testreconcile"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("chldten"))
+	require.NoError(t, err)
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  tenRes,
+		},
+	})
+	require.NoError(t, err)
+
+	// The test policy without the child resource type.
+	doc := iapl.DefaultPolicyDocument()
+
+	for _, rt := range testPolicyDocument().ResourceTypes {
+		if rt.Name == "group" {
+			doc.ResourceTypes = append(doc.ResourceTypes, rt)
+		}
+	}
+
+	newPolicy := iapl.NewPolicy(doc)
+
+	unchanged, err := e.ReconcilePolicy(ctx, testPolicy())
+	require.NoError(t, err)
+	assert.Empty(t, unchanged.Changes)
+	assert.True(t, unchanged.Applied)
+
+	report, err := e.ReconcilePolicy(ctx, newPolicy)
+	require.ErrorIs(t, err, ErrOrphanedRelationships)
+	assert.False(t, report.Applied)
+	require.Len(t, report.Orphaned, 1)
+	assert.Equal(t, spicedbx.SchemaElementDefinition, report.Orphaned[0].Change.Kind)
+	assert.Equal(t, namespace+"/child", report.Orphaned[0].Change.Name)
+	assert.Equal(t, 1, report.Orphaned[0].Count)
+	assert.NotNil(t, e.GetResourceType("child"))
+
+	report, err = e.ReconcilePolicy(ctx, newPolicy, ForceReconcile())
+	require.NoError(t, err)
+	assert.True(t, report.Applied)
+	assert.NotEmpty(t, report.QueryToken)
+	assert.Nil(t, e.GetResourceType("child"))
+
+	_, err = e.NewResourceFromID(childRes.ID)
+	assert.ErrorIs(t, err, ErrUnknownResourceType)
+
+	// Restore the test policy for later tests in the namespace.
+	_, err = e.ReconcilePolicy(ctx, testPolicy())
+	require.NoError(t, err)
+}
+
+func TestApplySchema(t *testing.T) {
+	namespace := "testapplyschema"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	// testEngine has applied the schema already, so applying it again changes nothing.
+	queryToken, err := e.ApplySchema(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, queryToken)
+
+	// The live schema is the policy's schema.
+	report, err := e.ReconcilePolicy(ctx, testPolicy())
+	require.NoError(t, err)
+	assert.Empty(t, report.Changes)
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("testschema", nil, WithPolicy(testPolicy()))
+
+	schema, err := e.Schema()
+	require.NoError(t, err)
+
+	expected, err := spicedbx.GenerateSchema("testschema", testPolicy().Schema(), testPolicy().Caveats()...)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, schema)
+	assert.Contains(t, schema, "definition testschema/child {")
+}
+
+func TestVerifySchema(t *testing.T) {
+	namespace := "testverifyschema"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	// testEngine has applied the schema, so the live schema matches.
+	require.NoError(t, e.VerifySchema(ctx))
+
+	client, err := spicedbx.NewClient(spicedbx.Config{
+		Endpoint: "spicedb:50051",
+		Key:      "infradev",
+		Insecure: true,
+	}, false)
+	require.NoError(t, err)
+
+	// An engine with the default policy has not written its schema, so the test policy's types are extra.
+	other := NewEngine(namespace, client, WithPolicy(iapl.DefaultPolicy()))
+
+	err = other.VerifySchema(ctx)
+	require.ErrorIs(t, err, ErrSchemaMismatch)
+	assert.Contains(t, err.Error(), namespace+"/child")
+}
+
+func TestSharedClientNamespaces(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := spicedbx.NewClient(spicedbx.Config{
+		Endpoint: "spicedb:50051",
+		Key:      "infradev",
+		Insecure: true,
+	}, false)
+	require.NoError(t, err)
+
+	namespaces := []string{"testsharedfoo", "testsharedbar"}
+	engines := make([]Engine, len(namespaces))
+
+	for i, namespace := range namespaces {
+		namespace := namespace
+
+		engines[i] = NewEngine(namespace, client, WithPolicy(testPolicy()))
+
+		t.Cleanup(func() {
+			cleanDB(ctx, t, client, namespace)
+		})
+	}
+
+	errs := make([]error, len(engines))
+
+	var wg sync.WaitGroup
+
+	for i, e := range engines {
+		i, e := i, e
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := e.ApplySchema(ctx); err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+			if err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+			if err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+			if err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+			if err != nil {
+				errs[i] = err
+
+				return
+			}
+
+			errs[i] = e.SubjectHasPermission(ContextWithQueryToken(ctx, queryToken), subjRes, "loadbalancer_get", tenRes)
+		}()
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		assert.NoError(t, err, namespaces[i])
+	}
+
+	// Neither namespace's schema write removed the other's definitions.
+	for _, e := range engines {
+		report, err := e.ReconcilePolicy(ctx, testPolicy())
+		require.NoError(t, err)
+		assert.Empty(t, report.Changes)
+	}
+}
@@ -5,21 +5,45 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.infratographer.com/permissions-api/internal/iapl"
 	"go.infratographer.com/permissions-api/internal/types"
 	"go.infratographer.com/x/gidx"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/multierr"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 var roleSubjectRelation = "subject"
 
+const (
+	parentRelation       = "parent"
+	roleMetadataRelation = "metadata"
+	roleMetadataCaveat   = "role_metadata"
+
+	// maxWriteUpdates is the most updates SpiceDB accepts in a single write by default,
+	// see its --write-relationships-max-updates-per-call flag.
+	maxWriteUpdates = 1000
+
+	// countPageSize is the number of relationships read per request when counting them.
+	countPageSize = 1000
+
+	// listAllPageSize is the number of relationships read per request when listing across the namespace.
+	listAllPageSize = 1000
+)
+
 func (e *engine) getTypeForResource(res types.Resource) (types.ResourceType, error) {
-	for _, resType := range e.schema {
+	for _, resType := range e.resourceTypes() {
 		if res.Type == resType.Name {
 			return resType, nil
 		}
@@ -28,33 +52,183 @@ func (e *engine) getTypeForResource(res types.Resource) (types.ResourceType, err
 	return types.ResourceType{}, ErrInvalidType
 }
 
+// validateRelationship checks the relationship against the policy's relationship definitions,
+// returning an error which names the offending relation and the allowed subject types.
 func (e *engine) validateRelationship(rel types.Relationship) error {
 	subjType, err := e.getTypeForResource(rel.Subject)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: subject type %s", err, rel.Subject.Type)
 	}
 
 	resType, err := e.getTypeForResource(rel.Resource)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: resource type %s", err, rel.Resource.Type)
 	}
 
 	e.logger.Debugw("validation relationship", "sub", subjType.Name, "rel", rel.Relation, "res", resType.Name)
 
 	for _, typeRel := range resType.Relationships {
-		// If we find a relation with a name and type that matches our relationship,
-		// return
-		if rel.Relation == typeRel.Relation {
-			for _, typeName := range typeRel.Types {
-				if subjType.Name == typeName {
-					return nil
-				}
+		if rel.Relation != typeRel.Relation {
+			continue
+		}
+
+		if rel.Subject.IsWildcard() && (!typeRel.Wildcard || rel.SubjectRelation != "") {
+			return fmt.Errorf("%w: relation %s on %s does not allow wildcard subjects", ErrInvalidRelationship, rel.Relation, resType.Name)
+		}
+
+		subjTypeName := subjType.Name
+		if rel.SubjectRelation != "" {
+			subjTypeName += "#" + rel.SubjectRelation
+		}
+
+		for _, typeName := range typeRel.Types {
+			if subjTypeName == typeName {
+				return e.validateRelationshipMetadata(rel, typeRel)
 			}
 		}
+
+		return fmt.Errorf(
+			"%w: relation %s on %s does not allow subject type %s, allowed types: %s",
+			ErrInvalidRelationship,
+			rel.Relation,
+			resType.Name,
+			subjTypeName,
+			strings.Join(typeRel.Types, ", "),
+		)
+	}
+
+	// No matching relation was found, so we should return an error
+	return fmt.Errorf("%w: %s has no relation %s", ErrInvalidRelationship, resType.Name, rel.Relation)
+}
+
+// validateRelationshipMetadata checks the relationship's metadata against the caveat declared for its
+// relation, as the metadata is stored as the caveat's context.
+func (e *engine) validateRelationshipMetadata(rel types.Relationship, typeRel types.ResourceTypeRelationship) error {
+	if len(rel.Metadata) == 0 {
+		return nil
+	}
+
+	if typeRel.Caveat == "" {
+		return fmt.Errorf("%w: relation %s does not declare a caveat to hold metadata", ErrInvalidRelationship, rel.Relation)
+	}
+
+	params := e.caveatParameters(typeRel.Caveat)
+
+	for key, value := range rel.Metadata {
+		paramType, ok := params[key]
+		if !ok {
+			return fmt.Errorf("%w: metadata key %s is not a parameter of caveat %s", ErrInvalidRelationship, key, typeRel.Caveat)
+		}
+
+		if paramType != "string" {
+			return fmt.Errorf("%w: metadata key %s must be a string parameter of caveat %s, not %s", ErrInvalidRelationship, key, typeRel.Caveat, paramType)
+		}
+
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("%w: metadata key %s is not valid UTF-8", ErrInvalidRelationship, key)
+		}
+	}
+
+	return nil
+}
+
+// relationshipCaveat returns the caveat storing the relationship's metadata, or nil if it has none.
+// The metadata must already have been validated.
+func (e *engine) relationshipCaveat(rel types.Relationship) *pb.ContextualizedCaveat {
+	if len(rel.Metadata) == 0 {
+		return nil
+	}
+
+	resType, ok := e.resourceType(rel.Resource.Type)
+	if !ok {
+		return nil
+	}
+
+	for _, typeRel := range resType.Relationships {
+		if typeRel.Relation != rel.Relation || typeRel.Caveat == "" {
+			continue
+		}
+
+		fields := make(map[string]*structpb.Value, len(rel.Metadata))
+
+		for key, value := range rel.Metadata {
+			fields[key] = structpb.NewStringValue(value)
+		}
+
+		return &pb.ContextualizedCaveat{
+			CaveatName: e.namespace + "/" + typeRel.Caveat,
+			Context: &structpb.Struct{
+				Fields: fields,
+			},
+		}
+	}
+
+	return nil
+}
+
+// relationshipCaveatFromSpiceDB returns the caveat the relationship is conditioned on, or nil if it has none.
+func (e *engine) relationshipCaveatFromSpiceDB(rel *pb.Relationship) *types.RelationshipCaveat {
+	caveat := rel.GetOptionalCaveat()
+	if caveat == nil {
+		return nil
+	}
+
+	return &types.RelationshipCaveat{
+		Name:    strings.TrimPrefix(caveat.CaveatName, e.namespace+"/"),
+		Context: caveat.GetContext().AsMap(),
+	}
+}
+
+// relationshipMetadata returns the metadata stored in the caveat context of the relationship, or nil if it has none.
+func relationshipMetadata(rel *pb.Relationship) map[string]string {
+	fields := rel.GetOptionalCaveat().GetContext().GetFields()
+	if len(fields) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]string, len(fields))
+
+	for key, value := range fields {
+		if str, ok := value.GetKind().(*structpb.Value_StringValue); ok {
+			metadata[key] = str.StringValue
+		}
+	}
+
+	return metadata
+}
+
+// resourceAttributes returns the span attributes which identify the given resource.
+func (e *engine) resourceAttributes(res types.Resource) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("permissions.namespace", e.namespace),
+		attribute.String("permissions.resource_type", res.Type),
+		attribute.Stringer("permissions.resource", res.ID),
+	}
+}
+
+// recordZedToken sets the zedtoken attribute on the span.
+func recordZedToken(span trace.Span, token string) {
+	span.SetAttributes(attribute.String("permissions.zedtoken", token))
+}
+
+// resourceFromSpiceDBRef returns the resource for the given SpiceDB object, which may be a wildcard.
+func (e *engine) resourceFromSpiceDBRef(ref *pb.ObjectReference) (types.Resource, error) {
+	if ref.ObjectId == types.WildcardID.String() {
+		resType := strings.TrimPrefix(ref.ObjectType, e.namespace+"/")
+
+		if _, ok := e.resourceType(resType); !ok {
+			return types.Resource{}, ErrInvalidType
+		}
+
+		return types.WildcardResource(resType), nil
 	}
 
-	// No matching relationship was found, so we should return an error
-	return ErrInvalidRelationship
+	id, err := gidx.Parse(ref.ObjectId)
+	if err != nil {
+		return types.Resource{}, err
+	}
+
+	return e.NewResourceFromID(id)
 }
 
 func resourceToSpiceDBRef(namespace string, r types.Resource) *pb.ObjectReference {
@@ -66,9 +240,63 @@ func resourceToSpiceDBRef(namespace string, r types.Resource) *pb.ObjectReferenc
 
 // SubjectHasPermission checks if the given subject can do the given action on the given resource
 func (e *engine) SubjectHasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) error {
+	allowed, err := e.HasPermission(ctx, subject, action, resource)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return ErrActionNotAssigned
+	}
+
+	return nil
+}
+
+// SubjectHasPermissionAt checks if the given subject can do the given action on the given resource,
+// with data at least as fresh as the query token, such as the token returned when assigning a role.
+// With no query token it behaves as SubjectHasPermission. The query token takes precedence over one
+// carried by the context, and is used according to the consistency mode as other reads do.
+func (e *engine) SubjectHasPermissionAt(ctx context.Context, subject types.Resource, action string, resource types.Resource, queryToken string) error {
+	if queryToken != "" {
+		ctx = ContextWithQueryToken(ctx, queryToken)
+	}
+
+	return e.SubjectHasPermission(ctx, subject, action, resource)
+}
+
+// HasPermission reports whether the given subject can do the given action on the given resource.
+// A denied check returns false with a nil error, the error is reserved for checks which could not be completed.
+func (e *engine) HasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) (bool, error) {
+	allowed, err := e.checkSubjectPermission(ctx, subject, action, resource, nil)
+	if errors.Is(err, ErrActionNotAssigned) {
+		return false, nil
+	}
+
+	return allowed, err
+}
+
+// SubjectHasPermissionWithContext checks if the given subject can do the given action on the given resource,
+// evaluating any caveats along the way with the provided caveat context.
+func (e *engine) SubjectHasPermissionWithContext(ctx context.Context, subject types.Resource, action string, resource types.Resource, caveatContext map[string]any) error {
+	allowed, err := e.checkSubjectPermission(ctx, subject, action, resource, caveatContext)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return ErrActionNotAssigned
+	}
+
+	return nil
+}
+
+// checkSubjectPermission checks the subject's permission for the action on the resource.
+// A permission which depends on caveat context that was not provided returns ErrActionNotAssigned
+// listing the missing context.
+func (e *engine) checkSubjectPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource, caveatContext map[string]any) (_ bool, err error) {
 	ctx, span := e.tracer.Start(
 		ctx,
-		"SubjectHasPermission",
+		"engine.SubjectHasPermission",
 		trace.WithAttributes(
 			attribute.Stringer(
 				"permissions.actor",
@@ -82,755 +310,4259 @@ func (e *engine) SubjectHasPermission(ctx context.Context, subject types.Resourc
 				"permissions.resource",
 				resource.ID,
 			),
+			attribute.String(
+				"permissions.namespace",
+				e.namespace,
+			),
+			attribute.String(
+				"permissions.resource_type",
+				resource.Type,
+			),
 		),
 	)
 
 	defer span.End()
+	defer e.observe(ctx, "SubjectHasPermission", time.Now(), &err)
 
-	req := &pb.CheckPermissionRequest{
-		Consistency: &pb.Consistency{
-			Requirement: &pb.Consistency_FullyConsistent{
-				FullyConsistent: true,
-			},
-		},
-		Resource:   resourceToSpiceDBRef(e.namespace, resource),
-		Permission: action,
-		Subject: &pb.SubjectReference{
-			Object: resourceToSpiceDBRef(e.namespace, subject),
-		},
-	}
+	// Checks with caveat context depend on more than the key, so they are never cached.
+	cacheKey, cacheable := e.checkCacheKeyFor(ctx, subject, action, resource)
+	cacheable = cacheable && caveatContext == nil
 
-	err := e.checkPermission(ctx, req)
+	if cacheable {
+		allowed, ok := e.checkCache.get(cacheKey)
 
-	switch {
-	case err == nil:
-		span.SetAttributes(
-			attribute.String(
-				"permissions.outcome",
-				outcomeAllowed,
-			),
-		)
-	case errors.Is(err, ErrActionNotAssigned):
-		span.SetAttributes(
-			attribute.String(
-				"permissions.outcome",
-				outcomeDenied,
-			),
-		)
-	default:
-		span.SetStatus(codes.Error, err.Error())
-	}
+		e.recordCacheLookup(ctx, ok)
 
-	return err
-}
+		if ok {
+			outcome := checkOutcome(allowed)
 
-// AssignSubjectRole assigns the given role to the given subject.
-func (e *engine) AssignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (string, error) {
-	request := &pb.WriteRelationshipsRequest{
-		Updates: []*pb.RelationshipUpdate{
-			e.subjectRoleRelCreate(subject, role),
+			span.SetAttributes(
+				attribute.Bool(
+					"permissions.cache_hit",
+					true,
+				),
+				attribute.String(
+					"permissions.outcome",
+					outcome,
+				),
+			)
+
+			e.recordCheck(ctx, "SubjectHasPermission", outcome)
+
+			return allowed, nil
+		}
+	}
+
+	req := &pb.CheckPermissionRequest{
+		Consistency: e.checkConsistency(ctx, ""),
+		Resource:    resourceToSpiceDBRef(e.namespace, resource),
+		Permission:  action,
+		Subject: &pb.SubjectReference{
+			Object: resourceToSpiceDBRef(e.namespace, subject),
 		},
 	}
-	r, err := e.client.WriteRelationships(ctx, request)
 
+	reqContext, err := checkContext(caveatContext)
 	if err != nil {
-		return "", err
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
 	}
 
-	return r.WrittenAt.GetToken(), nil
-}
+	req.Context = reqContext
 
-// UnassignSubjectRole removes the given role from the given subject.
-func (e *engine) UnassignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (string, error) {
-	request := &pb.DeleteRelationshipsRequest{
-		RelationshipFilter: e.subjectRoleRelDelete(subject, role),
-	}
-	r, err := e.client.DeleteRelationships(ctx, request)
+	allowed, err := e.checkPermission(ctx, req)
 
-	if err != nil {
-		return "", err
+	// Conditional results depend on caveat context, so only definite results are cached.
+	if cacheable && err == nil {
+		e.checkCache.set(cacheKey, allowed)
 	}
 
-	return r.DeletedAt.GetToken(), nil
-}
+	if err != nil && !errors.Is(err, ErrActionNotAssigned) {
+		span.SetStatus(codes.Error, err.Error())
 
-// ListAssignments returns the assigned subjects for a given role.
-func (e *engine) ListAssignments(ctx context.Context, role types.Role, queryToken string) ([]types.Resource, error) {
-	roleType := e.namespace + "/role"
-	filter := &pb.RelationshipFilter{
-		ResourceType:       roleType,
-		OptionalResourceId: role.ID.String(),
-		OptionalRelation:   roleSubjectRelation,
+		return allowed, err
 	}
 
-	relationships, err := e.readRelationships(ctx, filter, queryToken)
-	if err != nil {
-		return nil, err
-	}
+	outcome := checkOutcome(allowed)
 
-	out := make([]types.Resource, len(relationships))
+	span.SetAttributes(
+		attribute.String(
+			"permissions.outcome",
+			outcome,
+		),
+	)
 
-	for i, rel := range relationships {
-		id, err := gidx.Parse(rel.Subject.Object.ObjectId)
-		if err != nil {
-			return nil, err
-		}
+	e.recordCheck(ctx, "SubjectHasPermission", outcome)
 
-		res, err := e.NewResourceFromID(id)
-		if err != nil {
-			return nil, err
-		}
+	return allowed, err
+}
 
-		out[i] = res
+// checkOutcome returns the outcome a permission check is recorded with.
+func checkOutcome(allowed bool) string {
+	if allowed {
+		return outcomeAllowed
 	}
 
-	return out, nil
+	return outcomeDenied
 }
 
-func (e *engine) subjectRoleRelCreate(subject types.Resource, role types.Role) *pb.RelationshipUpdate {
-	roleResource := types.Resource{
-		Type: "role",
-		ID:   role.ID,
-	}
+// PermissionCheck is an action to check on a resource.
+type PermissionCheck struct {
+	Action   string
+	Resource types.Resource
+}
 
-	return &pb.RelationshipUpdate{
-		Operation: pb.RelationshipUpdate_OPERATION_CREATE,
-		Relationship: &pb.Relationship{
-			Resource: resourceToSpiceDBRef(e.namespace, roleResource),
-			Relation: roleSubjectRelation,
-			Subject: &pb.SubjectReference{
-				Object: resourceToSpiceDBRef(e.namespace, subject),
-			},
-		},
-	}
+// PermissionResult is the outcome of a PermissionCheck.
+// Err is set if the check itself could not be completed.
+type PermissionResult struct {
+	Action   string
+	Resource types.Resource
+	Allowed  bool
+	Err      error
 }
 
-func (e *engine) subjectRoleRelDelete(subject types.Resource, role types.Role) *pb.RelationshipFilter {
-	roleResource := types.Resource{
-		Type: "role",
-		ID:   role.ID,
-	}
+// SubjectHasPermissions checks if the given subject can do each of the given actions on the given resources
+// concurrently. The results are returned in the same order as the checks.
+func (e *engine) SubjectHasPermissions(ctx context.Context, subject types.Resource, checks []PermissionCheck) (_ []PermissionResult, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectHasPermissions",
+		trace.WithAttributes(
+			attribute.Stringer(
+				"permissions.actor",
+				subject.ID,
+			),
+			attribute.String(
+				"permissions.namespace",
+				e.namespace,
+			),
+			attribute.Int(
+				"permissions.checks",
+				len(checks),
+			),
+		),
+	)
 
-	return &pb.RelationshipFilter{
-		ResourceType:       e.namespace + "/" + roleResource.Type,
-		OptionalResourceId: roleResource.ID.String(),
-		OptionalRelation:   roleSubjectRelation,
-		OptionalSubjectFilter: &pb.SubjectFilter{
-			SubjectType:       e.namespace + "/" + subject.Type,
-			OptionalSubjectId: subject.ID.String(),
-		},
-	}
-}
+	defer span.End()
+	defer e.observe(ctx, "SubjectHasPermissions", time.Now(), &err)
 
-func (e *engine) checkPermission(ctx context.Context, req *pb.CheckPermissionRequest) error {
-	resp, err := e.client.CheckPermission(ctx, req)
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, ""), subject, checks)
 	if err != nil {
-		return err
-	}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	if resp.Permissionship == pb.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
-		return nil
+		return nil, err
 	}
 
-	return ErrActionNotAssigned
+	return results, nil
 }
 
-// CreateRelationships atomically creates the given relationships in SpiceDB.
-func (e *engine) CreateRelationships(ctx context.Context, rels []types.Relationship) (string, error) {
-	ctx, span := e.tracer.Start(ctx, "engine.CreateRelationships", trace.WithAttributes(attribute.Int("relationships", len(rels))))
+// ListSubjectActions returns the sorted list of actions the subject is allowed to perform on the resource.
+func (e *engine) ListSubjectActions(ctx context.Context, subject, resource types.Resource, queryToken string) (_ []string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ListSubjectActions",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+			)...,
+		),
+	)
 
 	defer span.End()
+	defer e.observe(ctx, "ListSubjectActions", time.Now(), &err)
 
-	for _, rel := range rels {
-		err := e.validateRelationship(rel)
-		if err != nil {
-			span.RecordError(err)
-			span.SetStatus(codes.Error, err.Error())
+	resType, ok := e.resourceType(resource.Type)
+	if !ok {
+		span.SetStatus(codes.Error, ErrInvalidType.Error())
 
-			return "", err
-		}
+		return nil, ErrInvalidType
 	}
 
-	relUpdates := e.relationshipsToUpdates(rels)
+	seen := make(map[string]struct{}, len(resType.Actions))
+	checks := make([]PermissionCheck, 0, len(resType.Actions))
 
-	request := &pb.WriteRelationshipsRequest{
-		Updates: relUpdates,
+	for _, action := range resType.Actions {
+		if _, ok := seen[action.Name]; ok {
+			continue
+		}
+
+		seen[action.Name] = struct{}{}
+
+		checks = append(checks, PermissionCheck{
+			Action:   action.Name,
+			Resource: resource,
+		})
 	}
 
-	r, err := e.client.WriteRelationships(ctx, request)
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, queryToken), subject, checks)
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
 
-		return "", err
+		return nil, err
 	}
 
-	return r.WrittenAt.GetToken(), nil
-}
+	actions := []string{}
 
-// CreateRole creates a role scoped to the given resource with the given actions.
-func (e *engine) CreateRole(ctx context.Context, res types.Resource, actions []string) (types.Role, string, error) {
-	role := newRole(actions)
-	roleRels := e.roleRelationships(role, res)
+	for _, result := range results {
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
 
-	request := &pb.WriteRelationshipsRequest{Updates: roleRels}
+			return nil, result.Err
+		}
 
-	r, err := e.client.WriteRelationships(ctx, request)
-	if err != nil {
-		return types.Role{}, "", err
+		if result.Allowed {
+			actions = append(actions, result.Action)
+		}
 	}
 
-	return role, r.WrittenAt.GetToken(), nil
+	sort.Strings(actions)
+
+	return actions, nil
 }
 
-func actionToRelation(action string) string {
-	return action + "_rel"
-}
+// SubjectsWithPermission returns the subjects which may perform the action on the resource, checking
+// them concurrently as by SubjectHasPermissions. The subjects are returned in the order given. Subjects which may
+// only perform the action given caveat context are not returned.
+func (e *engine) SubjectsWithPermission(ctx context.Context, subjects []types.Resource, action string, resource types.Resource, queryToken string) (_ []types.Resource, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectsWithPermission",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.String("permissions.action", action),
+				attribute.Int("permissions.subjects", len(subjects)),
+			)...,
+		),
+	)
 
-func relationToAction(relation string) string {
-	action, _, found := strings.Cut(relation, "_rel")
+	defer span.End()
+	defer e.observe(ctx, "SubjectsWithPermission", time.Now(), &err)
 
-	if !found {
-		panic("unexpected relation on role")
+	if err := e.validateAction(resource.Type, action); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
 	}
 
-	return action
-}
+	if len(subjects) == 0 {
+		return []types.Resource{}, nil
+	}
 
-func (e *engine) roleRelationships(role types.Role, resource types.Resource) []*pb.RelationshipUpdate {
-	var rels []*pb.RelationshipUpdate
+	resourceRef := resourceToSpiceDBRef(e.namespace, resource)
+	consistency := e.checkConsistency(ctx, queryToken)
 
-	roleResource, err := e.NewResourceFromID(role.ID)
+	reqContext, err := checkContext(nil)
 	if err != nil {
-		panic(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
 	}
 
-	resourceRef := resourceToSpiceDBRef(e.namespace, resource)
-	roleRef := resourceToSpiceDBRef(e.namespace, roleResource)
+	reqs := make([]*pb.CheckPermissionRequest, len(subjects))
 
-	for _, action := range role.Actions {
-		rels = append(rels, &pb.RelationshipUpdate{
-			Operation: pb.RelationshipUpdate_OPERATION_TOUCH,
-			Relationship: &pb.Relationship{
-				Resource: resourceRef,
-				Relation: actionToRelation(action),
-				Subject: &pb.SubjectReference{
-					Object:           roleRef,
-					OptionalRelation: roleSubjectRelation,
-				},
+	for i, subject := range subjects {
+		reqs[i] = &pb.CheckPermissionRequest{
+			Consistency: consistency,
+			Resource:    resourceRef,
+			Permission:  action,
+			Subject: &pb.SubjectReference{
+				Object: resourceToSpiceDBRef(e.namespace, subject),
 			},
-		})
+			Context: reqContext,
+		}
 	}
 
-	return rels
-}
+	allowed, errs := e.checkPermissions(ctx, reqs)
 
-func (e *engine) relationshipsToUpdates(rels []types.Relationship) []*pb.RelationshipUpdate {
-	relUpdates := make([]*pb.RelationshipUpdate, len(rels))
+	permitted := []types.Resource{}
 
-	for i, rel := range rels {
-		subjRef := resourceToSpiceDBRef(e.namespace, rel.Subject)
-		resRef := resourceToSpiceDBRef(e.namespace, rel.Resource)
+	for i, subject := range subjects {
+		if errs[i] != nil {
+			span.RecordError(errs[i])
+			span.SetStatus(codes.Error, errs[i].Error())
 
-		relUpdates[i] = &pb.RelationshipUpdate{
-			Operation: pb.RelationshipUpdate_OPERATION_TOUCH,
-			Relationship: &pb.Relationship{
-				Resource: resRef,
-				Relation: rel.Relation,
-				Subject: &pb.SubjectReference{
-					Object: subjRef,
-				},
-			},
+			return nil, errs[i]
+		}
+
+		if allowed[i] {
+			permitted = append(permitted, subject)
 		}
 	}
 
-	return relUpdates
+	span.SetAttributes(attribute.Int("permissions.permitted", len(permitted)))
+
+	return permitted, nil
 }
 
-func (e *engine) readRelationships(ctx context.Context, filter *pb.RelationshipFilter, queryToken string) ([]*pb.Relationship, error) {
-	var req pb.ReadRelationshipsRequest
+// FilterResourcesByPermission returns the resources on which the subject may perform the action,
+// checking them concurrently. The resources are returned in the order given.
+func (e *engine) FilterResourcesByPermission(ctx context.Context, subject types.Resource, action string, resources []types.Resource, queryToken string) (_ []types.Resource, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.FilterResourcesByPermission",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.String("permissions.action", action),
+			attribute.Int("permissions.resources", len(resources)),
+		),
+	)
 
-	if queryToken != "" {
-		req.Consistency = &pb.Consistency{
-			Requirement: &pb.Consistency_AtLeastAsFresh{
-				AtLeastAsFresh: &pb.ZedToken{
-					Token: queryToken,
-				},
-			},
+	defer span.End()
+	defer e.observe(ctx, "FilterResourcesByPermission", time.Now(), &err)
+
+	checks := make([]PermissionCheck, len(resources))
+
+	for i, resource := range resources {
+		if err := e.validateAction(resource.Type, action); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
 		}
-	}
 
-	req.RelationshipFilter = filter
+		checks[i] = PermissionCheck{
+			Action:   action,
+			Resource: resource,
+		}
+	}
 
-	r, err := e.client.ReadRelationships(ctx, &req)
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, queryToken), subject, checks)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
 		return nil, err
 	}
 
-	var (
-		responses []*pb.Relationship
-		done      bool
-	)
+	permitted := []types.Resource{}
 
-	for !done {
-		rel, err := r.Recv()
-		switch err {
-		case nil:
-			responses = append(responses, rel.Relationship)
-		case io.EOF:
-			done = true
-		default:
-			return nil, err
+	for _, result := range results {
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
+
+			return nil, result.Err
+		}
+
+		if result.Allowed {
+			permitted = append(permitted, result.Resource)
 		}
 	}
 
-	return responses, nil
+	span.SetAttributes(attribute.Int("permissions.permitted", len(permitted)))
+
+	return permitted, nil
 }
 
-// DeleteRelationships removes the specified relationships.
-// If any relationships fails to be deleted, all completed deletions are re-created.
-func (e *engine) DeleteRelationships(ctx context.Context, relationships ...types.Relationship) (string, error) {
-	ctx, span := e.tracer.Start(ctx, "engine.DeleteRelationships", trace.WithAttributes(attribute.Int("relationships", len(relationships))))
+// SubjectHasAnyPermission reports whether the subject may perform any of the given actions on the resource,
+// checking them concurrently. A subject denied every action returns false with no error.
+// A check which fails only returns an error if no other action is permitted.
+func (e *engine) SubjectHasAnyPermission(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (_ bool, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectHasAnyPermission",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+				attribute.StringSlice("permissions.actions", actions),
+			)...,
+		),
+	)
 
 	defer span.End()
+	defer e.observe(ctx, "SubjectHasAnyPermission", time.Now(), &err)
 
-	var errors []error
-
-	span.AddEvent("validating relationships")
-
-	for i, relationship := range relationships {
-		err := e.validateRelationship(relationship)
-		if err != nil {
-			err = fmt.Errorf("%w: invalid relationship %d", err, i)
+	checks := make([]PermissionCheck, len(actions))
 
+	for i, action := range actions {
+		if err := e.validateAction(resource.Type, action); err != nil {
 			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 
-			errors = append(errors, err)
+			return false, err
+		}
+
+		checks[i] = PermissionCheck{
+			Action:   action,
+			Resource: resource,
 		}
 	}
 
-	if len(errors) != 0 {
-		span.SetStatus(codes.Error, "invalid relationships")
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, queryToken), subject, checks)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-		return "", multierr.Combine(errors...)
+		return false, err
 	}
 
-	errors = []error{}
+	allowed, err := anyAllowed(results)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	var (
-		complete   []types.Relationship
-		queryToken string
-		dErr       error
-		cErr       error
-	)
+		return false, err
+	}
 
-	span.AddEvent("deleting relationships")
+	span.SetAttributes(attribute.Bool("permissions.allowed", allowed))
 
-	for i, relationship := range relationships {
-		resType := e.namespace + "/" + relationship.Resource.Type
-		subjType := e.namespace + "/" + relationship.Subject.Type
+	return allowed, nil
+}
 
-		filter := &pb.RelationshipFilter{
-			ResourceType:       resType,
-			OptionalResourceId: relationship.Resource.ID.String(),
-			OptionalRelation:   relationship.Relation,
-			OptionalSubjectFilter: &pb.SubjectFilter{
-				SubjectType:       subjType,
-				OptionalSubjectId: relationship.Subject.ID.String(),
-			},
-		}
+// SubjectHasAnyAccess reports whether the subject may perform any of the actions defined for the resource's
+// type, checking them concurrently, for coarse visibility filtering. A subject denied every
+// action, or a resource type without actions, returns false with no error.
+func (e *engine) SubjectHasAnyAccess(ctx context.Context, subject types.Resource, resource types.Resource, queryToken string) (_ bool, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectHasAnyAccess",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+			)...,
+		),
+	)
 
-		queryToken, dErr = e.deleteRelationships(ctx, filter)
-		if dErr != nil {
-			e.logger.Errorf("%w: failed to delete relationship %d reverting %d completed deletes", dErr, i, len(complete))
+	defer span.End()
+	defer e.observe(ctx, "SubjectHasAnyAccess", time.Now(), &err)
 
-			err := fmt.Errorf("%w: failed to delete relationship %d", dErr, i)
+	resType, ok := e.resourceType(resource.Type)
+	if !ok {
+		span.SetStatus(codes.Error, ErrInvalidType.Error())
 
-			span.RecordError(err)
+		return false, ErrInvalidType
+	}
 
-			errors = append(errors, err)
+	seen := make(map[string]struct{}, len(resType.Actions))
+	checks := make([]PermissionCheck, 0, len(resType.Actions))
 
-			break
+	for _, action := range resType.Actions {
+		if _, ok := seen[action.Name]; ok {
+			continue
 		}
 
-		complete = append(complete, relationship)
+		seen[action.Name] = struct{}{}
+
+		checks = append(checks, PermissionCheck{
+			Action:   action.Name,
+			Resource: resource,
+		})
 	}
 
-	if len(errors) != 0 {
-		span.SetStatus(codes.Error, "error occurred deleting relationships")
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, queryToken), subject, checks)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-		if len(complete) != 0 {
-			span.AddEvent("recreating deleted relationships")
+		return false, err
+	}
 
-			_, cErr = e.CreateRelationships(ctx, complete)
-			if cErr != nil {
-				e.logger.Error("%w: failed to revert %d deleted relationships", cErr, len(complete))
+	allowed, err := anyAllowed(results)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-				err := fmt.Errorf("%w: failed to revert deleted relationships", cErr)
+		return false, err
+	}
 
-				span.RecordError(err)
+	span.SetAttributes(attribute.Bool("permissions.allowed", allowed))
 
-				errors = append(errors, err)
-			}
+	return allowed, nil
+}
+
+// anyAllowed returns true if any result is allowed. A failed check only returns an error if no
+// result is allowed.
+func anyAllowed(results []PermissionResult) (bool, error) {
+	var checkErr error
+
+	for _, result := range results {
+		if result.Allowed {
+			return true, nil
 		}
 
-		return "", multierr.Combine(errors...)
+		if result.Err != nil && checkErr == nil {
+			checkErr = result.Err
+		}
 	}
 
-	return queryToken, nil
+	return false, checkErr
 }
 
-// DeleteResourceRelationships deletes all relationships originating from the given resource.
-func (e *engine) DeleteResourceRelationships(ctx context.Context, resource types.Resource) (string, error) {
-	resType := e.namespace + "/" + resource.Type
+// SubjectHasAllPermissions reports whether the subject may perform every one of the given actions on the
+// resource, checking them concurrently. When an action is denied, false is returned along with
+// ErrActionNotAssigned naming the first action denied, in the order given.
+func (e *engine) SubjectHasAllPermissions(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (_ bool, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectHasAllPermissions",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(resource),
+				attribute.Stringer("permissions.actor", subject.ID),
+				attribute.StringSlice("permissions.actions", actions),
+			)...,
+		),
+	)
 
-	filter := &pb.RelationshipFilter{
-		ResourceType:       resType,
-		OptionalResourceId: resource.ID.String(),
-	}
+	defer span.End()
+	defer e.observe(ctx, "SubjectHasAllPermissions", time.Now(), &err)
 
-	return e.deleteRelationships(ctx, filter)
-}
+	checks := make([]PermissionCheck, len(actions))
 
-func (e *engine) deleteRelationships(ctx context.Context, filter *pb.RelationshipFilter) (string, error) {
-	request := &pb.DeleteRelationshipsRequest{
-		RelationshipFilter: filter,
-	}
-	r, err := e.client.DeleteRelationships(ctx, request)
+	for i, action := range actions {
+		if err := e.validateAction(resource.Type, action); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 
-	if err != nil {
-		return "", err
-	}
+			return false, err
+		}
 
-	return r.DeletedAt.GetToken(), nil
-}
+		checks[i] = PermissionCheck{
+			Action:   action,
+			Resource: resource,
+		}
+	}
 
-func relationshipsToRoles(rels []*pb.Relationship) []types.Role {
-	var roleIDs []gidx.PrefixedID
+	results, err := e.bulkCheckPermissions(ctx, e.checkConsistency(ctx, queryToken), subject, checks)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	roleMap := make(map[gidx.PrefixedID]*types.Role)
+		return false, err
+	}
 
-	for _, rel := range rels {
-		roleIDStr := rel.Subject.Object.ObjectId
+	for _, result := range results {
+		if result.Err != nil {
+			span.RecordError(result.Err)
+			span.SetStatus(codes.Error, result.Err.Error())
 
-		roleID, err := gidx.Parse(roleIDStr)
-		if err != nil {
-			panic(err)
+			return false, result.Err
 		}
 
-		action := relationToAction(rel.Relation)
+		if !result.Allowed {
+			span.SetAttributes(
+				attribute.Bool("permissions.allowed", false),
+				attribute.String("permissions.denied_action", result.Action),
+			)
 
-		_, ok := roleMap[roleID]
-		if !ok {
-			roleIDs = append(roleIDs, roleID)
-			role := types.Role{
-				ID: roleID,
-			}
-			roleMap[roleID] = &role
+			return false, fmt.Errorf("%w: %s", ErrActionNotAssigned, result.Action)
 		}
-
-		roleMap[roleID].Actions = append(roleMap[roleID].Actions, action)
 	}
 
-	out := make([]types.Role, len(roleIDs))
-	for i, roleID := range roleIDs {
-		out[i] = *roleMap[roleID]
-	}
+	span.SetAttributes(attribute.Bool("permissions.allowed", true))
 
-	return out
+	return true, nil
 }
 
-func (e *engine) relationshipsToNonRoles(rels []*pb.Relationship) ([]types.Relationship, error) {
-	var out []types.Relationship
+// maxConcurrentChecks bounds the CheckPermission requests a bulk check has in flight at once.
+const maxConcurrentChecks = 10
 
-	for _, rel := range rels {
-		if rel.Subject.Object.ObjectType == e.namespace+"/role" {
-			continue
-		}
+// bulkCheckPermissions checks all of the given checks for the subject. The SpiceDB API has no bulk check,
+// so a CheckPermission request is sent per check, at most maxConcurrentChecks at a time. A check which
+// fails is reported by its result's Err rather than failing the others.
+func (e *engine) bulkCheckPermissions(ctx context.Context, consistency *pb.Consistency, subject types.Resource, checks []PermissionCheck) ([]PermissionResult, error) {
+	if len(checks) == 0 {
+		return []PermissionResult{}, nil
+	}
 
-		resID, err := gidx.Parse(rel.Resource.ObjectId)
-		if err != nil {
-			return nil, err
-		}
+	subjectRef := &pb.SubjectReference{
+		Object: resourceToSpiceDBRef(e.namespace, subject),
+	}
 
-		res, err := e.NewResourceFromID(resID)
-		if err != nil {
-			return nil, err
-		}
+	reqContext, err := checkContext(nil)
+	if err != nil {
+		return nil, err
+	}
 
-		subjID, err := gidx.Parse(rel.Subject.Object.ObjectId)
-		if err != nil {
-			return nil, err
-		}
+	reqs := make([]*pb.CheckPermissionRequest, len(checks))
 
-		subj, err := e.NewResourceFromID(subjID)
-		if err != nil {
-			return nil, err
+	for i, check := range checks {
+		reqs[i] = &pb.CheckPermissionRequest{
+			Consistency: consistency,
+			Resource:    resourceToSpiceDBRef(e.namespace, check.Resource),
+			Permission:  check.Action,
+			Subject:     subjectRef,
+			Context:     reqContext,
 		}
+	}
 
-		item := types.Relationship{
-			Resource: res,
-			Relation: rel.Relation,
-			Subject:  subj,
-		}
+	allowed, errs := e.checkPermissions(ctx, reqs)
 
-		out = append(out, item)
+	results := make([]PermissionResult, len(checks))
+
+	for i, check := range checks {
+		results[i] = PermissionResult{
+			Action:   check.Action,
+			Resource: check.Resource,
+			Allowed:  allowed[i],
+			Err:      errs[i],
+		}
 	}
 
-	return out, nil
+	return results, nil
+}
+
+// checkPermissions sends the given check requests, at most maxConcurrentChecks at a time, and returns
+// whether each is allowed along with the error of each which failed. A check which is conditional on
+// missing caveat context is not allowed.
+func (e *engine) checkPermissions(ctx context.Context, reqs []*pb.CheckPermissionRequest) ([]bool, []error) {
+	var (
+		allowed = make([]bool, len(reqs))
+		errs    = make([]error, len(reqs))
+		sem     = make(chan struct{}, maxConcurrentChecks)
+		wg      sync.WaitGroup
+	)
+
+	for i, req := range reqs {
+		i, req := i, req
+
+		sem <- struct{}{}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			var resp *pb.CheckPermissionResponse
+
+			err := e.retry(ctx, true, func() (err error) {
+				resp, err = e.client.CheckPermission(ctx, req)
+
+				return err
+			})
+			if err != nil {
+				errs[i] = newSpiceDBError(err)
+
+				return
+			}
+
+			allowed[i] = hasPermission(resp.Permissionship)
+		}()
+	}
+
+	wg.Wait()
+
+	return allowed, errs
+}
+
+// AssignSubjectRole assigns the given role to the given subject.
+// A wildcard subject assigns the role to every subject of its type.
+func (e *engine) AssignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.AssignSubjectRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "AssignSubjectRole", time.Now(), &err)
+
+	if subject.IsWildcard() {
+		if err := e.validateRelationship(roleAssignmentRelationship(subject, role)); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return "", err
+		}
+	}
+
+	if err := e.checkRoleNotDeleted(ctx, role.Resource()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	request := &pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{
+			e.subjectRoleRelCreate(subject, role),
+		},
+	}
+	r, err := e.writeRelationships(ctx, request)
+
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	e.notifyCreate(ctx, []types.Relationship{roleAssignmentRelationship(subject, role)}, r.WrittenAt.GetToken())
+	e.publishRoleAssignmentEvents(ctx, RoleEventTypeAssign, role, []types.Resource{subject}, r.WrittenAt.GetToken())
+
+	return r.WrittenAt.GetToken(), nil
+}
+
+// AssignSubjectRoles assigns the given role to all of the given subjects, writing the assignments in
+// as few requests as SpiceDB allows. Every subject is validated before anything is written, so a subject
+// which may not be assigned the role fails the call without assigning the role to any subject. Assignments
+// are written in chunks of at most maxWriteUpdates, each in its own request, so a failure part way through
+// leaves the earlier chunks written and returns the query token of the last chunk written along with the
+// error. Subjects already assigned the role are left as is, so a failed call may simply be retried. The
+// context's deadline applies to the whole batch. The returned query token is that of the final write.
+// AssignSubjectRolesWithReport continues past subjects which fail instead.
+func (e *engine) AssignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (string, error) {
+	report, err := e.AssignSubjectRolesWithReport(ctx, subjects, role, StrictAssignments())
+
+	return report.QueryToken, err
+}
+
+// FailedAssignment is a subject a bulk assignment did not assign the role to, along with the reason.
+type FailedAssignment struct {
+	Subject types.Resource
+	Err     error
+}
+
+// AssignmentReport describes the subjects assigned a role by AssignSubjectRolesWithReport.
+type AssignmentReport struct {
+	// QueryToken is the query token of the last chunk of assignments written.
+	QueryToken string
+	// Assigned are the subjects assigned the role, without duplicates.
+	Assigned []types.Resource
+	// Failed are the subjects which were not assigned the role.
+	Failed []FailedAssignment
+}
+
+// AssignOption is a functional option for bulk role assignments.
+type AssignOption func(opts *assignOptions)
+
+type assignOptions struct {
+	strict bool
+}
+
+// StrictAssignments makes a bulk assignment all or nothing for validation: a subject which may not be
+// assigned the role fails the call before anything is written, and a chunk which fails to be written
+// ends the call. Chunks written before a failure are not undone.
+func StrictAssignments() AssignOption {
+	return func(opts *assignOptions) {
+		opts.strict = true
+	}
+}
+
+// AssignSubjectRolesWithReport assigns the given role to all of the given subjects as AssignSubjectRoles
+// does, but continues past subjects which fail, reporting which subjects were assigned the role and
+// which failed along with the reason. A subject fails if it may not be assigned the role, or if the
+// chunk of assignments it was written in fails. An error is only returned if the call could not
+// continue, such as when the context ends, unless StrictAssignments is given.
+func (e *engine) AssignSubjectRolesWithReport(ctx context.Context, subjects []types.Resource, role types.Role, opts ...AssignOption) (_ AssignmentReport, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.AssignSubjectRoles",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+			attribute.Int("permissions.subjects", len(subjects)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "AssignSubjectRoles", time.Now(), &err)
+
+	var options assignOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var (
+		report AssignmentReport
+		valid  = make([]types.Resource, 0, len(subjects))
+	)
+
+	// A deleted role may not be assigned to any subject, so the call fails as a whole.
+	if err := e.checkRoleNotDeleted(ctx, role.Resource()); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return report, err
+	}
+
+	for _, subject := range subjects {
+		if err := e.validateRelationship(roleAssignmentRelationship(subject, role)); err != nil {
+			report.Failed = append(report.Failed, FailedAssignment{
+				Subject: subject,
+				Err:     err,
+			})
+
+			if options.strict {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return report, err
+			}
+
+			continue
+		}
+
+		valid = append(valid, subject)
+	}
+
+	var onChunkError func([]types.Resource, error) bool
+
+	if !options.strict {
+		onChunkError = func(chunk []types.Resource, err error) bool {
+			for _, subject := range chunk {
+				report.Failed = append(report.Failed, FailedAssignment{
+					Subject: subject,
+					Err:     err,
+				})
+			}
+
+			return true
+		}
+	}
+
+	// Touching rather than creating leaves subjects which already have the role as is,
+	// so a partially written batch may be retried.
+	queryToken, written, err := e.updateSubjectRoles(ctx, pb.RelationshipUpdate_OPERATION_TOUCH, valid, role, onChunkError)
+
+	report.QueryToken = queryToken
+	report.Assigned = written
+
+	span.SetAttributes(
+		attribute.Int("permissions.assigned", len(report.Assigned)),
+		attribute.Int("permissions.failed", len(report.Failed)),
+	)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return report, err
+	}
+
+	recordZedToken(span, queryToken)
+
+	return report, nil
+}
+
+// UnassignSubjectRoles removes the given role from all of the given subjects, in chunks of at most
+// maxWriteUpdates relationships. Each chunk is removed atomically in its own request, so a failure
+// part way through leaves the earlier chunks removed and returns the query token of the last chunk
+// removed along with the error; retrying the call with the same subjects completes the removal.
+// Subjects which are not assigned the role are ignored.
+func (e *engine) UnassignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.UnassignSubjectRoles",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+			attribute.Int("permissions.subjects", len(subjects)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "UnassignSubjectRoles", time.Now(), &err)
+
+	// Deleting a relationship which does not exist does nothing.
+	queryToken, _, err := e.updateSubjectRoles(ctx, pb.RelationshipUpdate_OPERATION_DELETE, subjects, role, nil)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return queryToken, err
+	}
+
+	recordZedToken(span, queryToken)
+
+	return queryToken, nil
+}
+
+// updateSubjectRoles applies the operation to the role assignments of the subjects, writing them in
+// chunks of at most maxWriteUpdates updates. The query token of the last chunk written is returned,
+// even if a later chunk fails, along with the subjects of the chunks written. When a chunk fails,
+// onChunkError is called with its subjects and the error, and the remaining chunks are written if it
+// returns true. Without onChunkError, the first chunk to fail ends the update.
+func (e *engine) updateSubjectRoles(ctx context.Context, op pb.RelationshipUpdate_Operation, subjects []types.Resource, role types.Role, onChunkError func([]types.Resource, error) bool) (string, []types.Resource, error) {
+	// Rejected before any chunk is written, so a read-only engine is not reported as failing each subject.
+	if err := e.checkWritable(); err != nil {
+		return "", nil, err
+	}
+
+	var (
+		seen    = make(map[types.Resource]struct{}, len(subjects))
+		unique  []types.Resource
+		updates []*pb.RelationshipUpdate
+	)
+
+	for _, subject := range subjects {
+		// SpiceDB rejects requests which update the same relationship more than once.
+		if _, ok := seen[subject]; ok {
+			continue
+		}
+
+		seen[subject] = struct{}{}
+
+		update := e.subjectRoleRelCreate(subject, role)
+		update.Operation = op
+
+		unique = append(unique, subject)
+		updates = append(updates, update)
+	}
+
+	eventType := RoleEventTypeAssign
+	if op == pb.RelationshipUpdate_OPERATION_DELETE {
+		eventType = RoleEventTypeUnassign
+	}
+
+	var (
+		queryToken string
+		written    []types.Resource
+	)
+
+	// The events of every chunk written are queued together once the update ends, so the role is looked
+	// up once for all of them.
+	defer func() {
+		if len(written) != 0 {
+			e.publishRoleAssignmentEvents(ctx, eventType, role, written, queryToken)
+		}
+	}()
+
+	for n, chunk := range chunkUpdates(updates, maxWriteUpdates) {
+		if err := ctx.Err(); err != nil {
+			return queryToken, written, err
+		}
+
+		offset := n * maxWriteUpdates
+		chunkSubjects := unique[offset : offset+len(chunk)]
+
+		r, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{Updates: chunk})
+		if err != nil {
+			err = newSpiceDBError(err)
+
+			if onChunkError != nil && ctx.Err() == nil && onChunkError(chunkSubjects, err) {
+				continue
+			}
+
+			return queryToken, written, err
+		}
+
+		queryToken = r.WrittenAt.GetToken()
+		written = append(written, chunkSubjects...)
+
+		rels := make([]types.Relationship, len(chunkSubjects))
+
+		for i, subject := range chunkSubjects {
+			rels[i] = roleAssignmentRelationship(subject, role)
+		}
+
+		if op == pb.RelationshipUpdate_OPERATION_DELETE {
+			e.notifyDelete(ctx, rels, queryToken)
+		} else {
+			e.notifyCreate(ctx, rels, queryToken)
+		}
+	}
+
+	return queryToken, written, nil
+}
+
+// chunkUpdates splits updates into chunks of at most size updates.
+func chunkUpdates(updates []*pb.RelationshipUpdate, size int) [][]*pb.RelationshipUpdate {
+	var chunks [][]*pb.RelationshipUpdate
+
+	for len(updates) > size {
+		chunks = append(chunks, updates[:size])
+		updates = updates[size:]
+	}
+
+	if len(updates) != 0 {
+		chunks = append(chunks, updates)
+	}
+
+	return chunks
+}
+
+// UnassignSubjectRole removes the given role from the given subject.
+func (e *engine) UnassignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.UnassignSubjectRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "UnassignSubjectRole", time.Now(), &err)
+
+	queryToken, err := e.deleteRelationships(ctx, e.subjectRoleRelDelete(subject, role))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, queryToken)
+
+	e.notifyDelete(ctx, []types.Relationship{roleAssignmentRelationship(subject, role)}, queryToken)
+	e.publishRoleAssignmentEvents(ctx, RoleEventTypeUnassign, role, []types.Resource{subject}, queryToken)
+
+	return queryToken, nil
+}
+
+// ListAssignments returns the assigned subjects for a given role.
+func (e *engine) ListAssignments(ctx context.Context, role types.Role, queryToken string) (_ []types.Resource, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ListAssignments",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ListAssignments", time.Now(), &err)
+
+	roleType := e.namespace + "/role"
+	filter := &pb.RelationshipFilter{
+		ResourceType:       roleType,
+		OptionalResourceId: role.ID.String(),
+		OptionalRelation:   roleSubjectRelation,
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	out := make([]types.Resource, 0, len(relationships))
+
+	for _, rel := range relationships {
+		// Subject sets are the subjects of child roles, which are not assigned the role directly.
+		if rel.Subject.OptionalRelation != "" {
+			continue
+		}
+
+		res, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		out = append(out, res)
+	}
+
+	span.SetAttributes(attribute.Int("permissions.assignments", len(out)))
+
+	return out, nil
+}
+
+// CountAssignments returns the number of subjects directly assigned the given role. SpiceDB has no
+// aggregate reads, so the assignments are read a page at a time and counted without being resolved.
+func (e *engine) CountAssignments(ctx context.Context, role types.Role, queryToken string) (_ int, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.CountAssignments",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "CountAssignments", time.Now(), &err)
+
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/role",
+		OptionalResourceId: role.ID.String(),
+		OptionalRelation:   roleSubjectRelation,
+	}
+
+	var (
+		count int
+		page  = PageOpts{Limit: countPageSize}
+	)
+
+	for {
+		relationships, cursor, err := e.readRelationshipsPage(ctx, filter, queryToken, page)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return 0, err
+		}
+
+		for _, rel := range relationships {
+			// Subject sets are the subjects of child roles, which are not assigned the role directly.
+			if rel.Subject.OptionalRelation == "" {
+				count++
+			}
+		}
+
+		if cursor == "" {
+			break
+		}
+
+		page.Cursor = cursor
+	}
+
+	span.SetAttributes(attribute.Int("permissions.assignments", count))
+
+	return count, nil
+}
+
+// ListRolesForSubject returns every role the subject is directly assigned, across all resources,
+// ordered by role ID. Each role's Owner is set to the resource the role is defined on.
+func (e *engine) ListRolesForSubject(ctx context.Context, subject types.Resource, queryToken string) (_ []types.Role, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ListRolesForSubject",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ListRolesForSubject", time.Now(), &err)
+
+	filter := &pb.RelationshipFilter{
+		ResourceType:     e.namespace + "/role",
+		OptionalRelation: roleSubjectRelation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/" + subject.Type,
+			OptionalSubjectId: subject.ID.String(),
+			// Only match the subject itself, not subject sets such as a parent role's subjects.
+			OptionalRelation: &pb.SubjectFilter_RelationFilter{},
+		},
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	out := make([]types.Role, 0, len(relationships))
+
+	for _, rel := range relationships {
+		roleRes, err := e.resourceFromSpiceDBRef(rel.Resource)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		role, err := e.GetRole(ctx, roleRes, queryToken)
+		if err != nil {
+			// The assignment outlived its role, there is no scope to report.
+			if errors.Is(err, ErrRoleNotFound) {
+				continue
+			}
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		out = append(out, role)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	span.SetAttributes(attribute.Int("permissions.roles", len(out)))
+
+	return out, nil
+}
+
+// SubjectHasRole returns true if the subject is assigned the role, checking the role's assignment
+// directly rather than resolving the role's actions. The subject has the role through an assignment
+// which has not expired, an assignment to one of its groups, or an assignment to a child of the role.
+// A role which does not exist returns ErrRoleNotFound.
+func (e *engine) SubjectHasRole(ctx context.Context, subject types.Resource, role types.Role, queryToken string) (_ bool, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.SubjectHasRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.actor", subject.ID),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.String("permissions.relation", roleSubjectRelation),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "SubjectHasRole", time.Now(), &err)
+
+	roleResource := role.Resource()
+
+	actions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
+	}
+
+	if len(actions) == 0 {
+		span.RecordError(ErrRoleNotFound)
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return false, ErrRoleNotFound
+	}
+
+	reqContext, err := checkContext(nil)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
+	}
+
+	req := &pb.CheckPermissionRequest{
+		Consistency: e.checkConsistency(ctx, queryToken),
+		Resource:    resourceToSpiceDBRef(e.namespace, roleResource),
+		Permission:  roleSubjectRelation,
+		Subject: &pb.SubjectReference{
+			Object: resourceToSpiceDBRef(e.namespace, subject),
+		},
+		Context: reqContext,
+	}
+
+	assigned, err := e.checkPermission(ctx, req)
+	if err != nil && !errors.Is(err, ErrActionNotAssigned) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
+	}
+
+	span.SetAttributes(attribute.Bool("permissions.assigned", assigned))
+
+	return assigned, nil
+}
+
+func (e *engine) subjectRoleRelCreate(subject types.Resource, role types.Role) *pb.RelationshipUpdate {
+	roleResource := role.Resource()
+
+	return &pb.RelationshipUpdate{
+		Operation: pb.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: &pb.Relationship{
+			Resource: resourceToSpiceDBRef(e.namespace, roleResource),
+			Relation: roleSubjectRelation,
+			Subject: &pb.SubjectReference{
+				Object: resourceToSpiceDBRef(e.namespace, subject),
+			},
+		},
+	}
+}
+
+func (e *engine) subjectRoleRelDelete(subject types.Resource, role types.Role) *pb.RelationshipFilter {
+	roleResource := role.Resource()
+
+	return &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/" + roleResource.Type,
+		OptionalResourceId: roleResource.ID.String(),
+		OptionalRelation:   roleSubjectRelation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/" + subject.Type,
+			OptionalSubjectId: subject.ID.String(),
+		},
+	}
+}
+
+// checkPermission returns whether the check is allowed. A check which is conditional on missing caveat
+// context is not allowed and returns ErrActionNotAssigned listing the missing context.
+func (e *engine) checkPermission(ctx context.Context, req *pb.CheckPermissionRequest) (bool, error) {
+	var resp *pb.CheckPermissionResponse
+
+	err := e.retry(ctx, true, func() (err error) {
+		resp, err = e.client.CheckPermission(ctx, req)
+
+		return err
+	})
+	if err != nil {
+		return false, newSpiceDBError(err)
+	}
+
+	if resp.Permissionship == pb.CheckPermissionResponse_PERMISSIONSHIP_CONDITIONAL_PERMISSION {
+		missing := resp.GetPartialCaveatInfo().GetMissingRequiredContext()
+
+		return false, fmt.Errorf("%w: missing caveat context: %s", ErrActionNotAssigned, strings.Join(missing, ", "))
+	}
+
+	return hasPermission(resp.Permissionship), nil
+}
+
+func hasPermission(permissionship pb.CheckPermissionResponse_Permissionship) bool {
+	return permissionship == pb.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+}
+
+// CreateRelationships atomically creates the given relationships in SpiceDB. Relationships which already
+// exist are left in place, and their metadata replaced by that given, so writing the same relationships
+// again is not an error. Callers which must know a relationship is new should use CreateRelationshipsStrict.
+func (e *engine) CreateRelationships(ctx context.Context, rels []types.Relationship) (string, error) {
+	return e.writeNewRelationships(ctx, "CreateRelationships", rels, pb.RelationshipUpdate_OPERATION_TOUCH)
+}
+
+// CreateRelationshipsStrict atomically creates the given relationships in SpiceDB. Unlike CreateRelationships,
+// creation is strict: if any of the relationships already exists none are written and ErrRelationshipExists
+// is returned.
+func (e *engine) CreateRelationshipsStrict(ctx context.Context, rels []types.Relationship) (string, error) {
+	return e.writeNewRelationships(ctx, "CreateRelationshipsStrict", rels, pb.RelationshipUpdate_OPERATION_CREATE)
+}
+
+// UpsertRelationships atomically writes the given relationships in SpiceDB, creating those which do not
+// exist and leaving those which do in place, so re-running a reconciliation loop is safe. It is the same
+// as CreateRelationships, and names the semantics for callers which depend on them. The metadata of an
+// existing relationship is replaced by that given.
+func (e *engine) UpsertRelationships(ctx context.Context, rels []types.Relationship) (string, error) {
+	return e.writeNewRelationships(ctx, "UpsertRelationships", rels, pb.RelationshipUpdate_OPERATION_TOUCH)
+}
+
+// writeNewRelationships validates and writes the given relationships with the given operation.
+func (e *engine) writeNewRelationships(ctx context.Context, method string, rels []types.Relationship, op pb.RelationshipUpdate_Operation) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine."+method,
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Int("relationships", len(rels)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, method, time.Now(), &err)
+
+	for _, rel := range rels {
+		err := e.validateRelationship(rel)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return "", err
+		}
+	}
+
+	relUpdates := e.relationshipsToUpdates(rels)
+
+	for _, update := range relUpdates {
+		update.Operation = op
+	}
+
+	request := &pb.WriteRelationshipsRequest{
+		Updates: relUpdates,
+	}
+
+	r, err := e.writeRelationships(ctx, request)
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	e.notifyCreate(ctx, rels, r.WrittenAt.GetToken())
+
+	return r.WrittenAt.GetToken(), nil
+}
+
+// expandActions replaces each composite action of the policy with the actions it includes, keeping
+// the first occurrence of each action. Actions are returned unchanged if none are composite actions.
+func (e *engine) expandActions(actions []string) []string {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	hasComposite := false
+
+	for _, action := range actions {
+		if _, ok := e.compositeActions[action]; ok {
+			hasComposite = true
+
+			break
+		}
+	}
+
+	if !hasComposite {
+		return actions
+	}
+
+	out := make([]string, 0, len(actions))
+
+	for _, action := range actions {
+		included, ok := e.compositeActions[action]
+		if !ok {
+			included = []string{action}
+		}
+
+		for _, name := range included {
+			if !containsString(out, name) {
+				out = append(out, name)
+			}
+		}
+	}
+
+	return out
+}
+
+// validateRoleActions ensures each action may be granted by a role on the given resource.
+func (e *engine) validateRoleActions(res types.Resource, actions []string) error {
+	resType, ok := e.resourceType(res.Type)
+	if !ok {
+		return ErrInvalidType
+	}
+
+	for _, action := range actions {
+		if !iapl.ValidActionName(action) {
+			return fmt.Errorf("%w: %q must be %s", ErrInvalidActionName, action, iapl.ActionNameRules)
+		}
+
+		if !resourceTypeHasRoleAction(resType, action) {
+			return fmt.Errorf("%w: %s", ErrInvalidAction, action)
+		}
+	}
+
+	return nil
+}
+
+func resourceTypeHasRoleAction(resType types.ResourceType, action string) bool {
+	for _, typeAction := range resType.Actions {
+		if typeAction.Name != action {
+			continue
+		}
+
+		for _, cond := range typeAction.Conditions {
+			if cond.RoleBinding != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// CreateRole creates a role scoped to the given resource with the given actions.
+// A name and description may optionally be provided with WithRoleName and WithRoleDescription.
+// Composite actions are replaced by the actions they include, so the role's actions are the expanded set.
+// When the role's ID is given with WithRoleID and a role with the ID already exists, the existing role is
+// returned unchanged with no query token, whatever actions and options it was created with.
+func (e *engine) CreateRole(ctx context.Context, res types.Resource, actions []string, opts ...RoleOption) (_ types.Role, _ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.CreateRole",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(res),
+				attribute.Int("permissions.actions", len(actions)),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "CreateRole", time.Now(), &err)
+
+	if err := e.validateRoleOwner(res); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	actions = e.expandActions(actions)
+
+	if err := e.validateRoleActions(res, actions); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	role, idGiven, err := newRole(actions, opts...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	role.Owner = res
+
+	span.SetAttributes(attribute.Stringer("permissions.role", role.ID))
+
+	if idGiven {
+		existing, ok, err := e.existingRole(ctx, role)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return types.Role{}, "", err
+		}
+
+		if ok {
+			span.SetAttributes(attribute.Bool("permissions.role_exists", true))
+
+			return existing, "", nil
+		}
+	}
+
+	roleRels, err := e.roleUpdates(role, res)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	request := &pb.WriteRelationshipsRequest{Updates: roleRels}
+
+	r, err := e.writeRelationships(ctx, request)
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		// The role was created concurrently with the same ID, as by a retry racing the original request.
+		if idGiven && errors.Is(err, ErrRelationshipExists) {
+			existing, ok, existingErr := e.existingRole(ctx, role)
+
+			switch {
+			case errors.Is(existingErr, ErrRoleConflict):
+				err = existingErr
+			case existingErr == nil && ok:
+				span.SetAttributes(attribute.Bool("permissions.role_exists", true))
+
+				return existing, "", nil
+			}
+		}
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	e.publishRoleEvent(ctx, roleEvent{
+		eventType: RoleEventTypeCreate,
+		role:      role,
+		resource:  res,
+	})
+
+	return role, r.WrittenAt.GetToken(), nil
+}
+
+// existingRole returns the role with the ID of the given role if it exists, reading fully consistently
+// so a role created just before is found. If the existing role has a different owner or actions, it is
+// not the role being created, and ErrRoleConflict is returned.
+func (e *engine) existingRole(ctx context.Context, role types.Role) (types.Role, bool, error) {
+	existing, err := e.GetRole(ContextWithConsistency(ctx, ConsistencyFullyConsistent), role.Resource(), "")
+
+	switch {
+	case errors.Is(err, ErrRoleNotFound):
+		return types.Role{}, false, nil
+	case err != nil:
+		return types.Role{}, false, err
+	}
+
+	if existing.Owner.ID != role.Owner.ID {
+		return types.Role{}, false, fmt.Errorf("%w: role %s is owned by %s", ErrRoleConflict, role.ID, existing.Owner.ID)
+	}
+
+	if !sameStrings(existing.Actions, role.Actions) {
+		return types.Role{}, false, fmt.Errorf("%w: role %s has actions %s", ErrRoleConflict, role.ID, strings.Join(existing.Actions, ", "))
+	}
+
+	return existing, true, nil
+}
+
+// sameStrings reports whether a and b hold the same strings, in any order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[string]int, len(a))
+
+	for _, value := range a {
+		counts[value]++
+	}
+
+	for _, value := range b {
+		if counts[value] == 0 {
+			return false
+		}
+
+		counts[value]--
+	}
+
+	return true
+}
+
+// RoleTuples returns the relationships CreateRole would write to create a role with the given actions
+// on the owner, without writing anything. The actions are expanded and validated as by CreateRole. The
+// role has a newly generated ID, so the tuples show the shape of the role rather than a role which
+// exists or will exist.
+func (e *engine) RoleTuples(owner types.Resource, actions []string) ([]types.Relationship, error) {
+	if err := e.validateRoleOwner(owner); err != nil {
+		return nil, err
+	}
+
+	actions = e.expandActions(actions)
+
+	if err := e.validateRoleActions(owner, actions); err != nil {
+		return nil, err
+	}
+
+	role, _, err := newRole(actions)
+	if err != nil {
+		return nil, err
+	}
+
+	role.Owner = owner
+
+	updates, err := e.roleUpdates(role, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]types.Relationship, len(updates))
+
+	for i, update := range updates {
+		rel, err := e.relationshipFromSpiceDB(update.Relationship)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = rel
+	}
+
+	return out, nil
+}
+
+// CreateRoles creates a role on the owner for each of the given specs in a single transaction.
+// Every spec is validated before anything is written, so either all roles are created or none are, and
+// a batch with more than maxWriteUpdates relationships returns ErrTransactionTooLarge. The roles are
+// returned in the same order as the specs, and a create event is published for each once they are written.
+// Composite actions are expanded as by CreateRole.
+func (e *engine) CreateRoles(ctx context.Context, owner types.Resource, roleSpecs []RoleSpec) (_ []types.Role, _ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.CreateRoles",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(owner),
+				attribute.Int("permissions.roles", len(roleSpecs)),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "CreateRoles", time.Now(), &err)
+
+	if len(roleSpecs) == 0 {
+		return []types.Role{}, "", nil
+	}
+
+	if err := e.validateRoleOwner(owner); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	roles := make([]types.Role, len(roleSpecs))
+
+	var updates []*pb.RelationshipUpdate
+
+	for i, spec := range roleSpecs {
+		spec.Actions = e.expandActions(spec.Actions)
+
+		if err := e.validateRoleActions(owner, spec.Actions); err != nil {
+			err = fmt.Errorf("role %d: %w", i, err)
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, "", err
+		}
+
+		role, _, err := newRole(spec.Actions, spec.options()...)
+		if err != nil {
+			err = fmt.Errorf("role %d: %w", i, err)
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, "", err
+		}
+
+		roleRels, err := e.roleUpdates(role, owner)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, "", err
+		}
+
+		role.Owner = owner
+
+		roles[i] = role
+		updates = append(updates, roleRels...)
+	}
+
+	if len(updates) > maxWriteUpdates {
+		err := fmt.Errorf("%w: %d updates, at most %d may be written together", ErrTransactionTooLarge, len(updates), maxWriteUpdates)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	request := &pb.WriteRelationshipsRequest{Updates: updates}
+
+	r, err := e.writeRelationships(ctx, request)
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	for _, role := range roles {
+		e.publishRoleEvent(ctx, roleEvent{
+			eventType: RoleEventTypeCreate,
+			role:      role,
+			resource:  owner,
+		})
+	}
+
+	return roles, r.WrittenAt.GetToken(), nil
+}
+
+// BootstrapTenant creates each of the policy's default roles on the tenant in a single transaction,
+// returning the roles in the order the policy declares them. Each role has its default role's name and
+// description. Roles are created whether or not the tenant already has them, so a tenant should be
+// bootstrapped once, when it is created. A policy without default roles creates nothing.
+func (e *engine) BootstrapTenant(ctx context.Context, tenant types.Resource) (_ []types.Role, _ string, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.BootstrapTenant", trace.WithAttributes(e.resourceAttributes(tenant)...))
+
+	defer span.End()
+	defer e.observe(ctx, "BootstrapTenant", time.Now(), &err)
+
+	defaultRoles := e.policyDefaultRoles()
+
+	specs := make([]RoleSpec, len(defaultRoles))
+
+	for i, role := range defaultRoles {
+		specs[i] = RoleSpec{
+			Actions:     role.Actions,
+			Name:        role.Name,
+			Description: role.Description,
+		}
+	}
+
+	roles, queryToken, err := e.CreateRoles(ctx, tenant, specs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.roles", len(roles)))
+
+	return roles, queryToken, nil
+}
+
+// policyDefaultRoles returns the default roles of the engine's policy.
+func (e *engine) policyDefaultRoles() []iapl.DefaultRole {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	return e.defaultRoles
+}
+
+// roleUpdates returns the relationship updates which create the role on the resource,
+// including its owner when the policy records role owners, the role's metadata when it has a name or description and its inheritance from any parents.
+func (e *engine) roleUpdates(role types.Role, res types.Resource) ([]*pb.RelationshipUpdate, error) {
+	roleRels := e.roleRelationships(role, res)
+
+	if _, ok := e.roleOwnerTypes(); ok {
+		roleRels = append(roleRels, e.roleOwnerUpdate(role, res))
+	}
+
+	if role.Name != "" || role.Description != "" {
+		metadataRel, err := e.roleMetadataUpdate(role)
+		if err != nil {
+			return nil, err
+		}
+
+		roleRels = append(roleRels, metadataRel)
+	}
+
+	if len(role.Parents) != 0 {
+		if !e.roleInheritanceSupported() {
+			return nil, fmt.Errorf("%w: policy does not allow role inheritance", ErrInvalidRoleParent)
+		}
+
+		for _, parentID := range role.Parents {
+			roleRels = append(roleRels, e.roleParentUpdate(role.ID, parentID))
+		}
+	}
+
+	return roleRels, nil
+}
+
+// roleOwnerTypes returns the resource types the policy allows to own roles. If the policy does not
+// name them, ok is false and roles may be owned by any type roles can be bound to.
+func (e *engine) roleOwnerTypes() (typeNames []string, ok bool) {
+	roleType, _ := e.resourceType(types.RoleResourceType)
+
+	for _, rel := range roleType.Relationships {
+		if rel.Relation == iapl.RoleOwnerRelation {
+			return rel.Types, true
+		}
+	}
+
+	return nil, false
+}
+
+// validateRoleOwner checks the resource may own roles.
+func (e *engine) validateRoleOwner(owner types.Resource) error {
+	ownerTypes, ok := e.roleOwnerTypes()
+	if !ok {
+		return nil
+	}
+
+	if !containsString(ownerTypes, owner.Type) {
+		return fmt.Errorf("%w: %s", ErrInvalidRoleOwner, owner.Type)
+	}
+
+	return nil
+}
+
+// roleOwnerUpdate builds the relationship recording the resource which owns the role.
+func (e *engine) roleOwnerUpdate(role types.Role, owner types.Resource) *pb.RelationshipUpdate {
+	return &pb.RelationshipUpdate{
+		Operation: pb.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: &pb.Relationship{
+			Resource: resourceToSpiceDBRef(e.namespace, role.Resource()),
+			Relation: iapl.RoleOwnerRelation,
+			Subject: &pb.SubjectReference{
+				Object: resourceToSpiceDBRef(e.namespace, owner),
+			},
+		},
+	}
+}
+
+// roleInheritanceSupported reports whether the policy allows the subjects of a role to be the subjects of another role.
+func (e *engine) roleInheritanceSupported() bool {
+	roleType, _ := e.resourceType("role")
+
+	for _, rel := range roleType.Relationships {
+		if rel.Relation != roleSubjectRelation {
+			continue
+		}
+
+		for _, typeName := range rel.Types {
+			if typeName == "role#"+roleSubjectRelation {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// roleParentUpdate builds the relationship which makes the subjects of the role subjects of the parent role.
+func (e *engine) roleParentUpdate(roleID, parentID gidx.PrefixedID) *pb.RelationshipUpdate {
+	return &pb.RelationshipUpdate{
+		Operation: pb.RelationshipUpdate_OPERATION_CREATE,
+		Relationship: &pb.Relationship{
+			Resource: resourceToSpiceDBRef(e.namespace, types.Resource{Type: "role", ID: parentID}),
+			Relation: roleSubjectRelation,
+			Subject: &pb.SubjectReference{
+				Object:           resourceToSpiceDBRef(e.namespace, types.Resource{Type: "role", ID: roleID}),
+				OptionalRelation: roleSubjectRelation,
+			},
+		},
+	}
+}
+
+// roleParentFilter matches the relationships which make the role's subjects subjects of its parent roles.
+func (e *engine) roleParentFilter(roleID gidx.PrefixedID) *pb.RelationshipFilter {
+	return &pb.RelationshipFilter{
+		ResourceType:     e.namespace + "/role",
+		OptionalRelation: roleSubjectRelation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/role",
+			OptionalSubjectId: roleID.String(),
+			OptionalRelation: &pb.SubjectFilter_RelationFilter{
+				Relation: roleSubjectRelation,
+			},
+		},
+	}
+}
+
+// readRoleParents populates the parents of the given role.
+func (e *engine) readRoleParents(ctx context.Context, role *types.Role, queryToken string) error {
+	relationships, err := e.readRelationships(ctx, e.roleParentFilter(role.ID), queryToken)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range relationships {
+		parentID, err := gidx.Parse(rel.Resource.ObjectId)
+		if err != nil {
+			return err
+		}
+
+		role.Parents = append(role.Parents, parentID)
+	}
+
+	return nil
+}
+
+// roleMetadataUpdate builds the relationship which stores the role's name, description and, for
+// a retained deleted role, when it was deleted. SpiceDB has no place for arbitrary data on an
+// object, so the metadata is stored as the caveat context of a relationship from the role to itself.
+func (e *engine) roleMetadataUpdate(role types.Role) (*pb.RelationshipUpdate, error) {
+	var deletedAt string
+
+	if role.IsDeleted() {
+		deletedAt = role.DeletedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	caveatContext, err := structpb.NewStruct(map[string]any{
+		"name":        role.Name,
+		"description": role.Description,
+		"deleted_at":  deletedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roleRef := resourceToSpiceDBRef(e.namespace, role.Resource())
+
+	return &pb.RelationshipUpdate{
+		Operation: pb.RelationshipUpdate_OPERATION_TOUCH,
+		Relationship: &pb.Relationship{
+			Resource: roleRef,
+			Relation: roleMetadataRelation,
+			Subject: &pb.SubjectReference{
+				Object: roleRef,
+			},
+			OptionalCaveat: &pb.ContextualizedCaveat{
+				CaveatName: e.namespace + "/" + roleMetadataCaveat,
+				Context:    caveatContext,
+			},
+		},
+	}, nil
+}
+
+// readRoleMetadata populates the name, description and deletion time of the given role.
+func (e *engine) readRoleMetadata(ctx context.Context, role *types.Role, queryToken string) error {
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/role",
+		OptionalResourceId: role.ID.String(),
+		OptionalRelation:   roleMetadataRelation,
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range relationships {
+		if err := setRoleMetadata(role, rel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readRolesMetadata populates the name, description and deletion time of each of the given roles,
+// reading the metadata of each role at most maxConcurrentChecks at a time.
+func (e *engine) readRolesMetadata(ctx context.Context, roles []types.Role, queryToken string) error {
+	return forEachRole(roles, func(role *types.Role) error {
+		return e.readRoleMetadata(ctx, role, queryToken)
+	})
+}
+
+// readRolesParents populates the parents of each of the given roles, reading the parents of each role
+// at most maxConcurrentChecks at a time.
+func (e *engine) readRolesParents(ctx context.Context, roles []types.Role, queryToken string) error {
+	return forEachRole(roles, func(role *types.Role) error {
+		return e.readRoleParents(ctx, role, queryToken)
+	})
+}
+
+// forEachRole calls fn for each of the given roles, at most maxConcurrentChecks at a time,
+// and returns the errors combined.
+func forEachRole(roles []types.Role, fn func(role *types.Role) error) error {
+	var (
+		errs = make([]error, len(roles))
+		sem  = make(chan struct{}, maxConcurrentChecks)
+		wg   sync.WaitGroup
+	)
+
+	for i := range roles {
+		i := i
+
+		sem <- struct{}{}
+
+		wg.Add(1)
+
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs[i] = fn(&roles[i])
+		}()
+	}
+
+	wg.Wait()
+
+	return multierr.Combine(errs...)
+}
+
+// setRoleMetadata sets the name, description and deletion time of the role from its metadata relationship.
+func setRoleMetadata(role *types.Role, rel *pb.Relationship) error {
+	fields := rel.GetOptionalCaveat().GetContext().GetFields()
+
+	role.Name = fields["name"].GetStringValue()
+	role.Description = fields["description"].GetStringValue()
+
+	if deletedAt := fields["deleted_at"].GetStringValue(); deletedAt != "" {
+		var err error
+
+		role.DeletedAt, err = time.Parse(time.RFC3339Nano, deletedAt)
+		if err != nil {
+			return fmt.Errorf("%w: role %s deleted_at: %s", ErrUnexpectedResponse, role.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func actionToRelation(action string) string {
+	return action + "_rel"
+}
+
+func relationToAction(relation string) string {
+	action, _, found := strings.Cut(relation, "_rel")
+
+	if !found {
+		panic("unexpected relation on role")
+	}
+
+	return action
+}
+
+func (e *engine) roleRelationships(role types.Role, resource types.Resource) []*pb.RelationshipUpdate {
+	var rels []*pb.RelationshipUpdate
+
+	roleResource, err := e.NewResourceFromID(role.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	resourceRef := resourceToSpiceDBRef(e.namespace, resource)
+	roleRef := resourceToSpiceDBRef(e.namespace, roleResource)
+
+	for _, action := range role.Actions {
+		rels = append(rels, roleActionUpdate(pb.RelationshipUpdate_OPERATION_TOUCH, resourceRef, roleRef, action))
+	}
+
+	return rels
+}
+
+func roleActionUpdate(op pb.RelationshipUpdate_Operation, resourceRef, roleRef *pb.ObjectReference, action string) *pb.RelationshipUpdate {
+	return &pb.RelationshipUpdate{
+		Operation: op,
+		Relationship: &pb.Relationship{
+			Resource: resourceRef,
+			Relation: actionToRelation(action),
+			Subject: &pb.SubjectReference{
+				Object:           roleRef,
+				OptionalRelation: roleSubjectRelation,
+			},
+		},
+	}
+}
+
+func (e *engine) relationshipsToUpdates(rels []types.Relationship) []*pb.RelationshipUpdate {
+	relUpdates := make([]*pb.RelationshipUpdate, len(rels))
+
+	for i, rel := range rels {
+		subjRef := resourceToSpiceDBRef(e.namespace, rel.Subject)
+		resRef := resourceToSpiceDBRef(e.namespace, rel.Resource)
+
+		relUpdates[i] = &pb.RelationshipUpdate{
+			Operation: pb.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: &pb.Relationship{
+				Resource: resRef,
+				Relation: rel.Relation,
+				Subject: &pb.SubjectReference{
+					Object:           subjRef,
+					OptionalRelation: rel.SubjectRelation,
+				},
+				OptionalCaveat: e.relationshipCaveat(rel),
+			},
+		}
+	}
+
+	return relUpdates
+}
+
+func (e *engine) readRelationships(ctx context.Context, filter *pb.RelationshipFilter, queryToken string) ([]*pb.Relationship, error) {
+	relationships, _, err := e.readRelationshipsPage(ctx, filter, queryToken, PageOpts{})
+
+	return relationships, err
+}
+
+// readRelationshipsPage reads a page of relationships matching the filter.
+// The returned cursor is empty when there are no more relationships to read.
+func (e *engine) readRelationshipsPage(ctx context.Context, filter *pb.RelationshipFilter, queryToken string, page PageOpts) ([]*pb.Relationship, string, error) {
+	var req pb.ReadRelationshipsRequest
+
+	req.Consistency = e.readConsistency(ctx, queryToken)
+	req.RelationshipFilter = filter
+
+	if page.Limit > 0 {
+		req.OptionalLimit = uint32(page.Limit)
+
+		if page.Cursor != "" {
+			req.OptionalCursor = &pb.Cursor{
+				Token: page.Cursor,
+			}
+		}
+	}
+
+	var (
+		responses []*pb.Relationship
+		cursor    string
+	)
+
+	// A stream which fails part way is read again from the start.
+	err := e.retry(ctx, true, func() error {
+		responses, cursor = nil, ""
+
+		r, err := e.client.ReadRelationships(ctx, &req)
+		if err != nil {
+			return err
+		}
+
+		for {
+			rel, err := r.Recv()
+			switch err {
+			case nil:
+				responses = append(responses, rel.Relationship)
+				cursor = rel.GetAfterResultCursor().GetToken()
+
+				recordReadAt(ctx, rel.GetReadAt().GetToken())
+			case io.EOF:
+				return nil
+			default:
+				return err
+			}
+		}
+	})
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		// Cursors are opaque to the engine, so one SpiceDB cannot decode is only found out here.
+		if page.Cursor != "" && status.Code(err) == grpccodes.InvalidArgument {
+			return nil, "", fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+
+		return nil, "", err
+	}
+
+	// A short page means there is nothing left to read.
+	if page.Limit <= 0 || len(responses) < page.Limit {
+		cursor = ""
+	}
+
+	return responses, cursor, nil
+}
+
+// DeleteRelationships removes the specified relationships.
+// If any relationships fails to be deleted, all completed deletions are re-created.
+func (e *engine) DeleteRelationships(ctx context.Context, relationships ...types.Relationship) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.DeleteRelationships",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Int("relationships", len(relationships)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "DeleteRelationships", time.Now(), &err)
+
+	var errors []error
+
+	span.AddEvent("validating relationships")
+
+	for i, relationship := range relationships {
+		err := e.validateRelationship(relationship)
+		if err != nil {
+			err = fmt.Errorf("%w: invalid relationship %d", err, i)
+
+			span.RecordError(err)
+
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) != 0 {
+		span.SetStatus(codes.Error, "invalid relationships")
+
+		return "", multierr.Combine(errors...)
+	}
+
+	errors = []error{}
+
+	var (
+		complete   []types.Relationship
+		queryToken string
+		dErr       error
+		cErr       error
+	)
+
+	span.AddEvent("deleting relationships")
+
+	for i, relationship := range relationships {
+		queryToken, dErr = e.deleteRelationships(ctx, e.relationshipFilter(relationship))
+		if dErr != nil {
+			e.logger.Errorf("%w: failed to delete relationship %d reverting %d completed deletes", dErr, i, len(complete))
+
+			err := fmt.Errorf("%w: failed to delete relationship %d", dErr, i)
+
+			span.RecordError(err)
+
+			errors = append(errors, err)
+
+			break
+		}
+
+		complete = append(complete, relationship)
+	}
+
+	if len(errors) != 0 {
+		span.SetStatus(codes.Error, "error occurred deleting relationships")
+
+		if len(complete) != 0 {
+			span.AddEvent("recreating deleted relationships")
+
+			// Recreated directly rather than through CreateRelationships so observers are not notified of a revert.
+			_, cErr = e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{
+				Updates: e.relationshipsToUpdates(complete),
+			})
+			if cErr != nil {
+				e.logger.Error("%w: failed to revert %d deleted relationships", cErr, len(complete))
+
+				err := fmt.Errorf("%w: failed to revert deleted relationships", newSpiceDBError(cErr))
+
+				span.RecordError(err)
+
+				errors = append(errors, err)
+			}
+		}
+
+		return "", multierr.Combine(errors...)
+	}
+
+	recordZedToken(span, queryToken)
+
+	e.notifyDelete(ctx, relationships, queryToken)
+
+	return queryToken, nil
+}
+
+// relationshipFilter returns the filter matching exactly the given relationship.
+func (e *engine) relationshipFilter(rel types.Relationship) *pb.RelationshipFilter {
+	return &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/" + rel.Resource.Type,
+		OptionalResourceId: rel.Resource.ID.String(),
+		OptionalRelation:   rel.Relation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/" + rel.Subject.Type,
+			OptionalSubjectId: rel.Subject.ID.String(),
+			OptionalRelation: &pb.SubjectFilter_RelationFilter{
+				Relation: rel.SubjectRelation,
+			},
+		},
+	}
+}
+
+// HasRelationship returns whether exactly the given relationship exists, without listing the
+// resource's other relationships. The relationship is validated against the policy first.
+func (e *engine) HasRelationship(ctx context.Context, rel types.Relationship, queryToken string) (_ bool, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.HasRelationship",
+		trace.WithAttributes(
+			append(
+				e.resourceAttributes(rel.Resource),
+				attribute.String("permissions.relation", rel.Relation),
+				attribute.Stringer("permissions.actor", rel.Subject.ID),
+			)...,
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "HasRelationship", time.Now(), &err)
+
+	if err := e.validateRelationship(rel); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
+	}
+
+	relationships, _, err := e.readRelationshipsPage(ctx, e.relationshipFilter(rel), queryToken, PageOpts{Limit: 1})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return false, err
+	}
+
+	return len(relationships) != 0, nil
+}
+
+// DeleteResourceRelationships deletes all relationships the given resource participates in, both those
+// originating from the resource and those where the resource is the subject. The relationships read are the
+// ones deleted, so the number of relationships returned is exactly the number removed, along with the query token.
+func (e *engine) DeleteResourceRelationships(ctx context.Context, resource types.Resource) (_ int, _ string, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.DeleteResourceRelationships", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "DeleteResourceRelationships", time.Now(), &err)
+
+	filters := []*pb.RelationshipFilter{
+		{
+			ResourceType:       e.namespace + "/" + resource.Type,
+			OptionalResourceId: resource.ID.String(),
+		},
+	}
+
+	for _, resType := range e.resourceTypes() {
+		filters = append(filters, &pb.RelationshipFilter{
+			ResourceType: e.namespace + "/" + resType.Name,
+			OptionalSubjectFilter: &pb.SubjectFilter{
+				SubjectType:       e.namespace + "/" + resource.Type,
+				OptionalSubjectId: resource.ID.String(),
+			},
+		})
+	}
+
+	var (
+		deleted    int
+		queryToken string
+	)
+
+	for _, filter := range filters {
+		relationships, token, err := e.deleteMatchingRelationships(ctx, filter)
+
+		deleted += len(relationships)
+
+		if err != nil {
+			err = fmt.Errorf("%w: %d relationships were deleted before the failure", err, deleted)
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return 0, "", err
+		}
+
+		if token != "" {
+			queryToken = token
+		}
+	}
+
+	span.SetAttributes(attribute.Int("permissions.deleted", deleted))
+	recordZedToken(span, queryToken)
+
+	return deleted, queryToken, nil
+}
+
+// DeleteRelationshipsMatching deletes the non-role relationships from the given resource which match
+// the filter options, such as all parent relationships of the resource with FilterRelation, whatever their
+// subject. The resource type and ID are required so a filter can never match every relationship in the
+// namespace. Without FilterRelation, each relation declared for the resource type is deleted, so the
+// bindings of the resource's roles are left in place. The relationships read are the ones deleted, so the
+// number of relationships returned is exactly the number removed, along with the query token.
+func (e *engine) DeleteRelationshipsMatching(ctx context.Context, resource types.Resource, opts ...RelationshipFilterOption) (_ int, _ string, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.DeleteRelationshipsMatching", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "DeleteRelationshipsMatching", time.Now(), &err)
+
+	if resource.Type == "" || resource.ID == "" || resource.IsWildcard() {
+		err := fmt.Errorf("%w: a resource type and ID are required to delete relationships", ErrInvalidReference)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, "", err
+	}
+
+	filter, err := e.relationshipsFromFilter(resource, opts)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return 0, "", err
+	}
+
+	filters := []*pb.RelationshipFilter{filter}
+
+	if filter.OptionalRelation == "" {
+		resType, err := e.getTypeForResource(resource)
+		if err != nil {
+			err = fmt.Errorf("%w: resource type %s", err, resource.Type)
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return 0, "", err
+		}
+
+		filters = make([]*pb.RelationshipFilter, len(resType.Relationships))
+
+		for i, rel := range resType.Relationships {
+			filters[i] = &pb.RelationshipFilter{
+				ResourceType:          filter.ResourceType,
+				OptionalResourceId:    filter.OptionalResourceId,
+				OptionalRelation:      rel.Relation,
+				OptionalSubjectFilter: filter.OptionalSubjectFilter,
+			}
+		}
+	}
+
+	var (
+		deleted    []types.Relationship
+		queryToken string
+	)
+
+	for _, filter := range filters {
+		relationships, token, err := e.deleteMatchingRelationships(ctx, filter)
+
+		rels, convErr := e.relationshipsToNonRoles(relationships)
+
+		deleted = append(deleted, rels...)
+
+		if err = multierr.Combine(err, convErr); err != nil {
+			err = fmt.Errorf("%w: %d relationships were deleted before the failure", err, len(deleted))
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return 0, "", err
+		}
+
+		if token != "" {
+			queryToken = token
+		}
+	}
+
+	span.SetAttributes(attribute.Int("permissions.deleted", len(deleted)))
+	recordZedToken(span, queryToken)
+
+	if len(deleted) != 0 {
+		e.notifyDelete(ctx, deleted, queryToken)
+	}
+
+	return len(deleted), queryToken, nil
+}
+
+func (e *engine) deleteRelationships(ctx context.Context, filter *pb.RelationshipFilter) (string, error) {
+	if err := e.checkWritable(); err != nil {
+		return "", err
+	}
+
+	request := &pb.DeleteRelationshipsRequest{
+		RelationshipFilter: filter,
+	}
+
+	var r *pb.DeleteRelationshipsResponse
+
+	// Deleting by filter is idempotent, so it is always safe to retry.
+	err := e.retry(ctx, true, func() (err error) {
+		r, err = e.client.DeleteRelationships(ctx, request)
+
+		return err
+	})
+	if err != nil {
+		return "", newSpiceDBError(err)
+	}
+
+	e.logDelete(filter, r.DeletedAt.GetToken())
+	e.recordWrite(r.DeletedAt.GetToken())
+
+	return r.DeletedAt.GetToken(), nil
+}
+
+// deleteMatchingRelationships deletes the relationships matching the filter by deleting exactly those read,
+// so the relationships returned are the ones removed rather than a count taken separately from the delete.
+// Pages are read fully consistent until none match, so relationships written while the delete is under way
+// are removed too. The relationships deleted before a failure are returned with the error.
+func (e *engine) deleteMatchingRelationships(ctx context.Context, filter *pb.RelationshipFilter) ([]*pb.Relationship, string, error) {
+	readCtx := ContextWithConsistency(ctx, ConsistencyFullyConsistent)
+
+	var (
+		deleted    []*pb.Relationship
+		queryToken string
+	)
+
+	for {
+		relationships, _, err := e.readRelationshipsPage(readCtx, filter, "", PageOpts{Limit: maxWriteUpdates})
+		if err != nil {
+			return deleted, queryToken, err
+		}
+
+		if len(relationships) == 0 {
+			return deleted, queryToken, nil
+		}
+
+		updates := make([]*pb.RelationshipUpdate, len(relationships))
+
+		for i, rel := range relationships {
+			updates[i] = &pb.RelationshipUpdate{
+				Operation:    pb.RelationshipUpdate_OPERATION_DELETE,
+				Relationship: rel,
+			}
+		}
+
+		resp, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{Updates: updates})
+		if err != nil {
+			return deleted, queryToken, newSpiceDBError(err)
+		}
+
+		deleted = append(deleted, relationships...)
+		queryToken = resp.WrittenAt.GetToken()
+	}
+}
+
+func relationshipsToRoles(rels []*pb.Relationship) []types.Role {
+	var roleIDs []gidx.PrefixedID
+
+	roleMap := make(map[gidx.PrefixedID]*types.Role)
+
+	for _, rel := range rels {
+		roleIDStr := rel.Subject.Object.ObjectId
+
+		roleID, err := gidx.Parse(roleIDStr)
+		if err != nil {
+			panic(err)
+		}
+
+		action := relationToAction(rel.Relation)
+
+		_, ok := roleMap[roleID]
+		if !ok {
+			roleIDs = append(roleIDs, roleID)
+			role := types.Role{
+				ID: roleID,
+			}
+			roleMap[roleID] = &role
+		}
+
+		roleMap[roleID].Actions = append(roleMap[roleID].Actions, action)
+	}
+
+	out := make([]types.Role, len(roleIDs))
+	for i, roleID := range roleIDs {
+		out[i] = *roleMap[roleID]
+	}
+
+	return out
+}
+
+func (e *engine) relationshipsToNonRoles(rels []*pb.Relationship) ([]types.Relationship, error) {
+	var out []types.Relationship
+
+	for _, rel := range rels {
+		if rel.Subject.Object.ObjectType == e.namespace+"/role" {
+			continue
+		}
+
+		resID, err := gidx.Parse(rel.Resource.ObjectId)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := e.NewResourceFromID(resID)
+		if err != nil {
+			return nil, err
+		}
+
+		subj, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		item := types.Relationship{
+			Resource:        res,
+			Relation:        rel.Relation,
+			Subject:         subj,
+			SubjectRelation: rel.Subject.OptionalRelation,
+			Metadata:        relationshipMetadata(rel),
+			Caveat:          e.relationshipCaveatFromSpiceDB(rel),
+		}
+
+		out = append(out, item)
+	}
+
+	return out, nil
+}
+
+// RelationshipFilterOption restricts the relationships listed from a resource.
+type RelationshipFilterOption func(opts *relationshipFilterOptions)
+
+type relationshipFilterOptions struct {
+	relation    string
+	subjectType string
+}
+
+// FilterRelation only lists relationships with the given relation.
+func FilterRelation(relation string) RelationshipFilterOption {
+	return func(opts *relationshipFilterOptions) {
+		opts.relation = relation
+	}
+}
+
+// FilterSubjectType only lists relationships whose subject is of the given resource type.
+func FilterSubjectType(subjectType string) RelationshipFilterOption {
+	return func(opts *relationshipFilterOptions) {
+		opts.subjectType = subjectType
+	}
+}
+
+// relationshipsFromFilter returns the filter for relationships from the resource restricted by the
+// options. The relation and subject type are validated against the policy.
+func (e *engine) relationshipsFromFilter(resource types.Resource, opts []RelationshipFilterOption) (*pb.RelationshipFilter, error) {
+	var options relationshipFilterOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/" + resource.Type,
+		OptionalResourceId: resource.ID.String(),
+		OptionalRelation:   options.relation,
+	}
+
+	if options.relation != "" {
+		resType, err := e.getTypeForResource(resource)
+		if err != nil {
+			return nil, fmt.Errorf("%w: resource type %s", err, resource.Type)
+		}
+
+		found := false
+
+		for _, rel := range resType.Relationships {
+			if rel.Relation == options.relation {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: %s has no relation %s", ErrInvalidRelationship, resType.Name, options.relation)
+		}
+	}
+
+	if options.subjectType != "" {
+		if _, ok := e.resourceType(options.subjectType); !ok {
+			return nil, fmt.Errorf("%w: subject type %s", ErrInvalidType, options.subjectType)
+		}
+
+		filter.OptionalSubjectFilter = &pb.SubjectFilter{
+			SubjectType: e.namespace + "/" + options.subjectType,
+		}
+	}
+
+	return filter, nil
 }
 
 // ListRelationshipsFrom returns all non-role relationships bound to a given resource.
-func (e *engine) ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string) ([]types.Relationship, error) {
-	resType := e.namespace + "/" + resource.Type
+// The relationships may be restricted by relation and subject type with FilterRelation and FilterSubjectType.
+func (e *engine) ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string, opts ...RelationshipFilterOption) ([]types.Relationship, error) {
+	rels, _, err := e.ListRelationshipsFromPage(ctx, resource, queryToken, PageOpts{}, opts...)
+
+	return rels, err
+}
+
+// ListRelationshipsFromPage returns a page of non-role relationships bound to a given resource.
+// The limit applies to the relationships read from SpiceDB, role relationships are filtered
+// out afterwards, so a page may contain fewer than the requested number of relationships.
+func (e *engine) ListRelationshipsFromPage(ctx context.Context, resource types.Resource, queryToken string, page PageOpts, opts ...RelationshipFilterOption) (_ []types.Relationship, _ string, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.ListRelationshipsFrom", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "ListRelationshipsFrom", time.Now(), &err)
+
+	filter, err := e.relationshipsFromFilter(resource, opts)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	relationships, cursor, err := e.readRelationshipsPage(ctx, filter, queryToken, page)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	rels, err := e.relationshipsToNonRoles(relationships)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.relationships", len(rels)))
+
+	return rels, cursor, nil
+}
+
+// ListRelationshipsTo returns all non-role relationships destined for a given resource.
+// The given resource is the subject of each of the returned relationships.
+func (e *engine) ListRelationshipsTo(ctx context.Context, resource types.Resource, queryToken string) (_ []types.Relationship, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.ListRelationshipsTo", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "ListRelationshipsTo", time.Now(), &err)
+
+	relTypes, ok := e.subjectRelations(resource.Type)
+	if !ok {
+		span.SetStatus(codes.Error, ErrInvalidType.Error())
+
+		return nil, ErrInvalidType
+	}
+
+	var relationships []*pb.Relationship
+
+	for relation, types := range relTypes {
+		for _, relType := range types {
+			rels, err := e.readRelationships(ctx, &pb.RelationshipFilter{
+				ResourceType:     e.namespace + "/" + relType,
+				OptionalRelation: relation,
+				OptionalSubjectFilter: &pb.SubjectFilter{
+					SubjectType:       e.namespace + "/" + resource.Type,
+					OptionalSubjectId: resource.ID.String(),
+				},
+			}, queryToken)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return nil, err
+			}
+
+			relationships = append(relationships, rels...)
+		}
+	}
+
+	rels, err := e.relationshipsToNonRoles(relationships)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.relationships", len(rels)))
+
+	return rels, nil
+}
+
+// ListAncestors returns the ancestors of the given resource by following parent relationships,
+// ordered from the immediate parent to the root.
+func (e *engine) ListAncestors(ctx context.Context, resource types.Resource, queryToken string) (_ []types.Resource, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.ListAncestors", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "ListAncestors", time.Now(), &err)
+
+	visited := map[gidx.PrefixedID]struct{}{
+		resource.ID: {},
+	}
+
+	ancestors := []types.Resource{}
+
+	for current := resource; ; {
+		filter := &pb.RelationshipFilter{
+			ResourceType:       e.namespace + "/" + current.Type,
+			OptionalResourceId: current.ID.String(),
+			OptionalRelation:   parentRelation,
+		}
+
+		relationships, err := e.readRelationships(ctx, filter, queryToken)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		if len(relationships) == 0 {
+			break
+		}
+
+		if len(relationships) > 1 {
+			err := fmt.Errorf("%w: %s", ErrTooManyParents, current.ID)
+
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		parent, err := e.resourceFromSpiceDBRef(relationships[0].Subject.Object)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		if _, ok := visited[parent.ID]; ok {
+			err := fmt.Errorf("%w: %s", ErrResourceCycle, parent.ID)
+
+			span.SetStatus(codes.Error, err.Error())
+
+			return nil, err
+		}
+
+		visited[parent.ID] = struct{}{}
+		ancestors = append(ancestors, parent)
+		current = parent
+	}
+
+	span.SetAttributes(attribute.Int("permissions.ancestors", len(ancestors)))
+
+	return ancestors, nil
+}
+
+// ListRoles returns all roles bound to a given resource.
+// Deleted roles retained by WithRoleTombstones are only returned with IncludeDeleted.
+func (e *engine) ListRoles(ctx context.Context, resource types.Resource, queryToken string, opts ...ListRolesOption) ([]types.Role, error) {
+	roles, _, err := e.ListRolesPage(ctx, resource, queryToken, PageOpts{}, opts...)
+
+	return roles, err
+}
+
+// ListEffectiveRoles returns the roles bound to the given resource and to every resource it inherits
+// actions from, which are those its actions reach through relationship action conditions, such as a
+// load balancer's owner or a tenant's parent. Roles are ordered from the nearest resource outward, and
+// each role is returned once, with its Owner set to the resource it is bound to.
+func (e *engine) ListEffectiveRoles(ctx context.Context, resource types.Resource, queryToken string) (_ []types.Role, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.ListEffectiveRoles", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "ListEffectiveRoles", time.Now(), &err)
+
+	roles, err := e.listEffectiveRoles(ctx, resource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.roles", len(roles)))
+
+	return roles, nil
+}
+
+func (e *engine) listEffectiveRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error) {
+	visited := map[gidx.PrefixedID]struct{}{
+		resource.ID: {},
+	}
+	seenRoles := make(map[gidx.PrefixedID]struct{})
+	out := []types.Role{}
+
+	for queue := []types.Resource{resource}; len(queue) != 0; queue = queue[1:] {
+		current := queue[0]
+
+		resType, ok := e.resourceType(current.Type)
+		if !ok {
+			return nil, ErrInvalidType
+		}
+
+		roles, err := e.ListRoles(ctx, current, queryToken)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(roles, func(i, j int) bool {
+			return roles[i].ID < roles[j].ID
+		})
+
+		for _, role := range roles {
+			if _, ok := seenRoles[role.ID]; ok {
+				continue
+			}
+
+			seenRoles[role.ID] = struct{}{}
+			out = append(out, role)
+		}
+
+		for _, relation := range inheritedRelations(resType) {
+			relationships, err := e.readRelationships(ctx, &pb.RelationshipFilter{
+				ResourceType:       e.namespace + "/" + current.Type,
+				OptionalResourceId: current.ID.String(),
+				OptionalRelation:   relation,
+			}, queryToken)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, rel := range relationships {
+				if rel.Subject.Object.ObjectId == types.WildcardID.String() {
+					continue
+				}
+
+				next, err := e.resourceFromSpiceDBRef(rel.Subject.Object)
+				if err != nil {
+					return nil, err
+				}
+
+				if _, ok := visited[next.ID]; ok {
+					continue
+				}
+
+				visited[next.ID] = struct{}{}
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// inheritedRelations returns the relations the resource type's actions follow to other resources,
+// sorted by name.
+func inheritedRelations(resType types.ResourceType) []string {
+	var relations []string
+
+	for _, action := range resType.Actions {
+		for _, cond := range action.Conditions {
+			if cond.RelationshipAction == nil || containsString(relations, cond.RelationshipAction.Relation) {
+				continue
+			}
+
+			relations = append(relations, cond.RelationshipAction.Relation)
+		}
+	}
+
+	sort.Strings(relations)
+
+	return relations
+}
+
+// ListRolesPage returns a page of roles bound to a given resource.
+// When the policy records role owners, pages are read with SpiceDB's cursor over the owner relationships
+// of the resource's roles, so a role, which is made up of a relationship per action, is never split across
+// pages, and the actions of only the roles on the page are read. Without role owners every role is returned
+// in a single page. Deleted roles are dropped from a page after it is read, so without IncludeDeleted a page
+// may hold fewer roles than the limit while the cursor still continues to the next page.
+func (e *engine) ListRolesPage(ctx context.Context, resource types.Resource, queryToken string, page PageOpts, opts ...ListRolesOption) (_ []types.Role, _ string, err error) {
+	ctx, span := e.tracer.Start(ctx, "engine.ListRoles", trace.WithAttributes(e.resourceAttributes(resource)...))
+
+	defer span.End()
+	defer e.observe(ctx, "ListRoles", time.Now(), &err)
+
+	var (
+		out    []types.Role
+		cursor string
+	)
+
+	if _, ok := e.roleOwnerTypes(); ok && page.Limit > 0 {
+		out, cursor, err = e.listOwnedRolesPage(ctx, resource, queryToken, page)
+	} else {
+		out, err = e.listBoundRoles(ctx, resource, queryToken)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	if err := e.readRolesMetadata(ctx, out, queryToken); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	options := newListRolesOptions(opts)
+	roles := make([]types.Role, 0, len(out))
+
+	for _, role := range out {
+		if role.IsDeleted() && !options.includeDeleted {
+			continue
+		}
+
+		role.Owner = resource
+
+		roles = append(roles, role)
+	}
+
+	if err := e.readRolesParents(ctx, roles, queryToken); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, "", err
+	}
+
+	out = roles
+
+	span.SetAttributes(attribute.Int("permissions.roles", len(out)))
+
+	return out, cursor, nil
+}
+
+// listBoundRoles returns every role bound to the resource with its actions on it.
+func (e *engine) listBoundRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error) {
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/" + resource.Type,
+		OptionalResourceId: resource.ID.String(),
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType: e.namespace + "/role",
+			OptionalRelation: &pb.SubjectFilter_RelationFilter{
+				Relation: roleSubjectRelation,
+			},
+		},
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return relationshipsToRoles(relationships), nil
+}
+
+// listOwnedRolesPage returns a page of the roles owned by the resource with their actions on it.
+// A role has a single owner relationship, so each relationship read is a whole role. A role which
+// has no actions left on the resource is not bound to it, and is dropped from the page.
+func (e *engine) listOwnedRolesPage(ctx context.Context, resource types.Resource, queryToken string, page PageOpts) ([]types.Role, string, error) {
+	filter := &pb.RelationshipFilter{
+		ResourceType:     e.namespace + "/role",
+		OptionalRelation: iapl.RoleOwnerRelation,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       e.namespace + "/" + resource.Type,
+			OptionalSubjectId: resource.ID.String(),
+		},
+	}
+
+	relationships, cursor, err := e.readRelationshipsPage(ctx, filter, queryToken, page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	roles := make([]types.Role, len(relationships))
+
+	for i, rel := range relationships {
+		roles[i].ID, err = gidx.Parse(rel.Resource.ObjectId)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := e.readRoleActions(ctx, resource, roles, queryToken); err != nil {
+		return nil, "", err
+	}
+
+	out := roles[:0]
+
+	for _, role := range roles {
+		if len(role.Actions) != 0 {
+			out = append(out, role)
+		}
+	}
+
+	return out, cursor, nil
+}
+
+// readRoleActions populates the actions each of the given roles has on the resource.
+// The roles are read at most maxConcurrentChecks at a time.
+func (e *engine) readRoleActions(ctx context.Context, resource types.Resource, roles []types.Role, queryToken string) error {
+	return forEachRole(roles, func(role *types.Role) error {
+		filter := &pb.RelationshipFilter{
+			ResourceType:       e.namespace + "/" + resource.Type,
+			OptionalResourceId: resource.ID.String(),
+			OptionalSubjectFilter: &pb.SubjectFilter{
+				SubjectType:       e.namespace + "/role",
+				OptionalSubjectId: role.ID.String(),
+				OptionalRelation: &pb.SubjectFilter_RelationFilter{
+					Relation: roleSubjectRelation,
+				},
+			},
+		}
+
+		relationships, err := e.readRelationships(ctx, filter, queryToken)
+		if err != nil {
+			return err
+		}
+
+		for _, rel := range relationships {
+			role.Actions = append(role.Actions, relationToAction(rel.Relation))
+		}
+
+		return nil
+	})
+}
+
+// ListAllRoles returns every role in the namespace, whatever resource it is bound to, ordered by role ID.
+// Each role carries the resource which owns it. The role relationships of each roleable resource type
+// are read a page at a time, and deleted roles are not returned.
+func (e *engine) ListAllRoles(ctx context.Context, queryToken string) (_ []types.Role, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.ListAllRoles",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "ListAllRoles", time.Now(), &err)
+
+	roleType := e.namespace + "/role"
+
+	var (
+		roleIDs []gidx.PrefixedID
+		roleMap = make(map[gidx.PrefixedID]*types.Role)
+	)
+
+	for _, resType := range e.roleableTypes() {
+		filter := &pb.RelationshipFilter{
+			ResourceType: e.namespace + "/" + resType.Name,
+			OptionalSubjectFilter: &pb.SubjectFilter{
+				SubjectType: roleType,
+				OptionalRelation: &pb.SubjectFilter_RelationFilter{
+					Relation: roleSubjectRelation,
+				},
+			},
+		}
+
+		page := PageOpts{Limit: listAllPageSize}
+
+		for {
+			relationships, cursor, err := e.readRelationshipsPage(ctx, filter, queryToken, page)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+
+				return nil, err
+			}
+
+			for _, rel := range relationships {
+				roleID, err := gidx.Parse(rel.Subject.Object.ObjectId)
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+
+					return nil, err
+				}
+
+				role, ok := roleMap[roleID]
+				if !ok {
+					owner, err := e.resourceFromSpiceDBRef(rel.Resource)
+					if err != nil {
+						span.RecordError(err)
+						span.SetStatus(codes.Error, err.Error())
+
+						return nil, err
+					}
+
+					role = &types.Role{
+						ID:    roleID,
+						Owner: owner,
+					}
+
+					roleIDs = append(roleIDs, roleID)
+					roleMap[roleID] = role
+				}
+
+				role.Actions = append(role.Actions, relationToAction(rel.Relation))
+			}
+
+			if cursor == "" {
+				break
+			}
+
+			page.Cursor = cursor
+		}
+	}
+
+	sort.Slice(roleIDs, func(i, j int) bool {
+		return roleIDs[i] < roleIDs[j]
+	})
+
+	roles := make([]types.Role, len(roleIDs))
+
+	for i, roleID := range roleIDs {
+		roles[i] = *roleMap[roleID]
+	}
+
+	if err := e.readRolesMetadata(ctx, roles, queryToken); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	out := make([]types.Role, 0, len(roles))
+
+	for _, role := range roles {
+		if !role.IsDeleted() {
+			out = append(out, role)
+		}
+	}
+
+	if err := e.readRolesParents(ctx, out, queryToken); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("permissions.roles", len(out)))
+
+	return out, nil
+}
+
+// listRoleResourceActions returns all resources and action relations for the provided resource type to the provided role.
+// Note: The actions returned by this function are the spicedb relationship action.
+func (e *engine) listRoleResourceActions(ctx context.Context, role types.Resource, resTypeName string, queryToken string) (map[types.Resource][]string, error) {
+	resType := e.namespace + "/" + resTypeName
+	roleType := e.namespace + "/role"
+
+	filter := &pb.RelationshipFilter{
+		ResourceType: resType,
+		OptionalSubjectFilter: &pb.SubjectFilter{
+			SubjectType:       roleType,
+			OptionalSubjectId: role.ID.String(),
+			OptionalRelation: &pb.SubjectFilter_RelationFilter{
+				Relation: roleSubjectRelation,
+			},
+		},
+	}
+
+	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceIDActions := make(map[gidx.PrefixedID][]string)
+
+	for _, rel := range relationships {
+		resourceID, err := gidx.Parse(rel.Resource.ObjectId)
+		if err != nil {
+			return nil, err
+		}
+
+		resourceIDActions[resourceID] = append(resourceIDActions[resourceID], rel.Relation)
+	}
+
+	resourceActions := make(map[types.Resource][]string, len(resourceIDActions))
+
+	for resID, actions := range resourceIDActions {
+		res, err := e.NewResourceFromID(resID)
+		if err != nil {
+			return nil, err
+		}
+
+		resourceActions[res] = actions
+	}
+
+	return resourceActions, nil
+}
+
+// findRoleResourceActions returns the resource the role is bound to along with the role's action relations.
+// An empty map is returned if the role is not bound to any resource.
+func (e *engine) findRoleResourceActions(ctx context.Context, roleResource types.Resource, queryToken string) (map[types.Resource][]string, error) {
+	var (
+		resActions map[types.Resource][]string
+		err        error
+	)
+
+	for _, resType := range e.roleableTypes() {
+		resActions, err = e.listRoleResourceActions(ctx, roleResource, resType.Name, queryToken)
+		if err != nil {
+			return nil, err
+		}
+
+		// roles are only ever created for a single resource, so we can break after the first one is found.
+		if len(resActions) != 0 {
+			break
+		}
+	}
+
+	return resActions, nil
+}
+
+// GetRole gets the role with it's actions.
+func (e *engine) GetRole(ctx context.Context, roleResource types.Resource, queryToken string) (_ types.Role, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.GetRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "GetRole", time.Now(), &err)
+
+	resActions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, err
+	}
+
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
+
+		return types.Role{}, ErrRoleHasTooManyResources
+	}
+
+	// returns the first resources actions.
+	for resource, actions := range resActions {
+		span.SetAttributes(e.resourceAttributes(resource)...)
+
+		for i, action := range actions {
+			actions[i] = relationToAction(action)
+		}
+
+		role := types.Role{
+			ID:      roleResource.ID,
+			Actions: actions,
+			Owner:   resource,
+		}
+
+		if err := e.readRoleMetadata(ctx, &role, queryToken); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return types.Role{}, err
+		}
+
+		if err := e.readRoleParents(ctx, &role, queryToken); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return types.Role{}, err
+		}
+
+		return role, nil
+	}
+
+	span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+	return types.Role{}, ErrRoleNotFound
+}
+
+// GetRoleResource gets the role's assigned resource.
+func (e *engine) GetRoleResource(ctx context.Context, roleResource types.Resource, queryToken string) (_ types.Resource, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.GetRoleResource",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "GetRoleResource", time.Now(), &err)
+
+	resActions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Resource{}, err
+	}
+
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
+
+		return types.Resource{}, ErrRoleHasTooManyResources
+	}
+
+	// returns the first resources actions.
+	for resource := range resActions {
+		span.SetAttributes(e.resourceAttributes(resource)...)
+
+		return resource, nil
+	}
+
+	span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+	return types.Resource{}, ErrRoleNotFound
+}
+
+// RoleDetail is a role along with the subjects assigned to it.
+type RoleDetail struct {
+	types.Role
+	Assignments []types.Resource
+}
+
+// GetRoleWithAssignments gets the role with its actions and assigned subjects. Both are read at the
+// same SpiceDB snapshot, at least as fresh as the query token, so the result is internally consistent.
+func (e *engine) GetRoleWithAssignments(ctx context.Context, roleResource types.Resource, queryToken string) (_ RoleDetail, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.GetRoleWithAssignments",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "GetRoleWithAssignments", time.Now(), &err)
+
+	// Finding the role's resource pins the snapshot the rest of the role is read at.
+	recorderCtx, recorder := contextWithReadAtRecorder(ctx)
+
+	resActions, err := e.findRoleResourceActions(recorderCtx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return RoleDetail{}, err
+	}
+
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return RoleDetail{}, ErrRoleNotFound
+	}
+
+	snapshot := recorder.readAt()
+
+	span.SetAttributes(attribute.String("permissions.snapshot", snapshot))
+
+	ctx = ContextWithConsistency(ctx, ConsistencyAtExactSnapshot)
+
+	role, err := e.GetRole(ctx, roleResource, snapshot)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return RoleDetail{}, err
+	}
+
+	assignments, err := e.ListAssignments(ctx, role, snapshot)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return RoleDetail{}, err
+	}
+
+	return RoleDetail{
+		Role:        role,
+		Assignments: assignments,
+	}, nil
+}
+
+// DeleteRole removes all role actions from the assigned resource.
+// If the engine retains deleted roles, see WithRoleTombstones, the role is tombstoned instead.
+func (e *engine) DeleteRole(ctx context.Context, roleResource types.Resource, queryToken string) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.DeleteRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+			attribute.Bool("permissions.tombstone", e.roleTombstones),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "DeleteRole", time.Now(), &err)
+
+	resActions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return "", ErrRoleNotFound
+	}
+
+	if e.roleTombstones {
+		queryToken, err = e.tombstoneRole(ctx, roleResource, queryToken)
+	} else {
+		queryToken, err = e.purgeRole(ctx, roleResource, resActions)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, queryToken)
+
+	e.publishRoleDeleteEvents(ctx, roleResource, resActions)
+
+	return queryToken, nil
+}
+
+// PurgeRole permanently removes the role's actions, metadata and parents, whether or not the role
+// was previously tombstoned by DeleteRole.
+func (e *engine) PurgeRole(ctx context.Context, roleResource types.Resource, queryToken string) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.PurgeRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "PurgeRole", time.Now(), &err)
+
+	resActions, err := e.findRoleResourceActions(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return "", ErrRoleNotFound
+	}
+
+	wasDeleted, err := e.roleIsDeleted(ctx, roleResource, queryToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	queryToken, err = e.purgeRole(ctx, roleResource, resActions)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, queryToken)
+
+	// Deletion events were published when the role was tombstoned.
+	if !wasDeleted {
+		e.publishRoleDeleteEvents(ctx, roleResource, resActions)
+	}
+
+	return queryToken, nil
+}
+
+// purgeRole deletes the role's action relationships on the given resources, its metadata, its parents and its owner.
+func (e *engine) purgeRole(ctx context.Context, roleResource types.Resource, resActions map[types.Resource][]string) (string, error) {
+	roleType := e.namespace + "/role"
+
+	var filters []*pb.RelationshipFilter
+
+	roleSubjectFilter := &pb.SubjectFilter{
+		SubjectType:       roleType,
+		OptionalSubjectId: roleResource.ID.String(),
+		OptionalRelation: &pb.SubjectFilter_RelationFilter{
+			Relation: roleSubjectRelation,
+		},
+	}
+
+	for resource, relActions := range resActions {
+		for _, relAction := range relActions {
+			filters = append(filters, &pb.RelationshipFilter{
+				ResourceType:          e.namespace + "/" + resource.Type,
+				OptionalResourceId:    resource.ID.String(),
+				OptionalRelation:      relAction,
+				OptionalSubjectFilter: roleSubjectFilter,
+			})
+		}
+	}
+
+	filters = append(filters, &pb.RelationshipFilter{
+		ResourceType:       roleType,
+		OptionalResourceId: roleResource.ID.String(),
+		OptionalRelation:   roleMetadataRelation,
+	}, e.roleParentFilter(roleResource.ID))
+
+	if _, ok := e.roleOwnerTypes(); ok {
+		filters = append(filters, &pb.RelationshipFilter{
+			ResourceType:       roleType,
+			OptionalResourceId: roleResource.ID.String(),
+			OptionalRelation:   iapl.RoleOwnerRelation,
+		})
+	}
+
+	var queryToken string
+
+	for _, filter := range filters {
+		token, err := e.deleteRelationships(ctx, filter)
+		if err != nil {
+			return "", fmt.Errorf("failed to delete role action %s: %w", filter.OptionalResourceId, err)
+		}
+
+		queryToken = token
+	}
+
+	return queryToken, nil
+}
+
+// publishRoleDeleteEvents publishes a delete event for the role on each resource it was bound to.
+func (e *engine) publishRoleDeleteEvents(ctx context.Context, roleResource types.Resource, resActions map[types.Resource][]string) {
+	for resource, relActions := range resActions {
+		deleted := types.Role{
+			ID:      roleResource.ID,
+			Actions: make([]string, len(relActions)),
+		}
+
+		for i, relAction := range relActions {
+			deleted.Actions[i] = relationToAction(relAction)
+		}
+
+		e.publishRoleEvent(ctx, roleEvent{
+			eventType: RoleEventTypeDelete,
+			role:      deleted,
+			resource:  resource,
+		})
+	}
+}
+
+// DeleteRoles removes the given roles, deleting each role's actions along with all assignments of the role.
+// Roles which do not exist are ignored. The returned query token reflects all of the deletions.
+// A role's actions are deleted with a single filter on the resource which owns it, falling back to a filter
+// per roleable resource type for a role with no recorded owner, and its parents and the relationships on
+// the role itself, which hold its assignments, metadata and owner, with a filter each. Roles are deleted in
+// order, each role's actions first and its owner last, so should a delete fail the roles before it are
+// deleted and the failing role can still be found by its owner. Deletes by filter are idempotent, so
+// calling DeleteRoles again with the same roles completes the deletion.
+func (e *engine) DeleteRoles(ctx context.Context, roleResources []types.Resource) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.DeleteRoles",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Int("permissions.roles", len(roleResources)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "DeleteRoles", time.Now(), &err)
+
+	var queryToken string
+
+	for i, roleResource := range roleResources {
+		token, err := e.deleteRole(ctx, roleResource)
+		if err != nil {
+			err = fmt.Errorf("failed to delete role %s after deleting %d of %d roles: %w", roleResource.ID, i, len(roleResources), err)
+
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+
+			return "", err
+		}
+
+		queryToken = token
+	}
+
+	recordZedToken(span, queryToken)
+
+	return queryToken, nil
+}
+
+// deleteRole deletes every relationship of the role, returning the query token of the last delete.
+func (e *engine) deleteRole(ctx context.Context, roleResource types.Resource) (string, error) {
+	filters, err := e.deleteRoleFilters(ctx, roleResource)
+	if err != nil {
+		return "", err
+	}
+
+	var queryToken string
+
+	for _, filter := range filters {
+		queryToken, err = e.deleteRelationships(ctx, filter)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return queryToken, nil
+}
+
+// deleteRoleFilters returns the filters which together match every relationship of the role, in the order
+// they are deleted.
+func (e *engine) deleteRoleFilters(ctx context.Context, roleResource types.Resource) ([]*pb.RelationshipFilter, error) {
+	roleType := e.namespace + "/role"
+
+	roleSubjectFilter := &pb.SubjectFilter{
+		SubjectType:       roleType,
+		OptionalSubjectId: roleResource.ID.String(),
+		OptionalRelation: &pb.SubjectFilter_RelationFilter{
+			Relation: roleSubjectRelation,
+		},
+	}
+
+	owner, ok, err := e.readRoleOwner(ctx, roleResource)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters []*pb.RelationshipFilter
+
+	if ok {
+		filters = append(filters, &pb.RelationshipFilter{
+			ResourceType:          e.namespace + "/" + owner.Type,
+			OptionalResourceId:    owner.ID.String(),
+			OptionalSubjectFilter: roleSubjectFilter,
+		})
+	} else {
+		for _, resType := range e.roleableTypes() {
+			filters = append(filters, &pb.RelationshipFilter{
+				ResourceType:          e.namespace + "/" + resType.Name,
+				OptionalSubjectFilter: roleSubjectFilter,
+			})
+		}
+	}
+
+	// The last filter removes the role's assignments, its metadata and its owner.
+	return append(filters, e.roleParentFilter(roleResource.ID), &pb.RelationshipFilter{
+		ResourceType:       roleType,
+		OptionalResourceId: roleResource.ID.String(),
+	}), nil
+}
+
+// readRoleOwner returns the resource recorded as the role's owner, read fully consistent so a role created
+// just before is found. False is returned if the policy does not record role owners or the role has none.
+func (e *engine) readRoleOwner(ctx context.Context, roleResource types.Resource) (types.Resource, bool, error) {
+	if _, ok := e.roleOwnerTypes(); !ok {
+		return types.Resource{}, false, nil
+	}
+
+	filter := &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/role",
+		OptionalResourceId: roleResource.ID.String(),
+		OptionalRelation:   iapl.RoleOwnerRelation,
+	}
+
+	relationships, _, err := e.readRelationshipsPage(ContextWithConsistency(ctx, ConsistencyFullyConsistent), filter, "", PageOpts{Limit: 1})
+	if err != nil {
+		return types.Resource{}, false, err
+	}
+
+	if len(relationships) == 0 {
+		return types.Resource{}, false, nil
+	}
+
+	owner, err := e.resourceFromSpiceDBRef(relationships[0].Subject.Object)
+	if err != nil {
+		return types.Resource{}, false, err
+	}
+
+	return owner, true, nil
+}
+
+// UpdateRole replaces the role's actions with the given actions.
+// Only the actions which were added or removed are written, all in a single transaction, and nothing is
+// written if none were. Composite actions are expanded as by CreateRole. A role must keep at least one
+// action, so an empty set of actions returns ErrRoleWithoutActions.
+func (e *engine) UpdateRole(ctx context.Context, roleResource types.Resource, actions []string) (_ types.Role, _ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.UpdateRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "UpdateRole", time.Now(), &err)
+
+	// The role is read fully consistent so the actions diffed against include any changed just before.
+	readCtx := ContextWithConsistency(ctx, ConsistencyFullyConsistent)
+
+	resActions, err := e.findRoleResourceActions(readCtx, roleResource, "")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
+
+		return types.Role{}, "", ErrRoleNotFound
+	}
+
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
+
+		return types.Role{}, "", ErrRoleHasTooManyResources
+	}
+
+	var (
+		resource   types.Resource
+		relActions []string
+	)
+
+	for res, rels := range resActions {
+		resource = res
+		relActions = rels
+	}
+
+	actions = e.expandActions(actions)
+
+	if len(actions) == 0 {
+		span.RecordError(ErrRoleWithoutActions)
+		span.SetStatus(codes.Error, ErrRoleWithoutActions.Error())
+
+		return types.Role{}, "", ErrRoleWithoutActions
+	}
+
+	if err := e.checkRoleNotDeleted(ctx, roleResource); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	if err := e.validateRoleActions(resource, actions); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
+	}
+
+	current := make(map[string]struct{}, len(relActions))
+
+	for _, relAction := range relActions {
+		current[relationToAction(relAction)] = struct{}{}
+	}
+
+	desired := make(map[string]struct{}, len(actions))
+	newActions := make([]string, 0, len(actions))
+
+	for _, action := range actions {
+		if _, ok := desired[action]; ok {
+			continue
+		}
+
+		desired[action] = struct{}{}
+		newActions = append(newActions, action)
+	}
+
+	resourceRef := resourceToSpiceDBRef(e.namespace, resource)
+	roleRef := resourceToSpiceDBRef(e.namespace, roleResource)
+
+	var updates []*pb.RelationshipUpdate
+
+	for _, action := range newActions {
+		if _, ok := current[action]; !ok {
+			updates = append(updates, roleActionUpdate(pb.RelationshipUpdate_OPERATION_TOUCH, resourceRef, roleRef, action))
+		}
+	}
+
+	for _, relAction := range relActions {
+		action := relationToAction(relAction)
 
-	filter := &pb.RelationshipFilter{
-		ResourceType:       resType,
-		OptionalResourceId: resource.ID.String(),
+		if _, ok := desired[action]; !ok {
+			updates = append(updates, roleActionUpdate(pb.RelationshipUpdate_OPERATION_DELETE, resourceRef, roleRef, action))
+		}
 	}
 
-	relationships, err := e.readRelationships(ctx, filter, queryToken)
-	if err != nil {
-		return nil, err
+	span.SetAttributes(attribute.Int("permissions.updates", len(updates)))
+
+	role := types.Role{
+		ID:      roleResource.ID,
+		Actions: newActions,
+		Owner:   resource,
 	}
 
-	return e.relationshipsToNonRoles(relationships)
-}
+	if err := e.readRoleMetadata(readCtx, &role, ""); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-// ListRelationshipsTo returns all non-role relationships destined for a given resource.
-func (e *engine) ListRelationshipsTo(ctx context.Context, resource types.Resource, queryToken string) ([]types.Relationship, error) {
-	relTypes, ok := e.schemaSubjectRelationMap[resource.Type]
-	if !ok {
-		return nil, ErrInvalidType
+		return types.Role{}, "", err
 	}
 
-	var relationships []*pb.Relationship
+	if err := e.readRoleParents(readCtx, &role, ""); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	for _, types := range relTypes {
-		for _, relType := range types {
-			rels, err := e.readRelationships(ctx, &pb.RelationshipFilter{
-				ResourceType: e.namespace + "/" + relType,
-				OptionalSubjectFilter: &pb.SubjectFilter{
-					SubjectType:       e.namespace + "/" + resource.Type,
-					OptionalSubjectId: resource.ID.String(),
-				},
-			}, queryToken)
-			if err != nil {
-				return nil, err
-			}
+		return types.Role{}, "", err
+	}
 
-			relationships = append(relationships, rels...)
-		}
+	// A role which already has exactly the given actions is returned without writing.
+	if len(updates) == 0 {
+		return role, "", nil
 	}
 
-	return e.relationshipsToNonRoles(relationships)
-}
+	request := &pb.WriteRelationshipsRequest{Updates: updates}
 
-// ListRoles returns all roles bound to a given resource.
-func (e *engine) ListRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error) {
-	resType := e.namespace + "/" + resource.Type
-	roleType := e.namespace + "/role"
+	r, err := e.writeRelationships(ctx, request)
+	if err != nil {
+		err = newSpiceDBError(err)
 
-	filter := &pb.RelationshipFilter{
-		ResourceType:       resType,
-		OptionalResourceId: resource.ID.String(),
-		OptionalSubjectFilter: &pb.SubjectFilter{
-			SubjectType: roleType,
-			OptionalRelation: &pb.SubjectFilter_RelationFilter{
-				Relation: roleSubjectRelation,
-			},
-		},
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return types.Role{}, "", err
 	}
 
-	relationships, err := e.readRelationships(ctx, filter, queryToken)
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	return role, r.WrittenAt.GetToken(), nil
+}
+
+// AddRoleAction grants the role the given action on its resource, without modifying its other actions.
+// Adding an action the role already has is a no-op.
+func (e *engine) AddRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error) {
+	return e.updateRoleAction(ctx, "AddRoleAction", pb.RelationshipUpdate_OPERATION_TOUCH, roleResource, action)
+}
+
+// RemoveRoleAction revokes the given action from the role, without modifying its other actions.
+// Removing an action the role does not have is a no-op, and removing its last action returns
+// ErrRoleWithoutActions.
+func (e *engine) RemoveRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error) {
+	return e.updateRoleAction(ctx, "RemoveRoleAction", pb.RelationshipUpdate_OPERATION_DELETE, roleResource, action)
+}
+
+// updateRoleAction writes a single role action relationship with the given operation.
+func (e *engine) updateRoleAction(ctx context.Context, method string, op pb.RelationshipUpdate_Operation, roleResource types.Resource, action string) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine."+method,
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", roleResource.ID),
+			attribute.String("permissions.action", action),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, method, time.Now(), &err)
+
+	// The role is read fully consistent so whether the action is its last reflects changes made just before.
+	resActions, err := e.findRoleResourceActions(ContextWithConsistency(ctx, ConsistencyFullyConsistent), roleResource, "")
 	if err != nil {
-		return nil, err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
 	}
 
-	out := relationshipsToRoles(relationships)
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
 
-	return out, nil
-}
+		return "", ErrRoleNotFound
+	}
 
-// listRoleResourceActions returns all resources and action relations for the provided resource type to the provided role.
-// Note: The actions returned by this function are the spicedb relationship action.
-func (e *engine) listRoleResourceActions(ctx context.Context, role types.Resource, resTypeName string, queryToken string) (map[types.Resource][]string, error) {
-	resType := e.namespace + "/" + resTypeName
-	roleType := e.namespace + "/role"
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
 
-	filter := &pb.RelationshipFilter{
-		ResourceType: resType,
-		OptionalSubjectFilter: &pb.SubjectFilter{
-			SubjectType:       roleType,
-			OptionalSubjectId: role.ID.String(),
-			OptionalRelation: &pb.SubjectFilter_RelationFilter{
-				Relation: roleSubjectRelation,
-			},
-		},
+		return "", ErrRoleHasTooManyResources
 	}
 
-	relationships, err := e.readRelationships(ctx, filter, queryToken)
-	if err != nil {
-		return nil, err
+	var (
+		resource   types.Resource
+		relActions []string
+	)
+
+	for res, rels := range resActions {
+		resource = res
+		relActions = rels
 	}
 
-	resourceIDActions := make(map[gidx.PrefixedID][]string)
+	if err := e.validateRoleActions(resource, []string{action}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	for _, rel := range relationships {
-		resourceID, err := gidx.Parse(rel.Resource.ObjectId)
-		if err != nil {
-			return nil, err
-		}
+		return "", err
+	}
 
-		resourceIDActions[resourceID] = append(resourceIDActions[resourceID], rel.Relation)
+	if op == pb.RelationshipUpdate_OPERATION_DELETE && len(relActions) == 1 && relActions[0] == actionToRelation(action) {
+		span.RecordError(ErrRoleWithoutActions)
+		span.SetStatus(codes.Error, ErrRoleWithoutActions.Error())
+
+		return "", ErrRoleWithoutActions
 	}
 
-	resourceActions := make(map[types.Resource][]string, len(resourceIDActions))
+	if op != pb.RelationshipUpdate_OPERATION_DELETE {
+		if err := e.checkRoleNotDeleted(ctx, roleResource); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 
-	for resID, actions := range resourceIDActions {
-		res, err := e.NewResourceFromID(resID)
-		if err != nil {
-			return nil, err
+			return "", err
 		}
+	}
 
-		resourceActions[res] = actions
+	request := &pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{
+			roleActionUpdate(op, resourceToSpiceDBRef(e.namespace, resource), resourceToSpiceDBRef(e.namespace, roleResource), action),
+		},
 	}
 
-	return resourceActions, nil
+	r, err := e.writeRelationships(ctx, request)
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	recordZedToken(span, r.WrittenAt.GetToken())
+
+	return r.WrittenAt.GetToken(), nil
 }
 
-// GetRole gets the role with it's actions.
-func (e *engine) GetRole(ctx context.Context, roleResource types.Resource, queryToken string) (types.Role, error) {
-	var (
-		resActions map[types.Resource][]string
-		err        error
+// MoveRole moves the role to a new owning resource, binding its actions to newOwner instead of its
+// current resource in a single write, so the role is never bound to both or neither. The role's
+// assignments, metadata and parents are unchanged. The new owner must be permitted to own roles and
+// must support each of the role's actions.
+func (e *engine) MoveRole(ctx context.Context, role types.Role, newOwner types.Resource) (_ string, err error) {
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.MoveRole",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Stringer("permissions.role", role.ID),
+			attribute.Stringer("permissions.owner", newOwner.ID),
+		),
 	)
 
-	for _, resType := range e.schemaRoleables {
-		resActions, err = e.listRoleResourceActions(ctx, roleResource, resType.Name, queryToken)
-		if err != nil {
-			return types.Role{}, err
-		}
+	defer span.End()
+	defer e.observe(ctx, "MoveRole", time.Now(), &err)
 
-		// roles are only ever created for a single resource, so we can break after the first one is found.
-		if len(resActions) != 0 {
-			break
-		}
-	}
+	roleResource := role.Resource()
 
-	if len(resActions) > 1 {
-		return types.Role{}, ErrRoleHasTooManyResources
+	// The role's actions are read fully consistent so an action added just before is moved along with the rest.
+	resActions, err := e.findRoleResourceActions(ContextWithConsistency(ctx, ConsistencyFullyConsistent), roleResource, "")
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
 	}
 
-	// returns the first resources actions.
-	for _, actions := range resActions {
-		for i, action := range actions {
-			actions[i] = relationToAction(action)
-		}
+	if len(resActions) == 0 {
+		span.SetStatus(codes.Error, ErrRoleNotFound.Error())
 
-		return types.Role{
-			ID:      roleResource.ID,
-			Actions: actions,
-		}, nil
+		return "", ErrRoleNotFound
 	}
 
-	return types.Role{}, ErrRoleNotFound
-}
+	if len(resActions) > 1 {
+		span.SetStatus(codes.Error, ErrRoleHasTooManyResources.Error())
+
+		return "", ErrRoleHasTooManyResources
+	}
 
-// GetRoleResource gets the role's assigned resource.
-func (e *engine) GetRoleResource(ctx context.Context, roleResource types.Resource, queryToken string) (types.Resource, error) {
 	var (
-		resActions map[types.Resource][]string
-		err        error
+		resource   types.Resource
+		relActions []string
 	)
 
-	for _, resType := range e.schemaRoleables {
-		resActions, err = e.listRoleResourceActions(ctx, roleResource, resType.Name, queryToken)
-		if err != nil {
-			return types.Resource{}, err
-		}
+	for res, rels := range resActions {
+		resource = res
+		relActions = rels
+	}
 
-		// roles are only ever created for a single resource, so we can break after the first one is found.
-		if len(resActions) != 0 {
-			break
-		}
+	actions := make([]string, len(relActions))
+
+	for i, relAction := range relActions {
+		actions[i] = relationToAction(relAction)
 	}
 
-	if len(resActions) > 1 {
-		return types.Resource{}, ErrRoleHasTooManyResources
+	if err := e.validateRoleOwner(newOwner); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
 	}
 
-	// returns the first resources actions.
-	for resource := range resActions {
-		return resource, nil
+	if err := e.validateRoleActions(newOwner, actions); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
 	}
 
-	return types.Resource{}, ErrRoleNotFound
-}
+	moved := types.Role{
+		ID:      role.ID,
+		Actions: actions,
+		Owner:   newOwner,
+	}
 
-// DeleteRole removes all role actions from the assigned resource.
-func (e *engine) DeleteRole(ctx context.Context, roleResource types.Resource, queryToken string) (string, error) {
-	var (
-		resActions map[types.Resource][]string
-		err        error
-	)
+	updates := e.roleRelationships(moved, newOwner)
 
-	for _, resType := range e.schemaRoleables {
-		resActions, err = e.listRoleResourceActions(ctx, roleResource, resType.Name, queryToken)
-		if err != nil {
-			return "", err
+	// Moving a role to the resource it is already bound to leaves it unchanged.
+	if resource != newOwner {
+		resourceRef := resourceToSpiceDBRef(e.namespace, resource)
+		roleRef := resourceToSpiceDBRef(e.namespace, roleResource)
+
+		for _, action := range actions {
+			updates = append(updates, roleActionUpdate(pb.RelationshipUpdate_OPERATION_DELETE, resourceRef, roleRef, action))
 		}
 
-		// roles are only ever created for a single resource, so we can break after the first one is found.
-		if len(resActions) != 0 {
-			break
+		if _, ok := e.roleOwnerTypes(); ok {
+			oldOwnerUpdate := e.roleOwnerUpdate(role, resource)
+			oldOwnerUpdate.Operation = pb.RelationshipUpdate_OPERATION_DELETE
+
+			updates = append(updates, oldOwnerUpdate)
 		}
 	}
 
-	if len(resActions) == 0 {
-		return "", ErrRoleNotFound
+	if _, ok := e.roleOwnerTypes(); ok {
+		ownerUpdate := e.roleOwnerUpdate(role, newOwner)
+		ownerUpdate.Operation = pb.RelationshipUpdate_OPERATION_TOUCH
+
+		updates = append(updates, ownerUpdate)
 	}
 
-	roleType := e.namespace + "/role"
+	r, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{Updates: updates})
+	if err != nil {
+		err = newSpiceDBError(err)
 
-	var filters []*pb.RelationshipFilter
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 
-	roleSubjectFilter := &pb.SubjectFilter{
-		SubjectType:       roleType,
-		OptionalSubjectId: roleResource.ID.String(),
-		OptionalRelation: &pb.SubjectFilter_RelationFilter{
-			Relation: roleSubjectRelation,
-		},
+		return "", err
 	}
 
-	for resource, relActions := range resActions {
-		for _, relAction := range relActions {
-			filters = append(filters, &pb.RelationshipFilter{
-				ResourceType:          e.namespace + "/" + resource.Type,
-				OptionalResourceId:    resource.ID.String(),
-				OptionalRelation:      relAction,
-				OptionalSubjectFilter: roleSubjectFilter,
-			})
-		}
-	}
+	recordZedToken(span, r.WrittenAt.GetToken())
 
-	for _, filter := range filters {
-		queryToken, err = e.deleteRelationships(ctx, filter)
-		if err != nil {
-			return "", fmt.Errorf("failed to delete role action %s: %w", filter.OptionalResourceId, err)
-		}
+	if resource != newOwner {
+		e.publishRoleDeleteEvents(ctx, roleResource, resActions)
+		e.publishRoleEvent(ctx, roleEvent{
+			eventType: RoleEventTypeCreate,
+			role:      moved,
+			resource:  newOwner,
+		})
 	}
 
-	return queryToken, nil
+	return r.WrittenAt.GetToken(), nil
+}
+
+// QualifyType returns the SpiceDB object type of the given resource type, which is the resource
+// type prefixed by the engine's namespace, such as "infratographer/tenant". Together with a
+// resource's ID this names the object to use when inspecting relationships with zed.
+func (e *engine) QualifyType(resourceType string) string {
+	return e.namespace + "/" + resourceType
 }
 
 // NewResourceFromID returns a new resource struct from a given id
 func (e *engine) NewResourceFromID(id gidx.PrefixedID) (types.Resource, error) {
+	return e.NewResourceFromIDString(id.String())
+}
+
+// NewResourceFromIDString parses the given prefixed ID and returns the resource it identifies.
+// The resource's type is the policy resource type whose IDPrefix matches the ID's prefix, so
+// "tnntten-abc" is a resource of type "tenant". The type is not namespaced; the SpiceDB object for
+// the resource is QualifyType(resource.Type) with the full ID as its object ID, such as
+// "infratographer/tenant:tnntten-abc". A malformed ID returns ErrInvalidID, and an ID whose prefix
+// belongs to no resource type of the policy returns an UnknownResourceTypeError. Prefixes mapped by
+// WithResourceTypeMapping take precedence over the policy's.
+func (e *engine) NewResourceFromIDString(s string) (types.Resource, error) {
+	// gidx accepts an empty ID, which cannot identify a resource.
+	if s == "" {
+		return types.Resource{}, fmt.Errorf("%w: empty id", ErrInvalidID)
+	}
+
+	id, err := gidx.Parse(s)
+	if err != nil {
+		return types.Resource{}, fmt.Errorf("%w: %w", ErrInvalidID, err)
+	}
+
 	prefix := id.Prefix()
 
-	rType, ok := e.schemaPrefixMap[prefix]
+	rType, ok := e.resourceTypeForPrefix(prefix)
 	if !ok {
-		return types.Resource{}, ErrInvalidNamespace
+		return types.Resource{}, &UnknownResourceTypeError{Prefix: prefix, Type: e.typeMapping[prefix]}
 	}
 
 	out := types.Resource{
@@ -841,9 +4573,19 @@ func (e *engine) NewResourceFromID(id gidx.PrefixedID) (types.Resource, error) {
 	return out, nil
 }
 
+// ResourceTypes returns the resource types of the policy the engine was created with.
+func (e *engine) ResourceTypes() []types.ResourceType {
+	schema := e.resourceTypes()
+	out := make([]types.ResourceType, len(schema))
+
+	copy(out, schema)
+
+	return out
+}
+
 // GetResourceType returns the resource type by name
 func (e *engine) GetResourceType(name string) *types.ResourceType {
-	rType, ok := e.schemaTypeMap[name]
+	rType, ok := e.resourceType(name)
 	if !ok {
 		return nil
 	}
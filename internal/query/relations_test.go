@@ -2,7 +2,11 @@ package query
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/v1"
@@ -16,35 +20,43 @@ import (
 	"go.infratographer.com/x/gidx"
 )
 
-func testEngine(ctx context.Context, t *testing.T, namespace string) Engine {
+func testEngine(ctx context.Context, t *testing.T, namespace string, options ...Option) Engine {
 	config := spicedbx.Config{
 		Endpoint: "spicedb:50051",
 		Key:      "infradev",
 		Insecure: true,
 	}
 
-	client, err := spicedbx.NewClient(config, false)
+	client, conn, err := spicedbx.NewClientConn(config, false)
 	require.NoError(t, err)
 
-	policy := testPolicy()
+	out := NewEngine(namespace, client, append([]Option{WithPolicy(testPolicy()), WithClientConn(conn), WithExperimentalClient(pb.NewExperimentalServiceClient(conn))}, options...)...)
 
-	schema, err := spicedbx.GenerateSchema(namespace, policy.Schema())
-	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, out.Close())
+	})
 
-	request := &pb.WriteSchemaRequest{Schema: schema}
-	_, err = client.WriteSchema(ctx, request)
+	_, err = out.ApplySchema(ctx)
 	require.NoError(t, err)
 
+	// Cleanups run last added first, so the database is cleaned before the engine is closed.
 	t.Cleanup(func() {
 		cleanDB(ctx, t, client, namespace)
 	})
 
-	out := NewEngine(namespace, client, WithPolicy(policy))
-
 	return out
 }
 
 func testPolicy() iapl.Policy {
+	policy := iapl.NewPolicy(testPolicyDocument())
+	if err := policy.Validate(); err != nil {
+		panic(err)
+	}
+
+	return policy
+}
+
+func testPolicyDocument() iapl.PolicyDocument {
 	policyDocument := iapl.DefaultPolicyDocument()
 
 	policyDocument.ResourceTypes = append(policyDocument.ResourceTypes,
@@ -58,20 +70,71 @@ func testPolicy() iapl.Policy {
 						"tenant",
 					},
 				},
+				{
+					Relation: "banned",
+					TargetTypeNames: []string{
+						"subject",
+					},
+				},
+				{
+					Relation: "watcher",
+					TargetTypeNames: []string{
+						"user",
+						"client",
+						"group",
+					},
+				},
+			},
+		},
+		iapl.ResourceType{
+			Name:     "group",
+			IDPrefix: "idntgrp",
+			Relationships: []iapl.Relationship{
+				{
+					Relation: "member",
+					TargetTypeNames: []string{
+						"user",
+						"group#member",
+					},
+				},
 			},
 		},
 	)
 
-	policy := iapl.NewPolicy(policyDocument)
-	if err := policy.Validate(); err != nil {
-		panic(err)
-	}
+	policyDocument.Actions = append(policyDocument.Actions,
+		iapl.Action{
+			Name: "loadbalancer_manage",
+			Includes: []string{
+				"loadbalancer_get",
+				"loadbalancer_update",
+				"loadbalancer_delete",
+			},
+		},
+	)
 
-	return policy
+	policyDocument.ActionBindings = append(policyDocument.ActionBindings,
+		iapl.ActionBinding{
+			ActionName: "loadbalancer_get",
+			TypeName:   "child",
+			Conditions: []iapl.Condition{
+				{
+					RelationshipAction: &iapl.ConditionRelationshipAction{
+						Relation:   "parent",
+						ActionName: "loadbalancer_get",
+					},
+				},
+			},
+			ExcludedRelations: []string{
+				"banned",
+			},
+		},
+	)
+
+	return policyDocument
 }
 
 func cleanDB(ctx context.Context, t *testing.T, client *authzed.Client, namespace string) {
-	for _, dbType := range []string{"user", "client", "role", "tenant"} {
+	for _, dbType := range []string{"user", "client", "role", "tenant", "group", "child"} {
 		namespacedType := namespace + "/" + dbType
 		delRequest := &pb.DeleteRelationshipsRequest{
 			RelationshipFilter: &pb.RelationshipFilter{
@@ -95,7 +158,18 @@ func TestCreateRoles(t *testing.T) {
 				"bad_action",
 			},
 			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
-				assert.Error(t, res.Err)
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+				assert.NotErrorIs(t, res.Err, ErrInvalidActionName)
+			},
+		},
+		{
+			Name: "CreateMalformedAction",
+			Input: []string{
+				"loadbalancer-get",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidActionName)
+				assert.NotErrorIs(t, res.Err, ErrInvalidAction)
 			},
 		},
 		{
@@ -141,6 +215,230 @@ func TestCreateRoles(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
+func TestRoleTuples(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	e := NewEngine("test", nil, WithPolicy(testPolicy()))
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[[]string, []types.Relationship]{
+		{
+			Name:  "InvalidAction",
+			Input: []string{"loadbalancer_get", "fly"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name:  "Success",
+			Input: []string{"loadbalancer_get", "loadbalancer_update"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				require.Len(t, res.Success, 3)
+
+				role := res.Success[0].Subject
+
+				assert.Equal(t, types.RoleResourceType, role.Type)
+
+				expected := []types.Relationship{
+					{
+						Resource:        tenRes,
+						Relation:        actionToRelation("loadbalancer_get"),
+						Subject:         role,
+						SubjectRelation: roleSubjectRelation,
+					},
+					{
+						Resource:        tenRes,
+						Relation:        actionToRelation("loadbalancer_update"),
+						Subject:         role,
+						SubjectRelation: roleSubjectRelation,
+					},
+					{
+						Resource: role,
+						Relation: iapl.RoleOwnerRelation,
+						Subject:  tenRes,
+					},
+				}
+
+				assert.Equal(t, expected, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, actions []string) testingx.TestResult[[]types.Relationship] {
+		rels, err := e.RoleTuples(tenRes, actions)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestCreateRolesBatch(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tooManyRoleSpecs := make([]RoleSpec, maxWriteUpdates)
+	for i := range tooManyRoleSpecs {
+		tooManyRoleSpecs[i] = RoleSpec{Actions: []string{"loadbalancer_get"}}
+	}
+
+	testCases := []testingx.TestCase[[]RoleSpec, []types.Role]{
+		{
+			Name: "CreateInvalidAction",
+			Input: []RoleSpec{
+				{
+					Actions: []string{"loadbalancer_get"},
+				},
+				{
+					Actions: []string{"bad_action"},
+				},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name:  "CreateTooLarge",
+			Input: tooManyRoleSpecs,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrTransactionTooLarge)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name: "CreateSuccess",
+			Input: []RoleSpec{
+				{
+					Actions: []string{"loadbalancer_get"},
+					Name:    "lb viewer",
+				},
+				{
+					Actions: []string{"loadbalancer_get", "loadbalancer_update"},
+					Name:    "lb editor",
+				},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.NoError(t, res.Err)
+				require.Len(t, res.Success, 2)
+
+				names := []string{res.Success[0].Name, res.Success[1].Name}
+				assert.ElementsMatch(t, []string{"lb viewer", "lb editor"}, names)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, specs []RoleSpec) testingx.TestResult[[]types.Role] {
+		tenID, err := gidx.NewID("tnntten")
+		require.NoError(t, err)
+		tenRes, err := e.NewResourceFromID(tenID)
+		require.NoError(t, err)
+
+		created, queryToken, err := e.CreateRoles(ctx, tenRes, specs)
+		for _, role := range created {
+			assert.Equal(t, tenRes.ID, role.Owner.ID)
+		}
+
+		roles, listErr := e.ListRoles(ctx, tenRes, queryToken)
+		require.NoError(t, listErr)
+
+		return testingx.TestResult[[]types.Role]{
+			Success: roles,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestListRolesPage(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+
+	var queryToken string
+
+	for i := 0; i < 3; i++ {
+		_, queryToken, err = e.CreateRole(ctx, tenRes, []string{"loadbalancer_get", "loadbalancer_update"})
+		require.NoError(t, err)
+	}
+
+	firstPage, cursor, err := e.ListRolesPage(ctx, tenRes, queryToken, PageOpts{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, firstPage, 2)
+	require.NotEmpty(t, cursor)
+
+	for _, role := range firstPage {
+		assert.ElementsMatch(t, []string{"loadbalancer_get", "loadbalancer_update"}, role.Actions)
+	}
+
+	secondPage, cursor, err := e.ListRolesPage(ctx, tenRes, queryToken, PageOpts{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, secondPage, 1)
+	assert.Empty(t, cursor)
+
+	assert.NotContains(t, firstPage, secondPage[0])
+
+	_, _, err = e.ListRolesPage(ctx, tenRes, queryToken, PageOpts{Limit: 2, Cursor: "!"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestListAllRoles(t *testing.T) {
+	namespace := "testallroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	owners := make(map[gidx.PrefixedID]types.Resource)
+
+	var queryToken string
+
+	for i := 0; i < 2; i++ {
+		tenID, err := gidx.NewID("tnntten")
+		require.NoError(t, err)
+		tenRes, err := e.NewResourceFromID(tenID)
+		require.NoError(t, err)
+
+		var role types.Role
+
+		role, queryToken, err = e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+		require.NoError(t, err)
+
+		owners[role.ID] = tenRes
+	}
+
+	roles, err := e.ListAllRoles(ctx, queryToken)
+	require.NoError(t, err)
+
+	found := 0
+
+	for _, role := range roles {
+		owner, ok := owners[role.ID]
+		if !ok {
+			continue
+		}
+
+		found++
+
+		assert.Equal(t, owner, role.Owner)
+		assert.Equal(t, []string{"loadbalancer_get"}, role.Actions)
+	}
+
+	assert.Equal(t, len(owners), found)
+}
+
 func TestGetRoles(t *testing.T) {
 	namespace := "testroles"
 	ctx := context.Background()
@@ -150,7 +448,7 @@ func TestGetRoles(t *testing.T) {
 	tenRes, err := e.NewResourceFromID(tenID)
 	require.NoError(t, err)
 
-	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleName("lb getter"), WithRoleDescription("gets load balancers"))
 	require.NoError(t, err)
 	roleRes, err := e.NewResourceFromID(role.ID)
 	require.NoError(t, err)
@@ -177,6 +475,8 @@ func TestGetRoles(t *testing.T) {
 				assert.NoError(t, res.Err)
 				require.NotEmpty(t, res.Success.ID)
 
+				assert.Equal(t, "lb getter", res.Success.Name)
+				assert.Equal(t, "gets load balancers", res.Success.Description)
 				assert.Equal(t, expActions, res.Success.Actions)
 			},
 		},
@@ -194,7 +494,7 @@ func TestGetRoles(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestRoleDelete(t *testing.T) {
+func TestExpandRole(t *testing.T) {
 	namespace := "testroles"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
@@ -203,50 +503,56 @@ func TestRoleDelete(t *testing.T) {
 	require.NoError(t, err)
 	tenRes, err := e.NewResourceFromID(tenID)
 	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
 
-	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
 	require.NoError(t, err)
-	roles, err := e.ListRoles(ctx, tenRes, queryToken)
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
 	require.NoError(t, err)
-	require.NotEmpty(t, roles)
 
-	testCases := []testingx.TestCase[gidx.PrefixedID, []types.Role]{
+	roleRes, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	missingRes, err := e.NewResourceFromID(gidx.MustNewID(RolePrefix))
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, *PermissionTree]{
 		{
-			Name:  "DeleteMissingRole",
-			Input: gidx.MustNewID(RolePrefix),
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
-				assert.Error(t, res.Err)
+			Name:  "RoleNotFound",
+			Input: missingRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[*PermissionTree]) {
+				assert.ErrorIs(t, res.Err, ErrRoleNotFound)
 			},
 		},
 		{
-			Name:  "DeleteSuccess",
-			Input: role.ID,
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
-				assert.NoError(t, res.Err)
-				require.Empty(t, res.Success)
+			Name:  "Success",
+			Input: roleRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[*PermissionTree]) {
+				require.NoError(t, res.Err)
+
+				assert.Equal(t, roleRes, res.Success.Role)
+				assert.Equal(t, tenRes, res.Success.Resource)
+				assert.Equal(t, []string{"loadbalancer_get"}, res.Success.Actions)
+
+				expSubjects := []PermissionTreeNode{
+					{
+						Subject: subjRes,
+					},
+				}
+
+				assert.Equal(t, expSubjects, res.Success.Subjects)
 			},
 		},
 	}
 
-	testFn := func(ctx context.Context, roleID gidx.PrefixedID) testingx.TestResult[[]types.Role] {
-		roleResource, err := e.NewResourceFromID(roleID)
-		if err != nil {
-			return testingx.TestResult[[]types.Role]{
-				Err: err,
-			}
-		}
-
-		queryToken, err = e.DeleteRole(ctx, roleResource, queryToken)
-		if err != nil {
-			return testingx.TestResult[[]types.Role]{
-				Err: err,
-			}
-		}
-
-		roles, err := e.ListRoles(ctx, tenRes, queryToken)
+	testFn := func(ctx context.Context, roleResource types.Resource) testingx.TestResult[*PermissionTree] {
+		tree, err := e.ExpandRole(ctx, roleResource, queryToken)
 
-		return testingx.TestResult[[]types.Role]{
-			Success: roles,
+		return testingx.TestResult[*PermissionTree]{
+			Success: tree,
 			Err:     err,
 		}
 	}
@@ -254,55 +560,2455 @@ func TestRoleDelete(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestAssignments(t *testing.T) {
-	namespace := "testassignments"
+func TestResolvePermissionPath(t *testing.T) {
+	namespace := "testpermissionpath"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
 
-	tenID, err := gidx.NewID("tnntten")
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
 	require.NoError(t, err)
-	tenRes, err := e.NewResourceFromID(tenID)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
 	require.NoError(t, err)
-	subjID, err := gidx.NewID("idntusr")
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
 	require.NoError(t, err)
-	subjRes, err := e.NewResourceFromID(subjID)
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
 	require.NoError(t, err)
-	role, _, err := e.CreateRole(
-		ctx,
-		tenRes,
-		[]string{
-			"loadbalancer_update",
-		},
-	)
-	assert.NoError(t, err)
 
-	testCases := []testingx.TestCase[types.Role, []types.Resource]{
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
 		{
-			Name:  "Success",
-			Input: role,
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
-				expAssignments := []types.Resource{
-					subjRes,
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  parentRes,
+		},
+	})
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, parentRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	ctx = ContextWithQueryToken(ctx, queryToken)
+
+	roleRes, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, *Path]{
+		{
+			Name:  "Granted",
+			Input: subjRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[*Path]) {
+				require.NoError(t, res.Err)
+
+				assert.True(t, res.Success.Granted)
+				assert.True(t, res.Success.Complete)
+
+				steps := res.Success.Steps
+				require.NotEmpty(t, steps)
+
+				assert.Equal(t, childRes, steps[0].Resource)
+				assert.Equal(t, subjRes, steps[len(steps)-1].Subject)
+				assert.Empty(t, steps[len(steps)-1].SubjectRelation)
+
+				var throughRole bool
+
+				for _, step := range steps {
+					if step.Subject == roleRes {
+						throughRole = true
+					}
 				}
 
+				assert.True(t, throughRole)
+			},
+		},
+		{
+			Name:  "Denied",
+			Input: otherRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[*Path]) {
+				require.NoError(t, res.Err)
+
+				assert.False(t, res.Success.Granted)
+				assert.False(t, res.Success.Complete)
+
+				steps := res.Success.Steps
+				require.NotEmpty(t, steps)
+
+				assert.Equal(t, childRes, steps[0].Resource)
+				assert.Equal(t, roleRes, steps[len(steps)-1].Subject)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, subject types.Resource) testingx.TestResult[*Path] {
+		path, err := e.ResolvePermissionPath(ctx, subject, "loadbalancer_get", childRes)
+
+		return testingx.TestResult[*Path]{
+			Success: path,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRoleInheritance(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+
+	_, _, err = e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleParents(tenID))
+	assert.ErrorIs(t, err, ErrInvalidRoleParent)
+
+	parent, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	child, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_update"}, WithRoleParents(parent.ID))
+	require.NoError(t, err)
+	assert.Equal(t, []gidx.PrefixedID{parent.ID}, child.Parents)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, child)
+	require.NoError(t, err)
+
+	childRes, err := e.NewResourceFromID(child.ID)
+	require.NoError(t, err)
+
+	got, err := e.GetRole(ctx, childRes, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []gidx.PrefixedID{parent.ID}, got.Parents)
+
+	assignments, err := e.ListAssignments(ctx, parent, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	err = e.SubjectHasPermission(ctx, subjRes, "loadbalancer_update", tenRes)
+	assert.NoError(t, err)
+
+	err = e.SubjectHasPermission(ctx, subjRes, "loadbalancer_get", tenRes)
+	assert.NoError(t, err)
+}
+
+func TestGarbageCollect(t *testing.T) {
+	namespace := "testgc"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	deletedRole, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	deletedRoleRes, err := e.NewResourceFromID(deletedRole.ID)
+	require.NoError(t, err)
+
+	liveRole, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, deletedRole)
+	require.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, liveRole)
+	require.NoError(t, err)
+
+	// Deleting a role leaves its assignments behind.
+	_, err = e.DeleteRole(ctx, deletedRoleRes, "")
+	require.NoError(t, err)
+
+	report, err := e.GarbageCollect(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []types.Resource{deletedRoleRes}, report.Roles)
+	assert.Equal(t, []types.Relationship{
+		{
+			Resource: deletedRoleRes,
+			Relation: "subject",
+			Subject:  subjRes,
+		},
+	}, report.Relationships)
+	assert.NotEmpty(t, report.QueryToken)
+
+	assignments, err := e.ListAssignments(ctx, deletedRole, report.QueryToken)
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	assignments, err = e.ListAssignments(ctx, liveRole, report.QueryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{subjRes}, assignments)
+}
+
+func TestRoleDelete(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+
+	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	roles, err := e.ListRoles(ctx, tenRes, queryToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, roles)
+
+	testCases := []testingx.TestCase[gidx.PrefixedID, []types.Role]{
+		{
+			Name:  "DeleteMissingRole",
+			Input: gidx.MustNewID(RolePrefix),
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.Error(t, res.Err)
+			},
+		},
+		{
+			Name:  "DeleteSuccess",
+			Input: role.ID,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				assert.NoError(t, res.Err)
+				require.Empty(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, roleID gidx.PrefixedID) testingx.TestResult[[]types.Role] {
+		roleResource, err := e.NewResourceFromID(roleID)
+		if err != nil {
+			return testingx.TestResult[[]types.Role]{
+				Err: err,
+			}
+		}
+
+		queryToken, err = e.DeleteRole(ctx, roleResource, queryToken)
+		if err != nil {
+			return testingx.TestResult[[]types.Role]{
+				Err: err,
+			}
+		}
+
+		roles, err := e.ListRoles(ctx, tenRes, queryToken)
+
+		return testingx.TestResult[[]types.Role]{
+			Success: roles,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRoleTombstone(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace, WithRoleTombstones())
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleName("auditors"))
+	require.NoError(t, err)
+
+	roleRes, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	queryToken, err := e.DeleteRole(ctx, roleRes, "")
+	require.NoError(t, err)
+
+	roles, err := e.ListRoles(ctx, tenRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	roles, err = e.ListRoles(ctx, tenRes, queryToken, IncludeDeleted())
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.Equal(t, role.ID, roles[0].ID)
+	assert.Equal(t, "auditors", roles[0].Name)
+	assert.Equal(t, []string{"loadbalancer_get"}, roles[0].Actions)
+	assert.True(t, roles[0].IsDeleted())
+
+	deleted, err := e.GetRole(ctx, roleRes, queryToken)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted())
+
+	// Deleting the role again keeps the original deletion time.
+	queryToken, err = e.DeleteRole(ctx, roleRes, queryToken)
+	require.NoError(t, err)
+
+	redeleted, err := e.GetRole(ctx, roleRes, queryToken)
+	require.NoError(t, err)
+	assert.True(t, deleted.DeletedAt.Equal(redeleted.DeletedAt))
+
+	assignments, err := e.ListAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	err = e.SubjectHasPermission(ContextWithQueryToken(ctx, queryToken), subjRes, "loadbalancer_get", tenRes)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+
+	// A deleted role may not be assigned or given actions again.
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	assert.ErrorIs(t, err, ErrRoleDeleted)
+
+	_, err = e.AssignSubjectRoles(ctx, []types.Resource{subjRes}, role)
+	assert.ErrorIs(t, err, ErrRoleDeleted)
+
+	_, err = e.AddRoleAction(ctx, roleRes, "loadbalancer_update")
+	assert.ErrorIs(t, err, ErrRoleDeleted)
+
+	_, _, err = e.UpdateRole(ctx, roleRes, []string{"loadbalancer_update"})
+	assert.ErrorIs(t, err, ErrRoleDeleted)
+
+	tx := e.Begin()
+	require.NoError(t, tx.AssignSubjectRole(subjRes, role))
+
+	_, err = tx.Commit(ctx)
+	assert.ErrorIs(t, err, ErrRoleDeleted)
+
+	queryToken, err = e.PurgeRole(ctx, roleRes, queryToken)
+	require.NoError(t, err)
+
+	_, err = e.GetRole(ctx, roleRes, queryToken)
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+
+	roles, err = e.ListRoles(ctx, tenRes, queryToken, IncludeDeleted())
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
+
+func TestRolesDelete(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+
+	roles, queryToken, err := e.CreateRoles(ctx, tenRes, []RoleSpec{
+		{Actions: []string{"loadbalancer_get"}},
+		{Actions: []string{"loadbalancer_update"}},
+	})
+	require.NoError(t, err)
+
+	for _, role := range roles {
+		queryToken, err = e.AssignSubjectRole(ctx, subjRes, role)
+		require.NoError(t, err)
+	}
+
+	roleResources := make([]types.Resource, len(roles))
+
+	for i, role := range roles {
+		roleResources[i], err = e.NewResourceFromID(role.ID)
+		require.NoError(t, err)
+	}
+
+	missingRole, err := e.NewResourceFromID(gidx.MustNewID(RolePrefix))
+	require.NoError(t, err)
+
+	queryToken, err = e.DeleteRoles(ctx, append(roleResources, missingRole))
+	require.NoError(t, err)
+
+	remaining, err := e.ListRoles(ctx, tenRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+
+	for _, role := range roles {
+		assignments, err := e.ListAssignments(ctx, role, queryToken)
+		require.NoError(t, err)
+		assert.Empty(t, assignments)
+	}
+
+	// Deleting the roles again is a no-op.
+	_, err = e.DeleteRoles(ctx, roleResources)
+	assert.NoError(t, err)
+}
+
+func TestRoleUpdate(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get", "loadbalancer_update"})
+	require.NoError(t, err)
+	roleRes, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	missingRes, err := e.NewResourceFromID(gidx.MustNewID(RolePrefix))
+	require.NoError(t, err)
+
+	type testInput struct {
+		role    types.Resource
+		actions []string
+	}
+
+	testCases := []testingx.TestCase[testInput, types.Role]{
+		{
+			Name: "UpdateMissingRole",
+			Input: testInput{
+				role:    missingRes,
+				actions: []string{"loadbalancer_get"},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrRoleNotFound)
+			},
+		},
+		{
+			Name: "UpdateInvalidAction",
+			Input: testInput{
+				role:    roleRes,
+				actions: []string{"bad_action"},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name: "UpdateNoActions",
+			Input: testInput{
+				role:    roleRes,
+				actions: []string{},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrRoleWithoutActions)
+			},
+		},
+		{
+			Name: "UpdateSuccess",
+			Input: testInput{
+				role:    roleRes,
+				actions: []string{"loadbalancer_get", "loadbalancer_delete"},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				require.NoError(t, res.Err)
+
+				assert.Equal(t, role.ID, res.Success.ID)
+				assert.ElementsMatch(t, []string{"loadbalancer_get", "loadbalancer_delete"}, res.Success.Actions)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input testInput) testingx.TestResult[types.Role] {
+		_, queryToken, err := e.UpdateRole(ctx, input.role, input.actions)
+		if err != nil {
+			return testingx.TestResult[types.Role]{
+				Err: err,
+			}
+		}
+
+		role, err := e.GetRole(ctx, input.role, queryToken)
+
+		return testingx.TestResult[types.Role]{
+			Success: role,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRoleActionUpdate(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+
+	missingRes, err := e.NewResourceFromID(gidx.MustNewID(RolePrefix))
+	require.NoError(t, err)
+
+	// Each case gets its own role since the cases run in parallel.
+	newRole := func() types.Resource {
+		role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get", "loadbalancer_update"})
+		require.NoError(t, err)
+
+		roleRes, err := e.NewResourceFromID(role.ID)
+		require.NoError(t, err)
+
+		return roleRes
+	}
+
+	singleActionRole, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	lastActionRole, err := e.NewResourceFromID(singleActionRole.ID)
+	require.NoError(t, err)
+
+	type testInput struct {
+		role   types.Resource
+		add    bool
+		action string
+	}
+
+	testCases := []testingx.TestCase[testInput, types.Role]{
+		{
+			Name: "MissingRole",
+			Input: testInput{
+				role:   missingRes,
+				add:    true,
+				action: "loadbalancer_delete",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrRoleNotFound)
+			},
+		},
+		{
+			Name: "InvalidAction",
+			Input: testInput{
+				role:   newRole(),
+				add:    true,
+				action: "bad_action",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name: "AddAction",
+			Input: testInput{
+				role:   newRole(),
+				add:    true,
+				action: "loadbalancer_delete",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				require.NoError(t, res.Err)
+
+				assert.ElementsMatch(t, []string{"loadbalancer_get", "loadbalancer_update", "loadbalancer_delete"}, res.Success.Actions)
+			},
+		},
+		{
+			Name: "AddExistingAction",
+			Input: testInput{
+				role:   newRole(),
+				add:    true,
+				action: "loadbalancer_get",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				require.NoError(t, res.Err)
+
+				assert.ElementsMatch(t, []string{"loadbalancer_get", "loadbalancer_update"}, res.Success.Actions)
+			},
+		},
+		{
+			Name: "RemoveAction",
+			Input: testInput{
+				role:   newRole(),
+				action: "loadbalancer_update",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				require.NoError(t, res.Err)
+
+				assert.ElementsMatch(t, []string{"loadbalancer_get"}, res.Success.Actions)
+			},
+		},
+		{
+			Name: "RemoveMissingAction",
+			Input: testInput{
+				role:   newRole(),
+				action: "loadbalancer_delete",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				require.NoError(t, res.Err)
+
+				assert.ElementsMatch(t, []string{"loadbalancer_get", "loadbalancer_update"}, res.Success.Actions)
+			},
+		},
+		{
+			Name: "RemoveLastAction",
+			Input: testInput{
+				role:   lastActionRole,
+				action: "loadbalancer_get",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[types.Role]) {
+				assert.ErrorIs(t, res.Err, ErrRoleWithoutActions)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input testInput) testingx.TestResult[types.Role] {
+		update := e.RemoveRoleAction
+		if input.add {
+			update = e.AddRoleAction
+		}
+
+		queryToken, err := update(ctx, input.role, input.action)
+		if err != nil {
+			return testingx.TestResult[types.Role]{
+				Err: err,
+			}
+		}
+
+		role, err := e.GetRole(ctx, input.role, queryToken)
+
+		return testingx.TestResult[types.Role]{
+			Success: role,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestAssignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	role, _, err := e.CreateRole(
+		ctx,
+		tenRes,
+		[]string{
+			"loadbalancer_update",
+		},
+	)
+	assert.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Role, []types.Resource]{
+		{
+			Name:  "Success",
+			Input: role,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				expAssignments := []types.Resource{
+					subjRes,
+				}
+
+				assert.NoError(t, res.Err)
+				assert.Equal(t, expAssignments, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, role types.Role) testingx.TestResult[[]types.Resource] {
+		queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+		if err != nil {
+			return testingx.TestResult[[]types.Resource]{
+				Err: err,
+			}
+		}
+
+		resources, err := e.ListAssignments(ctx, role, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: resources,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestAssignmentsAtSnapshot(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	firstRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	secondRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	snapshot, err := e.AssignSubjectRole(ctx, firstRes, role)
+	require.NoError(t, err)
+
+	latest, err := e.AssignSubjectRole(ctx, secondRes, role)
+	require.NoError(t, err)
+
+	assignments, err := e.ListAssignments(ContextAtSnapshot(ctx, snapshot), role, "")
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{firstRes}, assignments)
+
+	assignments, err = e.ListAssignments(ctx, role, latest)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []types.Resource{firstRes, secondRes}, assignments)
+}
+
+func TestBulkAssignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	subjects := make([]types.Resource, maxWriteUpdates+5)
+
+	for i := range subjects {
+		subjects[i], err = e.NewResourceFromID(gidx.MustNewID("idntusr"))
+		require.NoError(t, err)
+	}
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	// Duplicate subjects are only written once.
+	queryToken, err := e.AssignSubjectRoles(ctx, append(subjects, subjects[0]), role)
+	require.NoError(t, err)
+	assert.NotEmpty(t, queryToken)
+
+	assignments, err := e.ListAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, subjects, assignments)
+
+	// More assignments than fit in a single page are counted.
+	count, err := e.CountAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, len(subjects), count)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = e.AssignSubjectRoles(canceled, subjects, role)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBulkAssignmentsReport(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	firstRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	secondRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	// A tenant may not be assigned a role, which fails the whole call when strict.
+	_, err = e.AssignSubjectRoles(ctx, []types.Resource{firstRes, tenRes}, role)
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+
+	report, err := e.AssignSubjectRolesWithReport(ctx, []types.Resource{firstRes, tenRes, secondRes}, role, StrictAssignments())
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+	assert.Empty(t, report.Assigned)
+
+	assignments, err := e.ListAssignments(ctx, role, "")
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	// Otherwise the valid subjects are assigned the role.
+	report, err = e.AssignSubjectRolesWithReport(ctx, []types.Resource{firstRes, tenRes, secondRes}, role)
+	require.NoError(t, err)
+	assert.NotEmpty(t, report.QueryToken)
+	assert.Equal(t, []types.Resource{firstRes, secondRes}, report.Assigned)
+
+	require.Len(t, report.Failed, 1)
+	assert.Equal(t, tenRes, report.Failed[0].Subject)
+	assert.ErrorIs(t, report.Failed[0].Err, ErrInvalidRelationship)
+
+	assignments, err = e.ListAssignments(ctx, role, report.QueryToken)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []types.Resource{firstRes, secondRes}, assignments)
+}
+
+func TestBulkUnassignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	subjects := make([]types.Resource, 3)
+
+	for i := range subjects {
+		subjects[i], err = e.NewResourceFromID(gidx.MustNewID("idntusr"))
+		require.NoError(t, err)
+	}
+
+	unassignedRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRoles(ctx, subjects, role)
+	require.NoError(t, err)
+
+	// Subjects without the role are ignored.
+	queryToken, err := e.UnassignSubjectRoles(ctx, []types.Resource{subjects[0], subjects[1], unassignedRes}, role)
+	require.NoError(t, err)
+	assert.NotEmpty(t, queryToken)
+
+	assignments, err := e.ListAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{subjects[2]}, assignments)
+}
+
+func TestChunkUpdates(t *testing.T) {
+	t.Parallel()
+
+	updates := make([]*pb.RelationshipUpdate, 5)
+
+	assert.Empty(t, chunkUpdates(nil, 2))
+	assert.Len(t, chunkUpdates(updates, 5), 1)
+
+	chunks := chunkUpdates(updates, 2)
+
+	require.Len(t, chunks, 3)
+	assert.Len(t, chunks[0], 2)
+	assert.Len(t, chunks[1], 2)
+	assert.Len(t, chunks[2], 1)
+}
+
+func TestGetRoleWithAssignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleName("lb getter"))
+	require.NoError(t, err)
+	roleRes, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	missingRes, err := e.NewResourceFromID(gidx.MustNewID(RolePrefix))
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, RoleDetail]{
+		{
+			Name:  "NotFound",
+			Input: missingRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[RoleDetail]) {
+				assert.ErrorIs(t, res.Err, ErrRoleNotFound)
+			},
+		},
+		{
+			Name:  "Success",
+			Input: roleRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[RoleDetail]) {
+				require.NoError(t, res.Err)
+
+				assert.Equal(t, role.ID, res.Success.ID)
+				assert.Equal(t, "lb getter", res.Success.Name)
+				assert.Equal(t, tenRes, res.Success.Owner)
+				assert.Equal(t, []string{"loadbalancer_get"}, res.Success.Actions)
+				assert.Equal(t, []types.Resource{subjRes}, res.Success.Assignments)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, roleResource types.Resource) testingx.TestResult[RoleDetail] {
+		detail, err := e.GetRoleWithAssignments(ctx, roleResource, queryToken)
+
+		return testingx.TestResult[RoleDetail]{
+			Success: detail,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestListRolesForSubject(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenARes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	tenBRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	otherSubjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	roleA, _, err := e.CreateRole(ctx, tenARes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	roleB, _, err := e.CreateRole(ctx, tenBRes, []string{"loadbalancer_update"})
+	require.NoError(t, err)
+	unassigned, _, err := e.CreateRole(ctx, tenARes, []string{"loadbalancer_delete"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, roleA)
+	require.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, otherSubjRes, unassigned)
+	require.NoError(t, err)
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, roleB)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, []types.Role]{
+		{
+			Name:  "AssignedRoles",
+			Input: subjRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				require.NoError(t, res.Err)
+				require.Len(t, res.Success, 2)
+
+				owners := map[gidx.PrefixedID]types.Resource{
+					roleA.ID: tenARes,
+					roleB.ID: tenBRes,
+				}
+
+				for _, role := range res.Success {
+					assert.Equal(t, owners[role.ID], role.Owner)
+				}
+			},
+		},
+		{
+			Name:  "NoRoles",
+			Input: tenARes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Role]) {
+				require.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, subject types.Resource) testingx.TestResult[[]types.Role] {
+		roles, err := e.ListRolesForSubject(ctx, subject, queryToken)
+
+		return testingx.TestResult[[]types.Role]{
+			Success: roles,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectHasRole(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	otherSubjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	type input struct {
+		subject types.Resource
+		role    types.Role
+	}
+
+	testCases := []testingx.TestCase[input, bool]{
+		{
+			Name: "Assigned",
+			Input: input{
+				subject: subjRes,
+				role:    role,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				require.NoError(t, res.Err)
+				assert.True(t, res.Success)
+			},
+		},
+		{
+			Name: "NotAssigned",
+			Input: input{
+				subject: otherSubjRes,
+				role:    role,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success)
+			},
+		},
+		{
+			Name: "RoleNotFound",
+			Input: input{
+				subject: subjRes,
+				role:    types.Role{ID: gidx.MustNewID(RolePrefix)},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.ErrorIs(t, res.Err, ErrRoleNotFound)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, in input) testingx.TestResult[bool] {
+		assigned, err := e.SubjectHasRole(ctx, in.subject, in.role, queryToken)
+
+		return testingx.TestResult[bool]{
+			Success: assigned,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestWildcardAssignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	clientID, err := gidx.NewID("idntcli")
+	require.NoError(t, err)
+	clientRes, err := e.NewResourceFromID(clientID)
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, types.WildcardResource("tenant"), role)
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+
+	queryToken, err := e.AssignSubjectRole(ctx, types.WildcardResource("user"), role)
+	require.NoError(t, err)
+
+	assignments, err := e.ListAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{types.WildcardResource("user")}, assignments)
+
+	err = e.SubjectHasPermission(ctx, subjRes, "loadbalancer_get", tenRes)
+	assert.NoError(t, err)
+
+	err = e.SubjectHasPermission(ctx, clientRes, "loadbalancer_get", tenRes)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+}
+
+func TestUnassignments(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	role, _, err := e.CreateRole(
+		ctx,
+		tenRes,
+		[]string{
+			"loadbalancer_update",
+		},
+	)
+	assert.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Role, []types.Resource]{
+		{
+			Name:  "Success",
+			Input: role,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, role types.Role) testingx.TestResult[[]types.Resource] {
+		queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+		if err != nil {
+			return testingx.TestResult[[]types.Resource]{
+				Err: err,
+			}
+		}
+
+		resources, err := e.ListAssignments(ctx, role, queryToken)
+		if err != nil {
+			return testingx.TestResult[[]types.Resource]{
+				Err: err,
+			}
+		}
+
+		var found bool
+
+		for _, resource := range resources {
+			if resource.ID == subjRes.ID {
+				found = true
+
+				break
+			}
+		}
+
+		require.True(t, found, "expected assignment to be found")
+
+		queryToken, err = e.UnassignSubjectRole(ctx, subjRes, role)
+		if err != nil {
+			return testingx.TestResult[[]types.Resource]{
+				Err: err,
+			}
+		}
+
+		resources, err = e.ListAssignments(ctx, role, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: resources,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRelationships(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromID(parentID)
+	require.NoError(t, err)
+	childID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(childID)
+	require.NoError(t, err)
+	child2ID, err := gidx.NewID("chldten")
+	require.NoError(t, err)
+	child2Res, err := e.NewResourceFromID(child2ID)
+	require.NoError(t, err)
+	userID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(userID)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Relationship, []types.Relationship]{
+		{
+			Name: "InvalidRelationship",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "foo",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+				assert.ErrorContains(t, res.Err, "tenant has no relation foo")
+			},
+		},
+		{
+			Name: "InvalidSubjectType",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  userRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+				assert.ErrorContains(t, res.Err, "does not allow subject type user, allowed types: tenant")
+			},
+		},
+		{
+			Name: "Success",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				expRels := []types.Relationship{
+					{
+						Resource: childRes,
+						Relation: "parent",
+						Subject:  parentRes,
+					},
+				}
+
+				require.NoError(t, res.Err)
+				assert.Equal(t, expRels, res.Success)
+			},
+		},
+		{
+			Name: "Different Success",
+			Input: types.Relationship{
+				Resource: child2Res,
+				Relation: "parent",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				expRels := []types.Relationship{
+					{
+						Resource: child2Res,
+						Relation: "parent",
+						Subject:  parentRes,
+					},
+				}
+
+				require.NoError(t, res.Err)
+				assert.Equal(t, expRels, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input types.Relationship) testingx.TestResult[[]types.Relationship] {
+		queryToken, err := e.CreateRelationships(ctx, []types.Relationship{input})
+		if err != nil {
+			return testingx.TestResult[[]types.Relationship]{
+				Err: err,
+			}
+		}
+
+		rels, err := e.ListRelationshipsFrom(ctx, input.Resource, queryToken)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRelationshipsMultipleTargetTypes(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("chldten"))
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	clientRes, err := e.NewResourceFromID(gidx.MustNewID("idntcli"))
+	require.NoError(t, err)
+	groupRes, err := e.NewResourceFromID(gidx.MustNewID("idntgrp"))
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	rels := []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  userRes,
+		},
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  clientRes,
+		},
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  groupRes,
+		},
+	}
+
+	// Each target type is accepted on its own, as well as together.
+	for _, rel := range rels {
+		queryToken, err := e.CreateRelationships(ctx, []types.Relationship{rel})
+		require.NoError(t, err)
+
+		got, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+		require.NoError(t, err)
+		assert.Contains(t, got, rel)
+
+		_, err = e.DeleteRelationships(ctx, rel)
+		require.NoError(t, err)
+	}
+
+	queryToken, err := e.CreateRelationships(ctx, rels)
+	require.NoError(t, err)
+
+	got, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, rels, got)
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  tenRes,
+		},
+	})
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+	assert.ErrorContains(t, err, "relation watcher on child does not allow subject type tenant, allowed types: user, client, group")
+}
+
+func TestUpsertRelationships(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("chldten"))
+	require.NoError(t, err)
+
+	rel := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  tenRes,
+	}
+
+	_, err = e.CreateRelationshipsStrict(ctx, []types.Relationship{rel})
+	require.NoError(t, err)
+
+	_, err = e.CreateRelationshipsStrict(ctx, []types.Relationship{rel})
+	assert.ErrorIs(t, err, ErrRelationshipExists)
+
+	// Creating relationships which already exist leaves them in place.
+	_, err = e.CreateRelationships(ctx, []types.Relationship{rel})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		queryToken, err := e.UpsertRelationships(ctx, []types.Relationship{rel})
+		require.NoError(t, err)
+
+		got, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+		require.NoError(t, err)
+		assert.Equal(t, []types.Relationship{rel}, got)
+	}
+}
+
+func TestDeleteRelationshipsMatching(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("chldten"))
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	clientRes, err := e.NewResourceFromID(gidx.MustNewID("idntcli"))
+	require.NoError(t, err)
+
+	parentRel := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  tenRes,
+	}
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
+		parentRel,
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  userRes,
+		},
+		{
+			Resource: childRes,
+			Relation: "watcher",
+			Subject:  clientRes,
+		},
+	})
+	require.NoError(t, err)
+
+	_, _, err = e.DeleteRelationshipsMatching(ctx, types.Resource{Type: "child"}, FilterRelation("watcher"))
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	deleted, queryToken, err := e.DeleteRelationshipsMatching(ctx, childRes, FilterRelation("watcher"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	got, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Relationship{parentRel}, got)
+
+	deleted, queryToken, err = e.DeleteRelationshipsMatching(ctx, childRes)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	got, err = e.ListRelationshipsFrom(ctx, childRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestRelationshipsFromFiltered(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	groupRes, err := e.NewResourceFromID(gidx.MustNewID("idntgrp"))
+	require.NoError(t, err)
+	subgroupRes, err := e.NewResourceFromID(gidx.MustNewID("idntgrp"))
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	userRel := types.Relationship{
+		Resource: groupRes,
+		Relation: "member",
+		Subject:  userRes,
+	}
+
+	subgroupRel := types.Relationship{
+		Resource:        groupRes,
+		Relation:        "member",
+		Subject:         subgroupRes,
+		SubjectRelation: "member",
+	}
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{userRel, subgroupRel})
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[[]RelationshipFilterOption, []types.Relationship]{
+		{
+			Name:  "Unfiltered",
+			Input: nil,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.ElementsMatch(t, []types.Relationship{userRel, subgroupRel}, res.Success)
+			},
+		},
+		{
+			Name:  "Relation",
+			Input: []RelationshipFilterOption{FilterRelation("member")},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.ElementsMatch(t, []types.Relationship{userRel, subgroupRel}, res.Success)
+			},
+		},
+		{
+			Name:  "SubjectType",
+			Input: []RelationshipFilterOption{FilterSubjectType("user")},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []types.Relationship{userRel}, res.Success)
+			},
+		},
+		{
+			Name:  "RelationAndSubjectType",
+			Input: []RelationshipFilterOption{FilterRelation("member"), FilterSubjectType("group")},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.Equal(t, []types.Relationship{subgroupRel}, res.Success)
+			},
+		},
+		{
+			Name:  "InvalidRelation",
+			Input: []RelationshipFilterOption{FilterRelation("parent")},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+			},
+		},
+		{
+			Name:  "InvalidSubjectType",
+			Input: []RelationshipFilterOption{FilterSubjectType("widget")},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidType)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, opts []RelationshipFilterOption) testingx.TestResult[[]types.Relationship] {
+		rels, err := e.ListRelationshipsFrom(ctx, groupRes, queryToken, opts...)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestHasRelationship(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  parentRes,
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Relationship, bool]{
+		{
+			Name: "InvalidRelationship",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  userRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+			},
+		},
+		{
+			Name: "Missing",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  otherRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success)
+			},
+		},
+		{
+			Name: "Exists",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				require.NoError(t, res.Err)
+				assert.True(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, rel types.Relationship) testingx.TestResult[bool] {
+		exists, err := e.HasRelationship(ctx, rel, queryToken)
+
+		return testingx.TestResult[bool]{
+			Success: exists,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRelationshipsTo(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromID(parentID)
+	require.NoError(t, err)
+	childID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(childID)
+	require.NoError(t, err)
+	child2ID, err := gidx.NewID("chldten")
+	require.NoError(t, err)
+	child2Res, err := e.NewResourceFromID(child2ID)
+	require.NoError(t, err)
+	otherID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(otherID)
+	require.NoError(t, err)
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  parentRes,
+		},
+		{
+			Resource: child2Res,
+			Relation: "parent",
+			Subject:  parentRes,
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, []types.Relationship]{
+		{
+			Name:  "NoRelationships",
+			Input: otherRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name:  "Success",
+			Input: parentRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				expRels := []types.Relationship{
+					{
+						Resource: childRes,
+						Relation: "parent",
+						Subject:  parentRes,
+					},
+					{
+						Resource: child2Res,
+						Relation: "parent",
+						Subject:  parentRes,
+					},
+				}
+
+				require.NoError(t, res.Err)
+				assert.ElementsMatch(t, expRels, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input types.Resource) testingx.TestResult[[]types.Relationship] {
+		rels, err := e.ListRelationshipsTo(ctx, input, queryToken)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestListAncestors(t *testing.T) {
+	namespace := "testancestors"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	newTenant := func() types.Resource {
+		id, err := gidx.NewID("tnntten")
+		require.NoError(t, err)
+		res, err := e.NewResourceFromID(id)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	rootRes := newTenant()
+	midRes := newTenant()
+	leafRes := newTenant()
+	cycleARes := newTenant()
+	cycleBRes := newTenant()
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{
+		{Resource: midRes, Relation: "parent", Subject: rootRes},
+		{Resource: leafRes, Relation: "parent", Subject: midRes},
+		{Resource: cycleARes, Relation: "parent", Subject: cycleBRes},
+		{Resource: cycleBRes, Relation: "parent", Subject: cycleARes},
+	})
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, []types.Resource]{
+		{
+			Name:  "Root",
+			Input: rootRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name:  "Leaf",
+			Input: leafRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Equal(t, []types.Resource{midRes, rootRes}, res.Success)
+			},
+		},
+		{
+			Name:  "Cycle",
+			Input: cycleARes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrResourceCycle)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, res types.Resource) testingx.TestResult[[]types.Resource] {
+		ancestors, err := e.ListAncestors(ctx, res, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: ancestors,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestRelationshipDelete(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromID(parentID)
+	require.NoError(t, err)
+	childID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(childID)
+	require.NoError(t, err)
+
+	relReq := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{relReq})
+	require.NoError(t, err)
+
+	createdResources, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, createdResources)
+
+	testCases := []testingx.TestCase[types.Relationship, []types.Relationship]{
+		{
+			Name: "InvalidRelationship",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "foo",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+			},
+		},
+		{
+			Name: "Success",
+			Input: types.Relationship{
+				Resource: childRes,
+				Relation: "parent",
+				Subject:  parentRes,
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				require.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input types.Relationship) testingx.TestResult[[]types.Relationship] {
+		queryToken, err := e.DeleteRelationships(ctx, input)
+		if err != nil {
+			return testingx.TestResult[[]types.Relationship]{
+				Err: err,
+			}
+		}
+
+		rels, err := e.ListRelationshipsFrom(ctx, input.Resource, queryToken)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectSetRelationships(t *testing.T) {
+	namespace := "testsubjectsets"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	engID, err := gidx.NewID("idntgrp")
+	require.NoError(t, err)
+	engRes, err := e.NewResourceFromID(engID)
+	require.NoError(t, err)
+	orgID, err := gidx.NewID("idntgrp")
+	require.NoError(t, err)
+	orgRes, err := e.NewResourceFromID(orgID)
+	require.NoError(t, err)
+	userID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromID(userID)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Relationship, []types.Relationship]{
+		{
+			Name: "UnknownSubjectRelation",
+			Input: types.Relationship{
+				Resource:        orgRes,
+				Relation:        "member",
+				Subject:         userRes,
+				SubjectRelation: "member",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+				assert.ErrorContains(t, res.Err, "does not allow subject type user#member")
+			},
+		},
+		{
+			Name: "Success",
+			Input: types.Relationship{
+				Resource:        orgRes,
+				Relation:        "member",
+				Subject:         engRes,
+				SubjectRelation: "member",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
+				expRels := []types.Relationship{
+					{
+						Resource:        orgRes,
+						Relation:        "member",
+						Subject:         engRes,
+						SubjectRelation: "member",
+					},
+				}
+
+				require.NoError(t, res.Err)
+				assert.Equal(t, expRels, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input types.Relationship) testingx.TestResult[[]types.Relationship] {
+		queryToken, err := e.CreateRelationships(ctx, []types.Relationship{input})
+		if err != nil {
+			return testingx.TestResult[[]types.Relationship]{
+				Err: err,
+			}
+		}
+
+		rels, err := e.ListRelationshipsFrom(ctx, input.Resource, queryToken)
+
+		return testingx.TestResult[[]types.Relationship]{
+			Success: rels,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectSetRelationshipDelete(t *testing.T) {
+	namespace := "testsubjectsets"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	engID, err := gidx.NewID("idntgrp")
+	require.NoError(t, err)
+	engRes, err := e.NewResourceFromID(engID)
+	require.NoError(t, err)
+	orgID, err := gidx.NewID("idntgrp")
+	require.NoError(t, err)
+	orgRes, err := e.NewResourceFromID(orgID)
+	require.NoError(t, err)
+
+	direct := types.Relationship{
+		Resource: orgRes,
+		Relation: "member",
+		Subject:  engRes,
+	}
+
+	subjectSet := direct
+	subjectSet.SubjectRelation = "member"
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{direct, subjectSet})
+	require.NoError(t, err)
+
+	// Deleting the direct relationship must leave the subject set in place.
+	queryToken, err := e.DeleteRelationships(ctx, direct)
+	require.NoError(t, err)
+
+	rels, err := e.ListRelationshipsFrom(ctx, orgRes, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Relationship{subjectSet}, rels)
+
+	queryToken, err = e.DeleteRelationships(ctx, subjectSet)
+	require.NoError(t, err)
+
+	rels, err = e.ListRelationshipsFrom(ctx, orgRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, rels)
+}
+
+func TestResourceRelationshipsDelete(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromID(parentID)
+	require.NoError(t, err)
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	childID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(childID)
+	require.NoError(t, err)
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{
+		{Resource: tenRes, Relation: "parent", Subject: parentRes},
+		{Resource: childRes, Relation: "parent", Subject: tenRes},
+	})
+	require.NoError(t, err)
+
+	deleted, queryToken, err := e.DeleteResourceRelationships(ctx, tenRes)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	rels, err := e.ListRelationshipsFrom(ctx, tenRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, rels)
+
+	rels, err = e.ListRelationshipsTo(ctx, tenRes, queryToken)
+	require.NoError(t, err)
+	assert.Empty(t, rels)
+
+	deleted, _, err = e.DeleteResourceRelationships(ctx, tenRes)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+var errObserverFailure = errors.New("observer failure")
+
+type testObserver struct {
+	mu      sync.Mutex
+	created []types.Relationship
+	deleted []types.Relationship
+}
+
+func (o *testObserver) OnCreate(_ context.Context, rels []types.Relationship, _ string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.created = append(o.created, rels...)
+
+	return nil
+}
+
+func (o *testObserver) OnDelete(_ context.Context, rels []types.Relationship, _ string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.deleted = append(o.deleted, rels...)
+
+	return errObserverFailure
+}
+
+func TestRelationshipObserver(t *testing.T) {
+	namespace := "testrelationships"
+	ctx := context.Background()
+	observer := &testObserver{}
+	e := testEngine(ctx, t, namespace, WithObserver(observer))
+
+	parentID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromID(parentID)
+	require.NoError(t, err)
+	childID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(childID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+
+	rel := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	_, err = e.CreateRelationships(ctx, []types.Relationship{rel})
+	require.NoError(t, err)
+
+	// Observer errors do not fail the operation.
+	_, err = e.DeleteRelationships(ctx, rel)
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, childRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+	_, err = e.UnassignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	assignment := types.Relationship{
+		Resource: types.Resource{Type: "role", ID: role.ID},
+		Relation: "subject",
+		Subject:  subjRes,
+	}
+
+	assert.Equal(t, []types.Relationship{rel, assignment}, observer.created)
+	assert.Equal(t, []types.Relationship{rel, assignment}, observer.deleted)
+}
+
+func TestSubjectActions(t *testing.T) {
+	namespace := "infratestactions"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	otherID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(otherID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	role, _, err := e.CreateRole(
+		ctx,
+		tenRes,
+		[]string{
+			"loadbalancer_update",
+		},
+	)
+	assert.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	assert.NoError(t, err)
+
+	type testInput struct {
+		resource types.Resource
+		action   string
+	}
+
+	testCases := []testingx.TestCase[testInput, any]{
+		{
+			Name: "BadResource",
+			Input: testInput{
+				resource: otherRes,
+				action:   "loadbalancer_update",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+				assert.ErrorIs(t, res.Err, ErrActionNotAssigned)
+			},
+		},
+		{
+			Name: "BadAction",
+			Input: testInput{
+				resource: tenRes,
+				action:   "loadbalancer_delete",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+				assert.ErrorIs(t, res.Err, ErrActionNotAssigned)
+			},
+		},
+		{
+			Name: "Success",
+			Input: testInput{
+				resource: tenRes,
+				action:   "loadbalancer_update",
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+				assert.NoError(t, res.Err)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, input testInput) testingx.TestResult[any] {
+		err := e.SubjectHasPermission(ctx, subjRes, input.action, input.resource)
+
+		return testingx.TestResult[any]{
+			Err: err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestHasPermission(t *testing.T) {
+	namespace := "infratesthaspermission"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	role, _, err := e.CreateRole(
+		ctx,
+		tenRes,
+		[]string{
+			"loadbalancer_update",
+		},
+	)
+	assert.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	assert.NoError(t, err)
+
+	testCases := []testingx.TestCase[string, bool]{
+		{
+			Name:  "Denied",
+			Input: "loadbalancer_delete",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.False(t, res.Success)
+			},
+		},
+		{
+			Name:  "Allowed",
+			Input: "loadbalancer_update",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.True(t, res.Success)
+			},
+		},
+		{
+			Name:  "UnknownAction",
+			Input: "loadbalancer_explode",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.Error(t, res.Err)
+				assert.False(t, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, action string) testingx.TestResult[bool] {
+		allowed, err := e.HasPermission(ctx, subjRes, action, tenRes)
+
+		return testingx.TestResult[bool]{
+			Success: allowed,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectHasPermissionAt(t *testing.T) {
+	namespace := "infratesthaspermission"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_update"})
+	require.NoError(t, err)
+
+	// The check is made with the assignment's token, so the new assignment is always visible.
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[string, any]{
+		{
+			Name:  "Allowed",
+			Input: "loadbalancer_update",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+				assert.NoError(t, res.Err)
+			},
+		},
+		{
+			Name:  "Denied",
+			Input: "loadbalancer_delete",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+				assert.ErrorIs(t, res.Err, ErrActionNotAssigned)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, action string) testingx.TestResult[any] {
+		err := e.SubjectHasPermissionAt(ctx, subjRes, action, tenRes, queryToken)
+
+		return testingx.TestResult[any]{
+			Err: err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestCheckPermissionWithReason(t *testing.T) {
+	namespace := "infratestpermissionreason"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	otherID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(otherID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_update"})
+	require.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	otherRole, _, err := e.CreateRole(ctx, otherRes, []string{"loadbalancer_delete"})
+	require.NoError(t, err)
+
+	roleRes := types.Resource{Type: "role", ID: role.ID}
+	otherRoleRes := types.Resource{Type: "role", ID: otherRole.ID}
+
+	type input struct {
+		action   string
+		resource types.Resource
+	}
+
+	testCases := []testingx.TestCase[input, PermissionDecision]{
+		{
+			Name:  "Allowed",
+			Input: input{"loadbalancer_update", tenRes},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.True(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonNone, res.Success.Reason)
+				assert.Equal(t, "loadbalancer_update", res.Success.Action)
+				assert.Equal(t, tenRes, res.Success.Resource)
+			},
+		},
+		{
+			Name:  "RoleOnOtherResource",
+			Input: input{"loadbalancer_update", otherRes},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonRoleOnOtherResource, res.Success.Reason)
+				assert.Equal(t, []types.Resource{roleRes}, res.Success.Roles)
+				assert.Contains(t, res.Success.Explanation, tenID.String())
+			},
+		},
+		{
+			Name:  "NotAssigned",
+			Input: input{"loadbalancer_delete", otherRes},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonNotAssigned, res.Success.Reason)
+				assert.Equal(t, []types.Resource{otherRoleRes}, res.Success.Roles)
+			},
+		},
+		{
+			Name:  "NoRole",
+			Input: input{"loadbalancer_get", tenRes},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonNoRole, res.Success.Reason)
+				assert.Equal(t, fmt.Sprintf("subject %s has no role granting loadbalancer_get on tenant %s", subjID, tenID), res.Success.Explanation)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, in input) testingx.TestResult[PermissionDecision] {
+		decision, err := e.CheckPermissionWithReason(ctx, subjRes, in.action, in.resource)
+
+		return testingx.TestResult[PermissionDecision]{
+			Success: decision,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestCompositeActions(t *testing.T) {
+	namespace := "infratestcomposite"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	expanded := []string{"loadbalancer_get", "loadbalancer_update", "loadbalancer_delete"}
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_manage", "loadbalancer_get"})
+	require.NoError(t, err)
+	assert.Equal(t, expanded, role.Actions)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	got, err := e.GetRole(ctx, role.Resource(), queryToken)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expanded, got.Actions)
+
+	for _, action := range expanded {
+		assert.NoError(t, e.SubjectHasPermissionAt(ctx, subjRes, action, tenRes, queryToken), action)
+	}
+
+	err = e.SubjectHasPermissionAt(ctx, subjRes, "loadbalancer_create", tenRes, queryToken)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+
+	// A composite action is not a permission itself, so it cannot be checked.
+	_, err = e.Authorize(ctx, subjRes, "loadbalancer_manage", tenRes)
+	assert.ErrorIs(t, err, ErrInvalidAction)
+}
+
+func TestAuthorize(t *testing.T) {
+	namespace := "infratestauthorize"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_update"})
+	require.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[string, PermissionDecision]{
+		{
+			Name:  "Allowed",
+			Input: "loadbalancer_update",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.True(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonNone, res.Success.Reason)
+			},
+		},
+		{
+			Name:  "Denied",
+			Input: "loadbalancer_delete",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				require.NoError(t, res.Err)
+				assert.False(t, res.Success.Allowed)
+				assert.Equal(t, DenialReasonDenied, res.Success.Reason)
+				assert.Equal(t, fmt.Sprintf("subject %s does not have loadbalancer_delete on tenant %s", subjRes.ID, tenRes.ID), res.Success.Explanation)
+			},
+		},
+		{
+			Name:  "InvalidAction",
+			Input: "tenant_explode",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[PermissionDecision]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, action string) testingx.TestResult[PermissionDecision] {
+		decision, err := e.Authorize(ctx, subjRes, action, tenRes)
+
+		return testingx.TestResult[PermissionDecision]{
+			Success: decision,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectBulkActions(t *testing.T) {
+	namespace := "infratestactions"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(tenID)
+	require.NoError(t, err)
+	otherID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(otherID)
+	require.NoError(t, err)
+	subjID, err := gidx.NewID("idntusr")
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(subjID)
+	require.NoError(t, err)
+	role, _, err := e.CreateRole(
+		ctx,
+		tenRes,
+		[]string{
+			"loadbalancer_update",
+		},
+	)
+	assert.NoError(t, err)
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	assert.NoError(t, err)
+
+	testCases := []testingx.TestCase[[]PermissionCheck, []PermissionResult]{
+		{
+			Name:  "Empty",
+			Input: []PermissionCheck{},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]PermissionResult]) {
 				assert.NoError(t, res.Err)
-				assert.Equal(t, expAssignments, res.Success)
+				assert.Empty(t, res.Success)
 			},
 		},
-	}
+		{
+			Name: "Mixed",
+			Input: []PermissionCheck{
+				{Action: "loadbalancer_update", Resource: tenRes},
+				{Action: "loadbalancer_update", Resource: otherRes},
+				{Action: "loadbalancer_delete", Resource: tenRes},
+			},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]PermissionResult]) {
+				require.NoError(t, res.Err)
+				require.Len(t, res.Success, 3)
 
-	testFn := func(ctx context.Context, role types.Role) testingx.TestResult[[]types.Resource] {
-		queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
-		if err != nil {
-			return testingx.TestResult[[]types.Resource]{
-				Err: err,
-			}
-		}
+				assert.True(t, res.Success[0].Allowed)
+				assert.False(t, res.Success[1].Allowed)
+				assert.False(t, res.Success[2].Allowed)
 
-		resources, err := e.ListAssignments(ctx, role, queryToken)
+				assert.Equal(t, otherRes, res.Success[1].Resource)
+				assert.Equal(t, "loadbalancer_delete", res.Success[2].Action)
+			},
+		},
+	}
 
-		return testingx.TestResult[[]types.Resource]{
-			Success: resources,
+	testFn := func(ctx context.Context, checks []PermissionCheck) testingx.TestResult[[]PermissionResult] {
+		results, err := e.SubjectHasPermissions(ctx, subjRes, checks)
+
+		return testingx.TestResult[[]PermissionResult]{
+			Success: results,
 			Err:     err,
 		}
 	}
@@ -310,8 +3016,8 @@ func TestAssignments(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestUnassignments(t *testing.T) {
-	namespace := "testassignments"
+func TestListSubjectActions(t *testing.T) {
+	namespace := "infratestactions"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
 
@@ -319,6 +3025,10 @@ func TestUnassignments(t *testing.T) {
 	require.NoError(t, err)
 	tenRes, err := e.NewResourceFromID(tenID)
 	require.NoError(t, err)
+	otherID, err := gidx.NewID("tnntten")
+	require.NoError(t, err)
+	otherRes, err := e.NewResourceFromID(otherID)
+	require.NoError(t, err)
 	subjID, err := gidx.NewID("idntusr")
 	require.NoError(t, err)
 	subjRes, err := e.NewResourceFromID(subjID)
@@ -328,59 +3038,449 @@ func TestUnassignments(t *testing.T) {
 		tenRes,
 		[]string{
 			"loadbalancer_update",
+			"loadbalancer_get",
 		},
 	)
+	require.NoError(t, err)
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[types.Resource, []string]{
+		{
+			Name:  "NoActions",
+			Input: otherRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]string]) {
+				assert.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name:  "Success",
+			Input: tenRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]string]) {
+				assert.NoError(t, res.Err)
+				assert.Equal(t, []string{"loadbalancer_get", "loadbalancer_update"}, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, resource types.Resource) testingx.TestResult[[]string] {
+		actions, err := e.ListSubjectActions(ctx, subjRes, resource, queryToken)
+
+		return testingx.TestResult[[]string]{
+			Success: actions,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestSubjectsWithPermission(t *testing.T) {
+	namespace := "infratestactions"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	subjects := make([]types.Resource, 4)
+
+	for i := range subjects {
+		subjects[i], err = e.NewResourceFromID(gidx.MustNewID("idntusr"))
+		require.NoError(t, err)
+	}
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRoles(ctx, []types.Resource{subjects[3], subjects[1]}, role)
+	require.NoError(t, err)
+
+	testCases := []testingx.TestCase[string, []types.Resource]{
+		{
+			Name:  "InvalidAction",
+			Input: "fly",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name:  "NotPermitted",
+			Input: "loadbalancer_update",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Empty(t, res.Success)
+			},
+		},
+		{
+			Name:  "Success",
+			Input: "loadbalancer_get",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Equal(t, []types.Resource{subjects[1], subjects[3]}, res.Success)
+			},
+		},
+	}
+
+	testFn := func(ctx context.Context, action string) testingx.TestResult[[]types.Resource] {
+		permitted, err := e.SubjectsWithPermission(ctx, subjects, action, tenRes, queryToken)
+
+		return testingx.TestResult[[]types.Resource]{
+			Success: permitted,
+			Err:     err,
+		}
+	}
+
+	testingx.RunTests(ctx, t, testCases, testFn)
+}
+
+func TestAssignSubjectRoleUntil(t *testing.T) {
+	namespace := "testassignments"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	activeRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	expiredRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRoleUntil(ctx, activeRes, role, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	queryToken, err := e.AssignSubjectRoleUntil(ctx, expiredRes, role, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	checkCtx := ContextWithQueryToken(ctx, queryToken)
+
+	err = e.SubjectHasPermission(checkCtx, activeRes, "loadbalancer_get", tenRes)
+	assert.NoError(t, err)
+
+	err = e.SubjectHasPermission(checkCtx, expiredRes, "loadbalancer_get", tenRes)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+
+	// A time given by the caller does not revive the expired assignment.
+	err = e.SubjectHasPermissionWithContext(checkCtx, expiredRes, "loadbalancer_get", tenRes, map[string]any{
+		"now": time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+	})
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+
+	decision, err := e.CheckPermissionWithReason(checkCtx, expiredRes, "loadbalancer_get", tenRes)
+	require.NoError(t, err)
+	assert.Equal(t, DenialReasonExpired, decision.Reason)
+	assert.Equal(t, []types.Resource{role.Resource()}, decision.Roles)
+
+	permitted, err := e.SubjectsWithPermission(ctx, []types.Resource{activeRes, expiredRes}, "loadbalancer_get", tenRes, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{activeRes}, permitted)
+
+	// The expiry is listed as the caveat of each assignment.
+	assignments, err := e.ListRelationshipsFrom(ctx, role.Resource(), queryToken, FilterRelation(roleSubjectRelation))
+	require.NoError(t, err)
+	require.Len(t, assignments, 2)
+
+	for _, assignment := range assignments {
+		require.NotNil(t, assignment.Caveat)
+		assert.Equal(t, assignmentExpiryCaveat, assignment.Caveat.Name)
+		assert.Contains(t, assignment.Caveat.Context, "expires_at")
+	}
+
+	_, err = e.AssignSubjectRoleUntil(ctx, types.WildcardResource("user"), role, time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+}
+
+func TestCreateRoleWithID(t *testing.T) {
+	namespace := "testroleid"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	roleID := gidx.MustNewID(RolePrefix)
+
+	_, _, err = e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleID(gidx.MustNewID("tnntten")))
+	assert.ErrorIs(t, err, ErrInvalidID)
+
+	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleID(roleID), WithRoleName("retried"))
+	require.NoError(t, err)
+	assert.Equal(t, roleID, role.ID)
+	assert.NotEmpty(t, queryToken)
+
+	// A retry returns the role created by the first request without writing anything.
+	retried, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"}, WithRoleID(roleID))
+	require.NoError(t, err)
+	assert.Empty(t, queryToken)
+	assert.Equal(t, roleID, retried.ID)
+	assert.Equal(t, []string{"loadbalancer_get"}, retried.Actions)
+	assert.Equal(t, "retried", retried.Name)
+	assert.Equal(t, tenRes, retried.Owner)
+
+	// A different role with the same ID conflicts with the existing role.
+	_, _, err = e.CreateRole(ctx, tenRes, []string{"loadbalancer_get", "loadbalancer_update"}, WithRoleID(roleID))
+	assert.ErrorIs(t, err, ErrRoleConflict)
+
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	_, _, err = e.CreateRole(ctx, otherRes, []string{"loadbalancer_get"}, WithRoleID(roleID))
+	assert.ErrorIs(t, err, ErrRoleConflict)
+
+	roles, err := e.ListRoles(ctx, tenRes, "")
+	require.NoError(t, err)
+	assert.Len(t, roles, 1)
+}
+
+func TestCreateRoleOwner(t *testing.T) {
+	namespace := "testroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	lbRes, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+
+	role, queryToken, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	owned, err := e.HasRelationship(ctx, types.Relationship{
+		Resource: role.Resource(),
+		Relation: iapl.RoleOwnerRelation,
+		Subject:  tenRes,
+	}, queryToken)
+	require.NoError(t, err)
+	assert.True(t, owned)
+
+	// Load balancers have role bound actions but are not role owners in the policy.
+	_, _, err = e.CreateRole(ctx, lbRes, []string{"loadbalancer_get"})
+	assert.ErrorIs(t, err, ErrInvalidRoleOwner)
+
+	_, _, err = e.CreateRoles(ctx, lbRes, []RoleSpec{{Actions: []string{"loadbalancer_get"}}})
+	assert.ErrorIs(t, err, ErrInvalidRoleOwner)
+
+	queryToken, err = e.DeleteRole(ctx, role.Resource(), queryToken)
+	require.NoError(t, err)
+
+	owned, err = e.HasRelationship(ctx, types.Relationship{
+		Resource: role.Resource(),
+		Relation: iapl.RoleOwnerRelation,
+		Subject:  tenRes,
+	}, queryToken)
+	require.NoError(t, err)
+	assert.False(t, owned)
+}
+
+func TestExcludedRelations(t *testing.T) {
+	namespace := "testexclusion"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("chldten"))
+	require.NoError(t, err)
+	allowedRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+	bannedRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRoles(ctx, []types.Resource{allowedRes, bannedRes}, role)
+	require.NoError(t, err)
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: childRes,
+			Relation: "parent",
+			Subject:  tenRes,
+		},
+		{
+			Resource: childRes,
+			Relation: "banned",
+			Subject:  bannedRes,
+		},
+	})
+	require.NoError(t, err)
+
+	checkCtx := ContextWithQueryToken(ctx, queryToken)
+
+	err = e.SubjectHasPermission(checkCtx, allowedRes, "loadbalancer_get", childRes)
+	assert.NoError(t, err)
+
+	// The banned subject keeps the action on the tenant, but not on the child.
+	err = e.SubjectHasPermission(checkCtx, bannedRes, "loadbalancer_get", tenRes)
+	assert.NoError(t, err)
+
+	err = e.SubjectHasPermission(checkCtx, bannedRes, "loadbalancer_get", childRes)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+}
+
+func TestListEffectiveRoles(t *testing.T) {
+	namespace := "testeffectiveroles"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	rootRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	lbRes, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+
+	rootRole, _, err := e.CreateRole(ctx, rootRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+	tenRole, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_update"})
+	require.NoError(t, err)
+
+	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{
+		{
+			Resource: tenRes,
+			Relation: "parent",
+			Subject:  rootRes,
+		},
+		{
+			Resource: lbRes,
+			Relation: "owner",
+			Subject:  tenRes,
+		},
+	})
+	require.NoError(t, err)
+
+	roles, err := e.ListEffectiveRoles(ctx, lbRes, queryToken)
+	require.NoError(t, err)
+
+	require.Len(t, roles, 2)
+	assert.Equal(t, tenRole.ID, roles[0].ID)
+	assert.Equal(t, tenRes, roles[0].Owner)
+	assert.Equal(t, rootRole.ID, roles[1].ID)
+	assert.Equal(t, rootRes, roles[1].Owner)
+
+	roles, err = e.ListEffectiveRoles(ctx, rootRes, queryToken)
+	require.NoError(t, err)
+
+	require.Len(t, roles, 1)
+	assert.Equal(t, rootRole.ID, roles[0].ID)
+}
+
+func TestMoveRole(t *testing.T) {
+	namespace := "testmoverole"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	fromRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	toRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	lbRes, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	role, _, err := e.CreateRole(ctx, fromRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
+
+	_, err = e.MoveRole(ctx, role, lbRes)
+	assert.ErrorIs(t, err, ErrInvalidRoleOwner)
+
+	queryToken, err := e.MoveRole(ctx, role, toRes)
+	require.NoError(t, err)
+	assert.NotEmpty(t, queryToken)
+
+	owner, err := e.GetRoleResource(ctx, role.Resource(), queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, toRes, owner)
+
+	owned, err := e.HasRelationship(ctx, types.Relationship{
+		Resource: role.Resource(),
+		Relation: iapl.RoleOwnerRelation,
+		Subject:  fromRes,
+	}, queryToken)
+	require.NoError(t, err)
+	assert.False(t, owned)
+
+	assignments, err := e.ListAssignments(ctx, role, queryToken)
+	require.NoError(t, err)
+	assert.Equal(t, []types.Resource{subjRes}, assignments)
+
+	checkCtx := ContextWithQueryToken(ctx, queryToken)
+
+	err = e.SubjectHasPermission(checkCtx, subjRes, "loadbalancer_get", toRes)
 	assert.NoError(t, err)
 
-	testCases := []testingx.TestCase[types.Role, []types.Resource]{
+	err = e.SubjectHasPermission(checkCtx, subjRes, "loadbalancer_get", fromRes)
+	assert.ErrorIs(t, err, ErrActionNotAssigned)
+
+	_, err = e.MoveRole(ctx, types.Role{ID: gidx.MustNewID(RolePrefix)}, toRes)
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+func TestFilterResourcesByPermission(t *testing.T) {
+	namespace := "infratestfilter"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	resources := make([]types.Resource, 4)
+
+	for i := range resources {
+		resources[i], err = e.NewResourceFromID(gidx.MustNewID("tnntten"))
+		require.NoError(t, err)
+	}
+
+	var queryToken string
+
+	for _, res := range []types.Resource{resources[3], resources[0]} {
+		role, _, err := e.CreateRole(ctx, res, []string{"loadbalancer_get"})
+		require.NoError(t, err)
+
+		queryToken, err = e.AssignSubjectRole(ctx, subjRes, role)
+		require.NoError(t, err)
+	}
+
+	testCases := []testingx.TestCase[string, []types.Resource]{
 		{
-			Name:  "Success",
-			Input: role,
+			Name:  "InvalidAction",
+			Input: "fly",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
+			},
+		},
+		{
+			Name:  "NotPermitted",
+			Input: "loadbalancer_update",
 			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
 				assert.NoError(t, res.Err)
 				assert.Empty(t, res.Success)
 			},
 		},
+		{
+			Name:  "Success",
+			Input: "loadbalancer_get",
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Resource]) {
+				assert.NoError(t, res.Err)
+				assert.Equal(t, []types.Resource{resources[0], resources[3]}, res.Success)
+			},
+		},
 	}
 
-	testFn := func(ctx context.Context, role types.Role) testingx.TestResult[[]types.Resource] {
-		queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
-		if err != nil {
-			return testingx.TestResult[[]types.Resource]{
-				Err: err,
-			}
-		}
-
-		resources, err := e.ListAssignments(ctx, role, queryToken)
-		if err != nil {
-			return testingx.TestResult[[]types.Resource]{
-				Err: err,
-			}
-		}
-
-		var found bool
-
-		for _, resource := range resources {
-			if resource.ID == subjRes.ID {
-				found = true
-
-				break
-			}
-		}
-
-		require.True(t, found, "expected assignment to be found")
-
-		queryToken, err = e.UnassignSubjectRole(ctx, subjRes, role)
-		if err != nil {
-			return testingx.TestResult[[]types.Resource]{
-				Err: err,
-			}
-		}
-
-		resources, err = e.ListAssignments(ctx, role, queryToken)
+	testFn := func(ctx context.Context, action string) testingx.TestResult[[]types.Resource] {
+		permitted, err := e.FilterResourcesByPermission(ctx, subjRes, action, resources, queryToken)
 
 		return testingx.TestResult[[]types.Resource]{
-			Success: resources,
+			Success: permitted,
 			Err:     err,
 		}
 	}
@@ -388,90 +3488,62 @@ func TestUnassignments(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestRelationships(t *testing.T) {
-	namespace := "testrelationships"
+func TestSubjectHasAnyPermission(t *testing.T) {
+	namespace := "infratestanyperm"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
 
-	parentID, err := gidx.NewID("tnntten")
-	require.NoError(t, err)
-	parentRes, err := e.NewResourceFromID(parentID)
-	require.NoError(t, err)
-	childID, err := gidx.NewID("tnntten")
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
 	require.NoError(t, err)
-	childRes, err := e.NewResourceFromID(childID)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
 	require.NoError(t, err)
-	child2ID, err := gidx.NewID("chldten")
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
 	require.NoError(t, err)
-	child2Res, err := e.NewResourceFromID(child2ID)
+
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
 	require.NoError(t, err)
 
-	testCases := []testingx.TestCase[types.Relationship, []types.Relationship]{
+	testCases := []testingx.TestCase[[]string, bool]{
 		{
-			Name: "InvalidRelationship",
-			Input: types.Relationship{
-				Resource: childRes,
-				Relation: "foo",
-				Subject:  parentRes,
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
-				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+			Name:  "InvalidAction",
+			Input: []string{"loadbalancer_get", "fly"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
 			},
 		},
 		{
-			Name: "Success",
-			Input: types.Relationship{
-				Resource: childRes,
-				Relation: "parent",
-				Subject:  parentRes,
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
-				expRels := []types.Relationship{
-					{
-						Resource: childRes,
-						Relation: "parent",
-						Subject:  parentRes,
-					},
-				}
-
-				require.NoError(t, res.Err)
-				assert.Equal(t, expRels, res.Success)
+			Name:  "NoActions",
+			Input: nil,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.False(t, res.Success)
 			},
 		},
 		{
-			Name: "Different Success",
-			Input: types.Relationship{
-				Resource: child2Res,
-				Relation: "parent",
-				Subject:  parentRes,
+			Name:  "AllDenied",
+			Input: []string{"loadbalancer_update", "loadbalancer_delete"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.False(t, res.Success)
 			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
-				expRels := []types.Relationship{
-					{
-						Resource: child2Res,
-						Relation: "parent",
-						Subject:  parentRes,
-					},
-				}
-
-				require.NoError(t, res.Err)
-				assert.Equal(t, expRels, res.Success)
+		},
+		{
+			Name:  "AnyPermitted",
+			Input: []string{"loadbalancer_update", "loadbalancer_get"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.True(t, res.Success)
 			},
 		},
 	}
 
-	testFn := func(ctx context.Context, input types.Relationship) testingx.TestResult[[]types.Relationship] {
-		queryToken, err := e.CreateRelationships(ctx, []types.Relationship{input})
-		if err != nil {
-			return testingx.TestResult[[]types.Relationship]{
-				Err: err,
-			}
-		}
-
-		rels, err := e.ListRelationshipsFrom(ctx, input.Resource, queryToken)
+	testFn := func(ctx context.Context, actions []string) testingx.TestResult[bool] {
+		allowed, err := e.SubjectHasAnyPermission(ctx, subjRes, tenRes, actions, queryToken)
 
-		return testingx.TestResult[[]types.Relationship]{
-			Success: rels,
+		return testingx.TestResult[bool]{
+			Success: allowed,
 			Err:     err,
 		}
 	}
@@ -479,71 +3551,57 @@ func TestRelationships(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestRelationshipDelete(t *testing.T) {
-	namespace := "testrelationships"
+func TestSubjectHasAnyAccess(t *testing.T) {
+	namespace := "infratestanyaccess"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
 
-	parentID, err := gidx.NewID("tnntten")
-	require.NoError(t, err)
-	parentRes, err := e.NewResourceFromID(parentID)
-	require.NoError(t, err)
-	childID, err := gidx.NewID("tnntten")
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
 	require.NoError(t, err)
-	childRes, err := e.NewResourceFromID(childID)
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
 	require.NoError(t, err)
 
-	relReq := types.Relationship{
-		Resource: childRes,
-		Relation: "parent",
-		Subject:  parentRes,
-	}
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
 
-	queryToken, err := e.CreateRelationships(ctx, []types.Relationship{relReq})
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get"})
 	require.NoError(t, err)
 
-	createdResources, err := e.ListRelationshipsFrom(ctx, childRes, queryToken)
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
 	require.NoError(t, err)
-	require.NotEmpty(t, createdResources)
 
-	testCases := []testingx.TestCase[types.Relationship, []types.Relationship]{
+	testCases := []testingx.TestCase[types.Resource, bool]{
 		{
-			Name: "InvalidRelationship",
-			Input: types.Relationship{
-				Resource: childRes,
-				Relation: "foo",
-				Subject:  parentRes,
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
-				assert.ErrorIs(t, res.Err, ErrInvalidRelationship)
+			Name:  "InvalidType",
+			Input: types.Resource{Type: "fly", ID: tenRes.ID},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidType)
 			},
 		},
 		{
-			Name: "Success",
-			Input: types.Relationship{
-				Resource: childRes,
-				Relation: "parent",
-				Subject:  parentRes,
+			Name:  "NoAccess",
+			Input: otherRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.False(t, res.Success)
 			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[[]types.Relationship]) {
-				require.NoError(t, res.Err)
-				assert.Empty(t, res.Success)
+		},
+		{
+			Name:  "SomeAccess",
+			Input: tenRes,
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.NoError(t, res.Err)
+				assert.True(t, res.Success)
 			},
 		},
 	}
 
-	testFn := func(ctx context.Context, input types.Relationship) testingx.TestResult[[]types.Relationship] {
-		queryToken, err := e.DeleteRelationships(ctx, input)
-		if err != nil {
-			return testingx.TestResult[[]types.Relationship]{
-				Err: err,
-			}
-		}
-
-		rels, err := e.ListRelationshipsFrom(ctx, input.Resource, queryToken)
+	testFn := func(ctx context.Context, resource types.Resource) testingx.TestResult[bool] {
+		allowed, err := e.SubjectHasAnyAccess(ctx, subjRes, resource, queryToken)
 
-		return testingx.TestResult[[]types.Relationship]{
-			Success: rels,
+		return testingx.TestResult[bool]{
+			Success: allowed,
 			Err:     err,
 		}
 	}
@@ -551,79 +3609,113 @@ func TestRelationshipDelete(t *testing.T) {
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
 
-func TestSubjectActions(t *testing.T) {
-	namespace := "infratestactions"
+func TestSubjectHasAllPermissions(t *testing.T) {
+	namespace := "infratestallperm"
 	ctx := context.Background()
 	e := testEngine(ctx, t, namespace)
 
-	tenID, err := gidx.NewID("tnntten")
-	require.NoError(t, err)
-	tenRes, err := e.NewResourceFromID(tenID)
-	require.NoError(t, err)
-	otherID, err := gidx.NewID("tnntten")
-	require.NoError(t, err)
-	otherRes, err := e.NewResourceFromID(otherID)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
 	require.NoError(t, err)
-	subjID, err := gidx.NewID("idntusr")
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
 	require.NoError(t, err)
-	subjRes, err := e.NewResourceFromID(subjID)
+
+	role, _, err := e.CreateRole(ctx, tenRes, []string{"loadbalancer_get", "loadbalancer_update"})
 	require.NoError(t, err)
-	role, _, err := e.CreateRole(
-		ctx,
-		tenRes,
-		[]string{
-			"loadbalancer_update",
-		},
-	)
-	assert.NoError(t, err)
-	_, err = e.AssignSubjectRole(ctx, subjRes, role)
-	assert.NoError(t, err)
 
-	type testInput struct {
-		resource types.Resource
-		action   string
-	}
+	queryToken, err := e.AssignSubjectRole(ctx, subjRes, role)
+	require.NoError(t, err)
 
-	testCases := []testingx.TestCase[testInput, any]{
+	testCases := []testingx.TestCase[[]string, bool]{
 		{
-			Name: "BadResource",
-			Input: testInput{
-				resource: otherRes,
-				action:   "loadbalancer_update",
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
-				assert.ErrorIs(t, res.Err, ErrActionNotAssigned)
+			Name:  "InvalidAction",
+			Input: []string{"loadbalancer_get", "fly"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
+				assert.ErrorIs(t, res.Err, ErrInvalidAction)
 			},
 		},
 		{
-			Name: "BadAction",
-			Input: testInput{
-				resource: tenRes,
-				action:   "loadbalancer_delete",
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+			Name:  "OneDenied",
+			Input: []string{"loadbalancer_get", "loadbalancer_delete", "loadbalancer_update"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
 				assert.ErrorIs(t, res.Err, ErrActionNotAssigned)
+				assert.ErrorContains(t, res.Err, "loadbalancer_delete")
+				assert.False(t, res.Success)
 			},
 		},
 		{
-			Name: "Success",
-			Input: testInput{
-				resource: tenRes,
-				action:   "loadbalancer_update",
-			},
-			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[any]) {
+			Name:  "AllPermitted",
+			Input: []string{"loadbalancer_get", "loadbalancer_update"},
+			CheckFn: func(ctx context.Context, t *testing.T, res testingx.TestResult[bool]) {
 				assert.NoError(t, res.Err)
+				assert.True(t, res.Success)
 			},
 		},
 	}
 
-	testFn := func(ctx context.Context, input testInput) testingx.TestResult[any] {
-		err := e.SubjectHasPermission(ctx, subjRes, input.action, input.resource)
+	testFn := func(ctx context.Context, actions []string) testingx.TestResult[bool] {
+		allowed, err := e.SubjectHasAllPermissions(ctx, subjRes, tenRes, actions, queryToken)
 
-		return testingx.TestResult[any]{
-			Err: err,
+		return testingx.TestResult[bool]{
+			Success: allowed,
+			Err:     err,
 		}
 	}
 
 	testingx.RunTests(ctx, t, testCases, testFn)
 }
+
+func TestBootstrapTenant(t *testing.T) {
+	namespace := "testbootstrap"
+	ctx := context.Background()
+
+	policyDocument := testPolicyDocument()
+	policyDocument.DefaultRoles = []iapl.DefaultRole{
+		{
+			Name:    "viewer",
+			Actions: []string{"loadbalancer_get"},
+		},
+		{
+			Name:        "editor",
+			Description: "updates load balancers",
+			Actions:     []string{"loadbalancer_get", "loadbalancer_update"},
+		},
+	}
+
+	policy := iapl.NewPolicy(policyDocument)
+	require.NoError(t, policy.Validate())
+
+	e := testEngine(ctx, t, namespace, WithPolicy(policy))
+
+	tenRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	lbRes, err := e.NewResourceFromID(gidx.MustNewID("loadbal"))
+	require.NoError(t, err)
+
+	roles, queryToken, err := e.BootstrapTenant(ctx, tenRes)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+
+	assert.Equal(t, "viewer", roles[0].Name)
+	assert.Equal(t, []string{"loadbalancer_get"}, roles[0].Actions)
+	assert.Equal(t, "editor", roles[1].Name)
+	assert.Equal(t, "updates load balancers", roles[1].Description)
+
+	for _, role := range roles {
+		assert.Equal(t, tenRes, role.Owner)
+
+		stored, err := e.GetRole(ctx, role.Resource(), queryToken)
+		require.NoError(t, err)
+		assert.Equal(t, role.Name, stored.Name)
+		assert.ElementsMatch(t, role.Actions, stored.Actions)
+	}
+
+	// Load balancers cannot own roles, so none are created.
+	_, _, err = e.BootstrapTenant(ctx, lbRes)
+	assert.ErrorIs(t, err, ErrInvalidRoleOwner)
+
+	// The test policy has no default roles.
+	roles, _, err = testEngine(ctx, t, namespace).BootstrapTenant(ctx, tenRes)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+}
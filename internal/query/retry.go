@@ -0,0 +1,185 @@
+package query
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 50 * time.Millisecond
+	defaultRetryMaxBackoff     = time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.2
+)
+
+// RetryPolicy configures retries of SpiceDB requests which failed with a transient error.
+// Zero values are replaced with conservative defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 50ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 1s.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after each retry. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction of each delay which is randomized, between 0 and 1. Defaults to 0.2.
+	Jitter float64
+	// Codes are the gRPC status codes which are retried. Defaults to Unavailable and ResourceExhausted.
+	Codes []codes.Code
+}
+
+// DefaultRetryPolicy returns the retry policy used when WithRetry is given a zero RetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{}.withDefaults()
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryMaxAttempts
+	}
+
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+
+	if p.Multiplier < 1 {
+		p.Multiplier = defaultRetryMultiplier
+	}
+
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = defaultRetryJitter
+	}
+
+	if len(p.Codes) == 0 {
+		p.Codes = []codes.Code{codes.Unavailable, codes.ResourceExhausted}
+	}
+
+	return p
+}
+
+// retryable returns true if the error has one of the policy's status codes.
+func (p RetryPolicy) retryable(err error) bool {
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	for _, code := range p.Codes {
+		if st.Code() == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the jittered delay before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+
+	for i := 1; i < retry && delay < float64(p.MaxBackoff); i++ {
+		delay *= p.Multiplier
+	}
+
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	// Spread the delay over [delay*(1-jitter), delay] so concurrent callers don't retry in lockstep.
+	delay -= delay * p.Jitter * rand.Float64() //nolint:gosec // jitter does not need a secure source
+
+	return time.Duration(delay)
+}
+
+// WithRetry enables retrying SpiceDB requests which fail with a transient error, as configured by the policy.
+// Reads are always retried, writes are only retried when repeating them is known to be safe.
+func WithRetry(policy RetryPolicy) Option {
+	return func(e *engine) {
+		policy = policy.withDefaults()
+
+		e.retryPolicy = &policy
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error which is not retryable or the policy's
+// attempts are exhausted. Requests which are not idempotent are only attempted once.
+// A request which ultimately fails is logged.
+func (e *engine) retry(ctx context.Context, idempotent bool, fn func() error) error {
+	err := e.attempt(ctx, idempotent, fn)
+	if err != nil {
+		e.logSpiceDBError(ctx, err)
+	}
+
+	return err
+}
+
+func (e *engine) attempt(ctx context.Context, idempotent bool, fn func() error) error {
+	err := fn()
+
+	if e.retryPolicy == nil || !idempotent {
+		return err
+	}
+
+	for attempt := 1; err != nil && attempt < e.retryPolicy.MaxAttempts && e.retryPolicy.retryable(err); attempt++ {
+		e.logger.Debugw("retrying spicedb request", "attempt", attempt, "error", err)
+
+		if sleepErr := sleepContext(ctx, e.retryPolicy.backoff(attempt)); sleepErr != nil {
+			return err
+		}
+
+		err = fn()
+	}
+
+	return err
+}
+
+// writeIsIdempotent returns true if repeating the write has the same effect as writing it once.
+// Touches and deletes are idempotent, creates fail if the relationship already exists and
+// preconditions may no longer hold after the first write.
+func writeIsIdempotent(req *pb.WriteRelationshipsRequest) bool {
+	if len(req.OptionalPreconditions) != 0 {
+		return false
+	}
+
+	for _, update := range req.Updates {
+		if update.Operation == pb.RelationshipUpdate_OPERATION_CREATE {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeRelationships writes the relationships, retrying if the write is idempotent.
+func (e *engine) writeRelationships(ctx context.Context, req *pb.WriteRelationshipsRequest) (*pb.WriteRelationshipsResponse, error) {
+	if err := e.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	var resp *pb.WriteRelationshipsResponse
+
+	err := e.retry(ctx, writeIsIdempotent(req), func() (err error) {
+		resp, err = e.client.WriteRelationships(ctx, req)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.logWrite(req, resp.WrittenAt.GetToken())
+	e.recordWrite(resp.WrittenAt.GetToken())
+
+	return resp, nil
+}
@@ -0,0 +1,155 @@
+package query
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	type testCase struct {
+		name        string
+		options     []Option
+		idempotent  bool
+		failures    int
+		code        codes.Code
+		expAttempts int
+		expErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			name:        "Disabled",
+			idempotent:  true,
+			failures:    1,
+			code:        codes.Unavailable,
+			expAttempts: 1,
+			expErr:      true,
+		},
+		{
+			name:        "RetrySuccess",
+			options:     []Option{WithRetry(policy)},
+			idempotent:  true,
+			failures:    2,
+			code:        codes.Unavailable,
+			expAttempts: 3,
+		},
+		{
+			name:        "RetryExhausted",
+			options:     []Option{WithRetry(policy)},
+			idempotent:  true,
+			failures:    5,
+			code:        codes.ResourceExhausted,
+			expAttempts: 3,
+			expErr:      true,
+		},
+		{
+			name:        "NotRetryable",
+			options:     []Option{WithRetry(policy)},
+			idempotent:  true,
+			failures:    1,
+			code:        codes.InvalidArgument,
+			expAttempts: 1,
+			expErr:      true,
+		},
+		{
+			name:        "NotIdempotent",
+			options:     []Option{WithRetry(policy)},
+			failures:    1,
+			code:        codes.Unavailable,
+			expAttempts: 1,
+			expErr:      true,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := &engine{
+				logger: zap.NewNop().Sugar(),
+			}
+
+			for _, opt := range tc.options {
+				opt(e)
+			}
+
+			attempts := 0
+
+			err := e.retry(context.Background(), tc.idempotent, func() error {
+				attempts++
+
+				if attempts <= tc.failures {
+					return status.Error(tc.code, "failed")
+				}
+
+				return nil
+			})
+
+			assert.Equal(t, tc.expAttempts, attempts)
+
+			if tc.expErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Jitter:         0.5,
+	}.withDefaults()
+
+	for retry, limit := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 300 * time.Millisecond,
+		8: 300 * time.Millisecond,
+	} {
+		delay := policy.backoff(retry)
+
+		assert.LessOrEqual(t, delay, limit)
+		assert.GreaterOrEqual(t, delay, limit/2)
+	}
+}
+
+func TestWriteIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	touch := &pb.RelationshipUpdate{Operation: pb.RelationshipUpdate_OPERATION_TOUCH}
+	del := &pb.RelationshipUpdate{Operation: pb.RelationshipUpdate_OPERATION_DELETE}
+	create := &pb.RelationshipUpdate{Operation: pb.RelationshipUpdate_OPERATION_CREATE}
+
+	assert.True(t, writeIsIdempotent(&pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{touch, del},
+	}))
+
+	assert.False(t, writeIsIdempotent(&pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{touch, create},
+	}))
+
+	assert.False(t, writeIsIdempotent(&pb.WriteRelationshipsRequest{
+		Updates:               []*pb.RelationshipUpdate{touch},
+		OptionalPreconditions: []*pb.Precondition{{}},
+	}))
+}
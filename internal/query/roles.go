@@ -1,6 +1,10 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
 	"go.infratographer.com/permissions-api/internal/types"
 	"go.infratographer.com/x/gidx"
 )
@@ -10,11 +14,136 @@ const (
 	ApplicationPrefix string = "perm"
 	// RolePrefix is the prefix for roles
 	RolePrefix string = ApplicationPrefix + "rol"
+
+	maxRoleNameLength        = 64
+	maxRoleDescriptionLength = 256
 )
 
-func newRole(actions []string) types.Role {
-	return types.Role{
-		ID:      gidx.MustNewID(RolePrefix),
+// RoleOption is a functional option for creating roles.
+type RoleOption func(role *types.Role) error
+
+// WithRoleName sets the name of the role.
+// The name must not be empty and may be at most 64 characters long.
+func WithRoleName(name string) RoleOption {
+	return func(role *types.Role) error {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			return fmt.Errorf("%w: name must not be empty", ErrInvalidRoleName)
+		}
+
+		if utf8.RuneCountInString(name) > maxRoleNameLength {
+			return fmt.Errorf("%w: name must be at most %d characters", ErrInvalidRoleName, maxRoleNameLength)
+		}
+
+		role.Name = name
+
+		return nil
+	}
+}
+
+// WithRoleDescription sets the description of the role.
+// The description may be at most 256 characters long.
+func WithRoleDescription(description string) RoleOption {
+	return func(role *types.Role) error {
+		if utf8.RuneCountInString(description) > maxRoleDescriptionLength {
+			return fmt.Errorf("%w: description must be at most %d characters", ErrInvalidRoleDescription, maxRoleDescriptionLength)
+		}
+
+		role.Description = description
+
+		return nil
+	}
+}
+
+// WithRoleID sets the ID of the role rather than generating one, so a role may be created with an ID
+// chosen ahead of time, such as one derived from an idempotency key. CreateRole returns the existing
+// role when a role with the ID already exists with the same owner and actions, so a retried CreateRole
+// creates the role only once; if the existing role's owner or actions differ, ErrRoleConflict is
+// returned. The ID must have the role prefix.
+func WithRoleID(id gidx.PrefixedID) RoleOption {
+	return func(role *types.Role) error {
+		if id.Prefix() != RolePrefix {
+			return fmt.Errorf("%w: %s is not a role id", ErrInvalidID, id)
+		}
+
+		for _, parentID := range role.Parents {
+			if parentID == id {
+				return fmt.Errorf("%w: role cannot be its own parent", ErrInvalidRoleParent)
+			}
+		}
+
+		role.ID = id
+
+		return nil
+	}
+}
+
+// WithRoleParents sets the parent roles of the role. Subjects assigned the role are granted the
+// actions of each of the parent roles as well.
+func WithRoleParents(parentIDs ...gidx.PrefixedID) RoleOption {
+	return func(role *types.Role) error {
+		for _, parentID := range parentIDs {
+			if parentID.Prefix() != RolePrefix {
+				return fmt.Errorf("%w: %s is not a role", ErrInvalidRoleParent, parentID)
+			}
+
+			if parentID == role.ID {
+				return fmt.Errorf("%w: role cannot be its own parent", ErrInvalidRoleParent)
+			}
+		}
+
+		role.Parents = append(role.Parents, parentIDs...)
+
+		return nil
+	}
+}
+
+// RoleSpec describes a role to be created with CreateRoles.
+type RoleSpec struct {
+	Actions     []string
+	Name        string
+	Description string
+	Parents     []gidx.PrefixedID
+}
+
+// options returns the role options for the spec's optional fields.
+func (s RoleSpec) options() []RoleOption {
+	var opts []RoleOption
+
+	if s.Name != "" {
+		opts = append(opts, WithRoleName(s.Name))
+	}
+
+	if s.Description != "" {
+		opts = append(opts, WithRoleDescription(s.Description))
+	}
+
+	if len(s.Parents) != 0 {
+		opts = append(opts, WithRoleParents(s.Parents...))
+	}
+
+	return opts
+}
+
+// newRole returns a role with the given actions and options. Unless an ID is given with WithRoleID,
+// a new ID is generated, and idGiven is false.
+func newRole(actions []string, options ...RoleOption) (role types.Role, idGiven bool, err error) {
+	role = types.Role{
 		Actions: actions,
 	}
+
+	for _, opt := range options {
+		if err := opt(&role); err != nil {
+			return types.Role{}, false, err
+		}
+	}
+
+	if role.ID != "" {
+		return role, true, nil
+	}
+
+	role.ID = gidx.MustNewID(RolePrefix)
+
+	return role, false, nil
 }
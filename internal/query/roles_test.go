@@ -0,0 +1,61 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestRoleOptionLengths(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name   string
+		option RoleOption
+		expErr error
+	}
+
+	testCases := []testCase{
+		{
+			name:   "NameMultibyteAtLimit",
+			option: WithRoleName(strings.Repeat("é", maxRoleNameLength)),
+		},
+		{
+			name:   "NameOverLimit",
+			option: WithRoleName(strings.Repeat("é", maxRoleNameLength+1)),
+			expErr: ErrInvalidRoleName,
+		},
+		{
+			name:   "DescriptionMultibyteAtLimit",
+			option: WithRoleDescription(strings.Repeat("日", maxRoleDescriptionLength)),
+		},
+		{
+			name:   "DescriptionOverLimit",
+			option: WithRoleDescription(strings.Repeat("日", maxRoleDescriptionLength+1)),
+			expErr: ErrInvalidRoleDescription,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var role types.Role
+
+			err := tc.option(&role)
+
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -2,8 +2,15 @@ package query
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
 
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/v1"
+	"go.infratographer.com/x/events"
 	"go.infratographer.com/x/gidx"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
@@ -21,21 +28,79 @@ const (
 // Engine represents a client for making permissions queries.
 type Engine interface {
 	AssignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (string, error)
+	AssignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (string, error)
+	AssignSubjectRolesWithReport(ctx context.Context, subjects []types.Resource, role types.Role, opts ...AssignOption) (AssignmentReport, error)
+	AssignSubjectRoleUntil(ctx context.Context, subject types.Resource, role types.Role, expiresAt time.Time) (string, error)
+	UnassignSubjectRoles(ctx context.Context, subjects []types.Resource, role types.Role) (string, error)
+	SubjectsWithPermission(ctx context.Context, subjects []types.Resource, action string, resource types.Resource, queryToken string) ([]types.Resource, error)
+	FilterResourcesByPermission(ctx context.Context, subject types.Resource, action string, resources []types.Resource, queryToken string) ([]types.Resource, error)
+	SubjectHasAnyPermission(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (bool, error)
+	SubjectHasAnyAccess(ctx context.Context, subject types.Resource, resource types.Resource, queryToken string) (bool, error)
+	SubjectHasAllPermissions(ctx context.Context, subject types.Resource, resource types.Resource, actions []string, queryToken string) (bool, error)
 	UnassignSubjectRole(ctx context.Context, subject types.Resource, role types.Role) (string, error)
 	CreateRelationships(ctx context.Context, rels []types.Relationship) (string, error)
-	CreateRole(ctx context.Context, res types.Resource, actions []string) (types.Role, string, error)
+	CreateRelationshipsStrict(ctx context.Context, rels []types.Relationship) (string, error)
+	UpsertRelationships(ctx context.Context, rels []types.Relationship) (string, error)
+	ImportRelationships(ctx context.Context, rels <-chan types.Relationship, opts ...ImportOption) (ImportReport, error)
+	ExportRelationships(ctx context.Context, queryToken string) (<-chan types.Relationship, *ExportReport, error)
+	Begin() Tx
+	CreateRole(ctx context.Context, res types.Resource, actions []string, opts ...RoleOption) (types.Role, string, error)
+	RoleTuples(owner types.Resource, actions []string) ([]types.Relationship, error)
+	CreateRoles(ctx context.Context, owner types.Resource, roleSpecs []RoleSpec) ([]types.Role, string, error)
+	BootstrapTenant(ctx context.Context, tenant types.Resource) ([]types.Role, string, error)
 	GetRole(ctx context.Context, roleResource types.Resource, queryToken string) (types.Role, error)
 	GetRoleResource(ctx context.Context, roleResource types.Resource, queryToken string) (types.Resource, error)
+	GetRoleWithAssignments(ctx context.Context, roleResource types.Resource, queryToken string) (RoleDetail, error)
+	ExpandRole(ctx context.Context, roleResource types.Resource, queryToken string) (*PermissionTree, error)
+	ResolvePermissionPath(ctx context.Context, subject types.Resource, action string, resource types.Resource) (*Path, error)
 	ListAssignments(ctx context.Context, role types.Role, queryToken string) ([]types.Resource, error)
-	ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string) ([]types.Relationship, error)
+	CountAssignments(ctx context.Context, role types.Role, queryToken string) (int, error)
+	ListRelationshipsFrom(ctx context.Context, resource types.Resource, queryToken string, opts ...RelationshipFilterOption) ([]types.Relationship, error)
+	HasRelationship(ctx context.Context, rel types.Relationship, queryToken string) (bool, error)
+	ListRelationshipsFromPage(ctx context.Context, resource types.Resource, queryToken string, page PageOpts, opts ...RelationshipFilterOption) ([]types.Relationship, string, error)
 	ListRelationshipsTo(ctx context.Context, resource types.Resource, queryToken string) ([]types.Relationship, error)
-	ListRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error)
+	ListAncestors(ctx context.Context, resource types.Resource, queryToken string) ([]types.Resource, error)
+	ListRoles(ctx context.Context, resource types.Resource, queryToken string, opts ...ListRolesOption) ([]types.Role, error)
+	ListEffectiveRoles(ctx context.Context, resource types.Resource, queryToken string) ([]types.Role, error)
+	ListRolesPage(ctx context.Context, resource types.Resource, queryToken string, page PageOpts, opts ...ListRolesOption) ([]types.Role, string, error)
+	ListRolesForSubject(ctx context.Context, subject types.Resource, queryToken string) ([]types.Role, error)
+	ListAllRoles(ctx context.Context, queryToken string) ([]types.Role, error)
+	SubjectHasRole(ctx context.Context, subject types.Resource, role types.Role, queryToken string) (bool, error)
 	DeleteRelationships(ctx context.Context, relationships ...types.Relationship) (string, error)
 	DeleteRole(ctx context.Context, roleResource types.Resource, queryToken string) (string, error)
-	DeleteResourceRelationships(ctx context.Context, resource types.Resource) (string, error)
+	PurgeRole(ctx context.Context, roleResource types.Resource, queryToken string) (string, error)
+	DeleteRoles(ctx context.Context, roleResources []types.Resource) (string, error)
+	UpdateRole(ctx context.Context, roleResource types.Resource, actions []string) (types.Role, string, error)
+	MoveRole(ctx context.Context, role types.Role, newOwner types.Resource) (string, error)
+	AddRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error)
+	RemoveRoleAction(ctx context.Context, roleResource types.Resource, action string) (string, error)
+	DeleteResourceRelationships(ctx context.Context, resource types.Resource) (int, string, error)
+	DeleteRelationshipsMatching(ctx context.Context, resource types.Resource, opts ...RelationshipFilterOption) (int, string, error)
+	GarbageCollect(ctx context.Context) (GCReport, error)
+	QualifyType(resourceType string) string
 	NewResourceFromID(id gidx.PrefixedID) (types.Resource, error)
+	NewResourceFromIDString(s string) (types.Resource, error)
 	GetResourceType(name string) *types.ResourceType
+	ResourceTypes() []types.ResourceType
+	RegisterResourceType(rt iapl.ResourceType) error
+	Healthcheck(ctx context.Context) error
+	Close() error
+	ApplySchema(ctx context.Context) (string, error)
+	Schema() (string, error)
+	VerifySchema(ctx context.Context) error
+	ReconcilePolicy(ctx context.Context, newPolicy iapl.Policy, opts ...ReconcileOption) (ReconcileReport, error)
 	SubjectHasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) error
+	SubjectHasPermissionAt(ctx context.Context, subject types.Resource, action string, resource types.Resource, queryToken string) error
+	HasPermission(ctx context.Context, subject types.Resource, action string, resource types.Resource) (bool, error)
+	CheckPermissionWithReason(ctx context.Context, subject types.Resource, action string, resource types.Resource) (PermissionDecision, error)
+	Authorize(ctx context.Context, subject types.Resource, action string, resource types.Resource) (PermissionDecision, error)
+	SubjectHasPermissionWithContext(ctx context.Context, subject types.Resource, action string, resource types.Resource, caveatContext map[string]any) error
+	SubjectHasPermissions(ctx context.Context, subject types.Resource, checks []PermissionCheck) ([]PermissionResult, error)
+	ListSubjectActions(ctx context.Context, subject, resource types.Resource, queryToken string) ([]string, error)
+	LookupResources(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string) ([]types.Resource, error)
+	LookupResourcesPage(ctx context.Context, subject types.Resource, action string, resourceType string, queryToken string, page PageOpts) ([]types.Resource, string, error)
+	LookupSubjects(ctx context.Context, resource types.Resource, action string, subjectType string, queryToken string) ([]types.Resource, error)
+	ListSubjectPermissionsInSubtree(ctx context.Context, subject, root types.Resource, queryToken string) (map[gidx.PrefixedID][]string, error)
 }
 
 type engine struct {
@@ -43,11 +108,33 @@ type engine struct {
 	logger                   *zap.SugaredLogger
 	namespace                string
 	client                   *authzed.Client
+	experimental             pb.ExperimentalServiceClient
+	schemaMu                 sync.RWMutex
 	schema                   []types.ResourceType
 	schemaPrefixMap          map[string]types.ResourceType
+	typeMapping              map[string]string
 	schemaTypeMap            map[string]types.ResourceType
 	schemaSubjectRelationMap map[string]map[string][]string
 	schemaRoleables          []types.ResourceType
+	caveats                  []types.Caveat
+	defaultRoles             []iapl.DefaultRole
+	compositeActions         map[string][]string
+	consistencyMode          ConsistencyMode
+	lastWrite                *writeTokenTracker
+	observers                []RelationshipObserver
+	publisher                events.Publisher
+	eventQueue               chan queuedRoleEvents
+	eventsDone               chan struct{}
+	eventsMu                 sync.Mutex
+	eventsClosed             bool
+	retryPolicy              *RetryPolicy
+	checkCache               *checkCache
+	metrics                  *engineMetrics
+	roleTombstones           bool
+	readOnly                 bool
+	conn                     io.Closer
+	closeOnce                sync.Once
+	closeErr                 error
 }
 
 func (e *engine) cacheSchemaResources() {
@@ -62,10 +149,17 @@ func (e *engine) cacheSchemaResources() {
 
 		for _, relationship := range res.Relationships {
 			for _, t := range relationship.Types {
+				// Subject sets are indexed by their object type, a relation may accept both.
+				t, _, _ = strings.Cut(t, "#")
+
 				if _, ok := e.schemaSubjectRelationMap[t]; !ok {
 					e.schemaSubjectRelationMap[t] = make(map[string][]string)
 				}
 
+				if containsString(e.schemaSubjectRelationMap[t][relationship.Relation], res.Name) {
+					continue
+				}
+
 				e.schemaSubjectRelationMap[t][relationship.Relation] = append(e.schemaSubjectRelationMap[t][relationship.Relation], res.Name)
 			}
 		}
@@ -76,6 +170,151 @@ func (e *engine) cacheSchemaResources() {
 	}
 }
 
+// resourceType returns the registered resource type with the given name.
+func (e *engine) resourceType(name string) (types.ResourceType, bool) {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	rType, ok := e.schemaTypeMap[name]
+
+	return rType, ok
+}
+
+// caveatParameters returns the types of the named caveat's parameters, keyed by parameter name.
+func (e *engine) caveatParameters(name string) map[string]string {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	for _, caveat := range e.caveats {
+		if caveat.Name != name {
+			continue
+		}
+
+		params := make(map[string]string, len(caveat.Parameters))
+
+		for _, param := range caveat.Parameters {
+			params[param.Name] = param.Type
+		}
+
+		return params
+	}
+
+	return nil
+}
+
+// resourceTypeForPrefix returns the registered resource type with the given id prefix, preferring
+// the type the prefix is mapped to by WithResourceTypeMapping.
+func (e *engine) resourceTypeForPrefix(prefix string) (types.ResourceType, bool) {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	if typeName, ok := e.typeMapping[prefix]; ok {
+		rType, ok := e.schemaTypeMap[typeName]
+
+		return rType, ok
+	}
+
+	rType, ok := e.schemaPrefixMap[prefix]
+
+	return rType, ok
+}
+
+// resourceTypes returns the registered resource types. Registration replaces the schema rather
+// than modifying it, so the returned slice may be read without holding the lock.
+func (e *engine) resourceTypes() []types.ResourceType {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	return e.schema
+}
+
+// roleableTypes returns the registered resource types roles may be bound to.
+func (e *engine) roleableTypes() []types.ResourceType {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	return e.schemaRoleables
+}
+
+// subjectRelations returns the relations, and the resource types defining them, which accept the
+// given type as a subject.
+func (e *engine) subjectRelations(subjectType string) (map[string][]string, bool) {
+	e.schemaMu.RLock()
+	defer e.schemaMu.RUnlock()
+
+	relTypes, ok := e.schemaSubjectRelationMap[subjectType]
+
+	return relTypes, ok
+}
+
+// RegisterResourceType adds a resource type to the engine at runtime, so resources with its ID prefix
+// can be resolved by NewResourceFromID. Registering a type identical in name and prefix to a
+// registered type does nothing. The type's relationships may only target registered types.
+//
+// Registration only changes the engine's view of the policy; the type and its relations must also
+// exist in the SpiceDB schema for relationships to be written.
+func (e *engine) RegisterResourceType(rt iapl.ResourceType) error {
+	if rt.Name == "" || rt.IDPrefix == "" {
+		return fmt.Errorf("%w: resource type requires a name and id prefix", ErrInvalidType)
+	}
+
+	e.schemaMu.Lock()
+	defer e.schemaMu.Unlock()
+
+	if existing, ok := e.schemaTypeMap[rt.Name]; ok {
+		if existing.IDPrefix == rt.IDPrefix {
+			return nil
+		}
+
+		return fmt.Errorf("%w: %s is registered with prefix %s", ErrResourceTypeExists, rt.Name, existing.IDPrefix)
+	}
+
+	if existing, ok := e.schemaPrefixMap[rt.IDPrefix]; ok {
+		return fmt.Errorf("%w: prefix %s is registered to %s", ErrResourceTypeExists, rt.IDPrefix, existing.Name)
+	}
+
+	out := types.ResourceType{
+		Name:     rt.Name,
+		IDPrefix: rt.IDPrefix,
+	}
+
+	for _, rel := range rt.Relationships {
+		for _, tn := range rel.TargetTypeNames {
+			typeName, _, _ := strings.Cut(tn, "#")
+
+			if _, ok := e.schemaTypeMap[typeName]; !ok && typeName != rt.Name {
+				return fmt.Errorf("%w: %s: relation %s targets %s", ErrInvalidType, rt.Name, rel.Relation, typeName)
+			}
+		}
+
+		out.Relationships = append(out.Relationships, types.ResourceTypeRelationship{
+			Relation: rel.Relation,
+			Types:    rel.TargetTypeNames,
+			Caveat:   rel.Caveat,
+			Wildcard: rel.Wildcard,
+		})
+	}
+
+	schema := make([]types.ResourceType, len(e.schema), len(e.schema)+1)
+	copy(schema, e.schema)
+
+	e.schema = append(schema, out)
+
+	e.cacheSchemaResources()
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func resourceHasRoleBindings(resType types.ResourceType) bool {
 	for _, action := range resType.Actions {
 		for _, cond := range action.Conditions {
@@ -89,6 +328,11 @@ func resourceHasRoleBindings(resType types.ResourceType) bool {
 }
 
 // NewEngine returns a new client for making permissions queries.
+//
+// Every SpiceDB object type the engine uses is prefixed by the namespace, so several engines with
+// different namespaces may share one SpiceDB cluster, and one client, which is safe for concurrent
+// use. Each engine keeps its own policy and caches. Schema writes by ApplySchema and ReconcilePolicy
+// replace only the engine's namespace.
 func NewEngine(namespace string, client *authzed.Client, options ...Option) Engine {
 	tracer := otel.GetTracerProvider().Tracer("go.infratographer.com/permissions-api/internal/query")
 
@@ -103,8 +347,17 @@ func NewEngine(namespace string, client *authzed.Client, options ...Option) Engi
 		fn(e)
 	}
 
+	if e.publisher != nil {
+		e.startEventPublisher()
+	}
+
 	if e.schema == nil {
-		e.schema = iapl.DefaultPolicy().Schema()
+		policy := iapl.DefaultPolicy()
+
+		e.schema = policy.Schema()
+		e.caveats = policy.Caveats()
+		e.defaultRoles = policy.DefaultRoles()
+		e.compositeActions = policy.CompositeActions()
 
 		e.cacheSchemaResources()
 	}
@@ -115,7 +368,9 @@ func NewEngine(namespace string, client *authzed.Client, options ...Option) Engi
 // Option is a functional option for the engine
 type Option func(*engine)
 
-// WithLogger sets the logger for the engine
+// WithLogger sets the logger for the engine. Each relationship written or deleted is logged at
+// debug level along with the resulting zedtoken, and failed SpiceDB requests at error level along
+// with their gRPC code.
 func WithLogger(logger *zap.SugaredLogger) Option {
 	return func(e *engine) {
 		e.logger = logger
@@ -126,6 +381,9 @@ func WithLogger(logger *zap.SugaredLogger) Option {
 func WithPolicy(policy iapl.Policy) Option {
 	return func(e *engine) {
 		e.schema = policy.Schema()
+		e.caveats = policy.Caveats()
+		e.defaultRoles = policy.DefaultRoles()
+		e.compositeActions = policy.CompositeActions()
 
 		e.cacheSchemaResources()
 	}
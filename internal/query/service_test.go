@@ -0,0 +1,452 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/iapl"
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestRegisterResourceType(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name   string
+		rt     iapl.ResourceType
+		expErr error
+	}
+
+	testCases := []testCase{
+		{
+			name: "Success",
+			rt: iapl.ResourceType{
+				Name:     "network",
+				IDPrefix: "ntwknet",
+				Relationships: []iapl.Relationship{
+					{
+						Relation:        "owner",
+						TargetTypeNames: []string{"tenant"},
+					},
+				},
+			},
+		},
+		{
+			name: "AlreadyRegistered",
+			rt: iapl.ResourceType{
+				Name:     "tenant",
+				IDPrefix: "tnntten",
+			},
+		},
+		{
+			name: "NameConflict",
+			rt: iapl.ResourceType{
+				Name:     "tenant",
+				IDPrefix: "tnntnew",
+			},
+			expErr: ErrResourceTypeExists,
+		},
+		{
+			name: "PrefixConflict",
+			rt: iapl.ResourceType{
+				Name:     "newtenant",
+				IDPrefix: "tnntten",
+			},
+			expErr: ErrResourceTypeExists,
+		},
+		{
+			name: "UnknownTarget",
+			rt: iapl.ResourceType{
+				Name:     "network",
+				IDPrefix: "ntwknet",
+				Relationships: []iapl.Relationship{
+					{
+						Relation:        "owner",
+						TargetTypeNames: []string{"unknown"},
+					},
+				},
+			},
+			expErr: ErrInvalidType,
+		},
+		{
+			name: "MissingPrefix",
+			rt: iapl.ResourceType{
+				Name: "network",
+			},
+			expErr: ErrInvalidType,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := NewEngine("test", nil)
+
+			err := e.RegisterResourceType(tc.rt)
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			res, err := e.NewResourceFromID(gidx.MustNewID(tc.rt.IDPrefix))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.rt.Name, res.Type)
+			assert.NotNil(t, e.GetResourceType(tc.rt.Name))
+		})
+	}
+}
+
+func TestRegisterResourceTypeConcurrent(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		prefix := fmt.Sprintf("tstres%d", i)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, e.RegisterResourceType(iapl.ResourceType{
+				Name:     "type" + prefix,
+				IDPrefix: prefix,
+			}))
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			_, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		res, err := e.NewResourceFromID(gidx.MustNewID(fmt.Sprintf("tstres%d", i)))
+		require.NoError(t, err)
+
+		assert.Equal(t, fmt.Sprintf("typetstres%d", i), res.Type)
+	}
+}
+
+func TestNewResourceFromIDUnknownPrefix(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	_, err := e.NewResourceFromID(gidx.MustNewID("unknwnp"))
+
+	var unknownErr *UnknownResourceTypeError
+
+	require.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, "unknwnp", unknownErr.Prefix)
+	assert.ErrorIs(t, err, ErrUnknownResourceType)
+	assert.ErrorIs(t, err, ErrInvalidNamespace)
+}
+
+func TestResourceTypeMapping(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil, WithResourceTypeMapping(map[string]string{
+		"lgcyten":  "tenant",
+		"idntcli":  "tenant",
+		"lgcyunk":  "unknown",
+		RolePrefix: "tenant",
+	}))
+
+	type testCase struct {
+		name    string
+		prefix  string
+		expType string
+	}
+
+	testCases := []testCase{
+		{
+			name:    "Mapped",
+			prefix:  "lgcyten",
+			expType: "tenant",
+		},
+		{
+			name:    "PolicyPrefix",
+			prefix:  "tnntten",
+			expType: "tenant",
+		},
+		{
+			name:    "Override",
+			prefix:  "idntcli",
+			expType: "tenant",
+		},
+		{
+			name:    "RolePrefixNotRemapped",
+			prefix:  RolePrefix,
+			expType: types.RoleResourceType,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			id := gidx.MustNewID(tc.prefix)
+
+			res, err := e.NewResourceFromID(id)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expType, res.Type)
+			assert.Equal(t, id, res.ID)
+		})
+	}
+
+	_, err := e.NewResourceFromID(gidx.MustNewID("lgcyunk"))
+
+	var unknownErr *UnknownResourceTypeError
+
+	require.True(t, errors.As(err, &unknownErr))
+	assert.Equal(t, "lgcyunk", unknownErr.Prefix)
+	assert.Equal(t, "unknown", unknownErr.Type)
+	assert.ErrorIs(t, err, ErrUnknownResourceType)
+}
+
+func TestNewResourceFromIDString(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name    string
+		id      string
+		expType string
+		expErr  error
+	}
+
+	testCases := []testCase{
+		{
+			name:    "Success",
+			id:      "tnntten-abc123",
+			expType: "tenant",
+		},
+		{
+			name:   "Malformed",
+			id:     "tnntten",
+			expErr: ErrInvalidID,
+		},
+		{
+			name:   "Empty",
+			id:     "",
+			expErr: ErrInvalidID,
+		},
+		{
+			name:   "UnknownPrefix",
+			id:     "unknwnp-abc123",
+			expErr: ErrUnknownResourceType,
+		},
+	}
+
+	e := NewEngine("test", nil)
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := e.NewResourceFromIDString(tc.id)
+			if tc.expErr != nil {
+				assert.ErrorIs(t, err, tc.expErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expType, res.Type)
+			assert.Equal(t, gidx.PrefixedID(tc.id), res.ID)
+		})
+	}
+}
+
+func TestQualifyType(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	res, err := e.NewResourceFromIDString("tnntten-abc123")
+	require.NoError(t, err)
+
+	assert.Equal(t, "test/tenant", e.QualifyType(res.Type))
+	assert.Equal(t, e.QualifyType(res.Type), resourceToSpiceDBRef("test", res).ObjectType)
+}
+
+func TestRoleResource(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine("test", nil)
+
+	role, _, err := newRole([]string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	res, err := e.NewResourceFromID(role.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, res, role.Resource())
+	assert.Equal(t, role.Resource(), roleAssignmentRelationship(types.Resource{}, role).Resource)
+}
+
+func TestCreateRelationshipsValidation(t *testing.T) {
+	t.Parallel()
+
+	// The engine has no client, so the relationship must be rejected before SpiceDB is called.
+	e := NewEngine("test", nil)
+
+	tenRes, err := e.NewResourceFromIDString("tnntten-abc123")
+	require.NoError(t, err)
+	userRes, err := e.NewResourceFromIDString("idntusr-abc123")
+	require.NoError(t, err)
+
+	_, err = e.CreateRelationships(context.Background(), []types.Relationship{
+		{
+			Resource: tenRes,
+			Relation: "parent",
+			Subject:  userRes,
+		},
+	})
+
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+	assert.ErrorContains(t, err, "relation parent on tenant does not allow subject type user, allowed types: tenant")
+}
+
+func TestRelationshipMetadata(t *testing.T) {
+	t.Parallel()
+
+	policyDocument := iapl.DefaultPolicyDocument()
+
+	policyDocument.Caveats = append(policyDocument.Caveats, iapl.Caveat{
+		Name: "audit",
+		Parameters: []iapl.CaveatParameter{
+			{Name: "created_by", Type: "string"},
+			{Name: "revision", Type: "int"},
+		},
+		Expression: "true",
+	})
+
+	for i, resType := range policyDocument.ResourceTypes {
+		if resType.Name != "tenant" {
+			continue
+		}
+
+		for j, rel := range resType.Relationships {
+			if rel.Relation == "parent" {
+				policyDocument.ResourceTypes[i].Relationships[j].Caveat = "audit"
+			}
+		}
+	}
+
+	policy := iapl.NewPolicy(policyDocument)
+	require.NoError(t, policy.Validate())
+
+	e := NewEngine("test", nil, WithPolicy(policy)).(*engine)
+
+	tenRes, err := e.NewResourceFromIDString("tnntten-abc123")
+	require.NoError(t, err)
+	parentRes, err := e.NewResourceFromIDString("tnntten-def456")
+	require.NoError(t, err)
+
+	type testCase struct {
+		name     string
+		relation string
+		metadata map[string]string
+		expErr   string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "NoMetadata",
+			relation: "parent",
+		},
+		{
+			name:     "Declared",
+			relation: "parent",
+			metadata: map[string]string{"created_by": "idntusr-abc123"},
+		},
+		{
+			name:     "UnknownKey",
+			relation: "parent",
+			metadata: map[string]string{"created_at": "yesterday"},
+			expErr:   "metadata key created_at is not a parameter of caveat audit",
+		},
+		{
+			name:     "NotString",
+			relation: "parent",
+			metadata: map[string]string{"revision": "1"},
+			expErr:   "metadata key revision must be a string parameter of caveat audit, not int",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rel := types.Relationship{
+				Resource: tenRes,
+				Relation: tc.relation,
+				Subject:  parentRes,
+				Metadata: tc.metadata,
+			}
+
+			err := e.validateRelationship(rel)
+
+			if tc.expErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidRelationship)
+				assert.ErrorContains(t, err, tc.expErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			update := e.relationshipsToUpdates([]types.Relationship{rel})[0]
+
+			rels, err := e.relationshipsToNonRoles([]*pb.Relationship{update.Relationship})
+			require.NoError(t, err)
+			require.Len(t, rels, 1)
+
+			assert.Equal(t, rel.Metadata, rels[0].Metadata)
+
+			if rel.Metadata == nil {
+				assert.Nil(t, rels[0].Caveat)
+
+				return
+			}
+
+			expCaveat := &types.RelationshipCaveat{
+				Name:    "audit",
+				Context: map[string]any{"created_by": "idntusr-abc123"},
+			}
+
+			assert.Equal(t, expCaveat, rels[0].Caveat)
+		})
+	}
+}
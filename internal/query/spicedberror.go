@@ -0,0 +1,86 @@
+package query
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SpiceDBError is an error returned by SpiceDB. It keeps the gRPC status of the failed request
+// and matches ErrUnavailable, ErrDeadlineExceeded, ErrPermissionDenied or ErrRelationshipExists
+// with errors.Is when the status code corresponds to one of them.
+type SpiceDBError struct {
+	status *status.Status
+	err    error
+}
+
+// newSpiceDBError wraps an error returned by the SpiceDB client. Errors which carry no gRPC
+// status, or which are already wrapped, are returned unchanged.
+func newSpiceDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var spiceErr *SpiceDBError
+	if errors.As(err, &spiceErr) {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	return &SpiceDBError{
+		status: st,
+		err:    err,
+	}
+}
+
+// Error returns the underlying error message.
+func (e *SpiceDBError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *SpiceDBError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error's status code corresponds to the target.
+func (e *SpiceDBError) Is(target error) bool {
+	switch target {
+	case ErrUnavailable:
+		return e.Code() == codes.Unavailable
+	case ErrDeadlineExceeded:
+		return e.Code() == codes.DeadlineExceeded
+	case ErrPermissionDenied:
+		return e.Code() == codes.PermissionDenied || e.Code() == codes.Unauthenticated
+	case ErrRelationshipExists:
+		return e.Code() == codes.AlreadyExists
+	}
+
+	return false
+}
+
+// Code returns the gRPC status code of the error.
+func (e *SpiceDBError) Code() codes.Code {
+	return e.status.Code()
+}
+
+// GRPCStatus returns the gRPC status of the error, which allows status.FromError to extract it.
+func (e *SpiceDBError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// StatusFromError returns the gRPC status of the SpiceDB error wrapped by err.
+// False is returned if err does not wrap a SpiceDB error.
+func StatusFromError(err error) (*status.Status, bool) {
+	var spiceErr *SpiceDBError
+	if !errors.As(err, &spiceErr) {
+		return nil, false
+	}
+
+	return spiceErr.status, true
+}
@@ -0,0 +1,105 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSpiceDBError(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name    string
+		input   error
+		checkFn func(*testing.T, error)
+	}
+
+	testCases := []testCase{
+		{
+			name:  "Unavailable",
+			input: status.Error(codes.Unavailable, "connection refused"),
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrUnavailable)
+				assert.False(t, errors.Is(err, ErrDeadlineExceeded))
+				assert.Equal(t, "rpc error: code = Unavailable desc = connection refused", err.Error())
+			},
+		},
+		{
+			name:  "DeadlineExceeded",
+			input: status.Error(codes.DeadlineExceeded, "too slow"),
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrDeadlineExceeded)
+				assert.False(t, errors.Is(err, ErrUnavailable))
+			},
+		},
+		{
+			name:  "PermissionDenied",
+			input: status.Error(codes.Unauthenticated, "bad token"),
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrPermissionDenied)
+			},
+		},
+		{
+			name:  "AlreadyExists",
+			input: status.Error(codes.AlreadyExists, "relationship exists"),
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrRelationshipExists)
+				assert.False(t, errors.Is(err, ErrUnavailable))
+			},
+		},
+		{
+			name:  "OtherCode",
+			input: status.Error(codes.FailedPrecondition, "unknown relation"),
+			checkFn: func(t *testing.T, err error) {
+				assert.False(t, errors.Is(err, ErrUnavailable))
+				assert.False(t, errors.Is(err, ErrDeadlineExceeded))
+				assert.False(t, errors.Is(err, ErrPermissionDenied))
+
+				st, ok := StatusFromError(err)
+				require.True(t, ok)
+				assert.Equal(t, codes.FailedPrecondition, st.Code())
+			},
+		},
+		{
+			name:  "Wrapped",
+			input: fmt.Errorf("%w: failed to delete relationship 0", status.Error(codes.Unavailable, "connection refused")),
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, fmt.Errorf("outer: %w", err), ErrUnavailable)
+
+				st, ok := status.FromError(fmt.Errorf("outer: %w", err))
+				require.True(t, ok)
+				assert.Equal(t, codes.Unavailable, st.Code())
+			},
+		},
+		{
+			name:  "NoStatus",
+			input: context.Canceled,
+			checkFn: func(t *testing.T, err error) {
+				assert.Equal(t, context.Canceled, err)
+
+				_, ok := StatusFromError(err)
+				assert.False(t, ok)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := newSpiceDBError(tc.input)
+
+			assert.Equal(t, err, newSpiceDBError(err))
+
+			tc.checkFn(t, err)
+		})
+	}
+}
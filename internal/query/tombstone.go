@@ -0,0 +1,114 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// WithRoleTombstones makes DeleteRole retain deleted roles rather than removing them. A deleted role
+// loses its assignments, so it no longer grants anything, but keeps its actions, parents, name and
+// description along with the time it was deleted, so its definition can still be inspected with
+// GetRole or ListRoles with IncludeDeleted. Assigning a deleted role or giving it actions returns
+// ErrRoleDeleted. PurgeRole removes a role permanently.
+func WithRoleTombstones() Option {
+	return func(e *engine) {
+		e.roleTombstones = true
+	}
+}
+
+// ListRolesOption is a functional option for listing roles.
+type ListRolesOption func(opts *listRolesOptions)
+
+type listRolesOptions struct {
+	includeDeleted bool
+}
+
+func newListRolesOptions(opts []ListRolesOption) listRolesOptions {
+	var options listRolesOptions
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
+
+// IncludeDeleted includes roles retained after being deleted when listing roles.
+func IncludeDeleted() ListRolesOption {
+	return func(opts *listRolesOptions) {
+		opts.includeDeleted = true
+	}
+}
+
+// tombstoneRole marks the role as deleted and removes its assignments, including the roles which
+// inherit from it. The marker is written first, so a tombstone which fails part way may be retried,
+// keeping the original deletion time.
+func (e *engine) tombstoneRole(ctx context.Context, roleResource types.Resource, queryToken string) (string, error) {
+	role := types.Role{
+		ID: roleResource.ID,
+	}
+
+	if err := e.readRoleMetadata(ctx, &role, queryToken); err != nil {
+		return "", err
+	}
+
+	if !role.IsDeleted() {
+		role.DeletedAt = time.Now()
+	}
+
+	metadataRel, err := e.roleMetadataUpdate(role)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{
+		Updates: []*pb.RelationshipUpdate{metadataRel},
+	}); err != nil {
+		return "", fmt.Errorf("failed to mark role deleted: %w", newSpiceDBError(err))
+	}
+
+	queryToken, err = e.deleteRelationships(ctx, &pb.RelationshipFilter{
+		ResourceType:       e.namespace + "/role",
+		OptionalResourceId: roleResource.ID.String(),
+		OptionalRelation:   roleSubjectRelation,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to delete role assignments: %w", err)
+	}
+
+	return queryToken, nil
+}
+
+// roleIsDeleted returns whether the role has been tombstoned.
+func (e *engine) roleIsDeleted(ctx context.Context, roleResource types.Resource, queryToken string) (bool, error) {
+	role := types.Role{
+		ID: roleResource.ID,
+	}
+
+	if err := e.readRoleMetadata(ctx, &role, queryToken); err != nil {
+		return false, err
+	}
+
+	return role.IsDeleted(), nil
+}
+
+// checkRoleNotDeleted returns ErrRoleDeleted if the role has been tombstoned, as a tombstone keeps the
+// role's actions and assigning it would grant them again. The role is read fully consistent so a role
+// deleted just before is caught.
+func (e *engine) checkRoleNotDeleted(ctx context.Context, roleResource types.Resource) error {
+	deleted, err := e.roleIsDeleted(ContextWithConsistency(ctx, ConsistencyFullyConsistent), roleResource, "")
+	if err != nil {
+		return err
+	}
+
+	if deleted {
+		return fmt.Errorf("%w: %s", ErrRoleDeleted, roleResource.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,328 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.infratographer.com/x/gidx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+// Tx accumulates relationship changes which are written together by Commit, so either all of them
+// are made or none are. Each change is validated when it is added, and nothing is written until
+// Commit. A relationship may only be changed once in a transaction. A Tx is not safe for concurrent use.
+type Tx interface {
+	CreateRelationships(rels []types.Relationship) error
+	DeleteRelationships(rels ...types.Relationship) error
+	CreateRole(res types.Resource, actions []string, opts ...RoleOption) (types.Role, error)
+	AssignSubjectRole(subject types.Resource, role types.Role) error
+	UnassignSubjectRole(subject types.Resource, role types.Role) error
+	Commit(ctx context.Context) (string, error)
+}
+
+type tx struct {
+	e       *engine
+	updates []*pb.RelationshipUpdate
+	changed map[relationshipKey]struct{}
+	created []types.Relationship
+	deleted []types.Relationship
+	roles   []types.Role
+	events  []txRoleEvent
+	done    bool
+}
+
+// txRoleEvent is a role assignment event to publish once the transaction is committed.
+type txRoleEvent struct {
+	eventType string
+	subject   types.Resource
+	role      types.Role
+}
+
+// relationshipKey identifies a relationship apart from its metadata, which is not comparable.
+type relationshipKey struct {
+	resource        types.Resource
+	relation        string
+	subject         types.Resource
+	subjectRelation string
+}
+
+func keyForRelationship(rel types.Relationship) relationshipKey {
+	return relationshipKey{
+		resource:        rel.Resource,
+		relation:        rel.Relation,
+		subject:         rel.Subject,
+		subjectRelation: rel.SubjectRelation,
+	}
+}
+
+var _ Tx = &tx{}
+
+// Begin starts a transaction grouping relationship changes into a single write.
+func (e *engine) Begin() Tx {
+	return &tx{
+		e:       e,
+		changed: make(map[relationshipKey]struct{}),
+	}
+}
+
+// add records the updates, rejecting them all if any changes a relationship already changed.
+func (t *tx) add(rels []types.Relationship, updates []*pb.RelationshipUpdate) error {
+	if t.done {
+		return ErrTransactionDone
+	}
+
+	seen := make(map[relationshipKey]struct{}, len(rels))
+
+	for _, rel := range rels {
+		key := keyForRelationship(rel)
+
+		_, changed := t.changed[key]
+		_, repeated := seen[key]
+
+		if changed || repeated {
+			return fmt.Errorf("%w: %s#%s@%s changed more than once in transaction", ErrInvalidRelationship, rel.Resource.ID, rel.Relation, rel.Subject.ID)
+		}
+
+		seen[key] = struct{}{}
+	}
+
+	for key := range seen {
+		t.changed[key] = struct{}{}
+	}
+
+	t.updates = append(t.updates, updates...)
+
+	return nil
+}
+
+// CreateRelationships adds the relationships to the transaction.
+func (t *tx) CreateRelationships(rels []types.Relationship) error {
+	for _, rel := range rels {
+		if err := t.e.validateRelationship(rel); err != nil {
+			return err
+		}
+	}
+
+	if err := t.add(rels, t.e.relationshipsToUpdates(rels)); err != nil {
+		return err
+	}
+
+	t.created = append(t.created, rels...)
+
+	return nil
+}
+
+// DeleteRelationships removes the relationships in the transaction. Relationships which do not exist are ignored.
+func (t *tx) DeleteRelationships(rels ...types.Relationship) error {
+	for _, rel := range rels {
+		if err := t.e.validateRelationship(rel); err != nil {
+			return err
+		}
+	}
+
+	updates := t.e.relationshipsToUpdates(rels)
+
+	for _, update := range updates {
+		update.Operation = pb.RelationshipUpdate_OPERATION_DELETE
+	}
+
+	if err := t.add(rels, updates); err != nil {
+		return err
+	}
+
+	t.deleted = append(t.deleted, rels...)
+
+	return nil
+}
+
+// CreateRole adds a role scoped to the given resource with the given actions to the transaction.
+// The returned role may be assigned within the same transaction. Composite actions are expanded as by
+// the engine's CreateRole. WithRoleID may not be given, as a transaction cannot check whether a role
+// with the ID already exists.
+func (t *tx) CreateRole(res types.Resource, actions []string, opts ...RoleOption) (types.Role, error) {
+	if err := t.e.validateRoleOwner(res); err != nil {
+		return types.Role{}, err
+	}
+
+	actions = t.e.expandActions(actions)
+
+	if err := t.e.validateRoleActions(res, actions); err != nil {
+		return types.Role{}, err
+	}
+
+	role, idGiven, err := newRole(actions, opts...)
+	if err != nil {
+		return types.Role{}, err
+	}
+
+	if idGiven {
+		return types.Role{}, fmt.Errorf("%w: role IDs may not be given in a transaction", ErrInvalidID)
+	}
+
+	role.Owner = res
+
+	updates, err := t.e.roleUpdates(role, res)
+	if err != nil {
+		return types.Role{}, err
+	}
+
+	// The role's ID is generated, so its relationships cannot conflict with any other change.
+	if err := t.add(nil, updates); err != nil {
+		return types.Role{}, err
+	}
+
+	t.roles = append(t.roles, role)
+
+	return role, nil
+}
+
+// AssignSubjectRole adds the assignment of the role to the subject to the transaction.
+func (t *tx) AssignSubjectRole(subject types.Resource, role types.Role) error {
+	rel := roleAssignmentRelationship(subject, role)
+
+	if subject.IsWildcard() {
+		if err := t.e.validateRelationship(rel); err != nil {
+			return err
+		}
+	}
+
+	if err := t.add([]types.Relationship{rel}, []*pb.RelationshipUpdate{t.e.subjectRoleRelCreate(subject, role)}); err != nil {
+		return err
+	}
+
+	t.created = append(t.created, rel)
+	t.events = append(t.events, txRoleEvent{eventType: RoleEventTypeAssign, subject: subject, role: role})
+
+	return nil
+}
+
+// UnassignSubjectRole adds the removal of the role from the subject to the transaction.
+func (t *tx) UnassignSubjectRole(subject types.Resource, role types.Role) error {
+	rel := roleAssignmentRelationship(subject, role)
+
+	update := t.e.subjectRoleRelCreate(subject, role)
+	update.Operation = pb.RelationshipUpdate_OPERATION_DELETE
+
+	if err := t.add([]types.Relationship{rel}, []*pb.RelationshipUpdate{update}); err != nil {
+		return err
+	}
+
+	t.deleted = append(t.deleted, rel)
+	t.events = append(t.events, txRoleEvent{eventType: RoleEventTypeUnassign, subject: subject, role: role})
+
+	return nil
+}
+
+// checkAssignedRoles returns ErrRoleDeleted if any role the transaction assigns has been deleted.
+func (t *tx) checkAssignedRoles(ctx context.Context) error {
+	checked := make(map[gidx.PrefixedID]struct{})
+
+	for _, ev := range t.events {
+		if ev.eventType != RoleEventTypeAssign {
+			continue
+		}
+
+		if _, ok := checked[ev.role.ID]; ok {
+			continue
+		}
+
+		checked[ev.role.ID] = struct{}{}
+
+		if err := t.e.checkRoleNotDeleted(ctx, ev.role.Resource()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit writes all of the transaction's changes in a single request, returning the query token of
+// the write. Observers are notified and events published only once the write succeeds. A transaction
+// may only be committed once, whether or not the write succeeds.
+func (t *tx) Commit(ctx context.Context) (_ string, err error) {
+	e := t.e
+
+	ctx, span := e.tracer.Start(
+		ctx,
+		"engine.Commit",
+		trace.WithAttributes(
+			attribute.String("permissions.namespace", e.namespace),
+			attribute.Int("permissions.updates", len(t.updates)),
+		),
+	)
+
+	defer span.End()
+	defer e.observe(ctx, "Commit", time.Now(), &err)
+
+	if t.done {
+		span.SetStatus(codes.Error, ErrTransactionDone.Error())
+
+		return "", ErrTransactionDone
+	}
+
+	t.done = true
+
+	if len(t.updates) == 0 {
+		return "", nil
+	}
+
+	if len(t.updates) > maxWriteUpdates {
+		err := fmt.Errorf("%w: %d updates, at most %d may be written together", ErrTransactionTooLarge, len(t.updates), maxWriteUpdates)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	if err := t.checkAssignedRoles(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	r, err := e.writeRelationships(ctx, &pb.WriteRelationshipsRequest{
+		Updates: t.updates,
+	})
+	if err != nil {
+		err = newSpiceDBError(err)
+
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return "", err
+	}
+
+	queryToken := r.WrittenAt.GetToken()
+
+	recordZedToken(span, queryToken)
+
+	if len(t.created) != 0 {
+		e.notifyCreate(ctx, t.created, queryToken)
+	}
+
+	if len(t.deleted) != 0 {
+		e.notifyDelete(ctx, t.deleted, queryToken)
+	}
+
+	for _, role := range t.roles {
+		e.publishRoleEvent(ctx, roleEvent{
+			eventType: RoleEventTypeCreate,
+			role:      role,
+			resource:  role.Owner,
+		})
+	}
+
+	for _, ev := range t.events {
+		e.publishRoleAssignmentEvents(ctx, ev.eventType, ev.role, []types.Resource{ev.subject}, queryToken)
+	}
+
+	return queryToken, nil
+}
@@ -0,0 +1,84 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.infratographer.com/x/gidx"
+
+	"go.infratographer.com/permissions-api/internal/types"
+)
+
+func TestTransaction(t *testing.T) {
+	namespace := "testtransaction"
+	ctx := context.Background()
+	e := testEngine(ctx, t, namespace)
+
+	parentRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	childRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+	subjRes, err := e.NewResourceFromID(gidx.MustNewID("idntusr"))
+	require.NoError(t, err)
+
+	parentRel := types.Relationship{
+		Resource: childRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	tx := e.Begin()
+
+	require.NoError(t, tx.CreateRelationships([]types.Relationship{parentRel}))
+
+	role, err := tx.CreateRole(parentRes, []string{"loadbalancer_get"})
+	require.NoError(t, err)
+
+	_, err = tx.CreateRole(parentRes, []string{"bad_action"})
+	assert.ErrorIs(t, err, ErrInvalidAction)
+
+	_, err = tx.CreateRole(parentRes, []string{"loadbalancer_get"}, WithRoleID(gidx.MustNewID(RolePrefix)))
+	assert.ErrorIs(t, err, ErrInvalidID)
+
+	require.NoError(t, tx.AssignSubjectRole(subjRes, role))
+
+	err = tx.UnassignSubjectRole(subjRes, role)
+	assert.ErrorIs(t, err, ErrInvalidRelationship)
+
+	queryToken, err := tx.Commit(ctx)
+	require.NoError(t, err)
+	assert.NotEmpty(t, queryToken)
+
+	_, err = tx.Commit(ctx)
+	assert.ErrorIs(t, err, ErrTransactionDone)
+
+	err = tx.AssignSubjectRole(subjRes, role)
+	assert.ErrorIs(t, err, ErrTransactionDone)
+
+	err = e.SubjectHasPermission(ContextWithQueryToken(ctx, queryToken), subjRes, "loadbalancer_get", childRes)
+	assert.NoError(t, err)
+
+	// The existing assignment fails the write, so the other changes are not made either.
+	otherRes, err := e.NewResourceFromID(gidx.MustNewID("tnntten"))
+	require.NoError(t, err)
+
+	otherRel := types.Relationship{
+		Resource: otherRes,
+		Relation: "parent",
+		Subject:  parentRes,
+	}
+
+	tx = e.Begin()
+
+	require.NoError(t, tx.CreateRelationships([]types.Relationship{otherRel}))
+	require.NoError(t, tx.AssignSubjectRole(subjRes, role))
+
+	_, err = tx.Commit(ctx)
+	require.Error(t, err)
+
+	exists, err := e.HasRelationship(ctx, otherRel, queryToken)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,25 @@
+package query
+
+// WithResourceTypeMapping maps ID prefixes to resource type names, for IDs minted with prefixes other
+// than those of the policy, such as by legacy systems. NewResourceFromID and NewResourceFromIDString
+// look up an ID's prefix in the mapping before the policy's prefixes, so the mapping may both add
+// prefixes and override the policy's. A prefix mapped to a type the engine does not have returns an
+// UnknownResourceTypeError naming the type. The mapping is copied, so later changes to it have no effect.
+//
+// RolePrefix cannot be remapped and is ignored if given: Role.Resource gives every role the type
+// types.RoleResourceType without consulting the engine, so role IDs must always resolve to that type.
+func WithResourceTypeMapping(mapping map[string]string) Option {
+	return func(e *engine) {
+		if e.typeMapping == nil {
+			e.typeMapping = make(map[string]string, len(mapping))
+		}
+
+		for prefix, typeName := range mapping {
+			if prefix == RolePrefix {
+				continue
+			}
+
+			e.typeMapping[prefix] = typeName
+		}
+	}
+}
@@ -4,13 +4,30 @@ package spicedbx
 import (
 	"context"
 	"fmt"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/authzed/authzed-go/v1"
 	"github.com/authzed/grpcutil"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	// DefaultKeepaliveTime is the default time a connection may be idle before it is pinged. It is the
+	// minimum gRPC servers, SpiceDB included, allow by default; pinging more often makes the server close
+	// the connection.
+	DefaultKeepaliveTime = 5 * time.Minute
+	// DefaultKeepaliveTimeout is the default time to wait for a ping to be acknowledged before the
+	// connection is closed.
+	DefaultKeepaliveTimeout = 20 * time.Second
+	// DefaultLoadBalancingPolicy is the default gRPC load balancing policy. SpiceDB recommends spreading
+	// requests across every address the endpoint resolves to, as with a dns:/// endpoint naming a headless
+	// service, rather than sending them all to the first.
+	DefaultLoadBalancingPolicy = "round_robin"
 )
 
 // Config values for a SpiceDB connection
@@ -21,10 +38,58 @@ type Config struct {
 	VerifyCA   bool `mapstruct:"verifyca"`
 	Prefix     string
 	PolicyFile string
+	// CAPath is the path of a PEM CA bundle to verify the server against rather than the system trust store.
+	// It requires VerifyCA.
+	CAPath string
+	// CertPath and KeyPath are the paths of a PEM client certificate and key presented for mTLS.
+	CertPath string
+	KeyPath  string
+	// ServerName overrides the name the server's certificate is verified against, which is the endpoint's host by default.
+	// It requires VerifyCA.
+	ServerName string
+	// KeepaliveTime is the time a connection may be idle before it is pinged, DefaultKeepaliveTime if unset.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is the time to wait for a ping to be acknowledged, DefaultKeepaliveTimeout if unset.
+	KeepaliveTimeout time.Duration
+	// MaxConnectionIdle is the time a connection may go without requests before it is closed, to be
+	// reopened by the next request. It is unlimited if unset. The age of a connection is limited by
+	// SpiceDB itself with its --grpc-max-conn-age flag, after which the client reconnects.
+	MaxConnectionIdle time.Duration
+	// LoadBalancingPolicy is the gRPC load balancing policy, DefaultLoadBalancingPolicy if unset.
+	LoadBalancingPolicy string
 }
 
 // NewClient returns a new spicedb/authzed client
 func NewClient(cfg Config, enableTracing bool) (*authzed.Client, error) {
+	client, _, err := NewClientConn(cfg, enableTracing)
+
+	return client, err
+}
+
+// NewClientConn returns a new spicedb/authzed client along with the connection it uses, which
+// the caller closes once the client is no longer needed.
+func NewClientConn(cfg Config, enableTracing bool) (*authzed.Client, *grpc.ClientConn, error) {
+	clientOpts, err := dialOptions(cfg, enableTracing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := grpc.Dial(cfg.Endpoint, clientOpts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &authzed.Client{
+		SchemaServiceClient:      v1.NewSchemaServiceClient(conn),
+		PermissionsServiceClient: v1.NewPermissionsServiceClient(conn),
+		WatchServiceClient:       v1.NewWatchServiceClient(conn),
+	}
+
+	return client, conn, nil
+}
+
+// dialOptions returns the gRPC dial options for the connection described by the config.
+func dialOptions(cfg Config, enableTracing bool) ([]grpc.DialOption, error) {
 	clientOpts := []grpc.DialOption{}
 
 	if cfg.Insecure {
@@ -33,23 +98,42 @@ func NewClient(cfg Config, enableTracing bool) (*authzed.Client, error) {
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 		)
 	} else {
+		tlsConfig, err := TLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+
 		clientOpts = append(clientOpts,
 			grpcutil.WithBearerToken(cfg.Key),
+			grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
 		)
+	}
 
-		if cfg.VerifyCA {
-			opt, err := grpcutil.WithSystemCerts(grpcutil.VerifyCA)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load system certificates: %w", err)
-			}
-			clientOpts = append(clientOpts, opt)
-		} else {
-			opt, err := grpcutil.WithSystemCerts(grpcutil.SkipVerifyCA)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load system certificates: %w", err)
-			}
-			clientOpts = append(clientOpts, opt)
-		}
+	keepaliveTime := cfg.KeepaliveTime
+	if keepaliveTime <= 0 {
+		keepaliveTime = DefaultKeepaliveTime
+	}
+
+	keepaliveTimeout := cfg.KeepaliveTimeout
+	if keepaliveTimeout <= 0 {
+		keepaliveTimeout = DefaultKeepaliveTimeout
+	}
+
+	lbPolicy := cfg.LoadBalancingPolicy
+	if lbPolicy == "" {
+		lbPolicy = DefaultLoadBalancingPolicy
+	}
+
+	clientOpts = append(clientOpts,
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    keepaliveTime,
+			Timeout: keepaliveTimeout,
+		}),
+		grpc.WithDefaultServiceConfig(fmt.Sprintf(`{"loadBalancingConfig": [{%q: {}}]}`, lbPolicy)),
+	)
+
+	if cfg.MaxConnectionIdle > 0 {
+		clientOpts = append(clientOpts, grpc.WithIdleTimeout(cfg.MaxConnectionIdle))
 	}
 
 	if enableTracing {
@@ -59,7 +143,7 @@ func NewClient(cfg Config, enableTracing bool) (*authzed.Client, error) {
 		)
 	}
 
-	return authzed.NewClient(cfg.Endpoint, clientOpts...)
+	return clientOpts, nil
 }
 
 // Healthcheck reads the schema to check if the connection is working
@@ -0,0 +1,264 @@
+package spicedbx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaElementKind is the kind of schema element a change applies to.
+type SchemaElementKind string
+
+const (
+	// SchemaElementCaveat is a caveat declaration.
+	SchemaElementCaveat SchemaElementKind = "caveat"
+	// SchemaElementDefinition is an object definition.
+	SchemaElementDefinition SchemaElementKind = "definition"
+	// SchemaElementRelation is a relation on an object definition.
+	SchemaElementRelation SchemaElementKind = "relation"
+	// SchemaElementPermission is a permission on an object definition.
+	SchemaElementPermission SchemaElementKind = "permission"
+)
+
+// SchemaChangeType describes how a schema element changed.
+type SchemaChangeType string
+
+const (
+	// SchemaChangeAdded is an element present only in the desired schema.
+	SchemaChangeAdded SchemaChangeType = "added"
+	// SchemaChangeRemoved is an element present only in the current schema.
+	SchemaChangeRemoved SchemaChangeType = "removed"
+	// SchemaChangeModified is an element present in both schemas with a different body.
+	SchemaChangeModified SchemaChangeType = "modified"
+)
+
+// SchemaChange is a single difference between two schemas.
+type SchemaChange struct {
+	Kind SchemaElementKind
+	Type SchemaChangeType
+	// Name is the fully qualified element name, e.g. ns/tenant or ns/tenant#parent.
+	Name     string
+	Current  string
+	Desired  string
+	Breaking bool
+}
+
+// String returns a human readable description of the change.
+func (c SchemaChange) String() string {
+	prefix := "+"
+
+	switch c.Type {
+	case SchemaChangeRemoved:
+		prefix = "-"
+	case SchemaChangeModified:
+		prefix = "~"
+	}
+
+	out := fmt.Sprintf("%s %s %s", prefix, c.Kind, c.Name)
+
+	if c.Type == SchemaChangeModified {
+		out += fmt.Sprintf(": %q -> %q", c.Current, c.Desired)
+	}
+
+	if c.Breaking {
+		out += " (breaking)"
+	}
+
+	return out
+}
+
+// SchemaDiff is the set of changes required to go from one schema to another.
+type SchemaDiff struct {
+	Changes []SchemaChange
+}
+
+// Empty returns true when the schemas are equivalent.
+func (d SchemaDiff) Empty() bool {
+	return len(d.Changes) == 0
+}
+
+// Breaking returns true if any change may fail to apply or remove access.
+func (d SchemaDiff) Breaking() bool {
+	for _, change := range d.Changes {
+		if change.Breaking {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BreakingChanges returns the changes which are breaking.
+func (d SchemaDiff) BreakingChanges() []SchemaChange {
+	var out []SchemaChange
+
+	for _, change := range d.Changes {
+		if change.Breaking {
+			out = append(out, change)
+		}
+	}
+
+	return out
+}
+
+// String returns the diff with one change per line.
+func (d SchemaDiff) String() string {
+	lines := make([]string, len(d.Changes))
+
+	for i, change := range d.Changes {
+		lines[i] = change.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// DiffSchema parses both schemas and reports the definitions, relations,
+// permissions and caveats added, removed or modified going from current to
+// desired. Removals, and relations which no longer allow a previously allowed
+// subject type, are marked as breaking.
+func DiffSchema(current, desired string) (SchemaDiff, error) {
+	currentSchema, err := parseSchema(current)
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("current schema: %w", err)
+	}
+
+	desiredSchema, err := parseSchema(desired)
+	if err != nil {
+		return SchemaDiff{}, fmt.Errorf("desired schema: %w", err)
+	}
+
+	var diff SchemaDiff
+
+	diff.Changes = append(diff.Changes, diffElements(SchemaElementCaveat, currentSchema.caveats, desiredSchema.caveats, nil)...)
+
+	for _, name := range sortedKeys(currentSchema.definitions, desiredSchema.definitions) {
+		currentDef, inCurrent := currentSchema.definitions[name]
+		desiredDef, inDesired := desiredSchema.definitions[name]
+
+		switch {
+		case !inCurrent:
+			diff.Changes = append(diff.Changes, SchemaChange{
+				Kind: SchemaElementDefinition,
+				Type: SchemaChangeAdded,
+				Name: name,
+			})
+		case !inDesired:
+			diff.Changes = append(diff.Changes, SchemaChange{
+				Kind:     SchemaElementDefinition,
+				Type:     SchemaChangeRemoved,
+				Name:     name,
+				Breaking: true,
+			})
+		}
+
+		if currentDef == nil {
+			currentDef = newSchemaDefinition()
+		}
+
+		if desiredDef == nil {
+			desiredDef = newSchemaDefinition()
+		}
+
+		diff.Changes = append(diff.Changes, diffElements(SchemaElementRelation, qualify(name, currentDef.relations), qualify(name, desiredDef.relations), relationNarrowed)...)
+		diff.Changes = append(diff.Changes, diffElements(SchemaElementPermission, qualify(name, currentDef.permissions), qualify(name, desiredDef.permissions), nil)...)
+	}
+
+	return diff, nil
+}
+
+// relationNarrowed returns true if a subject type allowed by current is not
+// allowed by desired.
+func relationNarrowed(current, desired string) bool {
+	allowed := make(map[string]struct{})
+
+	for _, subjectType := range splitSubjectTypes(desired) {
+		allowed[subjectType] = struct{}{}
+	}
+
+	for _, subjectType := range splitSubjectTypes(current) {
+		if _, ok := allowed[subjectType]; !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func splitSubjectTypes(expr string) []string {
+	parts := strings.Split(expr, "|")
+
+	out := make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		out = append(out, strings.TrimSpace(part))
+	}
+
+	return out
+}
+
+// diffElements compares two sets of named element bodies. Removals are always
+// breaking, modifications are breaking if breakingFn reports them so.
+func diffElements(kind SchemaElementKind, current, desired map[string]string, breakingFn func(current, desired string) bool) []SchemaChange {
+	var changes []SchemaChange
+
+	for _, name := range sortedKeys(current, desired) {
+		currentBody, inCurrent := current[name]
+		desiredBody, inDesired := desired[name]
+
+		change := SchemaChange{
+			Kind:    kind,
+			Name:    name,
+			Current: currentBody,
+			Desired: desiredBody,
+		}
+
+		switch {
+		case !inCurrent:
+			change.Type = SchemaChangeAdded
+		case !inDesired:
+			change.Type = SchemaChangeRemoved
+			change.Breaking = true
+		case currentBody != desiredBody:
+			change.Type = SchemaChangeModified
+			change.Breaking = breakingFn != nil && breakingFn(currentBody, desiredBody)
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func qualify(definition string, elements map[string]string) map[string]string {
+	out := make(map[string]string, len(elements))
+
+	for name, body := range elements {
+		out[definition+"#"+name] = body
+	}
+
+	return out
+}
+
+func sortedKeys[T any](maps ...map[string]T) []string {
+	seen := make(map[string]struct{})
+
+	var keys []string
+
+	for _, m := range maps {
+		for key := range m {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			seen[key] = struct{}{}
+
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,202 @@
+package spicedbx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSchema(t *testing.T) {
+	t.Parallel()
+
+	current := `definition foo/user {
+}
+definition foo/client {
+}
+definition foo/tenant {
+    relation parent: foo/tenant
+    relation member: foo/user | foo/client
+    relation loadbalancer_get_rel: foo/role#subject
+    permission loadbalancer_get = loadbalancer_get_rel + parent->loadbalancer_get
+}
+`
+
+	type testCase struct {
+		name    string
+		desired string
+		checkFn func(*testing.T, SchemaDiff, error)
+	}
+
+	testCases := []testCase{
+		{
+			name:    "NoChanges",
+			desired: current,
+			checkFn: func(t *testing.T, diff SchemaDiff, err error) {
+				require.NoError(t, err)
+				assert.True(t, diff.Empty())
+				assert.False(t, diff.Breaking())
+			},
+		},
+		{
+			name: "Additive",
+			desired: `definition foo/user {
+}
+definition foo/client {
+}
+definition foo/port {
+}
+definition foo/tenant {
+    relation parent: foo/tenant
+    relation member: foo/user | foo/client | foo/user:*
+    relation loadbalancer_get_rel: foo/role#subject
+    relation port_get_rel: foo/role#subject
+    permission loadbalancer_get = loadbalancer_get_rel + parent->loadbalancer_get
+    permission port_get = port_get_rel + parent->port_get
+}
+`,
+			checkFn: func(t *testing.T, diff SchemaDiff, err error) {
+				require.NoError(t, err)
+				assert.False(t, diff.Breaking())
+
+				expected := []SchemaChange{
+					{Kind: SchemaElementDefinition, Type: SchemaChangeAdded, Name: "foo/port"},
+					{Kind: SchemaElementRelation, Type: SchemaChangeModified, Name: "foo/tenant#member", Current: "foo/user | foo/client", Desired: "foo/user | foo/client | foo/user:*"},
+					{Kind: SchemaElementRelation, Type: SchemaChangeAdded, Name: "foo/tenant#port_get_rel", Desired: "foo/role#subject"},
+					{Kind: SchemaElementPermission, Type: SchemaChangeAdded, Name: "foo/tenant#port_get", Desired: "port_get_rel + parent->port_get"},
+				}
+
+				assert.Equal(t, expected, diff.Changes)
+			},
+		},
+		{
+			name: "Breaking",
+			desired: `definition foo/user {
+}
+definition foo/tenant {
+    relation parent: foo/tenant
+    relation member: foo/user
+}
+`,
+			checkFn: func(t *testing.T, diff SchemaDiff, err error) {
+				require.NoError(t, err)
+				assert.True(t, diff.Breaking())
+
+				expected := []SchemaChange{
+					{Kind: SchemaElementDefinition, Type: SchemaChangeRemoved, Name: "foo/client", Breaking: true},
+					{Kind: SchemaElementRelation, Type: SchemaChangeRemoved, Name: "foo/tenant#loadbalancer_get_rel", Current: "foo/role#subject", Breaking: true},
+					{Kind: SchemaElementRelation, Type: SchemaChangeModified, Name: "foo/tenant#member", Current: "foo/user | foo/client", Desired: "foo/user", Breaking: true},
+					{Kind: SchemaElementPermission, Type: SchemaChangeRemoved, Name: "foo/tenant#loadbalancer_get", Current: "loadbalancer_get_rel + parent->loadbalancer_get", Breaking: true},
+				}
+
+				assert.Equal(t, expected, diff.Changes)
+				assert.Len(t, diff.BreakingChanges(), 4)
+			},
+		},
+		{
+			name:    "InvalidSchema",
+			desired: "definition foo/user {\n    nonsense\n",
+			checkFn: func(t *testing.T, _ SchemaDiff, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff, err := DiffSchema(current, tc.desired)
+
+			tc.checkFn(t, diff, err)
+		})
+	}
+}
+
+func TestDiffGeneratedSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := GeneratedSchema("foo")
+
+	diff, err := DiffSchema(schema, schema)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	diff, err = DiffSchema("", schema)
+	require.NoError(t, err)
+	assert.False(t, diff.Breaking())
+	assert.False(t, diff.Empty())
+}
+
+// readSchemaFixture is the schema generated for the foo namespace as SpiceDB returns it from ReadSchema,
+// which formats the schema itself: caveats come first, empty definitions are written on one line, and
+// doc comments are kept. It also holds the schema of another namespace sharing the cluster.
+const readSchemaFixture = `/** bar_expiry lapses access */
+caveat bar/expiry(now timestamp, expires_at timestamp) {
+	now < expires_at
+}
+
+caveat foo/role_assignment_expiry(now timestamp, expires_at timestamp) {
+	now < expires_at
+}
+
+caveat foo/role_metadata(name string, description string, deleted_at string) {
+	name != ""
+}
+
+/**
+ * user is a user of another service
+ */
+definition bar/user {}
+
+definition foo/client {}
+
+definition foo/loadbalancer {
+	relation owner: foo/tenant
+	relation loadbalancer_delete_rel: foo/role#subject
+	relation loadbalancer_get_rel: foo/role#subject
+	relation loadbalancer_update_rel: foo/role#subject
+	permission loadbalancer_delete = loadbalancer_delete_rel + owner->loadbalancer_delete
+	permission loadbalancer_get = loadbalancer_get_rel + owner->loadbalancer_get
+	permission loadbalancer_update = loadbalancer_update_rel + owner->loadbalancer_update
+}
+
+definition foo/role {
+	relation owner: foo/tenant
+	relation subject: foo/user | foo/user:* | foo/user with foo/role_assignment_expiry | foo/client | foo/client:* | foo/client with foo/role_assignment_expiry | foo/role#subject
+	relation metadata: foo/role with foo/role_metadata
+}
+
+definition foo/tenant {
+	relation parent: foo/tenant
+	relation loadbalancer_create_rel: foo/role#subject
+	relation loadbalancer_delete_rel: foo/role#subject
+	relation loadbalancer_get_rel: foo/role#subject
+	relation loadbalancer_list_rel: foo/role#subject
+	relation loadbalancer_update_rel: foo/role#subject
+	permission loadbalancer_create = loadbalancer_create_rel + parent->loadbalancer_create
+	permission loadbalancer_delete = loadbalancer_delete_rel + parent->loadbalancer_delete
+	permission loadbalancer_get = loadbalancer_get_rel + parent->loadbalancer_get
+	permission loadbalancer_list = loadbalancer_list_rel + parent->loadbalancer_list
+	permission loadbalancer_update = loadbalancer_update_rel + parent->loadbalancer_update
+}
+
+definition foo/user {}`
+
+func TestDiffReadSchema(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, CheckSchema(readSchemaFixture))
+
+	schema := GeneratedSchema("foo")
+
+	// ValidateSchema diffs the live schema of the namespaces being written against the schema.
+	diff, err := DiffSchema(namespacesSchema(readSchemaFixture, schemaNamespaces(schema)), schema)
+	require.NoError(t, err)
+	assert.True(t, diff.Empty(), diff.String())
+
+	diff, err = DiffSchema(NamespaceSchema(readSchemaFixture, "bar"), schema)
+	require.NoError(t, err)
+	assert.True(t, diff.Breaking())
+}
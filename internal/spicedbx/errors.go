@@ -5,4 +5,19 @@ import "errors"
 var (
 	// ErrorNoNamespace is returned when no namespace is provided with a query
 	ErrorNoNamespace = errors.New("no namespace provided")
+
+	// ErrorInvalidSchema is returned when a schema cannot be parsed
+	ErrorInvalidSchema = errors.New("invalid schema")
+
+	// ErrorSchemaConflict is returned when a schema change conflicts with relationships stored in SpiceDB
+	ErrorSchemaConflict = errors.New("schema change conflicts with existing relationships")
+
+	// ErrorInvalidZedToken is returned when a zedtoken cannot be decoded
+	ErrorInvalidZedToken = errors.New("invalid zedtoken")
+
+	// ErrorIncomparableZedTokens is returned when the revisions of two zedtokens cannot be ordered
+	ErrorIncomparableZedTokens = errors.New("zedtokens are not comparable")
+
+	// ErrorInvalidTLSConfig is returned when the TLS settings for a SpiceDB connection cannot be used
+	ErrorInvalidTLSConfig = errors.New("invalid tls config")
 )
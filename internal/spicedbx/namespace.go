@@ -0,0 +1,151 @@
+package spicedbx
+
+import (
+	"strings"
+)
+
+// schemaBlock is a top-level caveat or definition of a schema, along with any comments preceding it.
+type schemaBlock struct {
+	name string
+	text string
+}
+
+// splitSchemaBlocks splits a schema into its top-level caveats and definitions. Like DefinitionNames,
+// it only tracks braces outside of comments, so it tolerates any formatting of the blocks' bodies.
+func splitSchemaBlocks(schema string) []schemaBlock {
+	var (
+		blocks    []schemaBlock
+		pending   []string
+		current   *schemaBlock
+		lines     []string
+		depth     int
+		opened    bool
+		inComment bool
+	)
+
+	for _, line := range strings.Split(schema, "\n") {
+		var code string
+
+		code, inComment = stripComments(line, inComment)
+
+		if current == nil {
+			fields := strings.Fields(code)
+
+			if len(fields) < 2 || (fields[0] != "definition" && fields[0] != "caveat") {
+				if strings.TrimSpace(line) != "" {
+					pending = append(pending, line)
+				}
+
+				continue
+			}
+
+			name, _, _ := strings.Cut(fields[1], "{")
+			name, _, _ = strings.Cut(name, "(")
+
+			current = &schemaBlock{name: name}
+			lines = pending
+			pending = nil
+			depth = 0
+			opened = false
+		}
+
+		lines = append(lines, line)
+
+		depth += strings.Count(code, "{") - strings.Count(code, "}")
+		opened = opened || strings.Contains(code, "{")
+
+		if opened && depth <= 0 {
+			current.text = strings.Join(lines, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+
+	if current != nil {
+		current.text = strings.Join(lines, "\n")
+		blocks = append(blocks, *current)
+	}
+
+	return blocks
+}
+
+// inNamespace returns true if the qualified name belongs to the namespace.
+func inNamespace(name, namespace string) bool {
+	return strings.HasPrefix(name, namespace+"/")
+}
+
+// schemaNamespaces returns the namespaces of the schema's caveats and definitions, in the order first seen.
+func schemaNamespaces(schema string) []string {
+	var namespaces []string
+
+	for _, block := range splitSchemaBlocks(schema) {
+		namespace, _, ok := strings.Cut(block.name, "/")
+		if !ok {
+			continue
+		}
+
+		seen := false
+
+		for _, ns := range namespaces {
+			if ns == namespace {
+				seen = true
+
+				break
+			}
+		}
+
+		if !seen {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return namespaces
+}
+
+// namespacesSchema returns the caveats and definitions of the schema which belong to any of the namespaces.
+func namespacesSchema(schema string, namespaces []string) string {
+	var parts []string
+
+	for _, namespace := range namespaces {
+		if part := NamespaceSchema(schema, namespace); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// NamespaceSchema returns the caveats and definitions of the schema which belong to the namespace,
+// dropping those of every other namespace. SpiceDB holds a single schema, so this is the part of the
+// live schema written for one namespace, suitable for diffing against GenerateSchema's output.
+func NamespaceSchema(schema, namespace string) string {
+	var parts []string
+
+	for _, block := range splitSchemaBlocks(schema) {
+		if inNamespace(block.name, namespace) {
+			parts = append(parts, block.text)
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// MergeNamespaceSchema returns the live schema with the caveats and definitions of the namespace
+// replaced by the given schema, keeping those of every other namespace. Writing a schema replaces the
+// whole of SpiceDB's schema, so writing the result, rather than the namespace's schema alone, leaves
+// the schemas written for other namespaces in place.
+func MergeNamespaceSchema(live, namespace, schema string) string {
+	var parts []string
+
+	for _, block := range splitSchemaBlocks(live) {
+		if !inNamespace(block.name, namespace) {
+			parts = append(parts, block.text)
+		}
+	}
+
+	if len(parts) == 0 {
+		return schema
+	}
+
+	return strings.Join(append(parts, schema), "\n\n")
+}
@@ -0,0 +1,65 @@
+package spicedbx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceSchema(t *testing.T) {
+	t.Parallel()
+
+	live := `caveat foo/role_assignment_expiry(now timestamp, expires_at timestamp) {
+	now < expires_at
+}
+
+/** user is a foo user */
+definition foo/user {}
+
+definition bar/user {}
+
+definition foo/tenant {
+	relation parent: foo/tenant
+	permission loadbalancer_get = parent->loadbalancer_get
+}
+
+definition bar/tenant {
+	relation parent: bar/tenant // the parent tenant
+}`
+
+	fooSchema := `caveat foo/role_assignment_expiry(now timestamp, expires_at timestamp) {
+	now < expires_at
+}
+
+/** user is a foo user */
+definition foo/user {}
+
+definition foo/tenant {
+	relation parent: foo/tenant
+	permission loadbalancer_get = parent->loadbalancer_get
+}`
+
+	barSchema := `definition bar/user {}
+
+definition bar/tenant {
+	relation parent: bar/tenant // the parent tenant
+}`
+
+	assert.Equal(t, fooSchema, NamespaceSchema(live, "foo"))
+	assert.Equal(t, barSchema, NamespaceSchema(live, "bar"))
+	assert.Empty(t, NamespaceSchema(live, "fo"))
+	assert.Empty(t, NamespaceSchema("", "foo"))
+
+	merged := MergeNamespaceSchema(live, "foo", "definition foo/user {}")
+
+	assert.Equal(t, barSchema+"\n\ndefinition foo/user {}", merged)
+	assert.Equal(t, barSchema, NamespaceSchema(merged, "bar"))
+	assert.Equal(t, "definition foo/user {}", NamespaceSchema(merged, "foo"))
+
+	// With nothing from other namespaces, the schema is written as given.
+	assert.Equal(t, "definition foo/user {}", MergeNamespaceSchema(fooSchema, "foo", "definition foo/user {}"))
+	assert.Equal(t, "definition foo/user {}", MergeNamespaceSchema("", "foo", "definition foo/user {}"))
+
+	assert.Equal(t, []string{"foo", "bar"}, schemaNamespaces(live))
+	assert.Equal(t, barSchema, namespacesSchema(live, schemaNamespaces(barSchema)))
+}
@@ -0,0 +1,257 @@
+package spicedbx
+
+import (
+	"fmt"
+	"strings"
+)
+
+type schemaDefinition struct {
+	line        int
+	relations   map[string]string
+	permissions map[string]string
+	// lines holds the line number of each relation and permission.
+	lines map[string]int
+}
+
+func newSchemaDefinition() *schemaDefinition {
+	return &schemaDefinition{
+		relations:   make(map[string]string),
+		permissions: make(map[string]string),
+		lines:       make(map[string]int),
+	}
+}
+
+// hasMember returns true if the definition has a relation or permission with the given name.
+func (d *schemaDefinition) hasMember(name string) bool {
+	_, ok := d.lines[name]
+
+	return ok
+}
+
+type parsedSchema struct {
+	caveats     map[string]string
+	definitions map[string]*schemaDefinition
+}
+
+// parseSchema parses the subset of the SpiceDB schema language produced by
+// GenerateSchema and by SpiceDB's ReadSchema: caveat and definition blocks
+// containing relation and permission lines, with line and block comments.
+// A definition with no relations or permissions may be written on one line.
+func parseSchema(schema string) (parsedSchema, error) {
+	out := parsedSchema{
+		caveats:     make(map[string]string),
+		definitions: make(map[string]*schemaDefinition),
+	}
+
+	var (
+		definition *schemaDefinition
+		caveatName string
+		caveatBody []string
+		caveatLine int
+		inCaveat   bool
+		inComment  bool
+	)
+
+	for i, line := range strings.Split(schema, "\n") {
+		lineNum := i + 1
+
+		line, inComment = stripComments(line, inComment)
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case inCaveat:
+			if line == "}" {
+				if len(caveatBody) == 1 {
+					return parsedSchema{}, fmt.Errorf("%w: line %d: caveat %s has no expression", ErrorInvalidSchema, caveatLine, caveatName)
+				}
+
+				out.caveats[caveatName] = strings.Join(caveatBody, " ")
+				inCaveat = false
+
+				continue
+			}
+
+			caveatBody = append(caveatBody, line)
+		case definition != nil:
+			if line == "}" {
+				definition = nil
+
+				continue
+			}
+
+			keyword, rest, _ := strings.Cut(line, " ")
+
+			var (
+				name, expr string
+				ok         bool
+			)
+
+			switch keyword {
+			case "relation":
+				name, expr, ok = strings.Cut(rest, ":")
+				if !ok {
+					return parsedSchema{}, fmt.Errorf("%w: line %d: expected relation name: types", ErrorInvalidSchema, lineNum)
+				}
+			case "permission":
+				name, expr, ok = strings.Cut(rest, "=")
+				if !ok {
+					return parsedSchema{}, fmt.Errorf("%w: line %d: expected permission name = expression", ErrorInvalidSchema, lineNum)
+				}
+			default:
+				return parsedSchema{}, fmt.Errorf("%w: line %d: unexpected %q in definition", ErrorInvalidSchema, lineNum, keyword)
+			}
+
+			name = strings.TrimSpace(name)
+			expr = normalizeExpr(expr)
+
+			if !validIdentifier(name) {
+				return parsedSchema{}, fmt.Errorf("%w: line %d: invalid %s name %q", ErrorInvalidSchema, lineNum, keyword, name)
+			}
+
+			if expr == "" {
+				return parsedSchema{}, fmt.Errorf("%w: line %d: %s %s is empty", ErrorInvalidSchema, lineNum, keyword, name)
+			}
+
+			if prev, ok := definition.lines[name]; ok {
+				return parsedSchema{}, fmt.Errorf("%w: line %d: %s redeclared, first declared on line %d", ErrorInvalidSchema, lineNum, name, prev)
+			}
+
+			definition.lines[name] = lineNum
+
+			if keyword == "relation" {
+				definition.relations[name] = expr
+			} else {
+				definition.permissions[name] = expr
+			}
+		case strings.HasPrefix(line, "definition ") && strings.Contains(line, "{"):
+			header, body, _ := strings.Cut(line, "{")
+			name := strings.TrimSpace(strings.TrimPrefix(header, "definition "))
+
+			if _, ok := out.definitions[name]; ok {
+				return parsedSchema{}, fmt.Errorf("%w: line %d: duplicate definition %s", ErrorInvalidSchema, lineNum, name)
+			}
+
+			definition = newSchemaDefinition()
+			definition.line = lineNum
+			out.definitions[name] = definition
+
+			switch strings.TrimSpace(body) {
+			case "":
+			case "}":
+				// An empty definition, as SpiceDB writes them, opens and closes on one line.
+				definition = nil
+			default:
+				return parsedSchema{}, fmt.Errorf("%w: line %d: unexpected %q after definition %s", ErrorInvalidSchema, lineNum, strings.TrimSpace(body), name)
+			}
+		case strings.HasPrefix(line, "caveat ") && strings.HasSuffix(line, "{"):
+			signature := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "caveat "), "{"))
+
+			name, _, _ := strings.Cut(signature, "(")
+			caveatName = strings.TrimSpace(name)
+			caveatBody = []string{normalizeExpr(signature)}
+			caveatLine = lineNum
+			inCaveat = true
+
+			if _, ok := out.caveats[caveatName]; ok {
+				return parsedSchema{}, fmt.Errorf("%w: line %d: duplicate caveat %s", ErrorInvalidSchema, lineNum, caveatName)
+			}
+		default:
+			return parsedSchema{}, fmt.Errorf("%w: line %d: unexpected %q", ErrorInvalidSchema, lineNum, line)
+		}
+	}
+
+	if definition != nil || inCaveat || inComment {
+		return parsedSchema{}, fmt.Errorf("%w: unterminated block", ErrorInvalidSchema)
+	}
+
+	return out, nil
+}
+
+// stripComments returns the code of the line without its comments. inComment is whether the line
+// starts inside a block comment, and the returned bool is whether the next line does.
+func stripComments(line string, inComment bool) (string, bool) {
+	var code strings.Builder
+
+	for line != "" {
+		if inComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				return code.String(), true
+			}
+
+			line = line[end+2:]
+			inComment = false
+
+			continue
+		}
+
+		lineIdx := strings.Index(line, "//")
+		blockIdx := strings.Index(line, "/*")
+
+		switch {
+		case blockIdx >= 0 && (lineIdx < 0 || blockIdx < lineIdx):
+			code.WriteString(line[:blockIdx])
+			code.WriteString(" ")
+
+			line = line[blockIdx+2:]
+			inComment = true
+		case lineIdx >= 0:
+			code.WriteString(line[:lineIdx])
+
+			return code.String(), false
+		default:
+			code.WriteString(line)
+
+			return code.String(), false
+		}
+	}
+
+	return code.String(), inComment
+}
+
+// DefinitionNames returns the names of the object definitions in the schema, as qualified in the
+// schema. Unlike parsing the schema, it tolerates any formatting of the definitions' bodies, so it
+// may be used on schemas read from SpiceDB.
+func DefinitionNames(schema string) []string {
+	var names []string
+
+	for _, line := range strings.Split(schema, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) < 2 || fields[0] != "definition" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(fields[1], "{")
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// normalizeExpr collapses whitespace so formatting differences are not
+// reported as changes.
+func normalizeExpr(expr string) string {
+	return strings.Join(strings.Fields(expr), " ")
+}
+
+// validIdentifier returns true if name is a valid relation or permission name.
+func validIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,159 @@
+package spicedbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/authzed-go/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ValidateSchema checks that the schema can be written to SpiceDB without writing it.
+// SpiceDB has no dry-run for WriteSchema, so the schema is checked client-side with
+// CheckSchema, then compared against the live schema: removing a definition or relation,
+// or a subject type from a relation, is rejected by SpiceDB while relationships using it
+// still exist, so each such change is checked for remaining relationships.
+func ValidateSchema(ctx context.Context, client *authzed.Client, schema string) error {
+	if err := CheckSchema(schema); err != nil {
+		return err
+	}
+
+	resp, err := client.ReadSchema(ctx, &v1.ReadSchemaRequest{})
+
+	switch {
+	case status.Code(err) == codes.NotFound:
+		// Nothing has been written yet, so there is nothing the schema can conflict with.
+		return nil
+	case err != nil:
+		return err
+	}
+
+	// Definitions of namespaces the schema does not cover are kept when it is written.
+	diff, err := DiffSchema(namespacesSchema(resp.SchemaText, schemaNamespaces(schema)), schema)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, change := range diff.BreakingChanges() {
+		for _, filter := range ConflictFilters(change) {
+			inUse, err := relationshipsExist(ctx, client, filter)
+			if err != nil {
+				return err
+			}
+
+			if inUse {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrorSchemaConflict, change))
+
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ConflictFilters returns the filters matching relationships which would prevent the change from being
+// written. Only breaking changes have conflicting relationships.
+func ConflictFilters(change SchemaChange) []*v1.RelationshipFilter {
+	switch change.Kind {
+	case SchemaElementDefinition:
+		return []*v1.RelationshipFilter{
+			{
+				ResourceType: change.Name,
+			},
+		}
+	case SchemaElementRelation:
+		resourceType, relation, _ := strings.Cut(change.Name, "#")
+
+		if change.Type == SchemaChangeRemoved {
+			return []*v1.RelationshipFilter{
+				{
+					ResourceType:     resourceType,
+					OptionalRelation: relation,
+				},
+			}
+		}
+
+		allowed := make(map[string]struct{})
+
+		for _, subjectType := range splitSubjectTypes(change.Desired) {
+			allowed[subjectType] = struct{}{}
+		}
+
+		var filters []*v1.RelationshipFilter
+
+		for _, subjectType := range splitSubjectTypes(change.Current) {
+			if _, ok := allowed[subjectType]; ok {
+				continue
+			}
+
+			filters = append(filters, &v1.RelationshipFilter{
+				ResourceType:          resourceType,
+				OptionalRelation:      relation,
+				OptionalSubjectFilter: subjectTypeFilter(subjectType),
+			})
+		}
+
+		return filters
+	}
+
+	return nil
+}
+
+// subjectTypeFilter returns a filter matching subjects of an allowed subject type, such as
+// ns/user, ns/user:* or ns/role#subject. Caveats are not part of a filter, so a caveated
+// subject type matches all subjects of the type.
+func subjectTypeFilter(subjectType string) *v1.SubjectFilter {
+	typeName, _, _ := strings.Cut(subjectType, " with ")
+	typeName = strings.TrimSpace(typeName)
+
+	filter := &v1.SubjectFilter{}
+
+	if strings.HasSuffix(typeName, ":*") {
+		typeName = strings.TrimSuffix(typeName, ":*")
+		filter.OptionalSubjectId = "*"
+	}
+
+	typeName, relation, _ := strings.Cut(typeName, "#")
+
+	filter.SubjectType = typeName
+	filter.OptionalRelation = &v1.SubjectFilter_RelationFilter{
+		Relation: relation,
+	}
+
+	return filter
+}
+
+// relationshipsExist returns true if any relationship matches the filter.
+func relationshipsExist(ctx context.Context, client *authzed.Client, filter *v1.RelationshipFilter) (bool, error) {
+	stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{
+			Requirement: &v1.Consistency_FullyConsistent{
+				FullyConsistent: true,
+			},
+		},
+		RelationshipFilter: filter,
+		OptionalLimit:      1,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = stream.Recv()
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, io.EOF):
+		return false, nil
+	default:
+		return false, err
+	}
+}
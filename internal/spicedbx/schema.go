@@ -2,6 +2,8 @@ package spicedbx
 
 import (
 	"bytes"
+	"sort"
+	"strings"
 	"text/template"
 
 	"go.infratographer.com/permissions-api/internal/iapl"
@@ -9,28 +11,53 @@ import (
 )
 
 var (
-	schemaTemplate = template.Must(template.New("schema").Parse(`
+	schemaTemplate = template.Must(template.New("schema").Funcs(template.FuncMap{
+		"isSubjectSet": func(typeName string) bool {
+			return strings.Contains(typeName, "#")
+		},
+	}).Parse(`
 {{- $namespace := .Namespace -}}
+{{- range .Caveats -}}
+caveat {{$namespace}}/{{.Name}}({{ range $index, $param := .Parameters }}{{ if $index }}, {{end}}{{$param.Name}} {{$param.Type}}{{ end }}) {
+    {{.Expression}}
+}
+{{ end -}}
 {{- range .ResourceTypes -}}
+{{- $typeIsRole := eq .Name "role" -}}
+{{ if $typeIsRole -}}
+caveat {{$namespace}}/role_assignment_expiry(now timestamp, expires_at timestamp) {
+    now < expires_at
+}
+caveat {{$namespace}}/role_metadata(name string, description string, deleted_at string) {
+    name != ""
+}
+{{ end -}}
 definition {{$namespace}}/{{.Name}} {
-{{- range .Relationships }}
-    relation {{.Relation}}: {{ range $index, $typeName := .Types -}}{{ if $index }} | {{end}}{{$namespace}}/{{$typeName}}{{- end }}
+{{- range $rel := .Relationships }}
+{{- $expires := and $typeIsRole (eq $rel.Relation "subject") }}
+    relation {{.Relation}}: {{ range $index, $typeName := .Types -}}{{ if $index }} | {{end}}{{$namespace}}/{{$typeName}}{{ if $rel.Caveat }} | {{$namespace}}/{{$typeName}} with {{$namespace}}/{{$rel.Caveat}}{{ end }}{{ if and $rel.Wildcard (not (isSubjectSet $typeName)) }} | {{$namespace}}/{{$typeName}}:*{{ end }}{{ if and $expires (not (isSubjectSet $typeName)) }} | {{$namespace}}/{{$typeName}} with {{$namespace}}/role_assignment_expiry{{ end }}{{- end }}
+{{- end }}
+
+{{- if eq .Name "role" }}
+    relation metadata: {{$namespace}}/role with {{$namespace}}/role_metadata
 {{- end }}
 
 {{- range .Actions }}
-    relation {{.Name}}_rel: {{ $namespace }}/role#subject
+    relation {{.Name}}_rel: {{ $namespace }}/role#subject{{ range .Conditions }}{{ if .RoleBinding }}{{ if .RoleBinding.Caveat }} | {{ $namespace }}/role#subject with {{ $namespace }}/{{ .RoleBinding.Caveat }}{{ end }}{{ end }}{{ end }}
 {{- end }}
 
 {{- range .Actions }}
 {{- $actionName := .Name }}
-    permission {{ $actionName }} = {{ range $index, $cond := .Conditions -}}{{ if $index }} + {{end}}{{ if $cond.RoleBinding }}{{ $actionName }}_rel{{ end }}{{ if $cond.RelationshipAction }}{{ $cond.RelationshipAction.Relation}}->{{ $cond.RelationshipAction.ActionName }}{{ end }}{{- end }}
+    permission {{ $actionName }} = {{ if .ExcludedRelations }}({{ end }}{{ range $index, $cond := .Conditions -}}{{ if $index }} + {{end}}{{ if $cond.RoleBinding }}{{ $actionName }}_rel{{ end }}{{ if $cond.RelationshipAction }}{{ $cond.RelationshipAction.Relation}}->{{ $cond.RelationshipAction.ActionName }}{{ end }}{{- end }}{{ if .ExcludedRelations }}){{ range .ExcludedRelations }} - {{ . }}{{ end }}{{ end }}
 {{- end }}
 }
 {{end}}`))
 )
 
-// GenerateSchema generates the spicedb schema from the template
-func GenerateSchema(namespace string, resourceTypes []types.ResourceType) (string, error) {
+// GenerateSchema generates the spicedb schema from the template. Resource types,
+// relations, actions and caveats are sorted by name so the same input always
+// produces the same output.
+func GenerateSchema(namespace string, resourceTypes []types.ResourceType, caveats ...types.Caveat) (string, error) {
 	if namespace == "" {
 		return "", ErrorNoNamespace
 	}
@@ -38,10 +65,12 @@ func GenerateSchema(namespace string, resourceTypes []types.ResourceType) (strin
 	var data struct {
 		Namespace     string
 		ResourceTypes []types.ResourceType
+		Caveats       []types.Caveat
 	}
 
 	data.Namespace = namespace
-	data.ResourceTypes = resourceTypes
+	data.ResourceTypes = sortResourceTypes(resourceTypes)
+	data.Caveats = sortCaveats(caveats)
 
 	var out bytes.Buffer
 
@@ -53,11 +82,49 @@ func GenerateSchema(namespace string, resourceTypes []types.ResourceType) (strin
 	return out.String(), nil
 }
 
+// sortResourceTypes returns a copy of the given resource types sorted by name,
+// with each type's relationships and actions also sorted by name.
+func sortResourceTypes(resourceTypes []types.ResourceType) []types.ResourceType {
+	out := make([]types.ResourceType, len(resourceTypes))
+
+	for i, rt := range resourceTypes {
+		rt.Relationships = append([]types.ResourceTypeRelationship(nil), rt.Relationships...)
+		rt.Actions = append([]types.Action(nil), rt.Actions...)
+
+		sort.SliceStable(rt.Relationships, func(i, j int) bool {
+			return rt.Relationships[i].Relation < rt.Relationships[j].Relation
+		})
+
+		sort.SliceStable(rt.Actions, func(i, j int) bool {
+			return rt.Actions[i].Name < rt.Actions[j].Name
+		})
+
+		out[i] = rt
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// sortCaveats returns a copy of the given caveats sorted by name.
+func sortCaveats(caveats []types.Caveat) []types.Caveat {
+	out := append([]types.Caveat(nil), caveats...)
+
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
 // GeneratedSchema produces a namespaced SpiceDB schema based on the default IAPL policy.
 func GeneratedSchema(namespace string) string {
 	policy := iapl.DefaultPolicy()
 
-	schema, err := GenerateSchema(namespace, policy.Schema())
+	schema, err := GenerateSchema(namespace, policy.Schema(), policy.Caveats()...)
 	if err != nil {
 		panic(err)
 	}
@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"go.infratographer.com/permissions-api/internal/iapl"
 	"go.infratographer.com/permissions-api/internal/types"
 )
 
@@ -14,6 +16,7 @@ func TestSchema(t *testing.T) {
 	type testInput struct {
 		namespace     string
 		resourceTypes []types.ResourceType
+		caveats       []types.Caveat
 	}
 
 	type testResult struct {
@@ -42,7 +45,9 @@ func TestSchema(t *testing.T) {
 					Types: []string{
 						"user",
 						"client",
+						"role#subject",
 					},
+					Wildcard: true,
 				},
 			},
 		},
@@ -171,12 +176,27 @@ func TestSchema(t *testing.T) {
 		},
 	}
 
-	schemaOutput := `definition foo/user {
+	schemaOutput := `definition foo/client {
 }
-definition foo/client {
+definition foo/loadbalancer {
+    relation owner: foo/tenant
+    relation loadbalancer_get_rel: foo/role#subject
+    permission loadbalancer_get = loadbalancer_get_rel + owner->loadbalancer_get
+}
+definition foo/port {
+    relation owner: foo/tenant
+    relation port_get_rel: foo/role#subject
+    permission port_get = port_get_rel + owner->port_get
+}
+caveat foo/role_assignment_expiry(now timestamp, expires_at timestamp) {
+    now < expires_at
+}
+caveat foo/role_metadata(name string, description string, deleted_at string) {
+    name != ""
 }
 definition foo/role {
-    relation subject: foo/user | foo/client
+    relation subject: foo/user | foo/user:* | foo/user with foo/role_assignment_expiry | foo/client | foo/client:* | foo/client with foo/role_assignment_expiry | foo/role#subject
+    relation metadata: foo/role with foo/role_metadata
 }
 definition foo/tenant {
     relation parent: foo/tenant
@@ -189,15 +209,165 @@ definition foo/tenant {
     permission port_create = port_create_rel + parent->port_create
     permission port_get = port_get_rel + parent->port_get
 }
-definition foo/loadbalancer {
-    relation owner: foo/tenant
-    relation loadbalancer_get_rel: foo/role#subject
-    permission loadbalancer_get = loadbalancer_get_rel + owner->loadbalancer_get
+definition foo/user {
 }
-definition foo/port {
-    relation owner: foo/tenant
-    relation port_get_rel: foo/role#subject
-    permission port_get = port_get_rel + owner->port_get
+`
+
+	caveatResourceTypes := []types.ResourceType{
+		{
+			Name: "user",
+		},
+		{
+			Name: "tenant",
+			Relationships: []types.ResourceTypeRelationship{
+				{
+					Relation: "member",
+					Types: []string{
+						"user",
+					},
+					Caveat: "ip_allowlist",
+				},
+			},
+		},
+	}
+
+	caveats := []types.Caveat{
+		{
+			Name: "ip_allowlist",
+			Parameters: []types.CaveatParameter{
+				{
+					Name: "allowed",
+					Type: "list<ipaddress>",
+				},
+				{
+					Name: "ip",
+					Type: "ipaddress",
+				},
+			},
+			Expression: "ip in allowed",
+		},
+	}
+
+	caveatSchemaOutput := `caveat foo/ip_allowlist(allowed list<ipaddress>, ip ipaddress) {
+    ip in allowed
+}
+definition foo/tenant {
+    relation member: foo/user | foo/user with foo/ip_allowlist
+}
+definition foo/user {
+}
+`
+
+	wildcardResourceTypes := []types.ResourceType{
+		{
+			Name: "user",
+		},
+		{
+			Name: "document",
+			Relationships: []types.ResourceTypeRelationship{
+				{
+					Relation: "viewer",
+					Types: []string{
+						"user",
+					},
+					Wildcard: true,
+				},
+			},
+		},
+	}
+
+	wildcardSchemaOutput := `definition foo/document {
+    relation viewer: foo/user | foo/user:*
+}
+definition foo/user {
+}
+`
+
+	exclusionResourceTypes := []types.ResourceType{
+		{
+			Name: "user",
+		},
+		{
+			Name: "document",
+			Relationships: []types.ResourceTypeRelationship{
+				{
+					Relation: "editor",
+					Types: []string{
+						"user",
+					},
+				},
+				{
+					Relation: "banned",
+					Types: []string{
+						"user",
+					},
+				},
+			},
+			Actions: []types.Action{
+				{
+					Name: "document_edit",
+					Conditions: []types.Condition{
+						{
+							RoleBinding: &types.ConditionRoleBinding{},
+						},
+						{
+							RelationshipAction: &types.ConditionRelationshipAction{
+								Relation:   "editor",
+								ActionName: "document_edit",
+							},
+						},
+					},
+					ExcludedRelations: []string{
+						"banned",
+					},
+				},
+			},
+		},
+	}
+
+	exclusionSchemaOutput := `definition foo/document {
+    relation banned: foo/user
+    relation editor: foo/user
+    relation document_edit_rel: foo/role#subject
+    permission document_edit = (document_edit_rel + editor->document_edit) - banned
+}
+definition foo/user {
+}
+`
+
+	multiTargetResourceTypes := []types.ResourceType{
+		{
+			Name: "group",
+		},
+		{
+			Name: "org",
+		},
+		{
+			Name: "tenant",
+		},
+		{
+			Name: "project",
+			Relationships: []types.ResourceTypeRelationship{
+				{
+					Relation: "parent",
+					Types: []string{
+						"tenant",
+						"group",
+						"org",
+					},
+				},
+			},
+		},
+	}
+
+	multiTargetSchemaOutput := `definition foo/group {
+}
+definition foo/org {
+}
+definition foo/project {
+    relation parent: foo/tenant | foo/group | foo/org
+}
+definition foo/tenant {
 }
 `
 
@@ -224,6 +394,51 @@ definition foo/port {
 				assert.Equal(t, schemaOutput, res.success)
 			},
 		},
+		{
+			name: "SuccessCaveats",
+			input: testInput{
+				namespace:     "foo",
+				resourceTypes: caveatResourceTypes,
+				caveats:       caveats,
+			},
+			checkFn: func(t *testing.T, res testResult) {
+				assert.NoError(t, res.err)
+				assert.Equal(t, caveatSchemaOutput, res.success)
+			},
+		},
+		{
+			name: "SuccessExclusion",
+			input: testInput{
+				namespace:     "foo",
+				resourceTypes: exclusionResourceTypes,
+			},
+			checkFn: func(t *testing.T, res testResult) {
+				assert.NoError(t, res.err)
+				assert.Equal(t, exclusionSchemaOutput, res.success)
+			},
+		},
+		{
+			name: "SuccessWildcard",
+			input: testInput{
+				namespace:     "foo",
+				resourceTypes: wildcardResourceTypes,
+			},
+			checkFn: func(t *testing.T, res testResult) {
+				assert.NoError(t, res.err)
+				assert.Equal(t, wildcardSchemaOutput, res.success)
+			},
+		},
+		{
+			name: "SuccessMultipleTargetTypes",
+			input: testInput{
+				namespace:     "foo",
+				resourceTypes: multiTargetResourceTypes,
+			},
+			checkFn: func(t *testing.T, res testResult) {
+				assert.NoError(t, res.err)
+				assert.Equal(t, multiTargetSchemaOutput, res.success)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -233,9 +448,57 @@ definition foo/port {
 
 			var result testResult
 
-			result.success, result.err = GenerateSchema(tc.input.namespace, tc.input.resourceTypes)
+			result.success, result.err = GenerateSchema(tc.input.namespace, tc.input.resourceTypes, tc.input.caveats...)
 
 			tc.checkFn(t, result)
 		})
 	}
 }
+
+func TestSchemaDeterministic(t *testing.T) {
+	t.Parallel()
+
+	policy := iapl.DefaultPolicy()
+
+	first, err := GenerateSchema("foo", policy.Schema(), policy.Caveats()...)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		regenerated := iapl.DefaultPolicy()
+
+		next, genErr := GenerateSchema("foo", regenerated.Schema(), regenerated.Caveats()...)
+		require.NoError(t, genErr)
+
+		assert.Equal(t, first, next)
+	}
+
+	reversed := policy.Schema()
+
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	next, err := GenerateSchema("foo", reversed, policy.Caveats()...)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, next)
+}
+
+func TestDefinitionNames(t *testing.T) {
+	t.Parallel()
+
+	policy := iapl.DefaultPolicy()
+
+	schema, err := GenerateSchema("foo", policy.Schema(), policy.Caveats()...)
+	require.NoError(t, err)
+
+	var expected []string
+
+	for _, rt := range policy.Schema() {
+		expected = append(expected, "foo/"+rt.Name)
+	}
+
+	assert.ElementsMatch(t, expected, DefinitionNames(schema))
+
+	assert.Equal(t, []string{"foo/user", "foo/tenant"}, DefinitionNames("definition foo/user {}\n\ndefinition foo/tenant {\n\trelation parent: foo/tenant\n}"))
+}
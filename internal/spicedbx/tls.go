@@ -0,0 +1,63 @@
+package spicedbx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig returns the TLS config for a secure connection described by the config. The server is
+// verified against the CA bundle at CAPath, or the system trust store if none is given, unless
+// VerifyCA is false. A client certificate is presented for mTLS when CertPath and KeyPath are given,
+// and ServerName overrides the name the server's certificate is verified against. Since CAPath and
+// ServerName only apply to verification, giving either without VerifyCA is an error rather than being
+// silently ignored.
+func TLSConfig(cfg Config) (*tls.Config, error) {
+	if !cfg.VerifyCA && (cfg.CAPath != "" || cfg.ServerName != "") {
+		return nil, fmt.Errorf("%w: a CA bundle or server name requires verifyca", ErrorInvalidTLSConfig)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: !cfg.VerifyCA, //nolint:gosec // verification is only skipped if VerifyCA is unset
+	}
+
+	if cfg.CAPath != "" {
+		caPEM, err := os.ReadFile(cfg.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read CA bundle: %w", ErrorInvalidTLSConfig, err)
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%w: no certificates found in CA bundle %s", ErrorInvalidTLSConfig, cfg.CAPath)
+		}
+
+		tlsConfig.RootCAs = pool
+	} else {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system certificates: %w", err)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertPath != "" || cfg.KeyPath != "" {
+		if cfg.CertPath == "" || cfg.KeyPath == "" {
+			return nil, fmt.Errorf("%w: client certificate and key must be given together", ErrorInvalidTLSConfig)
+		}
+
+		cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to load client certificate: %w", ErrorInvalidTLSConfig, err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
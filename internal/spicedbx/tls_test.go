@@ -0,0 +1,150 @@
+package spicedbx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate and its key to dir, returning their paths.
+func writeTestCert(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "spicedb.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certPath, keyPath
+}
+
+func TestTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certPath, keyPath := writeTestCert(t, dir)
+
+	invalidPath := filepath.Join(dir, "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("not a certificate"), 0o600))
+
+	testCases := []struct {
+		name    string
+		cfg     Config
+		err     error
+		checkFn func(*testing.T, *tls.Config)
+	}{
+		{
+			name: "SystemTrustStore",
+			cfg:  Config{VerifyCA: true},
+			checkFn: func(t *testing.T, c *tls.Config) {
+				assert.False(t, c.InsecureSkipVerify)
+				assert.NotNil(t, c.RootCAs)
+				assert.Empty(t, c.Certificates)
+			},
+		},
+		{
+			name: "SkipVerify",
+			cfg:  Config{},
+			checkFn: func(t *testing.T, c *tls.Config) {
+				assert.True(t, c.InsecureSkipVerify)
+			},
+		},
+		{
+			name: "CAWithoutVerify",
+			cfg:  Config{CAPath: certPath},
+			err:  ErrorInvalidTLSConfig,
+		},
+		{
+			name: "ServerNameWithoutVerify",
+			cfg:  Config{ServerName: "spicedb.internal"},
+			err:  ErrorInvalidTLSConfig,
+		},
+		{
+			name: "CustomCA",
+			cfg:  Config{VerifyCA: true, CAPath: certPath, ServerName: "spicedb.internal"},
+			checkFn: func(t *testing.T, c *tls.Config) {
+				assert.False(t, c.InsecureSkipVerify)
+				assert.Equal(t, "spicedb.internal", c.ServerName)
+				require.NotNil(t, c.RootCAs)
+				assert.False(t, c.RootCAs.Equal(x509.NewCertPool()))
+			},
+		},
+		{
+			name: "MissingCA",
+			cfg:  Config{VerifyCA: true, CAPath: filepath.Join(dir, "missing.pem")},
+			err:  ErrorInvalidTLSConfig,
+		},
+		{
+			name: "InvalidCA",
+			cfg:  Config{VerifyCA: true, CAPath: invalidPath},
+			err:  ErrorInvalidTLSConfig,
+		},
+		{
+			name: "ClientCertificate",
+			cfg:  Config{VerifyCA: true, CAPath: certPath, CertPath: certPath, KeyPath: keyPath},
+			checkFn: func(t *testing.T, c *tls.Config) {
+				assert.Len(t, c.Certificates, 1)
+			},
+		},
+		{
+			name: "CertificateWithoutKey",
+			cfg:  Config{VerifyCA: true, CertPath: certPath},
+			err:  ErrorInvalidTLSConfig,
+		},
+		{
+			name: "InvalidKey",
+			cfg:  Config{VerifyCA: true, CertPath: certPath, KeyPath: invalidPath},
+			err:  ErrorInvalidTLSConfig,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := TLSConfig(tc.cfg)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			tc.checkFn(t, c)
+		})
+	}
+}
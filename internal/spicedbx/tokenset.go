@@ -0,0 +1,65 @@
+package spicedbx
+
+import "sync"
+
+// TokenSet accumulates the query tokens of concurrent operations, such as writes fanned out in parallel,
+// to find the freshest to read against once they are done. The zero value is an empty set, and a
+// TokenSet may be used by multiple goroutines at once. It must not be copied after first use.
+type TokenSet struct {
+	mu       sync.Mutex
+	freshest ZedToken
+	err      error
+}
+
+// Add records the given query token. Empty tokens, as returned by operations which wrote nothing, are
+// ignored. A token which cannot be parsed, or compared with those already recorded, is reported by Freshest.
+func (s *TokenSet) Add(token string) {
+	if token == "" {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return
+	}
+
+	parsed, err := ParseZedToken(token)
+	if err != nil {
+		s.err = err
+
+		return
+	}
+
+	if s.freshest.token == "" {
+		s.freshest = parsed
+
+		return
+	}
+
+	cmp, err := parsed.Compare(s.freshest)
+	if err != nil {
+		s.err = err
+
+		return
+	}
+
+	if cmp > 0 {
+		s.freshest = parsed
+	}
+}
+
+// Freshest returns the freshest query token recorded, which is empty if none were. An error is returned
+// if any token could not be parsed or compared, see ZedToken.Compare for which tokens are comparable,
+// as the freshest token is then unknown.
+func (s *TokenSet) Freshest() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return "", s.err
+	}
+
+	return s.freshest.token, nil
+}
@@ -0,0 +1,95 @@
+package spicedbx
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenSet(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		tokens   []string
+		freshest string
+		err      error
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:     "EmptyTokensIgnored",
+			tokens:   []string{"", encodeZedToken("5"), ""},
+			freshest: encodeZedToken("5"),
+		},
+		{
+			name:     "Freshest",
+			tokens:   []string{encodeZedToken("999"), encodeZedToken("1001"), encodeZedToken("1000")},
+			freshest: encodeZedToken("1001"),
+		},
+		{
+			name:   "Invalid",
+			tokens: []string{encodeZedToken("1"), "not a token!"},
+			err:    ErrorInvalidZedToken,
+		},
+		{
+			name:   "Incomparable",
+			tokens: []string{encodeZedToken("1671553657000000000"), encodeZedToken("CAESAggB")},
+			err:    ErrorIncomparableZedTokens,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var set TokenSet
+
+			for _, token := range tc.tokens {
+				set.Add(token)
+			}
+
+			freshest, err := set.Freshest()
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.freshest, freshest)
+		})
+	}
+}
+
+func TestTokenSetConcurrent(t *testing.T) {
+	t.Parallel()
+
+	var (
+		set TokenSet
+		wg  sync.WaitGroup
+	)
+
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+
+		go func(revision int) {
+			defer wg.Done()
+
+			set.Add(encodeZedToken(strconv.Itoa(revision)))
+		}(i)
+	}
+
+	wg.Wait()
+
+	freshest, err := set.Freshest()
+	require.NoError(t, err)
+	assert.Equal(t, encodeZedToken("100"), freshest)
+}
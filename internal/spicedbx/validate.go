@@ -0,0 +1,148 @@
+package spicedbx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckSchema parses the schema client-side and checks that every type, relation, permission
+// and caveat it references is declared. Errors report the line they were found on.
+// This does not require a SpiceDB connection, so it can be used in CI to catch a bad policy.
+func CheckSchema(schema string) error {
+	parsed, err := parseSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	type lineError struct {
+		line int
+		err  error
+	}
+
+	var lineErrors []lineError
+
+	for defName, def := range parsed.definitions {
+		for name, expr := range def.relations {
+			for _, subjectType := range splitSubjectTypes(expr) {
+				if err := parsed.checkSubjectType(subjectType); err != nil {
+					lineErrors = append(lineErrors, lineError{
+						line: def.lines[name],
+						err:  fmt.Errorf("%w: line %d: relation %s#%s: %s", ErrorInvalidSchema, def.lines[name], defName, name, err),
+					})
+				}
+			}
+		}
+
+		for name, expr := range def.permissions {
+			for _, term := range permissionTerms(expr) {
+				if err := parsed.checkPermissionTerm(def, term); err != nil {
+					lineErrors = append(lineErrors, lineError{
+						line: def.lines[name],
+						err:  fmt.Errorf("%w: line %d: permission %s#%s: %s", ErrorInvalidSchema, def.lines[name], defName, name, err),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(lineErrors, func(i, j int) bool {
+		return lineErrors[i].line < lineErrors[j].line
+	})
+
+	errs := make([]error, len(lineErrors))
+
+	for i, lineErr := range lineErrors {
+		errs[i] = lineErr.err
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkSubjectType checks a single allowed subject type of a relation, such as ns/user,
+// ns/user:*, ns/role#subject or ns/user with ns/caveat.
+func (s parsedSchema) checkSubjectType(subjectType string) error {
+	typeName, caveat, hasCaveat := strings.Cut(subjectType, " with ")
+	if hasCaveat {
+		if _, ok := s.caveats[strings.TrimSpace(caveat)]; !ok {
+			return fmt.Errorf("unknown caveat %s", caveat)
+		}
+	}
+
+	typeName = strings.TrimSuffix(strings.TrimSpace(typeName), ":*")
+	typeName, relation, hasRelation := strings.Cut(typeName, "#")
+
+	def, ok := s.definitions[typeName]
+	if !ok {
+		return fmt.Errorf("unknown type %s", typeName)
+	}
+
+	if hasRelation && !def.hasMember(relation) {
+		return fmt.Errorf("type %s has no relation or permission %s", typeName, relation)
+	}
+
+	return nil
+}
+
+// checkPermissionTerm checks a single term of a permission expression: nil, a relation or
+// permission of the definition, or an arrow from one of the definition's relations.
+func (s parsedSchema) checkPermissionTerm(def *schemaDefinition, term string) error {
+	if term == "nil" {
+		return nil
+	}
+
+	relation, target, isArrow := strings.Cut(term, "->")
+	if !isArrow {
+		if !def.hasMember(term) {
+			return fmt.Errorf("unknown relation or permission %s", term)
+		}
+
+		return nil
+	}
+
+	expr, ok := def.relations[relation]
+	if !ok {
+		return fmt.Errorf("arrow %s uses unknown relation %s", term, relation)
+	}
+
+	for _, subjectType := range splitSubjectTypes(expr) {
+		typeName, _, _ := strings.Cut(subjectType, " with ")
+		typeName = strings.TrimSuffix(strings.TrimSpace(typeName), ":*")
+		typeName, _, _ = strings.Cut(typeName, "#")
+
+		if subjectDef, ok := s.definitions[typeName]; ok && subjectDef.hasMember(target) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("arrow %s: no type allowed on relation %s has %s", term, relation, target)
+}
+
+// permissionTerms splits a permission expression into its relation, permission and arrow terms.
+func permissionTerms(expr string) []string {
+	fields := strings.FieldsFunc(expr, func(r rune) bool {
+		switch r {
+		case '+', '-', '&', '(', ')', ' ':
+			return true
+		}
+
+		return false
+	})
+
+	// FieldsFunc splits arrows on '-', so rejoin them.
+	var terms []string
+
+	for i := 0; i < len(fields); i++ {
+		if i+1 < len(fields) && strings.HasPrefix(fields[i+1], ">") {
+			terms = append(terms, fields[i]+"->"+strings.TrimPrefix(fields[i+1], ">"))
+			i++
+
+			continue
+		}
+
+		terms = append(terms, fields[i])
+	}
+
+	return terms
+}
@@ -0,0 +1,143 @@
+package spicedbx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSchema(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name    string
+		schema  string
+		checkFn func(*testing.T, error)
+	}
+
+	testCases := []testCase{
+		{
+			name:   "GeneratedSchema",
+			schema: GeneratedSchema("foo"),
+			checkFn: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "SyntaxError",
+			schema: "definition foo/user {\n}\ndefinition foo/tenant {\n    relation parent foo/tenant\n}\n",
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+				assert.ErrorContains(t, err, "line 4")
+			},
+		},
+		{
+			name:   "Unterminated",
+			schema: "definition foo/user {\n",
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+			},
+		},
+		{
+			name: "Redeclared",
+			schema: `definition foo/tenant {
+    relation parent: foo/tenant
+    permission parent = parent
+}
+`,
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+				assert.ErrorContains(t, err, "line 3: parent redeclared, first declared on line 2")
+			},
+		},
+		{
+			name: "UnknownReferences",
+			schema: `definition foo/user {
+}
+definition foo/tenant {
+    relation parent: foo/tenant
+    relation member: foo/user | foo/group#member | foo/user with foo/ip_allowlist
+    relation viewer: foo/user#member
+    permission view = viewer + member + editor
+    permission parent_view = parent->view + member->view + owner->view
+}
+`,
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+				assert.ErrorContains(t, err, "line 5: relation foo/tenant#member: unknown type foo/group")
+				assert.ErrorContains(t, err, "line 5: relation foo/tenant#member: unknown caveat foo/ip_allowlist")
+				assert.ErrorContains(t, err, "line 6: relation foo/tenant#viewer: type foo/user has no relation or permission member")
+				assert.ErrorContains(t, err, "line 7: permission foo/tenant#view: unknown relation or permission editor")
+				assert.ErrorContains(t, err, "line 8: permission foo/tenant#parent_view: arrow member->view: no type allowed on relation member has view")
+				assert.ErrorContains(t, err, "line 8: permission foo/tenant#parent_view: arrow owner->view uses unknown relation owner")
+				assert.NotContains(t, err.Error(), "parent->view")
+			},
+		},
+		{
+			name: "Success",
+			schema: `caveat foo/ip_allowlist(allowed list<ipaddress>, ip ipaddress) {
+    ip in allowed
+}
+definition foo/user {
+}
+definition foo/group {
+    relation member: foo/user | foo/user:* | foo/group#member
+}
+definition foo/tenant {
+    relation parent: foo/tenant
+    relation member: foo/user with foo/ip_allowlist | foo/group#member
+    relation banned: foo/user
+    permission view = (member - banned) + parent->view
+    permission nothing = nil
+}
+`,
+			checkFn: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "Comments",
+			schema: `/** user is a person */
+definition foo/user {}
+
+/**
+ * tenant holds resources, such as { braces } in comments.
+ */
+definition foo/tenant {
+	/* the parent tenant */ relation parent: foo/tenant
+	relation member: foo/user // direct members
+	permission view = member + parent->view
+}
+`,
+			checkFn: func(t *testing.T, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name: "UnterminatedComment",
+			schema: `/** user is a person
+definition foo/user {}
+`,
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+			},
+		},
+		{
+			name:   "OneLineDefinitionBody",
+			schema: "definition foo/user { relation self: foo/user }\n",
+			checkFn: func(t *testing.T, err error) {
+				assert.ErrorIs(t, err, ErrorInvalidSchema)
+				assert.ErrorContains(t, err, "line 1")
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.checkFn(t, CheckSchema(tc.schema))
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package spicedbx
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decimalRevision matches the revisions of datastores whose revisions are ordered numerically,
+// which are integers, or for CockroachDB hybrid logical clock timestamps, decimals.
+var decimalRevision = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// Field numbers of SpiceDB's DecodedZedToken message, which a zedtoken is the base64 encoding of.
+const (
+	zedTokenV1ZookieField protowire.Number = 2
+	zedTokenV1Field       protowire.Number = 3
+	zedTokenRevisionField protowire.Number = 1
+)
+
+// ZedToken is a SpiceDB consistency token along with the datastore revision it was issued at.
+// Query tokens returned by the engine are zedtokens in their string form.
+type ZedToken struct {
+	token    string
+	revision string
+}
+
+// ParseZedToken decodes the given zedtoken. The revision's format depends on the datastore.
+func ParseZedToken(token string) (ZedToken, error) {
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return ZedToken{}, fmt.Errorf("%w: %s", ErrorInvalidZedToken, err)
+	}
+
+	revision, err := decodeZedTokenRevision(raw)
+	if err != nil {
+		return ZedToken{}, err
+	}
+
+	return ZedToken{
+		token:    token,
+		revision: revision,
+	}, nil
+}
+
+// String returns the zedtoken as given to ParseZedToken, which may be used as a query token.
+func (t ZedToken) String() string {
+	return t.token
+}
+
+// Revision returns the datastore revision the zedtoken was issued at.
+func (t ZedToken) Revision() string {
+	return t.revision
+}
+
+// Compare returns -1, 0 or 1 if the zedtoken's revision is respectively older than, the same as or
+// newer than the other's. Numeric revisions are compared by value, whether or not they have a fractional
+// part. Revisions which are not numeric, such as those of Postgres, return ErrorIncomparableZedTokens.
+func (t ZedToken) Compare(other ZedToken) (int, error) {
+	a, err := parseDecimalRevision(t.revision)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := parseDecimalRevision(other.revision)
+	if err != nil {
+		return 0, err
+	}
+
+	return a.Cmp(b), nil
+}
+
+// CompareTokens returns -1, 0 or 1 if the revision of query token a is respectively older than, the
+// same as or newer than that of query token b. See ZedToken.Compare for which tokens are comparable.
+func CompareTokens(a, b string) (int, error) {
+	aToken, err := ParseZedToken(a)
+	if err != nil {
+		return 0, err
+	}
+
+	bToken, err := ParseZedToken(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return aToken.Compare(bToken)
+}
+
+// parseDecimalRevision parses a numeric revision.
+func parseDecimalRevision(revision string) (*big.Rat, error) {
+	if !decimalRevision.MatchString(revision) {
+		return nil, fmt.Errorf("%w: revision %s is not numeric", ErrorIncomparableZedTokens, revision)
+	}
+
+	value, ok := new(big.Rat).SetString(revision)
+	if !ok {
+		return nil, fmt.Errorf("%w: revision %s is not numeric", ErrorIncomparableZedTokens, revision)
+	}
+
+	return value, nil
+}
+
+// decodeZedTokenRevision returns the revision from an encoded DecodedZedToken message.
+func decodeZedTokenRevision(raw []byte) (string, error) {
+	var revision string
+
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidZedToken, protowire.ParseError(n))
+		}
+
+		raw = raw[n:]
+
+		if typ == protowire.BytesType && (num == zedTokenV1Field || num == zedTokenV1ZookieField) {
+			msg, n := protowire.ConsumeBytes(raw)
+			if n < 0 {
+				return "", fmt.Errorf("%w: %s", ErrorInvalidZedToken, protowire.ParseError(n))
+			}
+
+			raw = raw[n:]
+
+			rev, err := decodeRevision(msg, num == zedTokenV1ZookieField)
+			if err != nil {
+				return "", err
+			}
+
+			revision = rev
+
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, raw)
+		if n < 0 {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidZedToken, protowire.ParseError(n))
+		}
+
+		raw = raw[n:]
+	}
+
+	if revision == "" {
+		return "", fmt.Errorf("%w: no revision", ErrorInvalidZedToken)
+	}
+
+	return revision, nil
+}
+
+// decodeRevision returns the revision from an encoded V1ZedToken message, or from the deprecated
+// V1Zookie message, which held the revision as an integer.
+func decodeRevision(msg []byte, zookie bool) (string, error) {
+	var revision string
+
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidZedToken, protowire.ParseError(n))
+		}
+
+		msg = msg[n:]
+
+		switch {
+		case num == zedTokenRevisionField && !zookie && typ == protowire.BytesType:
+			revision, n = protowire.ConsumeString(msg)
+		case num == zedTokenRevisionField && zookie && typ == protowire.VarintType:
+			var v uint64
+
+			v, n = protowire.ConsumeVarint(msg)
+			revision = strconv.FormatUint(v, 10)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+
+		if n < 0 {
+			return "", fmt.Errorf("%w: %s", ErrorInvalidZedToken, protowire.ParseError(n))
+		}
+
+		msg = msg[n:]
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,168 @@
+package spicedbx
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseZedToken(t *testing.T) {
+	t.Parallel()
+
+	zookie := protowire.AppendTag(nil, zedTokenRevisionField, protowire.VarintType)
+	zookie = protowire.AppendVarint(zookie, 42)
+
+	zookieToken := protowire.AppendTag(nil, zedTokenV1ZookieField, protowire.BytesType)
+	zookieToken = protowire.AppendBytes(zookieToken, zookie)
+
+	testCases := []struct {
+		name     string
+		token    string
+		revision string
+		err      error
+	}{
+		{
+			name:     "V1",
+			token:    "GhUKEzE2NzE1NTM2NTcwMDAwMDAwMDA=",
+			revision: "1671553657000000000",
+		},
+		{
+			name:     "Zookie",
+			token:    base64.StdEncoding.EncodeToString(zookieToken),
+			revision: "42",
+		},
+		{
+			name:  "NotBase64",
+			token: "not a token!",
+			err:   ErrorInvalidZedToken,
+		},
+		{
+			name:  "NoRevision",
+			token: "",
+			err:   ErrorInvalidZedToken,
+		},
+		{
+			name:  "Truncated",
+			token: base64.StdEncoding.EncodeToString([]byte{0x1a, 0x15, 0x0a}),
+			err:   ErrorInvalidZedToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := ParseZedToken(tc.token)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.revision, token.Revision())
+			assert.Equal(t, tc.token, token.String())
+		})
+	}
+}
+
+// encodeZedToken encodes a V1 zedtoken for the given revision.
+func encodeZedToken(revision string) string {
+	msg := protowire.AppendTag(nil, zedTokenRevisionField, protowire.BytesType)
+	msg = protowire.AppendString(msg, revision)
+
+	raw := protowire.AppendTag(nil, zedTokenV1Field, protowire.BytesType)
+	raw = protowire.AppendBytes(raw, msg)
+
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestCompareTokens(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		expected int
+		err      error
+	}{
+		{
+			name:     "Older",
+			a:        encodeZedToken("999"),
+			b:        encodeZedToken("1000"),
+			expected: -1,
+		},
+		{
+			name:     "Same",
+			a:        encodeZedToken("1671553657000000000"),
+			b:        "GhUKEzE2NzE1NTM2NTcwMDAwMDAwMDA=",
+			expected: 0,
+		},
+		{
+			name:     "Newer",
+			a:        encodeZedToken("1671553657000000001"),
+			b:        encodeZedToken("1671553657000000000"),
+			expected: 1,
+		},
+		{
+			name:     "HybridLogicalClock",
+			a:        encodeZedToken("1671553657000000000.0000000002"),
+			b:        encodeZedToken("1671553657000000000.0000000010"),
+			expected: -1,
+		},
+		{
+			name:     "FractionInOne",
+			a:        encodeZedToken("1671553657000000000"),
+			b:        encodeZedToken("1671553657000000000.0000000001"),
+			expected: -1,
+		},
+		{
+			name:     "ZeroFraction",
+			a:        encodeZedToken("1671553657000000000.0000000000"),
+			b:        encodeZedToken("1671553657000000000"),
+			expected: 0,
+		},
+		{
+			name: "NotNumeric",
+			a:    encodeZedToken("CAESAggB"),
+			b:    encodeZedToken("CAESAggC"),
+			err:  ErrorIncomparableZedTokens,
+		},
+		{
+			name: "Invalid",
+			a:    "not a token!",
+			b:    encodeZedToken("1"),
+			err:  ErrorInvalidZedToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := CompareTokens(tc.a, tc.b)
+
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+
+			reversed, err := CompareTokens(tc.b, tc.a)
+			require.NoError(t, err)
+			assert.Equal(t, -tc.expected, reversed)
+		})
+	}
+}
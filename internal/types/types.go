@@ -2,23 +2,70 @@
 package types
 
 import (
+	"time"
+
 	"go.infratographer.com/x/gidx"
 )
 
+// RoleResourceType is the name of the resource type roles are stored as.
+const RoleResourceType = "role"
+
 // Role is a collection of permissions.
+// Subjects of a role inherit the actions of the role's parents.
 type Role struct {
-	ID      gidx.PrefixedID
-	Actions []string
+	ID          gidx.PrefixedID
+	Name        string
+	Description string
+	Actions     []string
+	Parents     []gidx.PrefixedID
+	// Owner is the resource the role is defined on, which scopes the role's actions.
+	Owner Resource
+	// DeletedAt is when the role was deleted, if it was retained when deleted.
+	// It is zero for roles which have not been deleted.
+	DeletedAt time.Time
+}
+
+// Resource returns the role as a resource, as used to assign subjects to the role or to refer to
+// it in relationships. Its type is always RoleResourceType, which is the type the engine resolves
+// role IDs to; the engine refuses to remap the role ID prefix to keep the two in agreement.
+func (r Role) Resource() Resource {
+	return Resource{
+		Type: RoleResourceType,
+		ID:   r.ID,
+	}
+}
+
+// IsDeleted reports whether the role has been deleted and is only retained for its history.
+func (r Role) IsDeleted() bool {
+	return !r.DeletedAt.IsZero()
 }
 
 // ResourceTypeRelationship is a relationship for a resource type.
+// If Wildcard is set, the relationship may have a wildcard subject.
 type ResourceTypeRelationship struct {
 	Relation string
 	Types    []string
+	Caveat   string
+	Wildcard bool
+}
+
+// Caveat is a named condition evaluated against context provided when checking permissions.
+type Caveat struct {
+	Name       string
+	Parameters []CaveatParameter
+	Expression string
+}
+
+// CaveatParameter is a typed parameter of a caveat.
+type CaveatParameter struct {
+	Name string
+	Type string
 }
 
 // ConditionRoleBinding represents a condition where a role binding is necessary to perform an action.
-type ConditionRoleBinding struct{}
+type ConditionRoleBinding struct {
+	Caveat string
+}
 
 // ConditionRelationshipAction represents a condition where an action must be able to be performed
 // on another resource along a relation to perform an action.
@@ -34,9 +81,11 @@ type Condition struct {
 }
 
 // Action represents a named thing a subject can do.
+// Subjects of any of the ExcludedRelations may not do it, even if a condition allows them to.
 type Action struct {
-	Name       string
-	Conditions []Condition
+	Name              string
+	Conditions        []Condition
+	ExcludedRelations []string
 }
 
 // ResourceType defines a type of resource managed by the api
@@ -47,15 +96,52 @@ type ResourceType struct {
 	Actions       []Action
 }
 
+// WildcardID is the ID of a wildcard resource, which stands for every resource of its type.
+const WildcardID gidx.PrefixedID = "*"
+
 // Resource is the object to be acted upon by an subject
 type Resource struct {
 	Type string
 	ID   gidx.PrefixedID
 }
 
+// WildcardResource returns a resource representing every resource of the given type.
+// A wildcard resource may only be used as the subject of a relationship.
+func WildcardResource(typeName string) Resource {
+	return Resource{
+		Type: typeName,
+		ID:   WildcardID,
+	}
+}
+
+// IsWildcard reports whether the resource represents every resource of its type.
+func (r Resource) IsWildcard() bool {
+	return r.ID == WildcardID
+}
+
 // Relationship represents a named association between a resource and a subject.
 type Relationship struct {
 	Resource Resource
 	Relation string
 	Subject  Resource
+	// SubjectRelation optionally makes the subject a subject set, the subjects with the given
+	// relation on Subject, e.g. the members of a group.
+	SubjectRelation string
+	// Metadata optionally holds attributes recorded with the relationship, e.g. who created it.
+	// It is stored as the context of the caveat declared for the relation, so each key must be
+	// a string parameter of that caveat. The caveat's expression is evaluated with this context
+	// whenever the relationship is checked, so a relationship with metadata only grants access
+	// when the expression holds; relations meant to carry metadata alone should declare a caveat
+	// whose expression is always true.
+	Metadata map[string]string
+	// Caveat is the caveat the relationship is conditioned on, as read from SpiceDB, such as the expiry
+	// of a role assignment. It is nil for relationships without a caveat. It is ignored when writing,
+	// except by an import, which writes it in place of Metadata so exported relationships are restored.
+	Caveat *RelationshipCaveat
+}
+
+// RelationshipCaveat is a caveat a relationship is conditioned on, along with the context it was written with.
+type RelationshipCaveat struct {
+	Name    string
+	Context map[string]any
 }